@@ -6,6 +6,7 @@ import (
 	"os"
 	"regexp"
 	"sync"
+	"time"
 
 	"github.com/skia-dev/glog"
 
@@ -72,10 +73,25 @@ func (b *Blacklist) Match(builder, commit string) bool {
 // Rules in the Blacklist. Returns the name of the matched Rule or the empty
 // string if no Rules match.
 func (b *Blacklist) MatchRule(builder, commit string) string {
+	return b.MatchTaskRule(builder, nil, commit, time.Now())
+}
+
+// MatchTask determines whether a task with the given name and Swarming
+// dimensions at the given commit matches one of the Rules in the Blacklist
+// at the given time.
+func (b *Blacklist) MatchTask(name string, dimensions []string, commit string, now time.Time) bool {
+	return b.MatchTaskRule(name, dimensions, commit, now) != ""
+}
+
+// MatchTaskRule determines whether a task with the given name and Swarming
+// dimensions at the given commit matches one of the Rules in the Blacklist
+// at the given time. Returns the name of the matched Rule or the empty string
+// if no Rules match.
+func (b *Blacklist) MatchTaskRule(name string, dimensions []string, commit string, now time.Time) string {
 	b.mtx.RLock()
 	defer b.mtx.RUnlock()
 	for _, rule := range b.Rules {
-		if rule.Match(builder, commit) {
+		if rule.MatchTask(name, dimensions, commit, now) {
 			return rule.Name
 		}
 	}
@@ -237,13 +253,26 @@ func (b *Blacklist) RemoveRule(name string) error {
 // Commits are simply commit hashes for which the rule applies. If the list is
 // empty, the Rule applies for all commits.
 //
-// A Rule should specify BuilderPatterns or Commits or both.
+// DimensionPatterns consists of regular expressions used to match the
+// Swarming dimensions of tasks, in "key:value" form. The Rule applies to a
+// task if any pattern matches any of its dimensions. If the list is empty, the
+// Rule applies regardless of dimensions. Builds have no dimensions, so a Rule
+// which specifies DimensionPatterns never applies to builds.
+//
+// Start and End give the time window during which the Rule is in effect. A
+// zero Start or End leaves the window open on that side.
+//
+// A Rule should specify BuilderPatterns, Commits, or DimensionPatterns, or a
+// combination of them.
 type Rule struct {
-	AddedBy         string   `json:"added_by"`
-	BuilderPatterns []string `json:"builder_patterns"`
-	Commits         []string `json:"commits"`
-	Description     string   `json:"description"`
-	Name            string   `json:"name"`
+	AddedBy           string    `json:"added_by"`
+	BuilderPatterns   []string  `json:"builder_patterns"`
+	Commits           []string  `json:"commits"`
+	Description       string    `json:"description"`
+	DimensionPatterns []string  `json:"dimension_patterns"`
+	End               time.Time `json:"end"`
+	Name              string    `json:"name"`
+	Start             time.Time `json:"start"`
 }
 
 // ValidateRule returns an error if the given Rule is not valid.
@@ -257,8 +286,16 @@ func ValidateRule(r *Rule, repos *gitinfo.RepoMap) error {
 	if r.AddedBy == "" {
 		return fmt.Errorf("Rules must have an AddedBy user.")
 	}
-	if len(r.BuilderPatterns) == 0 && len(r.Commits) == 0 {
-		return fmt.Errorf("Rules must include a builder pattern and/or a commit/range.")
+	if len(r.BuilderPatterns) == 0 && len(r.Commits) == 0 && len(r.DimensionPatterns) == 0 {
+		return fmt.Errorf("Rules must include a builder pattern, dimension pattern, and/or a commit/range.")
+	}
+	for _, p := range r.DimensionPatterns {
+		if _, err := regexp.Compile(p); err != nil {
+			return fmt.Errorf("Invalid dimension pattern %q: %s", p, err)
+		}
+	}
+	if !util.TimeIsZero(r.Start) && !util.TimeIsZero(r.End) && !r.End.After(r.Start) {
+		return fmt.Errorf("Rule end time must be after start time.")
 	}
 	for _, c := range r.Commits {
 		if err := validateCommit(c, repos); err != nil {
@@ -304,9 +341,49 @@ func (r *Rule) matchCommit(commit string) bool {
 	return false
 }
 
+// matchDimensions determines whether the dimensions portion of the Rule
+// matches.
+func (r *Rule) matchDimensions(dimensions []string) bool {
+	// If no dimensions are specified, then the rule applies for ALL tasks.
+	if len(r.DimensionPatterns) == 0 {
+		return true
+	}
+	// If any pattern matches any dimension, then the rule applies.
+	for _, p := range r.DimensionPatterns {
+		for _, d := range dimensions {
+			match, err := regexp.MatchString(p, d)
+			if err != nil {
+				glog.Warningf("Rule regexp returned error for input %q: %s: %s", d, p, err)
+				return false
+			}
+			if match {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// matchTime determines whether the Rule is in effect at the given time.
+func (r *Rule) matchTime(now time.Time) bool {
+	if !util.TimeIsZero(r.Start) && now.Before(r.Start) {
+		return false
+	}
+	if !util.TimeIsZero(r.End) && !now.Before(r.End) {
+		return false
+	}
+	return true
+}
+
 // Match returns true iff the Rule matches the given builder and commit.
 func (r *Rule) Match(builder, commit string) bool {
-	return r.matchBuilder(builder) && r.matchCommit(commit)
+	return r.MatchTask(builder, nil, commit, time.Now())
+}
+
+// MatchTask returns true iff the Rule matches a task with the given name and
+// Swarming dimensions at the given commit, at the given time.
+func (r *Rule) MatchTask(name string, dimensions []string, commit string, now time.Time) bool {
+	return r.matchTime(now) && r.matchBuilder(name) && r.matchDimensions(dimensions) && r.matchCommit(commit)
 }
 
 // FromFile returns a Blacklist instance based on the given file. If the file
@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"path"
 	"testing"
+	"time"
 
 	"go.skia.org/infra/go/gitinfo"
 	"go.skia.org/infra/go/testutils"
@@ -157,6 +158,146 @@ func TestRules(t *testing.T) {
 	}
 }
 
+func TestTaskRules(t *testing.T) {
+	start := time.Unix(1471449600, 0)
+	end := start.Add(24 * time.Hour)
+	android := []string{"pool:Skia", "os:Android", "device_type:grouper"}
+	linux := []string{"pool:Skia", "os:Ubuntu"}
+	type testCase struct {
+		name        string
+		dimensions  []string
+		commit      string
+		now         time.Time
+		expectMatch bool
+		msg         string
+	}
+	tests := []struct {
+		rule  Rule
+		cases []testCase
+	}{
+		{
+			rule: Rule{
+				AddedBy:           "test@google.com",
+				Name:              "Android devices",
+				DimensionPatterns: []string{"^os:Android$"},
+			},
+			cases: []testCase{
+				{
+					name:        "Test-Android",
+					dimensions:  android,
+					commit:      "abc123",
+					now:         start,
+					expectMatch: true,
+					msg:         "Matching dimension",
+				},
+				{
+					name:        "Test-Ubuntu",
+					dimensions:  linux,
+					commit:      "abc123",
+					now:         start,
+					expectMatch: false,
+					msg:         "No matching dimension",
+				},
+				{
+					name:        "Test-Android",
+					dimensions:  nil,
+					commit:      "abc123",
+					now:         start,
+					expectMatch: false,
+					msg:         "No dimensions",
+				},
+			},
+		},
+		{
+			rule: Rule{
+				AddedBy:         "test@google.com",
+				Name:            "Pause Perf",
+				BuilderPatterns: []string{"^Perf-"},
+				End:             end,
+				Start:           start,
+			},
+			cases: []testCase{
+				{
+					name:        "Perf-Android",
+					dimensions:  android,
+					commit:      "abc123",
+					now:         start,
+					expectMatch: true,
+					msg:         "Start of window",
+				},
+				{
+					name:        "Perf-Android",
+					dimensions:  android,
+					commit:      "abc123",
+					now:         start.Add(-time.Second),
+					expectMatch: false,
+					msg:         "Before window",
+				},
+				{
+					name:        "Perf-Android",
+					dimensions:  android,
+					commit:      "abc123",
+					now:         end,
+					expectMatch: false,
+					msg:         "End of window",
+				},
+				{
+					name:        "Test-Android",
+					dimensions:  android,
+					commit:      "abc123",
+					now:         start,
+					expectMatch: false,
+					msg:         "Name does not match",
+				},
+			},
+		},
+		{
+			rule: Rule{
+				AddedBy:           "test@google.com",
+				Name:              "Bad Ubuntu commit",
+				Commits:           []string{"abc123"},
+				DimensionPatterns: []string{"^os:Ubuntu$"},
+				Start:             start,
+			},
+			cases: []testCase{
+				{
+					name:        "Build-Ubuntu",
+					dimensions:  linux,
+					commit:      "abc123",
+					now:         end,
+					expectMatch: true,
+					msg:         "Open-ended window",
+				},
+				{
+					name:        "Build-Ubuntu",
+					dimensions:  linux,
+					commit:      "def456",
+					now:         end,
+					expectMatch: false,
+					msg:         "Commit does not match",
+				},
+				{
+					name:        "Build-Ubuntu",
+					dimensions:  linux,
+					commit:      "abc123",
+					now:         start.Add(-time.Second),
+					expectMatch: false,
+					msg:         "Before window",
+				},
+			},
+		},
+	}
+	for _, test := range tests {
+		for _, c := range test.cases {
+			assert.Equal(t, c.expectMatch, test.rule.MatchTask(c.name, c.dimensions, c.commit, c.now), c.msg)
+		}
+	}
+
+	// Rules with dimension patterns don't apply to builds.
+	r := tests[0].rule
+	assert.False(t, r.Match("Test-Android", "abc123"))
+}
+
 func TestValidation(t *testing.T) {
 	// Setup.
 	tr := util.NewTempRepo()
@@ -222,9 +363,38 @@ func TestValidation(t *testing.T) {
 				BuilderPatterns: []string{},
 				Commits:         []string{},
 			},
-			expect: fmt.Errorf("Rules must include a builder pattern and/or a commit/range."),
+			expect: fmt.Errorf("Rules must include a builder pattern, dimension pattern, and/or a commit/range."),
 			msg:    "No builders or commits",
 		},
+		{
+			rule: Rule{
+				AddedBy:           "test@google.com",
+				Name:              "My rule",
+				DimensionPatterns: []string{"^os:Android$"},
+			},
+			expect: nil,
+			msg:    "One dimension pattern",
+		},
+		{
+			rule: Rule{
+				AddedBy:           "test@google.com",
+				Name:              "My rule",
+				DimensionPatterns: []string{"os:(Android"},
+			},
+			expect: fmt.Errorf("Invalid dimension pattern %q: %s", "os:(Android", "error parsing regexp: missing closing ): `os:(Android`"),
+			msg:    "Invalid dimension pattern",
+		},
+		{
+			rule: Rule{
+				AddedBy:         "test@google.com",
+				Name:            "My rule",
+				BuilderPatterns: []string{".*"},
+				End:             time.Unix(1471449600, 0),
+				Start:           time.Unix(1471449600, 0),
+			},
+			expect: fmt.Errorf("Rule end time must be after start time."),
+			msg:    "Empty time window",
+		},
 		{
 			rule: Rule{
 				AddedBy:         "test@google.com",
@@ -0,0 +1,76 @@
+package blacklist
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/login"
+	"go.skia.org/infra/go/util"
+)
+
+// JsonHandler returns an HTTP handler which adds or removes Rules from the
+// Blacklist. POST requests add the Rule given in the request body, recording
+// the logged-in user in Rule.AddedBy. If the Rule's Commits contain exactly two
+// commits, they are treated as a commit range. DELETE requests remove the Rule
+// whose name is given in the request body. All requests return the Blacklist.
+// Modifying the Blacklist requires a logged-in Googler.
+func (b *Blacklist) JsonHandler(repos *gitinfo.RepoMap) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodGet && !login.IsGoogler(r) {
+			errStr := "Cannot modify the blacklist; user is not a logged-in Googler."
+			httputils.ReportError(w, r, fmt.Errorf(errStr), errStr)
+			return
+		}
+
+		if r.Method == http.MethodDelete {
+			var msg struct {
+				Name string `json:"name"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
+				httputils.ReportError(w, r, err, fmt.Sprintf("Failed to decode request body: %s", err))
+				return
+			}
+			defer util.Close(r.Body)
+			if err := b.RemoveRule(msg.Name); err != nil {
+				httputils.ReportError(w, r, err, fmt.Sprintf("Failed to delete blacklist rule: %s", err))
+				return
+			}
+			glog.Infof("Blacklist rule %q removed by %s", msg.Name, login.LoggedInAs(r))
+		} else if r.Method == http.MethodPost {
+			var rule Rule
+			if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
+				httputils.ReportError(w, r, err, fmt.Sprintf("Failed to decode request body: %s", err))
+				return
+			}
+			defer util.Close(r.Body)
+			rule.AddedBy = login.LoggedInAs(r)
+			if len(rule.Commits) == 2 {
+				rangeRule, err := NewCommitRangeRule(rule.Name, rule.AddedBy, rule.Description, rule.BuilderPatterns, rule.Commits[0], rule.Commits[1], repos)
+				if err != nil {
+					httputils.ReportError(w, r, err, fmt.Sprintf("Failed to create commit range rule: %s", err))
+					return
+				}
+				rangeRule.DimensionPatterns = rule.DimensionPatterns
+				rangeRule.End = rule.End
+				rangeRule.Start = rule.Start
+				rule = *rangeRule
+			}
+			if err := b.AddRule(&rule, repos); err != nil {
+				httputils.ReportError(w, r, err, fmt.Sprintf("Failed to add blacklist rule: %s", err))
+				return
+			}
+			glog.Infof("Blacklist rule %q added by %s", rule.Name, rule.AddedBy)
+		}
+		b.mtx.RLock()
+		defer b.mtx.RUnlock()
+		if err := json.NewEncoder(w).Encode(b); err != nil {
+			httputils.ReportError(w, r, err, fmt.Sprintf("Failed to encode response: %s", err))
+			return
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package blacklist
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/login"
+	"go.skia.org/infra/go/testutils"
+)
+
+func TestJsonHandler(t *testing.T) {
+	// Setup.
+	login.Init("id", "secret", "http://localhost", "salt", login.DEFAULT_SCOPE, login.DEFAULT_DOMAIN_WHITELIST, false)
+	tmp, err := ioutil.TempDir("", "")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, tmp)
+	repos := gitinfo.NewRepoMap(tmp)
+	b, err := FromFile(path.Join(tmp, "blacklist.json"))
+	assert.NoError(t, err)
+	handler := b.JsonHandler(repos)
+
+	// do sends a request to the handler as the given user, or as a
+	// logged-out user if email is empty, and returns the response.
+	do := func(method, email string, body interface{}) *httptest.ResponseRecorder {
+		buf := bytes.NewBuffer(nil)
+		if body != nil {
+			assert.NoError(t, json.NewEncoder(buf).Encode(body))
+		}
+		r, err := http.NewRequest(method, "/json/blacklist", buf)
+		assert.NoError(t, err)
+		if email != "" {
+			cookie, err := login.CookieFor(&login.Session{
+				Email:     email,
+				AuthScope: login.DEFAULT_SCOPE[0],
+			})
+			assert.NoError(t, err)
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		handler(w, r)
+		return w
+	}
+
+	// decode returns the Rules from the Blacklist in the response body.
+	decode := func(w *httptest.ResponseRecorder) map[string]*Rule {
+		assert.Equal(t, http.StatusOK, w.Code)
+		var rv Blacklist
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(&rv))
+		return rv.Rules
+	}
+
+	// Test.
+
+	// Anyone may view the Blacklist.
+	rules := decode(do(http.MethodGet, "", nil))
+	assert.Equal(t, len(DEFAULT_RULES), len(rules))
+
+	rule := &Rule{
+		BuilderPatterns: []string{"^Build-"},
+		Description:     "Don't run Build tasks.",
+		Name:            "No Builds",
+	}
+
+	// Only logged-in Googlers may modify the Blacklist.
+	w := do(http.MethodPost, "", rule)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	w = do(http.MethodPost, "someone@example.com", rule)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Nil(t, b.Rules[rule.Name])
+
+	// Add the Rule. AddedBy is set to the logged-in user.
+	rules = decode(do(http.MethodPost, "test@google.com", rule))
+	assert.Equal(t, len(DEFAULT_RULES)+1, len(rules))
+	assert.NotNil(t, rules[rule.Name])
+	assert.Equal(t, "test@google.com", rules[rule.Name].AddedBy)
+	assert.Equal(t, "test@google.com", b.Rules[rule.Name].AddedBy)
+	assert.True(t, b.Match("Build-Ubuntu-GCC-x86_64-Release", ""))
+
+	// Invalid Rules and duplicate names are rejected.
+	w = do(http.MethodPost, "test@google.com", &Rule{Name: "Empty"})
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	w = do(http.MethodPost, "test@google.com", rule)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Delete the Rule.
+	msg := map[string]string{"name": rule.Name}
+	w = do(http.MethodDelete, "", msg)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.NotNil(t, b.Rules[rule.Name])
+	rules = decode(do(http.MethodDelete, "test@google.com", msg))
+	assert.Equal(t, len(DEFAULT_RULES), len(rules))
+	assert.Nil(t, rules[rule.Name])
+	assert.False(t, b.Match("Build-Ubuntu-GCC-x86_64-Release", ""))
+
+	// Deleting a nonexistent Rule fails.
+	w = do(http.MethodDelete, "test@google.com", msg)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
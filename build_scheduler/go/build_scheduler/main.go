@@ -164,54 +164,6 @@ func triggerHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func jsonBlacklistHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	if !login.IsGoogler(r) {
-		errStr := "Cannot modify the blacklist; user is not a logged-in Googler."
-		httputils.ReportError(w, r, fmt.Errorf(errStr), errStr)
-		return
-	}
-
-	if r.Method == http.MethodDelete {
-		var msg struct {
-			Name string `json:"name"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&msg); err != nil {
-			httputils.ReportError(w, r, err, fmt.Sprintf("Failed to decode request body: %s", err))
-			return
-		}
-		defer util.Close(r.Body)
-		if err := bs.GetBlacklist().RemoveRule(msg.Name); err != nil {
-			httputils.ReportError(w, r, err, fmt.Sprintf("Failed to delete blacklist rule: %s", err))
-			return
-		}
-	} else if r.Method == http.MethodPost {
-		var rule blacklist.Rule
-		if err := json.NewDecoder(r.Body).Decode(&rule); err != nil {
-			httputils.ReportError(w, r, err, fmt.Sprintf("Failed to decode request body: %s", err))
-			return
-		}
-		defer util.Close(r.Body)
-		rule.AddedBy = login.LoggedInAs(r)
-		if len(rule.Commits) == 2 {
-			rangeRule, err := blacklist.NewCommitRangeRule(rule.Name, rule.AddedBy, rule.Description, rule.BuilderPatterns, rule.Commits[0], rule.Commits[1], repos)
-			if err != nil {
-				httputils.ReportError(w, r, err, fmt.Sprintf("Failed to create commit range rule: %s", err))
-				return
-			}
-			rule = *rangeRule
-		}
-		if err := bs.GetBlacklist().AddRule(&rule, repos); err != nil {
-			httputils.ReportError(w, r, err, fmt.Sprintf("Failed to add blacklist rule: %s", err))
-			return
-		}
-	}
-	if err := json.NewEncoder(w).Encode(bs.GetBlacklist()); err != nil {
-		httputils.ReportError(w, r, err, fmt.Sprintf("Failed to encode response: %s", err))
-		return
-	}
-}
-
 func jsonTriggerHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	if !login.IsGoogler(r) {
@@ -250,7 +202,7 @@ func runServer(serverURL string) {
 	r.HandleFunc("/", mainHandler)
 	r.HandleFunc("/blacklist", blacklistHandler)
 	r.HandleFunc("/trigger", triggerHandler)
-	r.HandleFunc("/json/blacklist", jsonBlacklistHandler).Methods(http.MethodPost, http.MethodDelete)
+	r.HandleFunc("/json/blacklist", bs.GetBlacklist().JsonHandler(repos)).Methods(http.MethodGet, http.MethodPost, http.MethodDelete)
 	r.HandleFunc("/json/trigger", jsonTriggerHandler).Methods(http.MethodPost)
 	r.HandleFunc("/json/version", skiaversion.JsonHandler)
 	r.PathPrefix("/res/").HandlerFunc(httputils.MakeResourceHandler(*resourcesDir))
@@ -14,6 +14,9 @@ type TaskCache struct {
 	queryId        string
 	tasks          map[string]*Task
 	tasksByCommit  map[string]map[string]*Task
+	// tasksByPatch contains try jobs, keyed by Task.PatchKey() and then by
+	// task name. Try jobs are not included in tasksByCommit.
+	tasksByPatch map[string]map[string]*Task
 }
 
 // GetTask returns the task with the given ID, or an error if no such task exists.
@@ -89,10 +92,60 @@ func (c *TaskCache) GetTaskForCommit(name, commit string) (*Task, error) {
 	return nil, nil
 }
 
+// GetTaskForPatch retrieves the most recent try job with the given name which
+// ran with the given patch, or nil if no such task exists.
+func (c *TaskCache) GetTaskForPatch(server, issue, patchset, name string) (*Task, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	if tasks, ok := c.tasksByPatch[MakePatchKey(server, issue, patchset)]; ok {
+		if t, ok := tasks[name]; ok {
+			return t.Copy(), nil
+		}
+	}
+	return nil, nil
+}
+
+// UnfinishedTasks returns a list of tasks which were not finished at the time
+// of the last cache update, including try jobs.
+func (c *TaskCache) UnfinishedTasks() ([]*Task, error) {
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+
+	rv := []*Task{}
+	for _, t := range c.tasks {
+		if !t.Done() {
+			rv = append(rv, t.Copy())
+		}
+	}
+	return rv, nil
+}
+
+// updateTryJob inserts the new/updated try job into the cache. Assumes the
+// caller holds a lock.
+func (c *TaskCache) updateTryJob(t *Task) {
+	c.tasks[t.Id] = t.Copy()
+	key := t.PatchKey()
+	if _, ok := c.tasksByPatch[key]; !ok {
+		c.tasksByPatch[key] = map[string]*Task{}
+	}
+	// Retries at the same patch replace earlier attempts.
+	if prev, ok := c.tasksByPatch[key][t.Name]; ok && prev.Id != t.Id && prev.Created.After(t.Created) {
+		return
+	}
+	c.tasksByPatch[key][t.Name] = c.tasks[t.Id]
+}
+
 // update inserts the new/updated tasks into the cache. Assumes the caller
 // holds a lock.
 func (c *TaskCache) update(tasks []*Task) error {
 	for _, t := range tasks {
+		// Try jobs are kept apart from the main-branch blamelists.
+		if t.IsTryJob() {
+			c.updateTryJob(t)
+			continue
+		}
+
 		// If we already know about this task, the blamelist might,
 		// have changed, so we need to remove it from tasksByCommit
 		// and re-insert where needed.
@@ -177,6 +230,7 @@ func NewTaskCache(db DB, timePeriod time.Duration) (*TaskCache, error) {
 		queryId:        queryId,
 		tasks:          map[string]*Task{},
 		tasksByCommit:  map[string]map[string]*Task{},
+		tasksByPatch:   map[string]map[string]*Task{},
 	}
 	if err := tc.update(tasks); err != nil {
 		return nil, err
@@ -61,4 +61,65 @@ func TestDBCache(t *testing.T) {
 			t3.Name: t3,
 		},
 	}, tasks)
+
+	// All tasks are unfinished until one of them completes.
+	unfinished, err := c.UnfinishedTasks()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(unfinished))
+	t3.Status = TASK_STATUS_SUCCESS
+	assert.NoError(t, db.PutTask(t3))
+	assert.NoError(t, c.Update())
+	unfinished, err = c.UnfinishedTasks()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(unfinished))
+	for _, u := range unfinished {
+		assert.NotEqual(t, t3.Id, u.Id)
+	}
+}
+
+func TestDBCacheTryJobs(t *testing.T) {
+	db := NewInMemoryDB()
+	defer testutils.AssertCloses(t, db)
+
+	c, err := NewTaskCache(db, time.Hour)
+	assert.NoError(t, err)
+
+	// Insert a try job. It should not show up for its commits.
+	startTime := time.Now().Add(-30 * time.Minute)
+	t1 := makeTask(startTime, []string{"a"})
+	t1.Revision = "a"
+	t1.Server = "https://codereview.chromium.org"
+	t1.Issue = "12345"
+	t1.Patchset = "1"
+	assert.NoError(t, db.PutTask(t1))
+	assert.NoError(t, c.Update())
+
+	found, err := c.GetTaskForCommit(t1.Name, "a")
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+	assert.False(t, c.KnownTaskName(t1.Name))
+
+	found, err = c.GetTaskForPatch(t1.Server, t1.Issue, t1.Patchset, t1.Name)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, t1, found)
+
+	// A different patchset has no tasks.
+	found, err = c.GetTaskForPatch(t1.Server, t1.Issue, "2", t1.Name)
+	assert.NoError(t, err)
+	assert.Nil(t, found)
+
+	// A retry replaces the original task, even if the original is updated
+	// afterward.
+	t2 := t1.Copy()
+	t2.Id = ""
+	t2.Created = startTime.Add(time.Minute)
+	t2.Attempt = 1
+	t2.RetryOf = t1.Id
+	assert.NoError(t, db.PutTask(t2))
+	t1.Status = TASK_STATUS_FAILURE
+	assert.NoError(t, db.PutTask(t1))
+	assert.NoError(t, c.Update())
+	found, err = c.GetTaskForPatch(t1.Server, t1.Issue, t1.Patchset, t1.Name)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, t2, found)
 }
@@ -26,14 +26,12 @@ func IsUnknownId(e error) bool {
 	return e != nil && e.Error() == ErrUnknownId.Error()
 }
 
-type DB interface {
+// TaskDB is the interface for storing and retrieving Tasks.
+type TaskDB interface {
 	// AssignId sets the given task's Id field. Does not insert the task into the
 	// database.
 	AssignId(*Task) error
 
-	// Close the [connection to the] DB.
-	Close() error
-
 	// GetModifiedTasks returns all builds modified since the last time
 	// GetModifiedTasks was run with the given id.
 	GetModifiedTasks(string) ([]*Task, error)
@@ -59,3 +57,41 @@ type DB interface {
 	// expires after a period of inactivity.
 	StartTrackingModifiedTasks() (string, error)
 }
+
+// JobDB is the interface for storing and retrieving Jobs.
+type JobDB interface {
+	// GetJobById returns the job with the given Id field. Returns nil, nil if
+	// job is not found.
+	GetJobById(string) (*Job, error)
+
+	// GetJobsFromDateRange retrieves all jobs which were created in the given
+	// date range.
+	GetJobsFromDateRange(time.Time, time.Time) ([]*Job, error)
+
+	// GetModifiedJobs returns all jobs modified since the last time
+	// GetModifiedJobs was run with the given id.
+	GetModifiedJobs(string) ([]*Job, error)
+
+	// PutJob inserts or updates the Job in the database. Assigns the Job's Id
+	// field if it is empty. Job's Created field must be set.
+	PutJob(*Job) error
+
+	// PutJobs inserts or updates the Jobs in the database. Assigns each Job's
+	// Id field if it is empty. Each Job's Created field must be set.
+	PutJobs([]*Job) error
+
+	// StartTrackingModifiedJobs initiates tracking of modified jobs for the
+	// current caller. Returns a unique ID which can be used by the caller to
+	// retrieve jobs which have been modified since the last query. The ID
+	// expires after a period of inactivity.
+	StartTrackingModifiedJobs() (string, error)
+}
+
+// DB stores Tasks and Jobs.
+type DB interface {
+	TaskDB
+	JobDB
+
+	// Close the [connection to the] DB.
+	Close() error
+}
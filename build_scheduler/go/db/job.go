@@ -0,0 +1,191 @@
+package db
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sort"
+	"time"
+
+	"github.com/skia-dev/glog"
+)
+
+// JobStatus represents the current status of a Job. A JobStatus other than
+// JOB_STATUS_IN_PROGRESS is final; we do not retry Jobs, only their component
+// Tasks.
+type JobStatus string
+
+const (
+	// JOB_STATUS_IN_PROGRESS indicates that one or more of the Job's Tasks
+	// has not yet finished. It is the empty string so that it is the zero
+	// value of JobStatus.
+	JOB_STATUS_IN_PROGRESS JobStatus = ""
+	// JOB_STATUS_SUCCESS indicates that all of the Job's Tasks finished
+	// successfully.
+	JOB_STATUS_SUCCESS = "SUCCESS"
+	// JOB_STATUS_FAILURE indicates that one or more of the Job's Tasks failed
+	// and will not be retried.
+	JOB_STATUS_FAILURE = "FAILURE"
+	// JOB_STATUS_MISHAP indicates that one or more of the Job's Tasks had a
+	// mishap and will not be retried.
+	JOB_STATUS_MISHAP = "MISHAP"
+)
+
+// TaskSummary is a subset of the information found in a Task, kept in a Job
+// so that the Job's status can be displayed without loading its Tasks.
+type TaskSummary struct {
+	// Attempt is the Attempt of the Task.
+	Attempt int
+
+	// Id is the Id of the Task.
+	Id string
+
+	// Status is the Status of the Task.
+	Status TaskStatus
+}
+
+// Job describes a set of TaskSpecs, including their dependencies, which were
+// requested to run at a given revision, possibly with a patch applied.
+type Job struct {
+	// Created is the creation timestamp.
+	Created time.Time
+
+	// Dependencies describes the DAG of TaskSpecs in the Job. Keys are the
+	// names of all TaskSpecs in the Job and values are the names of the
+	// TaskSpecs on which they depend.
+	Dependencies map[string][]string
+
+	// Finished is the time at which the Job's Status became final, or zero if
+	// the Job is still in progress.
+	Finished time.Time
+
+	// Id is a generated unique identifier for this Job instance. Must be
+	// URL-safe.
+	Id string
+
+	// Issue is the code review issue whose patch is applied for this Job, or
+	// empty if this Job is not a try job.
+	Issue string
+
+	// Name is a human-friendly descriptive name for this Job.
+	Name string
+
+	// Patchset is the patchset of Issue which is applied for this Job, or
+	// empty if this Job is not a try job.
+	Patchset string
+
+	// Repo is the repository of the commit at which this Job runs.
+	Repo string
+
+	// Revision is the commit at which this Job runs. For try jobs, this is the
+	// commit to which the patch is applied.
+	Revision string
+
+	// Server is the URL of the code review server which hosts Issue, or empty
+	// if this Job is not a try job.
+	Server string
+
+	// Status is the current Job status, default JOB_STATUS_IN_PROGRESS.
+	Status JobStatus
+
+	// Tasks are summaries of all Tasks which have run for this Job, keyed by
+	// TaskSpec name. Includes retries, in order by Attempt.
+	Tasks map[string][]*TaskSummary
+
+	// TriggerReason is a human-readable description of why this Job was
+	// created.
+	TriggerReason string
+}
+
+// Done returns true iff the Job's Status is final.
+func (j *Job) Done() bool {
+	return j.Status != JOB_STATUS_IN_PROGRESS
+}
+
+// IsTryJob returns true iff the Job runs with a patch applied.
+func (j *Job) IsTryJob() bool {
+	return j.Issue != ""
+}
+
+// UpdateFromTask adds or updates the summary of the given Task, which must
+// belong to this Job. Returns true iff the Job was modified.
+func (j *Job) UpdateFromTask(t *Task) bool {
+	if j.Tasks == nil {
+		j.Tasks = map[string][]*TaskSummary{}
+	}
+	summaries := j.Tasks[t.Name]
+	for _, s := range summaries {
+		if s.Id == t.Id {
+			if s.Attempt == t.Attempt && s.Status == t.Status {
+				return false
+			}
+			s.Attempt = t.Attempt
+			s.Status = t.Status
+			sort.Sort(taskSummarySlice(summaries))
+			return true
+		}
+	}
+	j.Tasks[t.Name] = append(summaries, &TaskSummary{
+		Attempt: t.Attempt,
+		Id:      t.Id,
+		Status:  t.Status,
+	})
+	sort.Sort(taskSummarySlice(j.Tasks[t.Name]))
+	return true
+}
+
+// Copy returns a deep copy of the Job.
+func (j *Job) Copy() *Job {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(j); err != nil {
+		glog.Fatal(err)
+	}
+	var rv Job
+	if err := gob.NewDecoder(&buf).Decode(&rv); err != nil {
+		glog.Fatal(err)
+	}
+	return &rv
+}
+
+// taskSummarySlice implements sort.Interface, sorting TaskSummaries by
+// Attempt.
+type taskSummarySlice []*TaskSummary
+
+func (s taskSummarySlice) Len() int           { return len(s) }
+func (s taskSummarySlice) Less(i, j int) bool { return s[i].Attempt < s[j].Attempt }
+func (s taskSummarySlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// JobSlice implements sort.Interface. To sort jobs []*Job, use
+// sort.Sort(JobSlice(jobs)).
+type JobSlice []*Job
+
+func (s JobSlice) Len() int { return len(s) }
+
+func (s JobSlice) Less(i, j int) bool {
+	return s[i].Created.Before(s[j].Created)
+}
+
+func (s JobSlice) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
+
+// EncodeJobs GOB-encodes the given Jobs.
+func EncodeJobs(jobs []*Job) ([][]byte, error) {
+	rv := make([][]byte, 0, len(jobs))
+	for _, j := range jobs {
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(j); err != nil {
+			return nil, err
+		}
+		rv = append(rv, buf.Bytes())
+	}
+	return rv, nil
+}
+
+// DecodeJob decodes a GOB-encoded Job.
+func DecodeJob(b []byte) (*Job, error) {
+	var j Job
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+
+	"go.skia.org/infra/go/testutils"
+)
+
+func TestJobUpdateFromTask(t *testing.T) {
+	j := makeJob(time.Unix(0, 1470674132000000))
+	assert.False(t, j.Done())
+	assert.False(t, j.IsTryJob())
+
+	t1 := makeTask(time.Unix(0, 1470674132000000), []string{"a"})
+	t1.Id = "1"
+	t1.Status = TASK_STATUS_RUNNING
+
+	// New Task.
+	assert.True(t, j.UpdateFromTask(t1))
+	testutils.AssertDeepEqual(t, map[string][]*TaskSummary{
+		t1.Name: []*TaskSummary{
+			{Attempt: 0, Id: "1", Status: TASK_STATUS_RUNNING},
+		},
+	}, j.Tasks)
+
+	// No change.
+	assert.False(t, j.UpdateFromTask(t1))
+
+	// Status change.
+	t1.Status = TASK_STATUS_FAILURE
+	assert.True(t, j.UpdateFromTask(t1))
+
+	// Retry.
+	t2 := makeTask(time.Unix(0, 1470674376000000), []string{"a"})
+	t2.Id = "2"
+	t2.Attempt = 1
+	assert.True(t, j.UpdateFromTask(t2))
+	testutils.AssertDeepEqual(t, map[string][]*TaskSummary{
+		t1.Name: []*TaskSummary{
+			{Attempt: 0, Id: "1", Status: TASK_STATUS_FAILURE},
+			{Attempt: 1, Id: "2", Status: TASK_STATUS_PENDING},
+		},
+	}, j.Tasks)
+
+	// Copy is a deep copy.
+	cpy := j.Copy()
+	testutils.AssertDeepEqual(t, j, cpy)
+	cpy.Tasks[t1.Name][0].Status = TASK_STATUS_SUCCESS
+	assert.Equal(t, TaskStatus(TASK_STATUS_FAILURE), j.Tasks[t1.Name][0].Status)
+
+	j.Status = JOB_STATUS_FAILURE
+	assert.True(t, j.Done())
+}
@@ -29,6 +29,21 @@ const (
 	// BUCKET_TASKS will be append-mostly, so use a high fill percent.
 	BUCKET_TASKS_FILL_PERCENT = 0.9
 
+	// Index of tasks by creation time. Key is Task.Created formatted using
+	// TIMESTAMP_FORMAT, followed by "_" and Task.Id (see formatCreatedKey),
+	// value is Task.Id. Entries are moved if a Task's Created time changes.
+	BUCKET_TASKS_CREATED_INDEX = "tasks_by_created"
+	// BUCKET_TASKS_CREATED_INDEX will also be append-mostly.
+	BUCKET_TASKS_CREATED_INDEX_FILL_PERCENT = 0.9
+
+	// Jobs. Key is Job.Id, which is set to (creation time, sequence number)
+	// (see formatId for detail), value is the GOB of the job. Jobs will be
+	// updated in place. Unlike Tasks, a Job's Created time may not change
+	// after its Id is assigned, so no separate created-time index is needed.
+	BUCKET_JOBS = "jobs"
+	// BUCKET_JOBS will be append-mostly, so use a high fill percent.
+	BUCKET_JOBS_FILL_PERCENT = 0.9
+
 	// TIMESTAMP_FORMAT is a format string passed to Time.Format and time.Parse to
 	// format/parse the timestamp in the Task ID. It is similar to
 	// util.RFC3339NanoZeroPad, but since Task.Id can not contain colons, we omit
@@ -43,9 +58,9 @@ const (
 	// MAX_CREATED_TIME_SKEW is the maximum difference between the timestamp in a
 	// Task's Id field and that Task's Created field. This allows AssignId to be
 	// called before creating the Swarming task so that the Id can be included in
-	// the Swarming task tags. GetTasksFromDateRange accounts for this skew when
-	// retrieving tasks. This value can be increased in the future, but can never
-	// be decreased.
+	// the Swarming task tags. GetTasksFromDateRange uses
+	// BUCKET_TASKS_CREATED_INDEX, so it is not affected by this skew. This value
+	// can be increased in the future, but can never be decreased.
 	//
 	// 6 minutes is based on httputils.DIAL_TIMEOUT + httputils.REQUEST_TIMEOUT,
 	// which is assumed to be the approximate maximum duration of a successful
@@ -85,6 +100,12 @@ func parseId(id string) (time.Time, uint64, error) {
 	return t, seq, nil
 }
 
+// formatCreatedKey returns the key in BUCKET_TASKS_CREATED_INDEX for the given
+// creation time and Task ID. Keys sort by creation time, then by Task ID.
+func formatCreatedKey(created time.Time, id string) []byte {
+	return []byte(fmt.Sprintf("%s_%s", created.UTC().Format(TIMESTAMP_FORMAT), id))
+}
+
 // localDB accesses a local BoltDB database containing tasks.
 type localDB struct {
 	// name is used in logging and metrics to identify this DB.
@@ -101,6 +122,7 @@ type localDB struct {
 	txMutex  sync.RWMutex
 
 	modTasks db.ModifiedTasks
+	modJobs  db.ModifiedJobs
 }
 
 // startTx monitors when a transaction starts.
@@ -169,6 +191,41 @@ func tasksBucket(tx *bolt.Tx) *bolt.Bucket {
 	return b
 }
 
+// Returns the created-time index bucket with FillPercent set.
+func createdIndexBucket(tx *bolt.Tx) *bolt.Bucket {
+	b := tx.Bucket([]byte(BUCKET_TASKS_CREATED_INDEX))
+	b.FillPercent = BUCKET_TASKS_CREATED_INDEX_FILL_PERCENT
+	return b
+}
+
+// Returns the jobs bucket with FillPercent set.
+func jobsBucket(tx *bolt.Tx) *bolt.Bucket {
+	b := tx.Bucket([]byte(BUCKET_JOBS))
+	b.FillPercent = BUCKET_JOBS_FILL_PERCENT
+	return b
+}
+
+// buildCreatedIndex creates BUCKET_TASKS_CREATED_INDEX from the contents of
+// BUCKET_TASKS if it does not already exist. This allows opening databases
+// which were written before the index was added. tx must be an update
+// transaction.
+func buildCreatedIndex(tx *bolt.Tx) error {
+	if tx.Bucket([]byte(BUCKET_TASKS_CREATED_INDEX)) != nil {
+		return nil
+	}
+	if _, err := tx.CreateBucket([]byte(BUCKET_TASKS_CREATED_INDEX)); err != nil {
+		return err
+	}
+	idx := createdIndexBucket(tx)
+	return tasksBucket(tx).ForEach(func(k, v []byte) error {
+		var t db.Task
+		if err := gob.NewDecoder(bytes.NewReader(v)).Decode(&t); err != nil {
+			return err
+		}
+		return idx.Put(formatCreatedKey(t.Created, t.Id), []byte(t.Id))
+	})
+}
+
 // NewDB returns a local DB instance.
 func NewDB(name, filename string) (db.DB, error) {
 	boltdb, err := bolt.Open(filename, 0600, nil)
@@ -194,7 +251,10 @@ func NewDB(name, filename string) (db.DB, error) {
 		if _, err := tx.CreateBucketIfNotExists([]byte(BUCKET_TASKS)); err != nil {
 			return err
 		}
-		return nil
+		if _, err := tx.CreateBucketIfNotExists([]byte(BUCKET_JOBS)); err != nil {
+			return err
+		}
+		return buildCreatedIndex(tx)
 	}); err != nil {
 		return nil, err
 	}
@@ -276,12 +336,19 @@ func (d *localDB) GetTaskById(id string) (*db.Task, error) {
 
 // See docs for DB interface.
 func (d *localDB) GetTasksFromDateRange(start, end time.Time) ([]*db.Task, error) {
-	min := []byte(start.Add(-MAX_CREATED_TIME_SKEW).UTC().Format(TIMESTAMP_FORMAT))
+	min := []byte(start.UTC().Format(TIMESTAMP_FORMAT))
 	max := []byte(end.UTC().Format(TIMESTAMP_FORMAT))
 	decoder := db.TaskDecoder{}
 	if err := d.view("GetTasksFromDateRange", func(tx *bolt.Tx) error {
-		c := tasksBucket(tx).Cursor()
-		for k, v := c.Seek(min); k != nil && bytes.Compare(k, max) <= 0; k, v = c.Next() {
+		tasks := tasksBucket(tx)
+		c := createdIndexBucket(tx).Cursor()
+		// Index keys begin with the formatted creation time, which has a fixed
+		// length, so we only need to compare that prefix with max.
+		for k, id := c.Seek(min); k != nil && bytes.Compare(k[:len(max)], max) < 0; k, id = c.Next() {
+			v := tasks.Get(id)
+			if v == nil {
+				return fmt.Errorf("Created index refers to nonexistent task %q", string(id))
+			}
 			cpy := make([]byte, len(v))
 			copy(cpy, v)
 			if !decoder.Process(cpy) {
@@ -297,18 +364,7 @@ func (d *localDB) GetTasksFromDateRange(start, end time.Time) ([]*db.Task, error
 		return nil, err
 	}
 	sort.Sort(db.TaskSlice(result))
-	// The Tasks retrieved based on Id timestamp may include Tasks with Created
-	// time before/after the desired range.
-	// TODO(benjaminwagner): Biased binary search might be faster.
-	startIdx := 0
-	for startIdx < len(result) && result[startIdx].Created.Before(start) {
-		startIdx++
-	}
-	endIdx := len(result)
-	for endIdx > 0 && !result[endIdx-1].Created.Before(end) {
-		endIdx--
-	}
-	return result[startIdx:endIdx], nil
+	return result, nil
 }
 
 // See documentation for DB interface.
@@ -337,13 +393,34 @@ func (d *localDB) validate(t *db.Task) error {
 	return nil
 }
 
+// updateCreatedIndex inserts t into BUCKET_TASKS_CREATED_INDEX, removing the
+// existing entry if t was previously inserted with a different Created time.
+// Must be called before the new version of t is written to BUCKET_TASKS. tx
+// must be an update transaction.
+func (d *localDB) updateCreatedIndex(tx *bolt.Tx, t *db.Task) error {
+	idx := createdIndexBucket(tx)
+	if prev := tasksBucket(tx).Get([]byte(t.Id)); prev != nil {
+		var old db.Task
+		if err := gob.NewDecoder(bytes.NewReader(prev)).Decode(&old); err != nil {
+			return err
+		}
+		if old.Created.Equal(t.Created) {
+			return nil
+		}
+		if err := idx.Delete(formatCreatedKey(old.Created, old.Id)); err != nil {
+			return err
+		}
+	}
+	return idx.Put(formatCreatedKey(t.Created, t.Id), []byte(t.Id))
+}
+
 // See documentation for DB interface.
 // TODO(benjaminwagner): Figure out how to detect write/write conflicts and
 // return "concurrent modification" error.
 func (d *localDB) PutTasks(tasks []*db.Task) error {
 	// If there is an error during the transaction, we should leave the tasks
 	// unchanged. Save the old Ids since we set them below.
-	oldIds := make([]string, len(tasks))
+	oldIds := make([]string, 0, len(tasks))
 	// Validate and save current Ids.
 	for _, t := range tasks {
 		if err := d.validate(t); err != nil {
@@ -379,6 +456,9 @@ func (d *localDB) PutTasks(tasks []*db.Task) error {
 				break
 			}
 			gobs = append(gobs, serialized)
+			if err := d.updateCreatedIndex(tx, t); err != nil {
+				return err
+			}
 			if err := tasksBucket(tx).Put([]byte(t.Id), serialized); err != nil {
 				return err
 			}
@@ -404,6 +484,131 @@ func (d *localDB) StartTrackingModifiedTasks() (string, error) {
 	return d.modTasks.StartTrackingModifiedTasks()
 }
 
+// See docs for DB interface.
+func (d *localDB) GetJobById(id string) (*db.Job, error) {
+	var rv *db.Job
+	if err := d.view("GetJobById", func(tx *bolt.Tx) error {
+		serialized := jobsBucket(tx).Get([]byte(id))
+		if serialized == nil {
+			return nil
+		}
+		var err error
+		rv, err = db.DecodeJob(serialized)
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	if rv == nil {
+		// Return an error if id is invalid.
+		if _, _, err := parseId(id); err != nil {
+			return nil, err
+		}
+	}
+	return rv, nil
+}
+
+// See docs for DB interface.
+func (d *localDB) GetJobsFromDateRange(start, end time.Time) ([]*db.Job, error) {
+	min := []byte(start.UTC().Format(TIMESTAMP_FORMAT))
+	max := []byte(end.UTC().Format(TIMESTAMP_FORMAT))
+	rv := []*db.Job{}
+	if err := d.view("GetJobsFromDateRange", func(tx *bolt.Tx) error {
+		c := jobsBucket(tx).Cursor()
+		// Job keys begin with the formatted creation time, which has a fixed
+		// length, so we only need to compare that prefix with max.
+		for k, v := c.Seek(min); k != nil && bytes.Compare(k[:len(max)], max) < 0; k, v = c.Next() {
+			j, err := db.DecodeJob(v)
+			if err != nil {
+				return err
+			}
+			rv = append(rv, j)
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Sort(db.JobSlice(rv))
+	return rv, nil
+}
+
+// See docs for DB interface.
+func (d *localDB) GetModifiedJobs(id string) ([]*db.Job, error) {
+	return d.modJobs.GetModifiedJobs(id)
+}
+
+// validateJob returns an error if the job can not be inserted into the DB.
+// Does not modify j.
+func (d *localDB) validateJob(j *db.Job) error {
+	if util.TimeIsZero(j.Created) {
+		return fmt.Errorf("Created not set. Job %s created time is %s. %v", j.Id, j.Created, j)
+	}
+	if j.Id != "" {
+		idTs, _, err := parseId(j.Id)
+		if err != nil {
+			return err
+		}
+		if !j.Created.Equal(idTs) {
+			return fmt.Errorf("Created time changed. Job %s was assigned Id at Created time %s, but Created time is now %s.", j.Id, idTs, j.Created)
+		}
+	}
+	return nil
+}
+
+// See docs for DB interface.
+func (d *localDB) PutJob(j *db.Job) error {
+	return d.PutJobs([]*db.Job{j})
+}
+
+// See docs for DB interface.
+func (d *localDB) PutJobs(jobs []*db.Job) error {
+	// If there is an error during the transaction, we should leave the jobs
+	// unchanged. Save the old Ids since we set them below.
+	oldIds := make([]string, 0, len(jobs))
+	for _, j := range jobs {
+		if err := d.validateJob(j); err != nil {
+			return err
+		}
+		oldIds = append(oldIds, j.Id)
+	}
+	var gobs [][]byte
+	err := d.update("PutJobs", func(tx *bolt.Tx) error {
+		bucket := jobsBucket(tx)
+		for _, j := range jobs {
+			if j.Id == "" {
+				seq, err := bucket.NextSequence()
+				if err != nil {
+					return err
+				}
+				j.Id = formatId(j.Created, seq)
+			}
+		}
+		var err error
+		gobs, err = db.EncodeJobs(jobs)
+		if err != nil {
+			return err
+		}
+		for i, j := range jobs {
+			if err := bucket.Put([]byte(j.Id), gobs[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		for i, oldId := range oldIds {
+			jobs[i].Id = oldId
+		}
+		return err
+	}
+	d.modJobs.TrackModifiedJobsGOB(gobs)
+	return nil
+}
+
+// See docs for DB interface.
+func (d *localDB) StartTrackingModifiedJobs() (string, error) {
+	return d.modJobs.StartTrackingModifiedJobs()
+}
+
 // Returns the total number of tasks in the DB.
 // TODO(benjaminwagner): add a metrics goroutine.
 func (d *localDB) NumTasks() (int, error) {
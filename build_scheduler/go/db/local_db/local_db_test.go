@@ -7,6 +7,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/boltdb/bolt"
 	assert "github.com/stretchr/testify/require"
 	"go.skia.org/infra/build_scheduler/go/db"
 	"go.skia.org/infra/go/testutils"
@@ -306,6 +307,82 @@ func TestPutTaskValidateCreatedTime(t *testing.T) {
 	}
 }
 
+// Test that GetTasksFromDateRange uses the Created time, including when it
+// changes after the Task is inserted.
+func TestGetTasksFromDateRangeCreatedChanged(t *testing.T) {
+	d, tmpdir := makeDB(t, "TestGetTasksFromDateRangeCreatedChanged")
+	defer util.RemoveAll(tmpdir)
+	defer testutils.AssertCloses(t, d)
+
+	task := &db.Task{}
+	assert.NoError(t, d.AssignId(task))
+	created := time.Now()
+	task.Created = created
+	assert.NoError(t, d.PutTask(task))
+
+	tasks, err := d.GetTasksFromDateRange(created, created.Add(time.Nanosecond))
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*db.Task{task}, tasks)
+
+	// Move the Created time forward; the task should no longer be found at the
+	// old time.
+	task.Created = created.Add(time.Minute)
+	assert.NoError(t, d.PutTask(task))
+
+	tasks, err = d.GetTasksFromDateRange(created, created.Add(time.Nanosecond))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(tasks))
+
+	tasks, err = d.GetTasksFromDateRange(task.Created, task.Created.Add(time.Nanosecond))
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*db.Task{task}, tasks)
+
+	tasks, err = d.GetTasksFromDateRange(created, task.Created.Add(time.Nanosecond))
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*db.Task{task}, tasks)
+}
+
+// Test that tasks persist when the DB is closed and reopened, and that the
+// created-time index is rebuilt if it is missing.
+func TestReopenDB(t *testing.T) {
+	tmpdir, err := ioutil.TempDir("", "TestReopenDB")
+	assert.NoError(t, err)
+	defer util.RemoveAll(tmpdir)
+	filename := filepath.Join(tmpdir, "task.db")
+
+	d, err := NewDB("TestReopenDB", filename)
+	assert.NoError(t, err)
+	now := time.Now()
+	t1 := &db.Task{Created: now, Name: "a"}
+	t2 := &db.Task{Created: now.Add(time.Second), Name: "b"}
+	assert.NoError(t, d.PutTasks([]*db.Task{t1, t2}))
+	assert.NoError(t, d.Close())
+
+	check := func() {
+		d, err := NewDB("TestReopenDB", filename)
+		assert.NoError(t, err)
+		defer testutils.AssertCloses(t, d)
+
+		t1Again, err := d.GetTaskById(t1.Id)
+		assert.NoError(t, err)
+		testutils.AssertDeepEqual(t, t1, t1Again)
+
+		tasks, err := d.GetTasksFromDateRange(now, now.Add(2*time.Second))
+		assert.NoError(t, err)
+		testutils.AssertDeepEqual(t, []*db.Task{t1, t2}, tasks)
+	}
+	check()
+
+	// Remove the index to simulate a DB created before the index existed.
+	boltdb, err := bolt.Open(filename, 0600, nil)
+	assert.NoError(t, err)
+	assert.NoError(t, boltdb.Update(func(tx *bolt.Tx) error {
+		return tx.DeleteBucket([]byte(BUCKET_TASKS_CREATED_INDEX))
+	}))
+	assert.NoError(t, boltdb.Close())
+	check()
+}
+
 func TestLocalDB(t *testing.T) {
 	d, tmpdir := makeDB(t, "TestLocalDB")
 	defer util.RemoveAll(tmpdir)
@@ -317,3 +394,9 @@ func TestLocalDBTooManyUsers(t *testing.T) {
 	defer util.RemoveAll(tmpdir)
 	db.TestTooManyUsers(t, d)
 }
+
+func TestLocalJobDB(t *testing.T) {
+	d, tmpdir := makeDB(t, "TestLocalJobDB")
+	defer util.RemoveAll(tmpdir)
+	db.TestJobDB(t, d)
+}
@@ -15,6 +15,10 @@ type inMemoryDB struct {
 	tasks    map[string]*Task
 	tasksMtx sync.RWMutex
 	modTasks ModifiedTasks
+
+	jobs    map[string]*Job
+	jobsMtx sync.RWMutex
+	modJobs ModifiedJobs
 }
 
 // See docs for DB interface. Does not take any locks.
@@ -95,11 +99,75 @@ func (db *inMemoryDB) StartTrackingModifiedTasks() (string, error) {
 	return db.modTasks.StartTrackingModifiedTasks()
 }
 
+// See docs for DB interface.
+func (db *inMemoryDB) GetJobById(id string) (*Job, error) {
+	db.jobsMtx.RLock()
+	defer db.jobsMtx.RUnlock()
+	if j, ok := db.jobs[id]; ok {
+		return j.Copy(), nil
+	}
+	return nil, nil
+}
+
+// See docs for DB interface.
+func (db *inMemoryDB) GetJobsFromDateRange(start, end time.Time) ([]*Job, error) {
+	db.jobsMtx.RLock()
+	defer db.jobsMtx.RUnlock()
+
+	rv := []*Job{}
+	for _, j := range db.jobs {
+		if !start.After(j.Created) && j.Created.Before(end) {
+			rv = append(rv, j.Copy())
+		}
+	}
+	sort.Sort(JobSlice(rv))
+	return rv, nil
+}
+
+// See docs for DB interface.
+func (db *inMemoryDB) GetModifiedJobs(id string) ([]*Job, error) {
+	return db.modJobs.GetModifiedJobs(id)
+}
+
+// See docs for DB interface.
+func (db *inMemoryDB) PutJob(job *Job) error {
+	db.jobsMtx.Lock()
+	defer db.jobsMtx.Unlock()
+
+	if util.TimeIsZero(job.Created) {
+		return fmt.Errorf("Created not set. Job %s created time is %s. %v", job.Id, job.Created, job)
+	}
+
+	if job.Id == "" {
+		job.Id = uuid.NewV5(uuid.NewV1(), uuid.NewV4().String()).String()
+	}
+
+	db.jobs[job.Id] = job.Copy()
+	db.modJobs.TrackModifiedJob(job)
+	return nil
+}
+
+// See docs for DB interface.
+func (db *inMemoryDB) PutJobs(jobs []*Job) error {
+	for _, j := range jobs {
+		if err := db.PutJob(j); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// See docs for DB interface.
+func (db *inMemoryDB) StartTrackingModifiedJobs() (string, error) {
+	return db.modJobs.StartTrackingModifiedJobs()
+}
+
 // NewInMemoryDB returns an extremely simple, inefficient, in-memory DB
 // implementation.
 func NewInMemoryDB() DB {
 	db := &inMemoryDB{
 		tasks: map[string]*Task{},
+		jobs:  map[string]*Job{},
 	}
 	return db
 }
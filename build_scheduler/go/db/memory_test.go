@@ -9,3 +9,7 @@ func TestInMemoryDB(t *testing.T) {
 func TestInMemoryTooManyUsers(t *testing.T) {
 	TestTooManyUsers(t, NewInMemoryDB())
 }
+
+func TestInMemoryJobDB(t *testing.T) {
+	TestJobDB(t, NewInMemoryDB())
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"bytes"
+	"encoding/gob"
+	"sort"
+
+	"github.com/skia-dev/glog"
+)
+
+// ModifiedJobs allows subscribers to keep track of Jobs that have been
+// modified. It implements StartTrackingModifiedJobs and GetModifiedJobs from
+// the DB interface.
+type ModifiedJobs struct {
+	m modifiedData
+}
+
+// See docs for DB interface.
+func (m *ModifiedJobs) GetModifiedJobs(id string) ([]*Job, error) {
+	var rv []*Job
+	if err := m.m.getModified(id, func(gobs [][]byte) error {
+		jobs := make([]*Job, 0, len(gobs))
+		for _, g := range gobs {
+			j, err := DecodeJob(g)
+			if err != nil {
+				return err
+			}
+			jobs = append(jobs, j)
+		}
+		rv = jobs
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.Sort(JobSlice(rv))
+	return rv, nil
+}
+
+// TrackModifiedJob indicates the given Job should be returned from the next
+// call to GetModifiedJobs from each subscriber.
+func (m *ModifiedJobs) TrackModifiedJob(j *Job) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(j); err != nil {
+		glog.Fatal(err)
+	}
+	m.TrackModifiedJobsGOB([][]byte{buf.Bytes()})
+}
+
+// TrackModifiedJobsGOB is a batch, GOB version of TrackModifiedJob. It is
+// equivalent to GOB-decoding each element of gobs as a Job and calling
+// TrackModifiedJob on each one. Contents of gobs must not be modified after
+// this call.
+func (m *ModifiedJobs) TrackModifiedJobsGOB(gobs [][]byte) {
+	m.m.trackModifiedGOB(gobs)
+}
+
+// See docs for DB interface.
+func (m *ModifiedJobs) StartTrackingModifiedJobs() (string, error) {
+	return m.m.startTracking()
+}
@@ -11,50 +11,43 @@ import (
 	"github.com/skia-dev/glog"
 )
 
-// ModifiedTasks allows subscribers to keep track of Tasks that have been
-// modified. It implements StartTrackingModifiedTasks and GetModifiedTasks from
-// the DB interface.
-type ModifiedTasks struct {
-	// map[subscriber_id][]task_gob
-	tasks map[string][][]byte
+// modifiedData allows subscribers to keep track of GOB-encoded entries which
+// have been modified. It provides the common implementation of ModifiedTasks
+// and ModifiedJobs.
+type modifiedData struct {
+	// map[subscriber_id][]gob
+	data map[string][][]byte
 	// After the expiration time, subscribers are automatically removed.
 	expiration map[string]time.Time
-	// Protects tasks and expiration.
+	// Protects data and expiration.
 	mtx sync.RWMutex
 }
 
-// See docs for DB interface.
-func (m *ModifiedTasks) GetModifiedTasks(id string) ([]*Task, error) {
+// getModified passes the GOBs added since the last call with the given id to
+// decode. If decode returns an error, the GOBs are retained for the next call.
+func (m *modifiedData) getModified(id string, decode func([][]byte) error) error {
 	m.mtx.Lock()
 	defer m.mtx.Unlock()
 	if _, ok := m.expiration[id]; !ok {
-		return nil, ErrUnknownId
-	}
-	d := TaskDecoder{}
-	for _, g := range m.tasks[id] {
-		if !d.Process(g) {
-			break
-		}
+		return ErrUnknownId
 	}
-	rv, err := d.Result()
-	if err != nil {
-		return nil, err
+	if err := decode(m.data[id]); err != nil {
+		return err
 	}
 	m.expiration[id] = time.Now().Add(MODIFIED_BUILDS_TIMEOUT)
-	delete(m.tasks, id)
-	sort.Sort(TaskSlice(rv))
-	return rv, nil
+	delete(m.data, id)
+	return nil
 }
 
 // clearExpiredSubscribers periodically deletes data about any subscribers that
 // haven't been seen within MODIFIED_BUILDS_TIMEOUT. Must be called as a
 // goroutine. Returns when there are no remaining subscribers.
-func (m *ModifiedTasks) clearExpiredSubscribers() {
+func (m *modifiedData) clearExpiredSubscribers() {
 	for _ = range time.Tick(time.Minute) {
 		m.mtx.Lock()
 		for id, t := range m.expiration {
 			if time.Now().After(t) {
-				delete(m.tasks, id)
+				delete(m.data, id)
 				delete(m.expiration, id)
 			}
 		}
@@ -66,6 +59,61 @@ func (m *ModifiedTasks) clearExpiredSubscribers() {
 	}
 }
 
+// trackModifiedGOB indicates that the given GOBs should be returned from the
+// next call to getModified from each subscriber.
+func (m *modifiedData) trackModifiedGOB(gobs [][]byte) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	for id, _ := range m.expiration {
+		m.data[id] = append(m.data[id], gobs...)
+	}
+}
+
+// startTracking initiates tracking of modified data for a new subscriber and
+// returns its ID.
+func (m *modifiedData) startTracking() (string, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+	if len(m.expiration) == 0 {
+		// Initialize the data structure and start expiration goroutine.
+		m.data = map[string][][]byte{}
+		m.expiration = map[string]time.Time{}
+		go m.clearExpiredSubscribers()
+	} else if len(m.expiration) >= MAX_MODIFIED_BUILDS_USERS {
+		return "", ErrTooManyUsers
+	}
+	id := uuid.NewV5(uuid.NewV1(), uuid.NewV4().String()).String()
+	m.expiration[id] = time.Now().Add(MODIFIED_BUILDS_TIMEOUT)
+	return id, nil
+}
+
+// ModifiedTasks allows subscribers to keep track of Tasks that have been
+// modified. It implements StartTrackingModifiedTasks and GetModifiedTasks from
+// the DB interface.
+type ModifiedTasks struct {
+	m modifiedData
+}
+
+// See docs for DB interface.
+func (m *ModifiedTasks) GetModifiedTasks(id string) ([]*Task, error) {
+	var rv []*Task
+	if err := m.m.getModified(id, func(gobs [][]byte) error {
+		d := TaskDecoder{}
+		for _, g := range gobs {
+			if !d.Process(g) {
+				break
+			}
+		}
+		var err error
+		rv, err = d.Result()
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	sort.Sort(TaskSlice(rv))
+	return rv, nil
+}
+
 // TrackModifiedTask indicates the given Task should be returned from the next
 // call to GetModifiedTasks from each subscriber.
 func (m *ModifiedTasks) TrackModifiedTask(t *Task) {
@@ -81,26 +129,10 @@ func (m *ModifiedTasks) TrackModifiedTask(t *Task) {
 // TrackModifiedTask on each one. Contents of gobs must not be modified after
 // this call.
 func (m *ModifiedTasks) TrackModifiedTasksGOB(gobs [][]byte) {
-	m.mtx.Lock()
-	defer m.mtx.Unlock()
-	for id, _ := range m.expiration {
-		m.tasks[id] = append(m.tasks[id], gobs...)
-	}
+	m.m.trackModifiedGOB(gobs)
 }
 
 // See docs for DB interface.
 func (m *ModifiedTasks) StartTrackingModifiedTasks() (string, error) {
-	m.mtx.Lock()
-	defer m.mtx.Unlock()
-	if len(m.expiration) == 0 {
-		// Initialize the data structure and start expiration goroutine.
-		m.tasks = map[string][][]byte{}
-		m.expiration = map[string]time.Time{}
-		go m.clearExpiredSubscribers()
-	} else if len(m.expiration) >= MAX_MODIFIED_BUILDS_USERS {
-		return "", ErrTooManyUsers
-	}
-	id := uuid.NewV5(uuid.NewV1(), uuid.NewV4().String()).String()
-	m.expiration[id] = time.Now().Add(MODIFIED_BUILDS_TIMEOUT)
-	return id, nil
+	return m.m.startTracking()
 }
@@ -58,3 +58,41 @@ func TestModifiedTasksTooManyUsers(t *testing.T) {
 	_, err := m.StartTrackingModifiedTasks()
 	assert.True(t, IsTooManyUsers(err))
 }
+
+func TestModifiedJobs(t *testing.T) {
+	m := ModifiedJobs{}
+
+	_, err := m.GetModifiedJobs("dummy-id")
+	assert.True(t, IsUnknownId(err))
+
+	id, err := m.StartTrackingModifiedJobs()
+	assert.NoError(t, err)
+
+	jobs, err := m.GetModifiedJobs(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(jobs))
+
+	j1 := makeJob(time.Unix(0, 1470674132000000))
+	j1.Id = "1"
+
+	// Insert the job.
+	m.TrackModifiedJob(j1)
+
+	// Ensure that the job shows up in the modified list.
+	jobs, err = m.GetModifiedJobs(id)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*Job{j1}, jobs)
+
+	// Insert two more jobs, out of order.
+	j2 := makeJob(time.Unix(0, 1470674376000000))
+	j2.Id = "2"
+	j3 := makeJob(time.Unix(0, 1470674884000000))
+	j3.Id = "3"
+	m.TrackModifiedJob(j3)
+	m.TrackModifiedJob(j2)
+
+	// Ensure that both jobs show up in the modified list, sorted.
+	jobs, err = m.GetModifiedJobs(id)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*Job{j2, j3}, jobs)
+}
@@ -0,0 +1,223 @@
+package remote_db
+
+import (
+	"fmt"
+	"time"
+
+	"go.skia.org/infra/build_scheduler/go/db"
+	"go.skia.org/infra/build_scheduler/go/db/rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// remoteDB is a struct used for interacting with a remote task database.
+type remoteDB struct {
+	conn   *grpc.ClientConn
+	client rpc.TaskDBClient
+}
+
+// NewRemoteDB returns a db.DB instance which accesses the task server running
+// at the given address.
+func NewRemoteDB(addr string) (db.DB, error) {
+	// TODO(borenet): Shoudn't use WithInsecure...
+	conn, err := grpc.Dial(addr, grpc.WithInsecure())
+	if err != nil {
+		return nil, err
+	}
+	return &remoteDB{
+		conn:   conn,
+		client: rpc.NewTaskDBClient(conn),
+	}, nil
+}
+
+// convertErr translates errors returned by the server into the errors defined
+// in the db package, so that db.IsTooManyUsers and db.IsUnknownId work as
+// expected.
+func convertErr(err error) error {
+	if err == nil {
+		return nil
+	}
+	switch grpc.ErrorDesc(err) {
+	case db.ErrTooManyUsers.Error():
+		return db.ErrTooManyUsers
+	case db.ErrUnknownId.Error():
+		return db.ErrUnknownId
+	}
+	return err
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) AssignId(t *db.Task) error {
+	req, err := encodeTask(t)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.AssignId(context.Background(), req)
+	if err != nil {
+		return convertErr(err)
+	}
+	assigned, err := decodeTask(resp)
+	if err != nil {
+		return err
+	}
+	if assigned == nil {
+		return fmt.Errorf("Server did not return a task.")
+	}
+	t.Id = assigned.Id
+	return nil
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) Close() error {
+	return d.conn.Close()
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) GetModifiedTasks(id string) ([]*db.Task, error) {
+	req := &rpc.GetModifiedTasksRequest{
+		Id: id,
+	}
+	resp, err := d.client.GetModifiedTasks(context.Background(), req)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return decodeTasks(resp)
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) GetTaskById(id string) (*db.Task, error) {
+	req := &rpc.GetTaskByIdRequest{
+		Id: id,
+	}
+	resp, err := d.client.GetTaskById(context.Background(), req)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return decodeTask(resp)
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) GetTasksFromDateRange(start, end time.Time) ([]*db.Task, error) {
+	req := &rpc.GetTasksFromDateRangeRequest{
+		Start: start.Format(time.RFC3339Nano),
+		End:   end.Format(time.RFC3339Nano),
+	}
+	resp, err := d.client.GetTasksFromDateRange(context.Background(), req)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return decodeTasks(resp)
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) PutTask(t *db.Task) error {
+	return d.PutTasks([]*db.Task{t})
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) PutTasks(tasks []*db.Task) error {
+	req, err := encodeTasks(tasks)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.PutTasks(context.Background(), req)
+	if err != nil {
+		return convertErr(err)
+	}
+	// The server may have assigned Ids to the tasks.
+	inserted, err := decodeTasks(resp)
+	if err != nil {
+		return err
+	}
+	if len(inserted) != len(tasks) {
+		return fmt.Errorf("Server returned %d tasks; expected %d.", len(inserted), len(tasks))
+	}
+	for i, t := range tasks {
+		t.Id = inserted[i].Id
+	}
+	return nil
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) StartTrackingModifiedTasks() (string, error) {
+	resp, err := d.client.StartTrackingModifiedTasks(context.Background(), &rpc.Empty{})
+	if err != nil {
+		return "", convertErr(err)
+	}
+	return resp.Id, nil
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) GetJobById(id string) (*db.Job, error) {
+	req := &rpc.GetJobByIdRequest{
+		Id: id,
+	}
+	resp, err := d.client.GetJobById(context.Background(), req)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return decodeJob(resp)
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) GetJobsFromDateRange(start, end time.Time) ([]*db.Job, error) {
+	req := &rpc.GetJobsFromDateRangeRequest{
+		Start: start.Format(time.RFC3339Nano),
+		End:   end.Format(time.RFC3339Nano),
+	}
+	resp, err := d.client.GetJobsFromDateRange(context.Background(), req)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return decodeJobs(resp)
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) GetModifiedJobs(id string) ([]*db.Job, error) {
+	req := &rpc.GetModifiedJobsRequest{
+		Id: id,
+	}
+	resp, err := d.client.GetModifiedJobs(context.Background(), req)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+	return decodeJobs(resp)
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) PutJob(j *db.Job) error {
+	return d.PutJobs([]*db.Job{j})
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) PutJobs(jobs []*db.Job) error {
+	req, err := encodeJobs(jobs)
+	if err != nil {
+		return err
+	}
+	resp, err := d.client.PutJobs(context.Background(), req)
+	if err != nil {
+		return convertErr(err)
+	}
+	// The server may have assigned Ids to the jobs.
+	inserted, err := decodeJobs(resp)
+	if err != nil {
+		return err
+	}
+	if len(inserted) != len(jobs) {
+		return fmt.Errorf("Server returned %d jobs; expected %d.", len(inserted), len(jobs))
+	}
+	for i, j := range jobs {
+		j.Id = inserted[i].Id
+	}
+	return nil
+}
+
+// See documentation for DB interface.
+func (d *remoteDB) StartTrackingModifiedJobs() (string, error) {
+	resp, err := d.client.StartTrackingModifiedJobs(context.Background(), &rpc.Empty{})
+	if err != nil {
+		return "", convertErr(err)
+	}
+	return resp.Id, nil
+}
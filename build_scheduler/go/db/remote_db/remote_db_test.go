@@ -0,0 +1,31 @@
+package remote_db
+
+import (
+	"fmt"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/build_scheduler/go/db"
+)
+
+// makeDB runs a task server backed by an in-memory DB and returns a remote DB
+// which connects to it.
+func makeDB(t *testing.T) db.DB {
+	port, err := RunTaskServer(":0", db.NewInMemoryDB())
+	assert.NoError(t, err)
+	d, err := NewRemoteDB(fmt.Sprintf("localhost%s", port))
+	assert.NoError(t, err)
+	return d
+}
+
+func TestRemoteDB(t *testing.T) {
+	db.TestDB(t, makeDB(t))
+}
+
+func TestRemoteDBTooManyUsers(t *testing.T) {
+	db.TestTooManyUsers(t, makeDB(t))
+}
+
+func TestRemoteJobDB(t *testing.T) {
+	db.TestJobDB(t, makeDB(t))
+}
@@ -0,0 +1,300 @@
+package remote_db
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/build_scheduler/go/db"
+	"go.skia.org/infra/build_scheduler/go/db/rpc"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+)
+
+// RunTaskServer runs a gRPC server on the given port which provides access to
+// the given DB. Returns the port on which the server is listening, which is
+// useful when port is ":0".
+func RunTaskServer(port string, d db.DB) (string, error) {
+	lis, err := net.Listen("tcp", port)
+	if err != nil {
+		return "", fmt.Errorf("Failed to create task server: failed to listen on port %q: %s", port, err)
+	}
+	s := grpc.NewServer()
+	rpc.RegisterTaskDBServer(s, &rpcServer{db: d})
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			glog.Errorf("Failed to run RPC server: %s", err)
+		}
+	}()
+	addrSplit := strings.Split(lis.Addr().String(), ":")
+	return fmt.Sprintf(":%s", addrSplit[len(addrSplit)-1]), nil
+}
+
+type rpcServer struct {
+	db db.DB
+}
+
+// encodeTask GOB-encodes the given Task into an rpc.Task. A nil Task results
+// in an empty rpc.Task.
+func encodeTask(t *db.Task) (*rpc.Task, error) {
+	if t == nil {
+		return &rpc.Task{}, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(t); err != nil {
+		return nil, err
+	}
+	return &rpc.Task{
+		Task: buf.Bytes(),
+	}, nil
+}
+
+// decodeTask decodes the given rpc.Task. An empty rpc.Task results in a nil
+// Task.
+func decodeTask(t *rpc.Task) (*db.Task, error) {
+	if len(t.Task) == 0 {
+		return nil, nil
+	}
+	var rv db.Task
+	if err := gob.NewDecoder(bytes.NewBuffer(t.Task)).Decode(&rv); err != nil {
+		return nil, err
+	}
+	return &rv, nil
+}
+
+// encodeTasks GOB-encodes the given Tasks into an rpc.Tasks.
+func encodeTasks(tasks []*db.Task) (*rpc.Tasks, error) {
+	rv := &rpc.Tasks{
+		Tasks: make([]*rpc.Task, 0, len(tasks)),
+	}
+	for _, t := range tasks {
+		enc, err := encodeTask(t)
+		if err != nil {
+			return nil, err
+		}
+		rv.Tasks = append(rv.Tasks, enc)
+	}
+	return rv, nil
+}
+
+// decodeTasks decodes the given rpc.Tasks.
+func decodeTasks(tasks *rpc.Tasks) ([]*db.Task, error) {
+	rv := make([]*db.Task, 0, len(tasks.Tasks))
+	for _, t := range tasks.Tasks {
+		dec, err := decodeTask(t)
+		if err != nil {
+			return nil, err
+		}
+		if dec == nil {
+			return nil, fmt.Errorf("Received empty task.")
+		}
+		rv = append(rv, dec)
+	}
+	return rv, nil
+}
+
+func (s *rpcServer) AssignId(ctx context.Context, req *rpc.Task) (*rpc.Task, error) {
+	t, err := decodeTask(req)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		t = &db.Task{}
+	}
+	if err := s.db.AssignId(t); err != nil {
+		return nil, err
+	}
+	return encodeTask(t)
+}
+
+func (s *rpcServer) GetModifiedTasks(ctx context.Context, req *rpc.GetModifiedTasksRequest) (*rpc.Tasks, error) {
+	tasks, err := s.db.GetModifiedTasks(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return encodeTasks(tasks)
+}
+
+func (s *rpcServer) GetTaskById(ctx context.Context, req *rpc.GetTaskByIdRequest) (*rpc.Task, error) {
+	t, err := s.db.GetTaskById(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return encodeTask(t)
+}
+
+func (s *rpcServer) GetTasksFromDateRange(ctx context.Context, req *rpc.GetTasksFromDateRangeRequest) (*rpc.Tasks, error) {
+	start, err := time.Parse(time.RFC3339Nano, req.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse(time.RFC3339Nano, req.End)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.db.GetTasksFromDateRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return encodeTasks(tasks)
+}
+
+func (s *rpcServer) PutTask(ctx context.Context, req *rpc.Task) (*rpc.Task, error) {
+	t, err := decodeTask(req)
+	if err != nil {
+		return nil, err
+	}
+	if t == nil {
+		return nil, fmt.Errorf("Received empty task.")
+	}
+	if err := s.db.PutTask(t); err != nil {
+		return nil, err
+	}
+	return encodeTask(t)
+}
+
+func (s *rpcServer) PutTasks(ctx context.Context, req *rpc.Tasks) (*rpc.Tasks, error) {
+	tasks, err := decodeTasks(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.PutTasks(tasks); err != nil {
+		return nil, err
+	}
+	return encodeTasks(tasks)
+}
+
+func (s *rpcServer) StartTrackingModifiedTasks(ctx context.Context, req *rpc.Empty) (*rpc.StartTrackingModifiedTasksResponse, error) {
+	id, err := s.db.StartTrackingModifiedTasks()
+	if err != nil {
+		return nil, err
+	}
+	return &rpc.StartTrackingModifiedTasksResponse{
+		Id: id,
+	}, nil
+}
+
+// encodeJob GOB-encodes the given Job into an rpc.Job. A nil Job results in an
+// empty rpc.Job.
+func encodeJob(j *db.Job) (*rpc.Job, error) {
+	if j == nil {
+		return &rpc.Job{}, nil
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(j); err != nil {
+		return nil, err
+	}
+	return &rpc.Job{
+		Job: buf.Bytes(),
+	}, nil
+}
+
+// decodeJob decodes the given rpc.Job. An empty rpc.Job results in a nil Job.
+func decodeJob(j *rpc.Job) (*db.Job, error) {
+	if len(j.Job) == 0 {
+		return nil, nil
+	}
+	return db.DecodeJob(j.Job)
+}
+
+// encodeJobs GOB-encodes the given Jobs into an rpc.Jobs.
+func encodeJobs(jobs []*db.Job) (*rpc.Jobs, error) {
+	rv := &rpc.Jobs{
+		Jobs: make([]*rpc.Job, 0, len(jobs)),
+	}
+	for _, j := range jobs {
+		enc, err := encodeJob(j)
+		if err != nil {
+			return nil, err
+		}
+		rv.Jobs = append(rv.Jobs, enc)
+	}
+	return rv, nil
+}
+
+// decodeJobs decodes the given rpc.Jobs.
+func decodeJobs(jobs *rpc.Jobs) ([]*db.Job, error) {
+	rv := make([]*db.Job, 0, len(jobs.Jobs))
+	for _, j := range jobs.Jobs {
+		dec, err := decodeJob(j)
+		if err != nil {
+			return nil, err
+		}
+		if dec == nil {
+			return nil, fmt.Errorf("Received empty job.")
+		}
+		rv = append(rv, dec)
+	}
+	return rv, nil
+}
+
+func (s *rpcServer) GetJobById(ctx context.Context, req *rpc.GetJobByIdRequest) (*rpc.Job, error) {
+	j, err := s.db.GetJobById(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return encodeJob(j)
+}
+
+func (s *rpcServer) GetJobsFromDateRange(ctx context.Context, req *rpc.GetJobsFromDateRangeRequest) (*rpc.Jobs, error) {
+	start, err := time.Parse(time.RFC3339Nano, req.Start)
+	if err != nil {
+		return nil, err
+	}
+	end, err := time.Parse(time.RFC3339Nano, req.End)
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := s.db.GetJobsFromDateRange(start, end)
+	if err != nil {
+		return nil, err
+	}
+	return encodeJobs(jobs)
+}
+
+func (s *rpcServer) GetModifiedJobs(ctx context.Context, req *rpc.GetModifiedJobsRequest) (*rpc.Jobs, error) {
+	jobs, err := s.db.GetModifiedJobs(req.Id)
+	if err != nil {
+		return nil, err
+	}
+	return encodeJobs(jobs)
+}
+
+func (s *rpcServer) PutJob(ctx context.Context, req *rpc.Job) (*rpc.Job, error) {
+	j, err := decodeJob(req)
+	if err != nil {
+		return nil, err
+	}
+	if j == nil {
+		return nil, fmt.Errorf("Received empty job.")
+	}
+	if err := s.db.PutJob(j); err != nil {
+		return nil, err
+	}
+	return encodeJob(j)
+}
+
+func (s *rpcServer) PutJobs(ctx context.Context, req *rpc.Jobs) (*rpc.Jobs, error) {
+	jobs, err := decodeJobs(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.db.PutJobs(jobs); err != nil {
+		return nil, err
+	}
+	return encodeJobs(jobs)
+}
+
+func (s *rpcServer) StartTrackingModifiedJobs(ctx context.Context, req *rpc.Empty) (*rpc.StartTrackingModifiedJobsResponse, error) {
+	id, err := s.db.StartTrackingModifiedJobs()
+	if err != nil {
+		return nil, err
+	}
+	return &rpc.StartTrackingModifiedJobsResponse{
+		Id: id,
+	}, nil
+}
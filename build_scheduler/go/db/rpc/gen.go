@@ -0,0 +1,3 @@
+package rpc
+
+//go:generate protoc --go_out=plugins=grpc:. task_db.proto
@@ -0,0 +1,702 @@
+// Code generated by protoc-gen-go.
+// source: task_db.proto
+// DO NOT EDIT!
+
+/*
+Package rpc is a generated protocol buffer package.
+
+It is generated from these files:
+	task_db.proto
+
+It has these top-level messages:
+	Empty
+	Task
+	Tasks
+	GetModifiedTasksRequest
+	GetTaskByIdRequest
+	GetTasksFromDateRangeRequest
+	StartTrackingModifiedTasksResponse
+	Job
+	Jobs
+	GetJobByIdRequest
+	GetJobsFromDateRangeRequest
+	GetModifiedJobsRequest
+	StartTrackingModifiedJobsResponse
+*/
+package rpc
+
+import proto "github.com/golang/protobuf/proto"
+import fmt "fmt"
+import math "math"
+
+import (
+	context "golang.org/x/net/context"
+	grpc "google.golang.org/grpc"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion2 // please upgrade the proto package
+
+type Empty struct {
+}
+
+func (m *Empty) Reset()                    { *m = Empty{} }
+func (m *Empty) String() string            { return proto.CompactTextString(m) }
+func (*Empty) ProtoMessage()               {}
+func (*Empty) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{0} }
+
+type Task struct {
+	Task []byte `protobuf:"bytes,1,opt,name=task,proto3" json:"task,omitempty"`
+}
+
+func (m *Task) Reset()                    { *m = Task{} }
+func (m *Task) String() string            { return proto.CompactTextString(m) }
+func (*Task) ProtoMessage()               {}
+func (*Task) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{1} }
+
+type Tasks struct {
+	Tasks []*Task `protobuf:"bytes,1,rep,name=tasks" json:"tasks,omitempty"`
+}
+
+func (m *Tasks) Reset()                    { *m = Tasks{} }
+func (m *Tasks) String() string            { return proto.CompactTextString(m) }
+func (*Tasks) ProtoMessage()               {}
+func (*Tasks) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{2} }
+
+func (m *Tasks) GetTasks() []*Task {
+	if m != nil {
+		return m.Tasks
+	}
+	return nil
+}
+
+type GetModifiedTasksRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *GetModifiedTasksRequest) Reset()                    { *m = GetModifiedTasksRequest{} }
+func (m *GetModifiedTasksRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetModifiedTasksRequest) ProtoMessage()               {}
+func (*GetModifiedTasksRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{3} }
+
+type GetTaskByIdRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *GetTaskByIdRequest) Reset()                    { *m = GetTaskByIdRequest{} }
+func (m *GetTaskByIdRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetTaskByIdRequest) ProtoMessage()               {}
+func (*GetTaskByIdRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{4} }
+
+type GetTasksFromDateRangeRequest struct {
+	Start string `protobuf:"bytes,1,opt,name=start" json:"start,omitempty"`
+	End   string `protobuf:"bytes,2,opt,name=end" json:"end,omitempty"`
+}
+
+func (m *GetTasksFromDateRangeRequest) Reset()                    { *m = GetTasksFromDateRangeRequest{} }
+func (m *GetTasksFromDateRangeRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetTasksFromDateRangeRequest) ProtoMessage()               {}
+func (*GetTasksFromDateRangeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{5} }
+
+type StartTrackingModifiedTasksResponse struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *StartTrackingModifiedTasksResponse) Reset()         { *m = StartTrackingModifiedTasksResponse{} }
+func (m *StartTrackingModifiedTasksResponse) String() string { return proto.CompactTextString(m) }
+func (*StartTrackingModifiedTasksResponse) ProtoMessage()    {}
+func (*StartTrackingModifiedTasksResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{6}
+}
+
+type Job struct {
+	Job []byte `protobuf:"bytes,1,opt,name=job,proto3" json:"job,omitempty"`
+}
+
+func (m *Job) Reset()                    { *m = Job{} }
+func (m *Job) String() string            { return proto.CompactTextString(m) }
+func (*Job) ProtoMessage()               {}
+func (*Job) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{7} }
+
+type Jobs struct {
+	Jobs []*Job `protobuf:"bytes,1,rep,name=jobs" json:"jobs,omitempty"`
+}
+
+func (m *Jobs) Reset()                    { *m = Jobs{} }
+func (m *Jobs) String() string            { return proto.CompactTextString(m) }
+func (*Jobs) ProtoMessage()               {}
+func (*Jobs) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{8} }
+
+func (m *Jobs) GetJobs() []*Job {
+	if m != nil {
+		return m.Jobs
+	}
+	return nil
+}
+
+type GetJobByIdRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *GetJobByIdRequest) Reset()                    { *m = GetJobByIdRequest{} }
+func (m *GetJobByIdRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetJobByIdRequest) ProtoMessage()               {}
+func (*GetJobByIdRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{9} }
+
+type GetJobsFromDateRangeRequest struct {
+	Start string `protobuf:"bytes,1,opt,name=start" json:"start,omitempty"`
+	End   string `protobuf:"bytes,2,opt,name=end" json:"end,omitempty"`
+}
+
+func (m *GetJobsFromDateRangeRequest) Reset()                    { *m = GetJobsFromDateRangeRequest{} }
+func (m *GetJobsFromDateRangeRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetJobsFromDateRangeRequest) ProtoMessage()               {}
+func (*GetJobsFromDateRangeRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{10} }
+
+type GetModifiedJobsRequest struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *GetModifiedJobsRequest) Reset()                    { *m = GetModifiedJobsRequest{} }
+func (m *GetModifiedJobsRequest) String() string            { return proto.CompactTextString(m) }
+func (*GetModifiedJobsRequest) ProtoMessage()               {}
+func (*GetModifiedJobsRequest) Descriptor() ([]byte, []int) { return fileDescriptor0, []int{11} }
+
+type StartTrackingModifiedJobsResponse struct {
+	Id string `protobuf:"bytes,1,opt,name=id" json:"id,omitempty"`
+}
+
+func (m *StartTrackingModifiedJobsResponse) Reset()         { *m = StartTrackingModifiedJobsResponse{} }
+func (m *StartTrackingModifiedJobsResponse) String() string { return proto.CompactTextString(m) }
+func (*StartTrackingModifiedJobsResponse) ProtoMessage()    {}
+func (*StartTrackingModifiedJobsResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor0, []int{12}
+}
+
+func init() {
+	proto.RegisterType((*Empty)(nil), "rpc.Empty")
+	proto.RegisterType((*Task)(nil), "rpc.Task")
+	proto.RegisterType((*Tasks)(nil), "rpc.Tasks")
+	proto.RegisterType((*GetModifiedTasksRequest)(nil), "rpc.GetModifiedTasksRequest")
+	proto.RegisterType((*GetTaskByIdRequest)(nil), "rpc.GetTaskByIdRequest")
+	proto.RegisterType((*GetTasksFromDateRangeRequest)(nil), "rpc.GetTasksFromDateRangeRequest")
+	proto.RegisterType((*StartTrackingModifiedTasksResponse)(nil), "rpc.StartTrackingModifiedTasksResponse")
+	proto.RegisterType((*Job)(nil), "rpc.Job")
+	proto.RegisterType((*Jobs)(nil), "rpc.Jobs")
+	proto.RegisterType((*GetJobByIdRequest)(nil), "rpc.GetJobByIdRequest")
+	proto.RegisterType((*GetJobsFromDateRangeRequest)(nil), "rpc.GetJobsFromDateRangeRequest")
+	proto.RegisterType((*GetModifiedJobsRequest)(nil), "rpc.GetModifiedJobsRequest")
+	proto.RegisterType((*StartTrackingModifiedJobsResponse)(nil), "rpc.StartTrackingModifiedJobsResponse")
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion3
+
+// Client API for TaskDB service
+
+type TaskDBClient interface {
+	AssignId(ctx context.Context, in *Task, opts ...grpc.CallOption) (*Task, error)
+	GetModifiedTasks(ctx context.Context, in *GetModifiedTasksRequest, opts ...grpc.CallOption) (*Tasks, error)
+	GetTaskById(ctx context.Context, in *GetTaskByIdRequest, opts ...grpc.CallOption) (*Task, error)
+	GetTasksFromDateRange(ctx context.Context, in *GetTasksFromDateRangeRequest, opts ...grpc.CallOption) (*Tasks, error)
+	PutTask(ctx context.Context, in *Task, opts ...grpc.CallOption) (*Task, error)
+	PutTasks(ctx context.Context, in *Tasks, opts ...grpc.CallOption) (*Tasks, error)
+	StartTrackingModifiedTasks(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StartTrackingModifiedTasksResponse, error)
+	GetJobById(ctx context.Context, in *GetJobByIdRequest, opts ...grpc.CallOption) (*Job, error)
+	GetJobsFromDateRange(ctx context.Context, in *GetJobsFromDateRangeRequest, opts ...grpc.CallOption) (*Jobs, error)
+	GetModifiedJobs(ctx context.Context, in *GetModifiedJobsRequest, opts ...grpc.CallOption) (*Jobs, error)
+	PutJob(ctx context.Context, in *Job, opts ...grpc.CallOption) (*Job, error)
+	PutJobs(ctx context.Context, in *Jobs, opts ...grpc.CallOption) (*Jobs, error)
+	StartTrackingModifiedJobs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StartTrackingModifiedJobsResponse, error)
+}
+
+type taskDBClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewTaskDBClient(cc *grpc.ClientConn) TaskDBClient {
+	return &taskDBClient{cc}
+}
+
+func (c *taskDBClient) AssignId(ctx context.Context, in *Task, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/AssignId", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) GetModifiedTasks(ctx context.Context, in *GetModifiedTasksRequest, opts ...grpc.CallOption) (*Tasks, error) {
+	out := new(Tasks)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/GetModifiedTasks", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) GetTaskById(ctx context.Context, in *GetTaskByIdRequest, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/GetTaskById", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) GetTasksFromDateRange(ctx context.Context, in *GetTasksFromDateRangeRequest, opts ...grpc.CallOption) (*Tasks, error) {
+	out := new(Tasks)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/GetTasksFromDateRange", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) PutTask(ctx context.Context, in *Task, opts ...grpc.CallOption) (*Task, error) {
+	out := new(Task)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/PutTask", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) PutTasks(ctx context.Context, in *Tasks, opts ...grpc.CallOption) (*Tasks, error) {
+	out := new(Tasks)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/PutTasks", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) StartTrackingModifiedTasks(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StartTrackingModifiedTasksResponse, error) {
+	out := new(StartTrackingModifiedTasksResponse)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/StartTrackingModifiedTasks", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) GetJobById(ctx context.Context, in *GetJobByIdRequest, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/GetJobById", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) GetJobsFromDateRange(ctx context.Context, in *GetJobsFromDateRangeRequest, opts ...grpc.CallOption) (*Jobs, error) {
+	out := new(Jobs)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/GetJobsFromDateRange", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) GetModifiedJobs(ctx context.Context, in *GetModifiedJobsRequest, opts ...grpc.CallOption) (*Jobs, error) {
+	out := new(Jobs)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/GetModifiedJobs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) PutJob(ctx context.Context, in *Job, opts ...grpc.CallOption) (*Job, error) {
+	out := new(Job)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/PutJob", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) PutJobs(ctx context.Context, in *Jobs, opts ...grpc.CallOption) (*Jobs, error) {
+	out := new(Jobs)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/PutJobs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *taskDBClient) StartTrackingModifiedJobs(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*StartTrackingModifiedJobsResponse, error) {
+	out := new(StartTrackingModifiedJobsResponse)
+	err := grpc.Invoke(ctx, "/rpc.TaskDB/StartTrackingModifiedJobs", in, out, c.cc, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Server API for TaskDB service
+
+type TaskDBServer interface {
+	AssignId(context.Context, *Task) (*Task, error)
+	GetModifiedTasks(context.Context, *GetModifiedTasksRequest) (*Tasks, error)
+	GetTaskById(context.Context, *GetTaskByIdRequest) (*Task, error)
+	GetTasksFromDateRange(context.Context, *GetTasksFromDateRangeRequest) (*Tasks, error)
+	PutTask(context.Context, *Task) (*Task, error)
+	PutTasks(context.Context, *Tasks) (*Tasks, error)
+	StartTrackingModifiedTasks(context.Context, *Empty) (*StartTrackingModifiedTasksResponse, error)
+	GetJobById(context.Context, *GetJobByIdRequest) (*Job, error)
+	GetJobsFromDateRange(context.Context, *GetJobsFromDateRangeRequest) (*Jobs, error)
+	GetModifiedJobs(context.Context, *GetModifiedJobsRequest) (*Jobs, error)
+	PutJob(context.Context, *Job) (*Job, error)
+	PutJobs(context.Context, *Jobs) (*Jobs, error)
+	StartTrackingModifiedJobs(context.Context, *Empty) (*StartTrackingModifiedJobsResponse, error)
+}
+
+func RegisterTaskDBServer(s *grpc.Server, srv TaskDBServer) {
+	s.RegisterService(&_TaskDB_serviceDesc, srv)
+}
+
+func _TaskDB_AssignId_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Task)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).AssignId(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/AssignId",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).AssignId(ctx, req.(*Task))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_GetModifiedTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetModifiedTasksRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).GetModifiedTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/GetModifiedTasks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).GetModifiedTasks(ctx, req.(*GetModifiedTasksRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_GetTaskById_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTaskByIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).GetTaskById(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/GetTaskById",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).GetTaskById(ctx, req.(*GetTaskByIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_GetTasksFromDateRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetTasksFromDateRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).GetTasksFromDateRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/GetTasksFromDateRange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).GetTasksFromDateRange(ctx, req.(*GetTasksFromDateRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_PutTask_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Task)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).PutTask(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/PutTask",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).PutTask(ctx, req.(*Task))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_PutTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Tasks)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).PutTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/PutTasks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).PutTasks(ctx, req.(*Tasks))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_StartTrackingModifiedTasks_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).StartTrackingModifiedTasks(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/StartTrackingModifiedTasks",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).StartTrackingModifiedTasks(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_GetJobById_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobByIdRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).GetJobById(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/GetJobById",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).GetJobById(ctx, req.(*GetJobByIdRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_GetJobsFromDateRange_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetJobsFromDateRangeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).GetJobsFromDateRange(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/GetJobsFromDateRange",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).GetJobsFromDateRange(ctx, req.(*GetJobsFromDateRangeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_GetModifiedJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetModifiedJobsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).GetModifiedJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/GetModifiedJobs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).GetModifiedJobs(ctx, req.(*GetModifiedJobsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_PutJob_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Job)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).PutJob(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/PutJob",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).PutJob(ctx, req.(*Job))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_PutJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Jobs)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).PutJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/PutJobs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).PutJobs(ctx, req.(*Jobs))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _TaskDB_StartTrackingModifiedJobs_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(TaskDBServer).StartTrackingModifiedJobs(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/rpc.TaskDB/StartTrackingModifiedJobs",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(TaskDBServer).StartTrackingModifiedJobs(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _TaskDB_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "rpc.TaskDB",
+	HandlerType: (*TaskDBServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "AssignId",
+			Handler:    _TaskDB_AssignId_Handler,
+		},
+		{
+			MethodName: "GetModifiedTasks",
+			Handler:    _TaskDB_GetModifiedTasks_Handler,
+		},
+		{
+			MethodName: "GetTaskById",
+			Handler:    _TaskDB_GetTaskById_Handler,
+		},
+		{
+			MethodName: "GetTasksFromDateRange",
+			Handler:    _TaskDB_GetTasksFromDateRange_Handler,
+		},
+		{
+			MethodName: "PutTask",
+			Handler:    _TaskDB_PutTask_Handler,
+		},
+		{
+			MethodName: "PutTasks",
+			Handler:    _TaskDB_PutTasks_Handler,
+		},
+		{
+			MethodName: "StartTrackingModifiedTasks",
+			Handler:    _TaskDB_StartTrackingModifiedTasks_Handler,
+		},
+		{
+			MethodName: "GetJobById",
+			Handler:    _TaskDB_GetJobById_Handler,
+		},
+		{
+			MethodName: "GetJobsFromDateRange",
+			Handler:    _TaskDB_GetJobsFromDateRange_Handler,
+		},
+		{
+			MethodName: "GetModifiedJobs",
+			Handler:    _TaskDB_GetModifiedJobs_Handler,
+		},
+		{
+			MethodName: "PutJob",
+			Handler:    _TaskDB_PutJob_Handler,
+		},
+		{
+			MethodName: "PutJobs",
+			Handler:    _TaskDB_PutJobs_Handler,
+		},
+		{
+			MethodName: "StartTrackingModifiedJobs",
+			Handler:    _TaskDB_StartTrackingModifiedJobs_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: fileDescriptor0,
+}
+
+func init() { proto.RegisterFile("task_db.proto", fileDescriptor0) }
+
+var fileDescriptor0 = []byte{
+	// 473 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x09, 0x6e, 0x88, 0x02, 0xff, 0xa5, 0x54, 0x4d, 0x4f, 0x83, 0x40,
+	0x14, 0x54, 0x5b, 0x6a, 0x3b, 0x7e, 0xbf, 0xa8, 0x55, 0x6c, 0xd4, 0xae, 0x46, 0xeb, 0xa5, 0x31,
+	0xd6, 0x9b, 0x89, 0x89, 0xc6, 0xfa, 0x95, 0x98, 0x28, 0x7a, 0x37, 0x54, 0xb0, 0x41, 0x23, 0x54,
+	0x16, 0x0f, 0xfe, 0x50, 0xff, 0x8f, 0xbb, 0x0b, 0xb4, 0x80, 0xa0, 0x07, 0x6f, 0x6f, 0xf7, 0xcd,
+	0xcc, 0xbe, 0x37, 0x1d, 0x8a, 0x99, 0xc0, 0xe4, 0xaf, 0x8f, 0x56, 0xaf, 0x3d, 0xf0, 0xbd, 0xc0,
+	0xa3, 0x92, 0x3f, 0x78, 0x62, 0x93, 0xd0, 0xba, 0x6f, 0x83, 0xe0, 0x93, 0xe9, 0x28, 0x3f, 0x88,
+	0x36, 0x11, 0xca, 0x12, 0xb6, 0x32, 0xbe, 0x39, 0xde, 0x9a, 0x36, 0x54, 0xcd, 0x5a, 0xd0, 0x64,
+	0x8f, 0xd3, 0x06, 0x34, 0x79, 0xc1, 0x45, 0xb7, 0xd4, 0x9a, 0x3a, 0xa8, 0xb5, 0x85, 0x44, 0x5b,
+	0xb6, 0x8c, 0xf0, 0x9e, 0xed, 0xa1, 0x7e, 0x61, 0x07, 0x37, 0x9e, 0xe5, 0x3c, 0x3b, 0xb6, 0xa5,
+	0x48, 0x86, 0xfd, 0xfe, 0x61, 0xf3, 0x80, 0x66, 0x31, 0xe1, 0x58, 0x4a, 0xb6, 0x66, 0x88, 0x8a,
+	0x6d, 0x83, 0x04, 0x54, 0x42, 0x4e, 0x3f, 0xaf, 0xac, 0x22, 0xd4, 0x39, 0x1a, 0x11, 0x8a, 0x9f,
+	0xfb, 0xde, 0xdb, 0x99, 0x19, 0xd8, 0x86, 0xe9, 0xf6, 0xed, 0x18, 0xbf, 0x08, 0x8d, 0x07, 0xa6,
+	0x1f, 0x44, 0x94, 0xf0, 0x40, 0xf3, 0x28, 0xd9, 0xae, 0xb5, 0x32, 0xa1, 0xee, 0x64, 0xc9, 0x0e,
+	0xc1, 0xee, 0x65, 0xeb, 0xc1, 0x37, 0x9f, 0x5e, 0x1d, 0xb7, 0x9f, 0x19, 0x91, 0x0f, 0x3c, 0x97,
+	0xdb, 0x3f, 0x5e, 0xaf, 0xa3, 0x74, 0xed, 0xf5, 0xa4, 0xdc, 0x8b, 0xd7, 0x8b, 0x2c, 0x91, 0xa5,
+	0x18, 0xbe, 0x2c, 0x1a, 0x9c, 0x1a, 0x28, 0x8b, 0x63, 0xec, 0x47, 0x55, 0xf9, 0x21, 0x1a, 0x86,
+	0xba, 0x65, 0x5b, 0x58, 0x10, 0xc3, 0x8b, 0xf3, 0x6f, 0x1b, 0x76, 0xb1, 0x16, 0x82, 0xfe, 0xb7,
+	0x60, 0x0b, 0xcb, 0x09, 0xe7, 0xa5, 0x5c, 0xd1, 0x83, 0x1d, 0x34, 0x73, 0xad, 0x08, 0x39, 0xf9,
+	0x4e, 0x1c, 0x7c, 0x69, 0xa8, 0x48, 0xaf, 0xce, 0x4e, 0x89, 0xa1, 0x7a, 0xc2, 0xb9, 0xd3, 0x77,
+	0xaf, 0x2c, 0x1a, 0x25, 0x40, 0x1f, 0x95, 0x6c, 0x8c, 0x8e, 0x31, 0x9f, 0xcd, 0x01, 0x35, 0x14,
+	0xa0, 0x20, 0x1e, 0x3a, 0x86, 0x74, 0x2e, 0xf8, 0x1d, 0x4c, 0x25, 0xc2, 0x41, 0xf5, 0x98, 0x9a,
+	0x89, 0x4b, 0xfa, 0xd1, 0x4b, 0x2c, 0xe5, 0x66, 0x85, 0x9a, 0x49, 0x7a, 0xae, 0xcd, 0x99, 0xe7,
+	0x9b, 0x98, 0xbc, 0xfd, 0x50, 0xe8, 0xc2, 0x0d, 0xb7, 0x51, 0x8d, 0x20, 0x9c, 0x12, 0xe4, 0x8c,
+	0xd0, 0x3d, 0xf4, 0xe2, 0xd8, 0x45, 0x3c, 0xf5, 0xfd, 0xe9, 0xbb, 0xaa, 0xfe, 0x3b, 0xa3, 0x42,
+	0x74, 0x1f, 0x18, 0xc5, 0x8a, 0x96, 0xe3, 0xe5, 0xd2, 0x39, 0xd3, 0x87, 0x61, 0x14, 0x8c, 0x2e,
+	0x16, 0xf3, 0x32, 0x46, 0x9b, 0x09, 0x6e, 0xbe, 0x2f, 0xb5, 0x58, 0x45, 0x6e, 0x73, 0x84, 0xb9,
+	0x4c, 0xc6, 0x68, 0x2d, 0xfb, 0xa3, 0x26, 0x92, 0x97, 0x26, 0xaf, 0xa3, 0x22, 0x0c, 0x93, 0x9f,
+	0xd3, 0x70, 0xb2, 0xd4, 0x8c, 0xa1, 0xe7, 0x4a, 0x74, 0xc4, 0x4b, 0x4b, 0xdc, 0x61, 0xb5, 0x30,
+	0xb9, 0x29, 0x33, 0x77, 0x8a, 0xcd, 0x4c, 0xa6, 0x9c, 0x8d, 0xf5, 0x2a, 0xea, 0xbf, 0xb0, 0xf3,
+	0x0d, 0x6f, 0x8f, 0x88, 0x15, 0x1c, 0x05, 0x00, 0x00,
+}
@@ -27,9 +27,12 @@ const (
 
 	// Swarming tags added by Build Scheduler.
 	SWARMING_TAG_ID       = "scheduler_id"
+	SWARMING_TAG_ISSUE    = "issue"
 	SWARMING_TAG_NAME     = "name"
+	SWARMING_TAG_PATCHSET = "patchset"
 	SWARMING_TAG_REPO     = "repo"
 	SWARMING_TAG_REVISION = "revision"
+	SWARMING_TAG_SERVER   = "server"
 )
 
 type TaskStatus string
@@ -45,15 +48,22 @@ const (
 	// TASK_STATUS_FAILURE indicates the task completed with failures.
 	TASK_STATUS_FAILURE = "FAILURE"
 	// TASK_STATUS_MISHAP indicates the task exited early with an error, died
-	// while in progress, was manually canceled, expired while waiting on the
-	// queue, or timed out before completing.
+	// while in progress, was manually canceled, or timed out before completing.
 	TASK_STATUS_MISHAP = "MISHAP"
+	// TASK_STATUS_EXPIRED indicates the task expired while waiting on the
+	// queue and never ran.
+	TASK_STATUS_EXPIRED = "EXPIRED"
 )
 
 // Task describes a Swarming task generated from a TaskSpec, or a "fake" task
 // that can not be executed on Swarming, but can be added to the DB and
 // displayed as if it were a real TaskSpec.
 type Task struct {
+	// Attempt is the attempt number of this Task at its Revision, starting at
+	// zero. Retries of a failed Task have Attempt set to one more than the
+	// Task they retry.
+	Attempt int
+
 	// Commits are the commits which were tested in this Task. The list may
 	// change due to backfilling/bisecting.
 	Commits []string
@@ -65,10 +75,19 @@ type Task struct {
 	//  zero if the task is pending or running.
 	Finished time.Time
 
+	// Flaky indicates that this Task is a retry which succeeded after a
+	// previous attempt failed.
+	Flaky bool
+
 	// Id is a generated unique identifier for this Task instance. Must be
 	// URL-safe.
 	Id string
 
+	// Issue is the code review issue whose patch was applied for this Task, or
+	// empty if this Task is not a try job. Try jobs are not included in the
+	// blamelists of other Tasks.
+	Issue string
+
 	// IsolatedOutput is the isolated hash of any outputs produced by this Task.
 	// Filled in when the task is completed. We assume the isolate server is
 	// isolateserver.appspot.com and the namespace is default-gzip. This field
@@ -79,12 +98,25 @@ type Task struct {
 	// generated from the same TaskSpec have the same name.
 	Name string
 
+	// Patchset is the patchset of Issue which was applied for this Task, or
+	// empty if this Task is not a try job.
+	Patchset string
+
 	// Repo is the repository of the commit at which this task ran.
 	Repo string
 
-	// Revision is the commit at which this task ran.
+	// RetryOf is the Id of the previous attempt which this Task retries, or
+	// empty if this Task is not a retry.
+	RetryOf string
+
+	// Revision is the commit at which this task ran. For try jobs, this is the
+	// commit to which the patch was applied.
 	Revision string
 
+	// Server is the URL of the code review server which hosts Issue, or empty
+	// if this Task is not a try job.
+	Server string
+
 	// Started is the time the task started running, or zero if the task is
 	// pending, or the same as Finished if the task never ran.
 	Started time.Time
@@ -100,11 +132,12 @@ type Task struct {
 // UpdateFromSwarming sets or initializes t from data in s. If any changes were
 // made to t, returns true.
 //
-// If empty, sets t.Id, t.Name, t.Repo, and t.Revision from s's tags named
-// SWARMING_TAG_ID, SWARMING_TAG_NAME, SWARMING_TAG_REPO, and
-// SWARMING_TAG_REVISION, sets t.Created from s.TaskResult.CreatedTs, and sets
-// t.SwarmingTaskId from s.TaskId. If these fields are non-empty, returns an
-// error if they do not match.
+// If empty, sets t.Id, t.Name, t.Repo, t.Revision, t.Issue, t.Patchset, and
+// t.Server from s's tags named SWARMING_TAG_ID, SWARMING_TAG_NAME,
+// SWARMING_TAG_REPO, SWARMING_TAG_REVISION, SWARMING_TAG_ISSUE,
+// SWARMING_TAG_PATCHSET, and SWARMING_TAG_SERVER, sets t.Created from
+// s.TaskResult.CreatedTs, and sets t.SwarmingTaskId from s.TaskId. If these
+// fields are non-empty, returns an error if they do not match.
 //
 // Always sets t.Status, t.Started, t.Finished, and t.IsolatedOutput based on s.
 func (orig *Task) UpdateFromSwarming(s *swarming_api.SwarmingRpcsTaskRequestMetadata) (bool, error) {
@@ -144,6 +177,15 @@ func (orig *Task) UpdateFromSwarming(s *swarming_api.SwarmingRpcsTaskRequestMeta
 	if err := checkOrSetFromTag(SWARMING_TAG_REVISION, &copy.Revision, "Revision"); err != nil {
 		return false, err
 	}
+	if err := checkOrSetFromTag(SWARMING_TAG_ISSUE, &copy.Issue, "Issue"); err != nil {
+		return false, err
+	}
+	if err := checkOrSetFromTag(SWARMING_TAG_PATCHSET, &copy.Patchset, "Patchset"); err != nil {
+		return false, err
+	}
+	if err := checkOrSetFromTag(SWARMING_TAG_SERVER, &copy.Server, "Server"); err != nil {
+		return false, err
+	}
 
 	// CreatedTs should always be present.
 	if sCreated, err := swarming.ParseTimestamp(s.TaskResult.CreatedTs); err == nil {
@@ -165,8 +207,10 @@ func (orig *Task) UpdateFromSwarming(s *swarming_api.SwarmingRpcsTaskRequestMeta
 
 	// Status.
 	switch s.TaskResult.State {
-	case SWARMING_STATE_BOT_DIED, SWARMING_STATE_CANCELED, SWARMING_STATE_EXPIRED, SWARMING_STATE_TIMED_OUT:
+	case SWARMING_STATE_BOT_DIED, SWARMING_STATE_CANCELED, SWARMING_STATE_TIMED_OUT:
 		copy.Status = TASK_STATUS_MISHAP
+	case SWARMING_STATE_EXPIRED:
+		copy.Status = TASK_STATUS_EXPIRED
 	case SWARMING_STATE_PENDING:
 		copy.Status = TASK_STATUS_PENDING
 	case SWARMING_STATE_RUNNING:
@@ -208,7 +252,7 @@ func (orig *Task) UpdateFromSwarming(s *swarming_api.SwarmingRpcsTaskRequestMeta
 	if err := maybeUpdateTime(s.TaskResult.CompletedTs, &copy.Finished, "CompletedTs"); err != nil {
 		return false, err
 	}
-	if s.TaskResult.CompletedTs == "" && copy.Status == TASK_STATUS_MISHAP {
+	if s.TaskResult.CompletedTs == "" && (copy.Status == TASK_STATUS_MISHAP || copy.Status == TASK_STATUS_EXPIRED) {
 		if err := maybeUpdateTime(s.TaskResult.AbandonedTs, &copy.Finished, "AbandonedTs"); err != nil {
 			return false, err
 		}
@@ -237,6 +281,27 @@ func (t *Task) Success() bool {
 	return t.Status == TASK_STATUS_SUCCESS
 }
 
+// IsTryJob returns true iff the Task ran with a patch applied.
+func (t *Task) IsTryJob() bool {
+	return t.Issue != ""
+}
+
+// PatchKey returns a string which identifies the patch applied for this Task.
+// Only valid for try jobs.
+func (t *Task) PatchKey() string {
+	return MakePatchKey(t.Server, t.Issue, t.Patchset)
+}
+
+// MakePatchKey returns a string which identifies the given patch.
+func MakePatchKey(server, issue, patchset string) string {
+	return fmt.Sprintf("%s/%s/%s", server, issue, patchset)
+}
+
+// IsRetry returns true iff the Task is a retry of a previous attempt.
+func (t *Task) IsRetry() bool {
+	return t.RetryOf != ""
+}
+
 func (t *Task) Copy() *Task {
 	var buf bytes.Buffer
 	if err := gob.NewEncoder(&buf).Encode(t); err != nil {
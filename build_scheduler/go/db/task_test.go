@@ -203,7 +203,7 @@ func TestUpdateFromSwarmingInit(t *testing.T) {
 		Commits:        nil,
 		Started:        now.Add(-time.Hour),
 		Finished:       now.Add(-time.Minute),
-		Status:         TASK_STATUS_MISHAP,
+		Status:         TASK_STATUS_EXPIRED,
 		SwarmingTaskId: "E",
 		IsolatedOutput: "F",
 	})
@@ -299,7 +299,7 @@ func TestUpdateFromSwarmingUpdate(t *testing.T) {
 		Commits:        []string{"D", "Z"},
 		Started:        now.Add(-2 * time.Minute),
 		Finished:       now.Add(-90 * time.Second),
-		Status:         TASK_STATUS_MISHAP,
+		Status:         TASK_STATUS_EXPIRED,
 		SwarmingTaskId: "E",
 		IsolatedOutput: "G",
 	})
@@ -356,11 +356,14 @@ func TestUpdateFromSwarmingUpdateStatus(t *testing.T) {
 	s.TaskResult.State = SWARMING_STATE_RUNNING
 	testUpdateStatus(s, TASK_STATUS_RUNNING)
 
-	for _, state := range []string{SWARMING_STATE_BOT_DIED, SWARMING_STATE_CANCELED, SWARMING_STATE_EXPIRED, SWARMING_STATE_TIMED_OUT} {
+	for _, state := range []string{SWARMING_STATE_BOT_DIED, SWARMING_STATE_CANCELED, SWARMING_STATE_TIMED_OUT} {
 		s.TaskResult.State = state
 		testUpdateStatus(s, TASK_STATUS_MISHAP)
 	}
 
+	s.TaskResult.State = SWARMING_STATE_EXPIRED
+	testUpdateStatus(s, TASK_STATUS_EXPIRED)
+
 	s.TaskResult.State = SWARMING_STATE_COMPLETED
 	s.TaskResult.Failure = true
 	testUpdateStatus(s, TASK_STATUS_FAILURE)
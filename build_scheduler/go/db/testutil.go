@@ -169,3 +169,95 @@ func TestTooManyUsers(t *testing.T, db DB) {
 	_, err := db.StartTrackingModifiedTasks()
 	assert.True(t, IsTooManyUsers(err))
 }
+
+func makeJob(ts time.Time) *Job {
+	return &Job{
+		Created:  ts,
+		Name:     "Test-Job",
+		Repo:     "skia.git",
+		Revision: "a",
+	}
+}
+
+func TestJobDB(t *testing.T, db DB) {
+	defer testutils.AssertCloses(t, db)
+
+	_, err := db.GetModifiedJobs("dummy-id")
+	assert.True(t, IsUnknownId(err))
+
+	id, err := db.StartTrackingModifiedJobs()
+	assert.NoError(t, err)
+
+	jobs, err := db.GetModifiedJobs(id)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(jobs))
+
+	// Created must be set.
+	assert.Error(t, db.PutJob(makeJob(time.Time{})))
+
+	now := time.Now().Add(time.Nanosecond)
+	j1 := makeJob(now)
+
+	// Insert the job. PutJob should fill in j1.Id.
+	assert.NoError(t, db.PutJob(j1))
+	assert.NotEqual(t, "", j1.Id)
+	// Ids must be URL-safe.
+	assert.Equal(t, url.QueryEscape(j1.Id), j1.Id)
+
+	// Job can now be retrieved by Id.
+	j1Again, err := db.GetJobById(j1.Id)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, j1, j1Again)
+
+	// Ensure that the job shows up in the modified list.
+	jobs, err = db.GetModifiedJobs(id)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*Job{j1}, jobs)
+
+	// Insert two more jobs.
+	j2 := makeJob(now.Add(time.Nanosecond))
+	j3 := makeJob(now.Add(2 * time.Nanosecond))
+	assert.NoError(t, db.PutJobs([]*Job{j2, j3}))
+	assert.NotEqual(t, "", j2.Id)
+	assert.NotEqual(t, "", j3.Id)
+
+	// Ensure that both jobs show up in the modified list.
+	jobs, err = db.GetModifiedJobs(id)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*Job{j2, j3}, jobs)
+
+	// Update a job.
+	j2.Status = JOB_STATUS_SUCCESS
+	j2.Finished = now.Add(time.Minute)
+	assert.NoError(t, db.PutJob(j2))
+	j2Again, err := db.GetJobById(j2.Id)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, j2, j2Again)
+	jobs, err = db.GetModifiedJobs(id)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*Job{j2}, jobs)
+
+	// Ensure that all jobs show up in the correct time ranges, in sorted order.
+	timeStart := time.Time{}
+	timeEnd := now.Add(3 * time.Nanosecond)
+
+	jobs, err = db.GetJobsFromDateRange(timeStart, j1.Created)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(jobs))
+
+	jobs, err = db.GetJobsFromDateRange(timeStart, j2.Created)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*Job{j1}, jobs)
+
+	jobs, err = db.GetJobsFromDateRange(timeStart, timeEnd)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*Job{j1, j2, j3}, jobs)
+
+	jobs, err = db.GetJobsFromDateRange(j2.Created, j3.Created)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*Job{j2}, jobs)
+
+	jobs, err = db.GetJobsFromDateRange(j3.Created.Add(time.Nanosecond), timeEnd)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []*Job{}, jobs)
+}
@@ -0,0 +1,122 @@
+package main
+
+/*
+	Simulates the task scheduler over a range of historical commits, using a
+	synthetic bot pool and task durations. Useful for comparing scheduling
+	policies offline.
+
+	The config file is JSON, eg:
+
+	{
+		"bots": [
+			{"count": 10, "dimensions": ["pool:Skia", "os:Ubuntu"]},
+			{"count": 2, "dimensions": ["pool:Skia", "os:Android", "device_type:grouper"]}
+		],
+		"default_task_duration": "20m",
+		"task_durations": {
+			"Build-Ubuntu-GCC-Arm7-Release-Android": "45m"
+		},
+		"time_decay_amt_24hr": 0.9
+	}
+*/
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path"
+	"time"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/build_scheduler/go/task_scheduler"
+	"go.skia.org/infra/go/common"
+	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/human"
+)
+
+var (
+	configFile = flag.String("config", "", "JSON file describing the simulated bot pool and task durations.")
+	end        = flag.String("end", "", "End of the simulation, in RFC3339 format. Defaults to the current time.")
+	repoName   = flag.String("repo", common.REPO_SKIA, "Repo URL to simulate. Must already be checked out in workdir.")
+	start      = flag.String("start", "", "Start of the simulation, in RFC3339 format. Required.")
+	tick       = flag.Duration("tick", time.Minute, "Simulated time between scheduling loops.")
+	timePeriod = flag.String("timePeriod", "4d", "Time period over which commits are scheduled.")
+	workdir    = flag.String("workdir", "workdir", "Working directory containing the repo checkout.")
+)
+
+// simConfigFile is the format of the config file.
+type simConfigFile struct {
+	Bots                []*task_scheduler.SimBots `json:"bots"`
+	DefaultTaskDuration string                    `json:"default_task_duration"`
+	TaskDurations       map[string]string         `json:"task_durations"`
+	TimeDecayAmt24Hr    float64                   `json:"time_decay_amt_24hr"`
+}
+
+func main() {
+	common.Init()
+	defer common.LogPanic()
+
+	if *configFile == "" {
+		glog.Fatal("--config is required.")
+	}
+
+	// Read the config file.
+	f, err := os.Open(*configFile)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	var cfgFile simConfigFile
+	if err := json.NewDecoder(f).Decode(&cfgFile); err != nil {
+		glog.Fatalf("Failed to parse config file: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		glog.Fatal(err)
+	}
+	cfg := &task_scheduler.SimConfig{
+		Bots:             cfgFile.Bots,
+		End:              time.Now(),
+		Tick:             *tick,
+		TaskDurations:    make(map[string]time.Duration, len(cfgFile.TaskDurations)),
+		TimeDecayAmt24Hr: cfgFile.TimeDecayAmt24Hr,
+	}
+	cfg.DefaultTaskDuration, err = time.ParseDuration(cfgFile.DefaultTaskDuration)
+	if err != nil {
+		glog.Fatalf("Invalid default_task_duration: %s", err)
+	}
+	for name, d := range cfgFile.TaskDurations {
+		cfg.TaskDurations[name], err = time.ParseDuration(d)
+		if err != nil {
+			glog.Fatalf("Invalid duration for %s: %s", name, err)
+		}
+	}
+	cfg.Period, err = human.ParseDuration(*timePeriod)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	cfg.Start, err = time.Parse(time.RFC3339, *start)
+	if err != nil {
+		glog.Fatalf("Invalid --start: %s", err)
+	}
+	if *end != "" {
+		cfg.End, err = time.Parse(time.RFC3339, *end)
+		if err != nil {
+			glog.Fatalf("Invalid --end: %s", err)
+		}
+	}
+
+	// Use the existing checkout without syncing it.
+	repo, err := gitinfo.NewGitInfo(path.Join(*workdir, path.Base(*repoName)), false, true)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	repos := gitinfo.NewRepoMap(*workdir)
+	repos.Add(*repoName, repo)
+
+	result, err := task_scheduler.Simulate(repos, cfg)
+	if err != nil {
+		glog.Fatal(err)
+	}
+	if err := result.WriteReport(os.Stdout); err != nil {
+		glog.Fatal(err)
+	}
+}
@@ -0,0 +1,297 @@
+package task_scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/exec"
+	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/isolate"
+	"go.skia.org/infra/go/metrics2"
+	"go.skia.org/infra/go/util"
+)
+
+const (
+	// ISOLATE_DIR is the directory, relative to the repo root, which contains
+	// the isolate files referenced by TaskSpecs.
+	ISOLATE_DIR = "infra/bots"
+
+	// ISOLATE_OS_TYPE is the OS config variable passed to isolate. The
+	// inputs are isolated on the scheduler host, regardless of where the
+	// task runs.
+	ISOLATE_OS_TYPE = "linux"
+
+	// ISOLATE_CACHE_EXPIRATION is how long an isolated hash stays in the
+	// isolateCache after it was last used.
+	ISOLATE_CACHE_EXPIRATION = 14 * 24 * time.Hour
+)
+
+// isolateRequest describes the inputs to be isolated for a task candidate.
+type isolateRequest struct {
+	// Deps are the isolated hashes of the outputs of the candidate's
+	// dependencies. They are included in the resulting isolated.
+	Deps []string
+
+	// ExtraVars are passed to isolate as extra variables.
+	ExtraVars map[string]string
+
+	// IsolateFile is the path to the isolate file, relative to the repo root.
+	IsolateFile string
+
+	// Patch is the patch to apply before isolating, or empty if none.
+	Patch string
+
+	// PatchId uniquely identifies Patch, eg. "<server>/<issue>/<patchset>".
+	PatchId string
+
+	// Repo is the repository containing the files to isolate.
+	Repo string
+
+	// Revision is the commit at which to isolate the files.
+	Revision string
+}
+
+// key returns the key used to identify the request's isolated hash in the
+// isolateCache. Requests with equal keys produce the same isolated hash.
+func (r *isolateRequest) key() string {
+	vars := make([]string, 0, len(r.ExtraVars))
+	for k, v := range r.ExtraVars {
+		vars = append(vars, fmt.Sprintf("%s=%s", k, v))
+	}
+	sort.Strings(vars)
+	deps := make([]string, len(r.Deps))
+	copy(deps, r.Deps)
+	sort.Strings(deps)
+	return fmt.Sprintf("%s@%s+%s:%s?%s#%s", r.Repo, r.Revision, r.PatchId, r.IsolateFile, strings.Join(vars, "&"), strings.Join(deps, ","))
+}
+
+// checkoutKey identifies the checkout needed for the request; requests with
+// equal checkoutKeys can be isolated from the same checkout.
+func (r *isolateRequest) checkoutKey() string {
+	return fmt.Sprintf("%s@%s+%s", r.Repo, r.Revision, r.PatchId)
+}
+
+// isolateCacheEntry is an isolated hash in the isolateCache.
+type isolateCacheEntry struct {
+	Hash     string    `json:"hash"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+// isolateCache is a cache of isolated hashes, keyed by the repo, revision,
+// patch, isolate file, extra variables, and dependencies which produced them. Isolating
+// the same inputs always produces the same hash, but entries which have not
+// been used for ISOLATE_CACHE_EXPIRATION are removed so that the cache does
+// not grow forever. The cache is backed by a file so that it survives
+// restarts.
+type isolateCache struct {
+	// isolate isolates the given tasks, including the isolated hashes in
+	// their Deps, and returns their isolated hashes in the same order. It is
+	// normally isolate.Client.IsolateTasks.
+	isolate     func([]*isolate.Task) ([]string, error)
+	backingFile string
+	entries     map[string]*isolateCacheEntry
+	hits        *metrics2.Counter
+	misses      *metrics2.Counter
+	mtx         sync.Mutex
+	repos       *gitinfo.RepoMap
+}
+
+// newIsolateCache returns an isolateCache instance backed by the given file,
+// loading any existing entries. If the file can not be decoded, eg. because
+// it was written by an older version, the cache starts out empty.
+func newIsolateCache(file string, repos *gitinfo.RepoMap, isolateTasks func([]*isolate.Task) ([]string, error)) (*isolateCache, error) {
+	c := &isolateCache{
+		isolate:     isolateTasks,
+		backingFile: file,
+		entries:     map[string]*isolateCacheEntry{},
+		hits:        metrics2.GetCounter("isolate-cache-hits", nil),
+		misses:      metrics2.GetCounter("isolate-cache-misses", nil),
+		repos:       repos,
+	}
+	f, err := os.Open(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return c, c.writeOut()
+		}
+		return nil, err
+	}
+	defer util.Close(f)
+	if err := json.NewDecoder(f).Decode(&c.entries); err != nil {
+		glog.Warningf("Failed to decode isolate cache %s; starting with an empty cache: %s", file, err)
+		c.entries = map[string]*isolateCacheEntry{}
+	}
+	c.expire(time.Now())
+	return c, nil
+}
+
+// expire removes the entries which have not been used since
+// ISOLATE_CACHE_EXPIRATION before now. Assumes the caller holds a lock or has
+// exclusive access.
+func (c *isolateCache) expire(now time.Time) {
+	for k, e := range c.entries {
+		if e == nil || now.Sub(e.LastUsed) > ISOLATE_CACHE_EXPIRATION {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// writeOut writes the cache to its backing file. The cache is written to a
+// temporary file which then replaces the backing file, so that the backing
+// file is never left partially written. Assumes the caller holds a lock.
+func (c *isolateCache) writeOut() error {
+	f, err := ioutil.TempFile(path.Dir(c.backingFile), path.Base(c.backingFile))
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary file for isolate cache: %s", err)
+	}
+	if err := json.NewEncoder(f).Encode(c.entries); err != nil {
+		util.Close(f)
+		util.Remove(f.Name())
+		return fmt.Errorf("Failed to write isolate cache: %s", err)
+	}
+	if err := f.Close(); err != nil {
+		util.Remove(f.Name())
+		return fmt.Errorf("Failed to write isolate cache: %s", err)
+	}
+	if err := os.Rename(f.Name(), c.backingFile); err != nil {
+		util.Remove(f.Name())
+		return fmt.Errorf("Failed to replace isolate cache: %s", err)
+	}
+	return nil
+}
+
+// checkout creates a temporary checkout of the given repo at the given
+// revision, with the given patch applied if it is not empty. The caller is
+// responsible for removing the returned directory.
+func (c *isolateCache) checkout(repoName, revision, patch string) (string, error) {
+	repo, err := c.repos.Repo(repoName)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := ioutil.TempDir("", "isolate_checkout")
+	if err != nil {
+		return "", err
+	}
+	if _, err := exec.RunCwd(tmp, "git", "clone", "--shared", "--no-checkout", repo.Dir(), "."); err != nil {
+		util.RemoveAll(tmp)
+		return "", fmt.Errorf("Failed to create checkout: %s", err)
+	}
+	if _, err := exec.RunCwd(tmp, "git", "checkout", revision); err != nil {
+		util.RemoveAll(tmp)
+		return "", fmt.Errorf("Failed to check out %s: %s", revision, err)
+	}
+	if patch != "" {
+		if _, err := exec.RunCommand(&exec.Command{
+			Name:  "git",
+			Args:  []string{"apply", "-"},
+			Dir:   tmp,
+			Stdin: strings.NewReader(patch),
+		}); err != nil {
+			util.RemoveAll(tmp)
+			return "", fmt.Errorf("Failed to apply patch: %s", err)
+		}
+	}
+	return tmp, nil
+}
+
+// Isolate returns the isolated hash for each of the given requests. Cached
+// hashes are used where available; all other requests are de-duplicated and
+// isolated in a single batch. Expired entries are removed from the cache.
+func (c *isolateCache) Isolate(reqs []*isolateRequest) ([]string, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	now := time.Now()
+	c.expire(now)
+
+	// Find the requests which are not already cached. Requests with the same
+	// key only need to be isolated once.
+	misses := map[string]*isolateRequest{}
+	for _, r := range reqs {
+		k := r.key()
+		if e, ok := c.entries[k]; ok {
+			c.hits.Inc(1)
+			e.LastUsed = now
+		} else {
+			c.misses.Inc(1)
+			misses[k] = r
+		}
+	}
+
+	if len(misses) > 0 {
+		if err := c.isolateMisses(misses, now); err != nil {
+			return nil, err
+		}
+	}
+
+	rv := make([]string, 0, len(reqs))
+	for _, r := range reqs {
+		rv = append(rv, c.entries[r.key()].Hash)
+	}
+	return rv, nil
+}
+
+// isolateMisses isolates the given requests, keyed by isolateRequest.key(),
+// using one checkout per repo, revision, and patch and a single call to
+// isolate, and adds the results to the cache as used at the given time.
+// Assumes the caller holds a lock.
+func (c *isolateCache) isolateMisses(misses map[string]*isolateRequest, now time.Time) error {
+	keys := make([]string, 0, len(misses))
+	for k, _ := range misses {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	checkouts := map[string]string{}
+	defer func() {
+		for _, dir := range checkouts {
+			util.RemoveAll(dir)
+		}
+	}()
+	tasks := make([]*isolate.Task, 0, len(keys))
+	for _, k := range keys {
+		r := misses[k]
+		dir, ok := checkouts[r.checkoutKey()]
+		if !ok {
+			var err error
+			dir, err = c.checkout(r.Repo, r.Revision, r.Patch)
+			if err != nil {
+				return err
+			}
+			checkouts[r.checkoutKey()] = dir
+		}
+		tasks = append(tasks, &isolate.Task{
+			BaseDir:     dir,
+			Deps:        r.Deps,
+			ExtraVars:   r.ExtraVars,
+			IsolateFile: path.Join(dir, r.IsolateFile),
+			OsType:      ISOLATE_OS_TYPE,
+		})
+	}
+
+	hashes, err := c.isolate(tasks)
+	if err != nil {
+		return err
+	}
+	if len(hashes) != len(keys) {
+		return fmt.Errorf("Isolate returned %d hashes for %d tasks.", len(hashes), len(keys))
+	}
+	for i, k := range keys {
+		if hashes[i] == "" {
+			return fmt.Errorf("Isolate did not return a hash for %s", k)
+		}
+		c.entries[k] = &isolateCacheEntry{
+			Hash:     hashes[i],
+			LastUsed: now,
+		}
+	}
+	glog.Infof("Isolated %d tasks.", len(tasks))
+	return c.writeOut()
+}
@@ -0,0 +1,205 @@
+package task_scheduler
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/isolate"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/util"
+)
+
+func TestIsolateCache(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	// Setup.
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	repo := "skia.git"
+	_, err := repos.Repo(repo)
+	assert.NoError(t, err)
+	c1 := "b993cfa023855f4e27f0280465d477b0e0969708"
+	c2 := "21e4383ee704174c6ca715645181e076c4a30bdd"
+
+	// Fake out the isolate step. Record the number of tasks in each batch
+	// and the Deps of each task, and return a unique hash for each task.
+	batches := []int{}
+	deps := map[string][]string{}
+	n := 0
+	isolateTasks := func(tasks []*isolate.Task) ([]string, error) {
+		batches = append(batches, len(tasks))
+		hashes := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			_, err := os.Stat(task.BaseDir)
+			assert.NoError(t, err)
+			h := fmt.Sprintf("hash%d", n)
+			hashes = append(hashes, h)
+			deps[h] = task.Deps
+			n++
+		}
+		return hashes, nil
+	}
+
+	cacheFile := path.Join(tr.Dir, "isolate_cache.json")
+	c, err := newIsolateCache(cacheFile, repos, isolateTasks)
+	assert.NoError(t, err)
+
+	build1 := &isolateRequest{
+		IsolateFile: "infra/bots/compile_skia.isolate",
+		Repo:        repo,
+		Revision:    c1,
+	}
+	test1 := &isolateRequest{
+		IsolateFile: "infra/bots/test_skia.isolate",
+		Repo:        repo,
+		Revision:    c1,
+	}
+	build2 := &isolateRequest{
+		IsolateFile: "infra/bots/compile_skia.isolate",
+		Repo:        repo,
+		Revision:    c2,
+	}
+	build2Vars := &isolateRequest{
+		ExtraVars:   map[string]string{"BUILDER": "Build-Ubuntu"},
+		IsolateFile: "infra/bots/compile_skia.isolate",
+		Repo:        repo,
+		Revision:    c2,
+	}
+
+	hits := c.hits.Get()
+	misses := c.misses.Get()
+
+	// Duplicate requests are only isolated once.
+	hashes, err := c.Isolate([]*isolateRequest{build1, test1, build1})
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []int{2}, batches)
+	assert.Equal(t, 3, len(hashes))
+	assert.Equal(t, hashes[0], hashes[2])
+	assert.NotEqual(t, hashes[0], hashes[1])
+	assert.Equal(t, int64(3), c.misses.Get()-misses)
+
+	// Cached requests are not isolated again.
+	hashes2, err := c.Isolate([]*isolateRequest{test1, build2, build2Vars})
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []int{2, 2}, batches)
+	assert.Equal(t, hashes[1], hashes2[0])
+	assert.NotEqual(t, hashes2[1], hashes2[2])
+	assert.Equal(t, int64(1), c.hits.Get()-hits)
+
+	// The cache survives restarts.
+	c, err = newIsolateCache(cacheFile, repos, isolateTasks)
+	assert.NoError(t, err)
+	hashes3, err := c.Isolate([]*isolateRequest{build1, test1, build2, build2Vars})
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []int{2, 2}, batches)
+	testutils.AssertDeepEqual(t, []string{hashes[0], hashes[1], hashes2[1], hashes2[2]}, hashes3)
+	assert.Equal(t, int64(5), c.hits.Get()-hits)
+
+	// Failures to isolate are not cached.
+	c.isolate = func([]*isolate.Task) ([]string, error) {
+		return nil, fmt.Errorf("Failed to isolate.")
+	}
+	_, err = c.Isolate([]*isolateRequest{{
+		IsolateFile: "infra/bots/perf_skia.isolate",
+		Repo:        repo,
+		Revision:    c2,
+	}})
+	assert.Error(t, err)
+	c, err = newIsolateCache(cacheFile, repos, isolateTasks)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(c.entries))
+
+	// Writing the cache leaves no temporary files behind.
+	files, err := ioutil.ReadDir(tr.Dir)
+	assert.NoError(t, err)
+	for _, f := range files {
+		assert.False(t, strings.HasPrefix(f.Name(), "isolate_cache.json") && f.Name() != "isolate_cache.json", f.Name())
+	}
+
+	// Entries which have not been used recently expire.
+	c.entries[build1.key()].LastUsed = time.Now().Add(-2 * ISOLATE_CACHE_EXPIRATION)
+	c.expire(time.Now())
+	assert.Equal(t, 3, len(c.entries))
+	_, ok := c.entries[build1.key()]
+	assert.False(t, ok)
+
+	// An undecodable cache file, eg. in an old format, is treated as empty.
+	assert.NoError(t, ioutil.WriteFile(cacheFile, []byte(`{"key": "hash"}`), os.ModePerm))
+	c, err = newIsolateCache(cacheFile, repos, isolateTasks)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(c.entries))
+	assert.NoError(t, ioutil.WriteFile(cacheFile, []byte("garbage"), os.ModePerm))
+	c, err = newIsolateCache(cacheFile, repos, isolateTasks)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(c.entries))
+
+	// The outputs of dependencies are passed to isolate, and requests with
+	// different dependencies are cached separately.
+	test1Deps := &isolateRequest{
+		Deps:        []string{"buildOutput"},
+		IsolateFile: test1.IsolateFile,
+		Repo:        repo,
+		Revision:    c1,
+	}
+	hashes4, err := c.Isolate([]*isolateRequest{test1, test1Deps})
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []int{2, 2, 2}, batches)
+	assert.NotEqual(t, hashes4[0], hashes4[1])
+	testutils.AssertDeepEqual(t, []string{"buildOutput"}, deps[hashes4[1]])
+	assert.Equal(t, 0, len(deps[hashes4[0]]))
+}
+
+func TestIsolateCandidatesDeps(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	// Setup.
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	repo := "skia.git"
+	_, err := repos.Repo(repo)
+	assert.NoError(t, err)
+	c1 := "b993cfa023855f4e27f0280465d477b0e0969708"
+
+	deps := map[string][]string{}
+	isolateTasks := func(tasks []*isolate.Task) ([]string, error) {
+		hashes := make([]string, 0, len(tasks))
+		for _, task := range tasks {
+			h := path.Base(task.IsolateFile)
+			hashes = append(hashes, h)
+			deps[h] = task.Deps
+		}
+		return hashes, nil
+	}
+	ic, err := newIsolateCache(path.Join(tr.Dir, "isolate_cache.json"), repos, isolateTasks)
+	assert.NoError(t, err)
+	s := &TaskScheduler{isolateCache: ic}
+
+	// The Test task's isolated includes the output of its Build dependency.
+	build := &taskCandidate{
+		Name:     "Build",
+		Repo:     repo,
+		Revision: c1,
+		TaskSpec: &TaskSpec{Isolate: "compile_skia.isolate"},
+	}
+	test := &taskCandidate{
+		IsolatedHashes: []string{"buildOutput"},
+		Name:           "Test",
+		Repo:           repo,
+		Revision:       c1,
+		TaskSpec:       &TaskSpec{Isolate: "test_skia.isolate"},
+	}
+	assert.NoError(t, s.isolateCandidates([]*taskCandidate{build, test}))
+	assert.Equal(t, "compile_skia.isolate", build.IsolatedInput)
+	assert.Equal(t, "test_skia.isolate", test.IsolatedInput)
+	assert.Equal(t, 0, len(deps[build.IsolatedInput]))
+	testutils.AssertDeepEqual(t, []string{"buildOutput"}, deps[test.IsolatedInput])
+}
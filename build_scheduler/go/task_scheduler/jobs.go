@@ -0,0 +1,333 @@
+package task_scheduler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/build_scheduler/go/db"
+)
+
+const (
+	// JOB_RELOAD_PERIOD is how far back the TaskScheduler looks for
+	// unfinished Jobs in the DB when it starts.
+	JOB_RELOAD_PERIOD = 4 * 24 * time.Hour
+)
+
+// jobTaskState describes the progress of a single TaskSpec within a Job.
+type jobTaskState int
+
+const (
+	// jobTaskPending indicates that the task has not yet finished, or that it
+	// failed and will be retried.
+	jobTaskPending jobTaskState = iota
+	// jobTaskSucceeded indicates that the task finished successfully.
+	jobTaskSucceeded
+	// jobTaskFailed indicates that the task or one of its dependencies failed
+	// and will not be retried.
+	jobTaskFailed
+)
+
+// jobTaskStates computes the state of each of the given TaskSpecs and all of
+// their dependencies. getTask returns the most recent Task for the given
+// TaskSpec name within the Job, or nil if there is none. If candidate is not
+// nil, it is called for each TaskSpec which needs to run and whose
+// dependencies have all succeeded, along with the Task it would retry, if
+// any, and its attempt number.
+func jobTaskStates(cfg *TasksCfg, names []string, getTask func(string) (*db.Task, error), candidate func(string, *TaskSpec, *db.Task, int) error) (map[string]jobTaskState, error) {
+	states := map[string]jobTaskState{}
+	var visit func(string) (jobTaskState, error)
+	visit = func(name string) (jobTaskState, error) {
+		if state, ok := states[name]; ok {
+			return state, nil
+		}
+		spec, ok := cfg.Tasks[name]
+		if !ok {
+			return jobTaskPending, fmt.Errorf("Unknown task %q.", name)
+		}
+
+		// If any dependency failed, this task can't run.
+		depsSucceeded := true
+		for _, dep := range spec.Dependencies {
+			state, err := visit(dep)
+			if err != nil {
+				return jobTaskPending, err
+			}
+			if state == jobTaskFailed {
+				states[name] = jobTaskFailed
+				return jobTaskFailed, nil
+			}
+			if state != jobTaskSucceeded {
+				depsSucceeded = false
+			}
+		}
+
+		state := jobTaskPending
+		prev, err := getTask(name)
+		if err != nil {
+			return jobTaskPending, err
+		}
+		var retryOf *db.Task
+		attempt := 0
+		if prev != nil {
+			if prev.Success() {
+				state = jobTaskSucceeded
+			} else if prev.Done() {
+				if spec.ShouldRetry(prev) {
+					retryOf = prev
+					attempt = prev.Attempt + 1
+				} else {
+					state = jobTaskFailed
+				}
+			}
+		}
+		states[name] = state
+
+		if candidate != nil && depsSucceeded && state == jobTaskPending && (prev == nil || retryOf != nil) {
+			if err := candidate(name, spec, retryOf, attempt); err != nil {
+				return jobTaskPending, err
+			}
+		}
+		return state, nil
+	}
+
+	for _, name := range names {
+		if _, err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return states, nil
+}
+
+// jobDependencies returns the DAG of the given TaskSpecs and all of their
+// dependencies, in the form used by db.Job.Dependencies.
+func jobDependencies(cfg *TasksCfg, names []string) (map[string][]string, error) {
+	rv := map[string][]string{}
+	var visit func(string) error
+	visit = func(name string) error {
+		if _, ok := rv[name]; ok {
+			return nil
+		}
+		spec, ok := cfg.Tasks[name]
+		if !ok {
+			return fmt.Errorf("Unknown task %q.", name)
+		}
+		deps := make([]string, len(spec.Dependencies))
+		copy(deps, spec.Dependencies)
+		rv[name] = deps
+		for _, dep := range deps {
+			if err := visit(dep); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name); err != nil {
+			return nil, err
+		}
+	}
+	return rv, nil
+}
+
+// activeJob is a Job which has not yet finished, along with the TasksCfg from
+// which its dependencies were derived.
+type activeJob struct {
+	cfg *TasksCfg
+	job *db.Job
+}
+
+// newJob creates a Job for the given TaskSpecs, inserts it into the DB, and
+// tracks it until it finishes.
+func (s *TaskScheduler) newJob(cfg *TasksCfg, j *db.Job, taskNames []string) (*db.Job, error) {
+	if len(taskNames) == 0 {
+		return nil, fmt.Errorf("Job must include at least one task.")
+	}
+	deps, err := jobDependencies(cfg, taskNames)
+	if err != nil {
+		return nil, err
+	}
+	j.Created = time.Now()
+	j.Dependencies = deps
+	j.Name = strings.Join(taskNames, ",")
+	if err := s.db.PutJob(j); err != nil {
+		return nil, err
+	}
+
+	s.jobsMtx.Lock()
+	defer s.jobsMtx.Unlock()
+	s.jobs = append(s.jobs, &activeJob{
+		cfg: cfg,
+		job: j.Copy(),
+	})
+	return j, nil
+}
+
+// TriggerJob creates a Job which runs the given TaskSpecs, along with their
+// dependencies, at the given commit. The TaskSpecs are scheduled through the
+// usual commit queue; the Job tracks their progress. Jobs are tracked in
+// memory until they finish; Jobs which are in progress when the TaskScheduler
+// exits are reloaded by loadUnfinishedJobs.
+func (s *TaskScheduler) TriggerJob(repo, revision string, taskNames []string, reason string) (*db.Job, error) {
+	if revision == "" {
+		return nil, fmt.Errorf("Job must specify a revision.")
+	}
+	cfg, err := s.taskCfgCache.ReadTasksCfg(repo, revision)
+	if err != nil {
+		return nil, err
+	}
+	return s.newJob(cfg, &db.Job{
+		Repo:          repo,
+		Revision:      revision,
+		TriggerReason: reason,
+	}, taskNames)
+}
+
+// loadUnfinishedJobs resumes tracking the Jobs in the DB which were created
+// within period before now and have not finished, e.g. because the
+// TaskScheduler exited while they were in progress. The TryRequests of
+// unfinished try jobs are recreated so that their tasks are scheduled.
+func (s *TaskScheduler) loadUnfinishedJobs(now time.Time, period time.Duration) error {
+	jobs, err := s.db.GetJobsFromDateRange(now.Add(-period), now)
+	if err != nil {
+		return err
+	}
+	active := []*activeJob{}
+	tryRequests := []*TryRequest{}
+	for _, j := range jobs {
+		if j.Done() {
+			continue
+		}
+		var cfg *TasksCfg
+		if j.IsTryJob() {
+			r, err := tryRequestFromJob(j)
+			if err != nil {
+				return err
+			}
+			if err := s.readTryCfg(r); err != nil {
+				return fmt.Errorf("Failed to reload try job %s: %s", j.Id, err)
+			}
+			cfg = r.cfg
+			tryRequests = append(tryRequests, r)
+		} else {
+			cfg, err = s.taskCfgCache.ReadTasksCfg(j.Repo, j.Revision)
+			if err != nil {
+				return fmt.Errorf("Failed to reload job %s: %s", j.Id, err)
+			}
+		}
+		active = append(active, &activeJob{
+			cfg: cfg,
+			job: j,
+		})
+	}
+	glog.Infof("Loaded %d unfinished jobs from the DB.", len(active))
+
+	s.jobsMtx.Lock()
+	s.jobs = append(s.jobs, active...)
+	s.jobsMtx.Unlock()
+	s.tryMtx.Lock()
+	s.tryRequests = append(s.tryRequests, tryRequests...)
+	s.tryMtx.Unlock()
+	return nil
+}
+
+// updateJob updates the given Job from the most recent Task for each of its
+// TaskSpecs and computes its status. Returns true iff the Job was modified.
+func (s *TaskScheduler) updateJob(a *activeJob, now time.Time) (bool, error) {
+	j := a.job
+	modified := false
+	tasks := map[string]*db.Task{}
+	getTask := func(name string) (*db.Task, error) {
+		var t *db.Task
+		var err error
+		if j.IsTryJob() {
+			t, err = s.cache.GetTaskForPatch(j.Server, j.Issue, j.Patchset, name)
+		} else {
+			t, err = s.cache.GetTaskForCommit(name, j.Revision)
+		}
+		if err != nil || t == nil {
+			return nil, err
+		}
+		if j.UpdateFromTask(t) {
+			modified = true
+		}
+		// A failed task which ran at a different commit will be bisected
+		// onto this commit, so it does not count as a failure of this Job.
+		if !j.IsTryJob() && t.Done() && !t.Success() && t.Revision != j.Revision {
+			return nil, nil
+		}
+		tasks[name] = t
+		return t, nil
+	}
+	names := make([]string, 0, len(j.Dependencies))
+	for name, _ := range j.Dependencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	states, err := jobTaskStates(a.cfg, names, getTask, nil)
+	if err != nil {
+		return false, err
+	}
+
+	var status db.JobStatus = db.JOB_STATUS_SUCCESS
+	pending := false
+	for _, name := range names {
+		switch states[name] {
+		case jobTaskPending:
+			pending = true
+		case jobTaskFailed:
+			if t := tasks[name]; t != nil && (t.Status == db.TASK_STATUS_MISHAP || t.Status == db.TASK_STATUS_EXPIRED) {
+				status = db.JOB_STATUS_MISHAP
+			} else if status != db.JOB_STATUS_MISHAP {
+				status = db.JOB_STATUS_FAILURE
+			}
+		}
+	}
+	if pending {
+		status = db.JOB_STATUS_IN_PROGRESS
+	}
+	if status != j.Status {
+		j.Status = status
+		if j.Done() {
+			j.Finished = now
+		}
+		modified = true
+	}
+	return modified, nil
+}
+
+// updateJobs updates all unfinished Jobs from their Tasks, writes any changes
+// to the DB, and stops tracking Jobs which have finished.
+func (s *TaskScheduler) updateJobs(now time.Time) error {
+	s.jobsMtx.Lock()
+	defer s.jobsMtx.Unlock()
+
+	modified := []*db.Job{}
+	remaining := make([]*activeJob, 0, len(s.jobs))
+	for _, a := range s.jobs {
+		updated, err := s.updateJob(a, now)
+		if err != nil {
+			return err
+		}
+		if updated {
+			modified = append(modified, a.job)
+		}
+		if !a.job.Done() {
+			remaining = append(remaining, a)
+		}
+	}
+	if len(modified) > 0 {
+		if err := s.db.PutJobs(modified); err != nil {
+			return err
+		}
+		for _, j := range modified {
+			if j.Done() {
+				glog.Infof("Job %s (%s) finished with status %q", j.Id, j.Name, j.Status)
+			}
+		}
+	}
+	s.jobs = remaining
+	return nil
+}
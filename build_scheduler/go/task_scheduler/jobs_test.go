@@ -0,0 +1,217 @@
+package task_scheduler
+
+import (
+	"math"
+	"sort"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/build_scheduler/go/db"
+	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/util"
+)
+
+func TestJobs(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	// Setup.
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, time.Hour)
+	assert.NoError(t, err)
+
+	c1 := "b993cfa023855f4e27f0280465d477b0e0969708"
+	c2 := "21e4383ee704174c6ca715645181e076c4a30bdd"
+	repo := "skia.git"
+	buildTask := "Build-Ubuntu-GCC-Arm7-Release-Android"
+	testTask := "Test-Android-GCC-Nexus7-GPU-Tegra3-Arm7-Release"
+	perfTask := "Perf-Android-GCC-Nexus7-GPU-Tegra3-Arm7-Release"
+
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	_, err = repos.Repo(repo)
+	assert.NoError(t, err)
+	s := NewTaskScheduler(d, nil, cache, time.Duration(math.MaxInt64), repos)
+
+	// Invalid jobs.
+	_, err = s.TriggerJob(repo, "", []string{buildTask}, "test")
+	assert.Error(t, err)
+	_, err = s.TriggerJob(repo, c1, []string{}, "test")
+	assert.Error(t, err)
+	_, err = s.TriggerJob(repo, c1, []string{perfTask}, "test")
+	assert.Error(t, err)
+	_, err = s.TriggerJob("bogus.git", c1, []string{buildTask}, "test")
+	assert.Error(t, err)
+	assert.Equal(t, 0, len(s.jobs))
+
+	// Trigger some jobs.
+	j1, err := s.TriggerJob(repo, c1, []string{testTask}, "test")
+	assert.NoError(t, err)
+	assert.NotEqual(t, "", j1.Id)
+	assert.Equal(t, testTask, j1.Name)
+	assert.Equal(t, "test", j1.TriggerReason)
+	assert.False(t, j1.IsTryJob())
+	testutils.AssertDeepEqual(t, map[string][]string{
+		buildTask: []string{},
+		testTask:  []string{buildTask},
+	}, j1.Dependencies)
+	j2, err := s.TriggerJob(repo, c1, []string{buildTask}, "test")
+	assert.NoError(t, err)
+	j3, err := s.TriggerJob(repo, c2, []string{testTask, perfTask}, "test")
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, map[string][]string{
+		buildTask: []string{},
+		perfTask:  []string{buildTask},
+		testTask:  []string{buildTask},
+	}, j3.Dependencies)
+	assert.Equal(t, 3, len(s.jobs))
+
+	getJob := func(id string) *db.Job {
+		j, err := d.GetJobById(id)
+		assert.NoError(t, err)
+		return j
+	}
+	update := func() {
+		assert.NoError(t, cache.Update())
+		assert.NoError(t, s.updateJobs(time.Now()))
+	}
+
+	// Nothing has run yet.
+	update()
+	for _, j := range []*db.Job{j1, j2, j3} {
+		assert.Equal(t, db.JobStatus(db.JOB_STATUS_IN_PROGRESS), getJob(j.Id).Status)
+	}
+
+	// The Build task succeeds at c1; j2 is finished.
+	b1 := makeTask(buildTask, c1)
+	b1.Status = db.TASK_STATUS_SUCCESS
+	assert.NoError(t, d.PutTask(b1))
+	update()
+	j := getJob(j1.Id)
+	assert.Equal(t, db.JobStatus(db.JOB_STATUS_IN_PROGRESS), j.Status)
+	testutils.AssertDeepEqual(t, []*db.TaskSummary{
+		{Attempt: 0, Id: b1.Id, Status: db.TASK_STATUS_SUCCESS},
+	}, j.Tasks[buildTask])
+	j = getJob(j2.Id)
+	assert.Equal(t, db.JobStatus(db.JOB_STATUS_SUCCESS), j.Status)
+	assert.False(t, util.TimeIsZero(j.Finished))
+	assert.Equal(t, 2, len(s.jobs))
+
+	// The Test task fails at c1. It will be retried, so j1 is not finished.
+	t1 := makeTask(testTask, c1)
+	t1.Status = db.TASK_STATUS_FAILURE
+	assert.NoError(t, d.PutTask(t1))
+	update()
+	assert.Equal(t, db.JobStatus(db.JOB_STATUS_IN_PROGRESS), getJob(j1.Id).Status)
+
+	// The retry has a mishap. There are no more retries, so j1 is finished.
+	t2 := makeTask(testTask, c1)
+	t2.Attempt = 1
+	t2.RetryOf = t1.Id
+	t2.Status = db.TASK_STATUS_MISHAP
+	assert.NoError(t, d.PutTask(t2))
+	update()
+	j = getJob(j1.Id)
+	assert.Equal(t, db.JobStatus(db.JOB_STATUS_MISHAP), j.Status)
+	assert.False(t, util.TimeIsZero(j.Finished))
+	assert.Equal(t, 2, len(j.Tasks[testTask]))
+	assert.Equal(t, 1, len(s.jobs))
+
+	// The Build task fails at c2 on both attempts, which blocks both the
+	// Test and Perf tasks.
+	b2 := makeTask(buildTask, c2)
+	b2.Status = db.TASK_STATUS_FAILURE
+	assert.NoError(t, d.PutTask(b2))
+	update()
+	assert.Equal(t, db.JobStatus(db.JOB_STATUS_IN_PROGRESS), getJob(j3.Id).Status)
+	b3 := makeTask(buildTask, c2)
+	b3.Attempt = 1
+	b3.RetryOf = b2.Id
+	b3.Status = db.TASK_STATUS_FAILURE
+	assert.NoError(t, d.PutTask(b3))
+	update()
+	j = getJob(j3.Id)
+	assert.Equal(t, db.JobStatus(db.JOB_STATUS_FAILURE), j.Status)
+	assert.Equal(t, 0, len(j.Tasks[testTask]))
+	assert.Equal(t, 0, len(s.jobs))
+}
+
+func TestLoadUnfinishedJobs(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	// Setup.
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, time.Hour)
+	assert.NoError(t, err)
+
+	c1 := "b993cfa023855f4e27f0280465d477b0e0969708"
+	c2 := "21e4383ee704174c6ca715645181e076c4a30bdd"
+	repo := "skia.git"
+	buildTask := "Build-Ubuntu-GCC-Arm7-Release-Android"
+	testTask := "Test-Android-GCC-Nexus7-GPU-Tegra3-Arm7-Release"
+	houseTask := "Housekeeper-Nightly-RecreateSKPs"
+	server := "https://codereview.chromium.org"
+	getPatch := func(srv string, issue, patchset int64) (string, error) {
+		return testPatch, nil
+	}
+
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	_, err = repos.Repo(repo)
+	assert.NoError(t, err)
+	s := NewTaskScheduler(d, nil, cache, time.Duration(math.MaxInt64), repos)
+	s.getPatch = getPatch
+
+	// Trigger two jobs and a try job. One of the jobs finishes.
+	j1, err := s.TriggerJob(repo, c1, []string{buildTask}, "test")
+	assert.NoError(t, err)
+	j2, err := s.TriggerJob(repo, c1, []string{testTask}, "test")
+	assert.NoError(t, err)
+	j3, err := s.AddTryRequest(&TryRequest{
+		Server:    server,
+		Issue:     12345,
+		Patchset:  1,
+		Repo:      repo,
+		Revision:  c2,
+		TaskNames: []string{testTask, houseTask},
+	})
+	assert.NoError(t, err)
+	b1 := makeTask(buildTask, c1)
+	b1.Status = db.TASK_STATUS_SUCCESS
+	assert.NoError(t, d.PutTask(b1))
+	assert.NoError(t, cache.Update())
+	assert.NoError(t, s.updateJobs(time.Now()))
+	assert.Equal(t, 2, len(s.jobs))
+
+	// A new TaskScheduler, as after a restart, picks up the unfinished jobs
+	// and the try request.
+	s = NewTaskScheduler(d, nil, cache, time.Duration(math.MaxInt64), repos)
+	s.getPatch = getPatch
+	assert.NoError(t, s.loadUnfinishedJobs(time.Now(), time.Hour))
+	assert.Equal(t, 2, len(s.jobs))
+	ids := []string{s.jobs[0].job.Id, s.jobs[1].job.Id}
+	sort.Strings(ids)
+	expect := []string{j2.Id, j3.Id}
+	sort.Strings(expect)
+	testutils.AssertDeepEqual(t, expect, ids)
+	assert.Equal(t, 1, len(s.tryRequests))
+	testutils.AssertDeepEqual(t, []string{testTask, houseTask}, s.tryRequests[0].TaskNames)
+	assert.NotNil(t, s.tryRequests[0].cfg.Tasks[houseTask])
+
+	// The reloaded jobs are updated as their tasks finish.
+	t1 := makeTask(testTask, c1)
+	t1.Status = db.TASK_STATUS_SUCCESS
+	assert.NoError(t, d.PutTask(t1))
+	assert.NoError(t, cache.Update())
+	assert.NoError(t, s.updateJobs(time.Now()))
+	j, err := d.GetJobById(j2.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, db.JobStatus(db.JOB_STATUS_SUCCESS), j.Status)
+	j, err = d.GetJobById(j1.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, db.JobStatus(db.JOB_STATUS_SUCCESS), j.Status)
+	assert.Equal(t, 1, len(s.jobs))
+}
@@ -0,0 +1,474 @@
+package task_scheduler
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	swarming_api "github.com/luci/luci-go/common/api/swarming/swarming/v1"
+	"go.skia.org/infra/build_scheduler/go/db"
+	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/util"
+)
+
+// SimBots describes a set of identical bots in a simulated bot pool.
+type SimBots struct {
+	// Count is the number of bots in the set.
+	Count int `json:"count"`
+
+	// Dimensions are the Swarming dimensions of each bot, in "key:value"
+	// form.
+	Dimensions []string `json:"dimensions"`
+}
+
+// SimConfig describes a simulation of the TaskScheduler.
+type SimConfig struct {
+	// Bots describes the simulated bot pool.
+	Bots []*SimBots
+
+	// DefaultTaskDuration is how long a task runs if its name is not found
+	// in TaskDurations.
+	DefaultTaskDuration time.Duration
+
+	// End is the time at which the simulation stops.
+	End time.Time
+
+	// Period is the time period over which the TaskScheduler considers
+	// commits, as in NewTaskScheduler.
+	Period time.Duration
+
+	// Start is the time at which the simulation begins. Commits which landed
+	// before Start are not scheduled.
+	Start time.Time
+
+	// TaskDurations indicates how long each task runs, by TaskSpec name.
+	TaskDurations map[string]time.Duration
+
+	// Tick is the simulated time between scheduling loops.
+	Tick time.Duration
+
+	// TimeDecayAmt24Hr is the time decay amount used for scoring, as in
+	// timeDecay24Hr. If zero, the TaskScheduler's default is used.
+	TimeDecayAmt24Hr float64
+}
+
+// Validate returns an error if the SimConfig is not valid.
+func (c *SimConfig) Validate() error {
+	if len(c.Bots) == 0 {
+		return fmt.Errorf("Simulation requires at least one bot.")
+	}
+	for _, b := range c.Bots {
+		if b.Count <= 0 {
+			return fmt.Errorf("Bot count must be positive; got %d", b.Count)
+		}
+		for _, d := range b.Dimensions {
+			if len(strings.SplitN(d, ":", 2)) != 2 {
+				return fmt.Errorf("Dimension %q does not contain a colon!", d)
+			}
+		}
+	}
+	if c.DefaultTaskDuration <= 0 {
+		return fmt.Errorf("DefaultTaskDuration must be positive.")
+	}
+	if !c.End.After(c.Start) {
+		return fmt.Errorf("Simulation end time must be after start time.")
+	}
+	if c.Period <= 0 {
+		return fmt.Errorf("Period must be positive.")
+	}
+	if c.Tick <= 0 {
+		return fmt.Errorf("Tick must be positive.")
+	}
+	if c.TimeDecayAmt24Hr < 0.0 || c.TimeDecayAmt24Hr > 1.0 {
+		return fmt.Errorf("TimeDecayAmt24Hr must be in [0, 1]; got %f", c.TimeDecayAmt24Hr)
+	}
+	return nil
+}
+
+// SimResult contains the results of a simulation.
+type SimResult struct {
+	// BlamelistLengths is a histogram of the blamelist lengths of all tasks
+	// triggered during the simulation, keyed by length.
+	BlamelistLengths map[int]int
+
+	// BotUtilization is the fraction of the simulated time which each bot
+	// spent running tasks, keyed by bot ID.
+	BotUtilization map[string]float64
+
+	// TasksTriggered is the number of tasks triggered during the simulation.
+	TasksTriggered int
+
+	// TimeToCoverage is the time between each commit landing and every
+	// TaskSpec at that commit having a finished task whose blamelist includes
+	// the commit, keyed by repo and commit hash. Measured with a resolution
+	// of SimConfig.Tick. Commits which were not fully covered by the end of
+	// the simulation are not included.
+	TimeToCoverage map[string]map[string]time.Duration
+
+	// Uncovered is the number of commits which landed during the simulation
+	// but were not fully covered by the end of the simulation.
+	Uncovered int
+}
+
+// WriteReport writes a human-readable summary of the SimResult.
+func (r *SimResult) WriteReport(w io.Writer) error {
+	coverage := []time.Duration{}
+	for _, commits := range r.TimeToCoverage {
+		for _, d := range commits {
+			coverage = append(coverage, d)
+		}
+	}
+	sort.Sort(durationSlice(coverage))
+	if _, err := fmt.Fprintf(w, "Tasks triggered: %d\n", r.TasksTriggered); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "Commits covered: %d (%d uncovered)\n", len(coverage), r.Uncovered); err != nil {
+		return err
+	}
+	if len(coverage) > 0 {
+		var total time.Duration
+		for _, d := range coverage {
+			total += d
+		}
+		if _, err := fmt.Fprintf(w, "Time to coverage: mean %s, median %s, 90th percentile %s, max %s\n", total/time.Duration(len(coverage)), coverage[len(coverage)/2], coverage[len(coverage)*9/10], coverage[len(coverage)-1]); err != nil {
+			return err
+		}
+	}
+
+	botIds := make([]string, 0, len(r.BotUtilization))
+	totalUtil := 0.0
+	for botId, u := range r.BotUtilization {
+		botIds = append(botIds, botId)
+		totalUtil += u
+	}
+	sort.Strings(botIds)
+	if len(botIds) > 0 {
+		if _, err := fmt.Fprintf(w, "Bot utilization: mean %.1f%%\n", 100.0*totalUtil/float64(len(botIds))); err != nil {
+			return err
+		}
+	}
+	for _, botId := range botIds {
+		if _, err := fmt.Fprintf(w, "\t%s\t%.1f%%\n", botId, 100.0*r.BotUtilization[botId]); err != nil {
+			return err
+		}
+	}
+
+	lengths := make([]int, 0, len(r.BlamelistLengths))
+	for n, _ := range r.BlamelistLengths {
+		lengths = append(lengths, n)
+	}
+	sort.Ints(lengths)
+	if _, err := fmt.Fprintf(w, "Blamelist lengths:\n"); err != nil {
+		return err
+	}
+	for _, n := range lengths {
+		if _, err := fmt.Fprintf(w, "\t%d\t%d\n", n, r.BlamelistLengths[n]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// durationSlice is an alias used for sorting a slice of time.Durations.
+type durationSlice []time.Duration
+
+func (s durationSlice) Len() int           { return len(s) }
+func (s durationSlice) Less(i, j int) bool { return s[i] < s[j] }
+func (s durationSlice) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+// simBot is a bot in a simulated bot pool.
+type simBot struct {
+	busy    time.Duration
+	info    *swarming_api.SwarmingRpcsBotInfo
+	task    *db.Task
+	taskEnd time.Time
+}
+
+// simulator runs a TaskScheduler against a simulated clock and bot pool.
+type simulator struct {
+	bots   []*simBot
+	cfg    *SimConfig
+	d      db.DB
+	landed map[string]map[string]time.Time
+	result *SimResult
+	s      *TaskScheduler
+}
+
+// Simulate replays the commit history of the given repos between cfg.Start
+// and cfg.End, running the TaskScheduler's candidate selection and scoring
+// against an in-memory DB and a simulated bot pool. All simulated tasks
+// succeed. The repos are not updated during the simulation.
+func Simulate(repos *gitinfo.RepoMap, cfg *SimConfig) (*SimResult, error) {
+	sim, err := newSimulator(repos, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for now := cfg.Start; now.Before(cfg.End); now = now.Add(cfg.Tick) {
+		if err := sim.step(now); err != nil {
+			return nil, err
+		}
+	}
+
+	// Tasks which are still running were busy until the end.
+	total := cfg.End.Sub(cfg.Start)
+	for _, b := range sim.bots {
+		if b.task != nil {
+			b.busy += cfg.End.Sub(b.task.Started)
+		}
+		sim.result.BotUtilization[b.info.BotId] = math.Min(float64(b.busy)/float64(total), 1.0)
+	}
+	for repo, commits := range sim.landed {
+		for commit, _ := range commits {
+			if _, ok := sim.result.TimeToCoverage[repo][commit]; !ok {
+				sim.result.Uncovered++
+			}
+		}
+	}
+	return sim.result, nil
+}
+
+// newSimulator returns a simulator with an empty in-memory DB and the bot pool
+// described by the given SimConfig.
+func newSimulator(repos *gitinfo.RepoMap, cfg *SimConfig) (*simulator, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, cfg.Period)
+	if err != nil {
+		return nil, err
+	}
+	s := NewTaskScheduler(d, nil, cache, cfg.Period, repos)
+	if cfg.TimeDecayAmt24Hr != 0.0 {
+		s.timeDecayAmt24Hr = cfg.TimeDecayAmt24Hr
+	}
+	sim := &simulator{
+		bots:   []*simBot{},
+		cfg:    cfg,
+		d:      d,
+		landed: map[string]map[string]time.Time{},
+		result: &SimResult{
+			BlamelistLengths: map[int]int{},
+			BotUtilization:   map[string]float64{},
+			TimeToCoverage:   map[string]map[string]time.Duration{},
+		},
+		s: s,
+	}
+	for _, b := range cfg.Bots {
+		for i := 0; i < b.Count; i++ {
+			sim.bots = append(sim.bots, &simBot{
+				info: makeSimBotInfo(fmt.Sprintf("sim-bot-%03d", len(sim.bots)), b.Dimensions),
+			})
+		}
+	}
+	return sim, nil
+}
+
+// makeSimBotInfo returns a fake SwarmingRpcsBotInfo for a simulated bot.
+func makeSimBotInfo(id string, dims []string) *swarming_api.SwarmingRpcsBotInfo {
+	byKey := map[string][]string{}
+	keys := []string{}
+	for _, d := range dims {
+		split := strings.SplitN(d, ":", 2)
+		if _, ok := byKey[split[0]]; !ok {
+			keys = append(keys, split[0])
+		}
+		byKey[split[0]] = append(byKey[split[0]], split[1])
+	}
+	pairs := make([]*swarming_api.SwarmingRpcsStringListPair, 0, len(keys))
+	for _, k := range keys {
+		pairs = append(pairs, &swarming_api.SwarmingRpcsStringListPair{
+			Key:   k,
+			Value: byKey[k],
+		})
+	}
+	return &swarming_api.SwarmingRpcsBotInfo{
+		BotId:      id,
+		Dimensions: pairs,
+	}
+}
+
+// step runs one iteration of the simulation at the given time: finish any
+// tasks which are done, find and score task candidates, and trigger tasks on
+// the free bots.
+func (sim *simulator) step(now time.Time) error {
+	// Finish tasks.
+	finished := []*db.Task{}
+	for _, b := range sim.bots {
+		if b.task != nil && !b.taskEnd.After(now) {
+			b.task.Status = db.TASK_STATUS_SUCCESS
+			b.task.Finished = b.taskEnd
+			b.task.IsolatedOutput = fmt.Sprintf("fake-isolated-output-%s", b.task.Id)
+			b.busy += b.taskEnd.Sub(b.task.Started)
+			finished = append(finished, b.task)
+			b.task = nil
+		}
+	}
+	if err := sim.d.PutTasks(finished); err != nil {
+		return err
+	}
+	if err := sim.s.cache.Update(); err != nil {
+		return err
+	}
+
+	// Find the commits which have landed within the scheduling period.
+	commits, err := sim.commitsAt(now)
+	if err != nil {
+		return err
+	}
+	if err := sim.recordCoverage(now, commits); err != nil {
+		return err
+	}
+
+	// Find and score task candidates.
+	candidates, err := sim.s.findTaskCandidates(commits)
+	if err != nil {
+		return err
+	}
+	if err := sim.s.processTaskCandidates(candidates, now); err != nil {
+		return err
+	}
+
+	// Trigger tasks on the free bots.
+	free := []*swarming_api.SwarmingRpcsBotInfo{}
+	botsById := map[string]*simBot{}
+	for _, b := range sim.bots {
+		if b.task == nil {
+			free = append(free, b.info)
+			botsById[b.info.BotId] = b
+		}
+	}
+	running, err := countUnfinishedTasks(sim.s.cache)
+	if err != nil {
+		return err
+	}
+	schedule := getCandidatesToSchedule(free, candidates, running)
+	botIds := make([]string, 0, len(schedule))
+	for botId, _ := range schedule {
+		botIds = append(botIds, botId)
+	}
+	sort.Strings(botIds)
+	stolen := map[string]*db.Task{}
+	for _, botId := range botIds {
+		c := schedule[botId]
+		t := c.MakeTask()
+		t.Created = now
+		t.Started = now
+		t.Status = db.TASK_STATUS_RUNNING
+		// Remove stolen commits from the blamelist of the previous task.
+		// Multiple candidates may steal from the same task.
+		if c.StealingFrom != nil {
+			prev, ok := stolen[c.StealingFrom.Id]
+			if !ok {
+				prev = c.StealingFrom.Copy()
+				stolen[prev.Id] = prev
+			}
+			prev.Commits = util.NewStringSet(prev.Commits).Complement(util.NewStringSet(t.Commits)).Keys()
+			sort.Strings(prev.Commits)
+		}
+		if err := sim.d.PutTask(t); err != nil {
+			return err
+		}
+		b := botsById[botId]
+		b.task = t
+		b.taskEnd = now.Add(sim.taskDuration(t.Name))
+		sim.result.BlamelistLengths[len(t.Commits)]++
+		sim.result.TasksTriggered++
+	}
+	updated := make([]*db.Task, 0, len(stolen))
+	for _, t := range stolen {
+		updated = append(updated, t)
+	}
+	if err := sim.d.PutTasks(updated); err != nil {
+		return err
+	}
+
+	// Bots which are still running a task we stole from must finish the
+	// updated copy, or the stolen commits would be written back to it.
+	for _, b := range sim.bots {
+		if b.task != nil {
+			if t, ok := stolen[b.task.Id]; ok {
+				b.task = t
+			}
+		}
+	}
+	return nil
+}
+
+// taskDuration returns the simulated duration of the given task.
+func (sim *simulator) taskDuration(name string) time.Duration {
+	if d, ok := sim.cfg.TaskDurations[name]; ok {
+		return d
+	}
+	return sim.cfg.DefaultTaskDuration
+}
+
+// commitsAt returns the commits in each repo which landed within the
+// scheduling period before the given time, and records the landing time of
+// any commits which landed during the simulation.
+func (sim *simulator) commitsAt(now time.Time) (map[string][]string, error) {
+	from := now.Add(-sim.cfg.Period)
+	if from.Before(sim.cfg.Start) {
+		from = sim.cfg.Start
+	}
+	rv := map[string][]string{}
+	for _, repoName := range sim.s.repos.Repos() {
+		repo, err := sim.s.repos.Repo(repoName)
+		if err != nil {
+			return nil, err
+		}
+		commits := []string{}
+		for _, c := range repo.From(from) {
+			ts := repo.Timestamp(c)
+			if ts.After(now) {
+				continue
+			}
+			if _, ok := sim.landed[repoName]; !ok {
+				sim.landed[repoName] = map[string]time.Time{}
+			}
+			sim.landed[repoName][c] = ts
+			commits = append(commits, c)
+		}
+		rv[repoName] = commits
+	}
+	return rv, nil
+}
+
+// recordCoverage records the time to coverage for any of the given commits
+// which are newly covered, ie. every TaskSpec at the commit has a finished
+// task whose blamelist includes the commit.
+func (sim *simulator) recordCoverage(now time.Time, commitsByRepo map[string][]string) error {
+	specs, err := sim.s.taskCfgCache.GetTaskSpecsForCommits(commitsByRepo)
+	if err != nil {
+		return err
+	}
+	for repo, commits := range specs {
+		if _, ok := sim.result.TimeToCoverage[repo]; !ok {
+			sim.result.TimeToCoverage[repo] = map[string]time.Duration{}
+		}
+		for commit, tasks := range commits {
+			if _, ok := sim.result.TimeToCoverage[repo][commit]; ok {
+				continue
+			}
+			covered := true
+			for name, _ := range tasks {
+				t, err := sim.s.cache.GetTaskForCommit(name, commit)
+				if err != nil {
+					return err
+				}
+				if t == nil || !t.Done() {
+					covered = false
+					break
+				}
+			}
+			if covered {
+				sim.result.TimeToCoverage[repo][commit] = now.Sub(sim.landed[repo][commit])
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package task_scheduler
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/build_scheduler/go/db"
+	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/util"
+)
+
+func TestSimulate(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+
+	c1 := "b993cfa023855f4e27f0280465d477b0e0969708"
+	c2 := "21e4383ee704174c6ca715645181e076c4a30bdd"
+	repoName := "skia.git"
+	buildTask := "Build-Ubuntu-GCC-Arm7-Release-Android"
+
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	repo, err := repos.Repo(repoName)
+	assert.NoError(t, err)
+	c1Time := repo.Timestamp(c1)
+	c2Time := repo.Timestamp(c2)
+
+	cfg := &SimConfig{
+		Bots: []*SimBots{
+			&SimBots{
+				Count:      1,
+				Dimensions: []string{"pool:Skia", "os:Ubuntu"},
+			},
+			&SimBots{
+				Count:      1,
+				Dimensions: []string{"pool:Skia", "os:Android", "device_type:grouper"},
+			},
+		},
+		DefaultTaskDuration: 5 * time.Minute,
+		End:                 c1Time.Add(119 * time.Minute),
+		Period:              24 * time.Hour,
+		Start:               c1Time.Add(-time.Minute),
+		TaskDurations: map[string]time.Duration{
+			buildTask: 10 * time.Minute,
+		},
+		Tick: time.Minute,
+	}
+
+	// Invalid configs.
+	assert.Error(t, (&SimConfig{}).Validate())
+	cfg.Tick = 0
+	assert.EqualError(t, cfg.Validate(), "Tick must be positive.")
+	cfg.Tick = time.Minute
+
+	// The Build task runs at c1 first. When it finishes, the Test task runs
+	// at c1 and the Build task runs at c2. Finally, the Test and Perf tasks
+	// run at c2.
+	result, err := Simulate(repos, cfg)
+	assert.NoError(t, err)
+	assert.Equal(t, 5, result.TasksTriggered)
+	testutils.AssertDeepEqual(t, map[int]int{1: 5}, result.BlamelistLengths)
+	testutils.AssertDeepEqual(t, map[string]float64{
+		"sim-bot-000": float64(20*time.Minute) / float64(2*time.Hour),
+		"sim-bot-001": float64(15*time.Minute) / float64(2*time.Hour),
+	}, result.BotUtilization)
+	testutils.AssertDeepEqual(t, map[string]map[string]time.Duration{
+		repoName: map[string]time.Duration{
+			c1: 15 * time.Minute,
+			c2: c1Time.Add(30 * time.Minute).Sub(c2Time),
+		},
+	}, result.TimeToCoverage)
+	assert.Equal(t, 0, result.Uncovered)
+
+	var buf bytes.Buffer
+	assert.NoError(t, result.WriteReport(&buf))
+	assert.Contains(t, buf.String(), "Tasks triggered: 5\n")
+	assert.Contains(t, buf.String(), "Commits covered: 2 (0 uncovered)\n")
+
+	// With a shorter simulation, c2 is not covered.
+	cfg.End = c1Time.Add(20 * time.Minute)
+	result, err = Simulate(repos, cfg)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, map[string]map[string]time.Duration{
+		repoName: map[string]time.Duration{
+			c1: 15 * time.Minute,
+		},
+	}, result.TimeToCoverage)
+	assert.Equal(t, 1, result.Uncovered)
+}
+
+func TestSimulateStealing(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+
+	c1 := "b993cfa023855f4e27f0280465d477b0e0969708"
+	c2 := "21e4383ee704174c6ca715645181e076c4a30bdd"
+	repoName := "skia.git"
+	buildTask := "Build-Ubuntu-GCC-Arm7-Release-Android"
+
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	repo, err := repos.Repo(repoName)
+	assert.NoError(t, err)
+	c1Time := repo.Timestamp(c1)
+	c2Time := repo.Timestamp(c2)
+
+	cfg := &SimConfig{
+		Bots: []*SimBots{
+			&SimBots{
+				Count:      2,
+				Dimensions: []string{"pool:Skia", "os:Ubuntu"},
+			},
+		},
+		DefaultTaskDuration: 10 * time.Minute,
+		End:                 c2Time.Add(time.Hour),
+		Period:              24 * time.Hour,
+		Start:               c1Time.Add(-time.Minute),
+		Tick:                time.Minute,
+	}
+	sim, err := newSimulator(repos, cfg)
+	assert.NoError(t, err)
+
+	// The first bot is running a Build task at c2 whose blamelist includes
+	// c1.
+	prev := &db.Task{
+		Commits:  []string{c1, c2},
+		Created:  c2Time,
+		Name:     buildTask,
+		Repo:     repoName,
+		Revision: c2,
+		Started:  c2Time,
+		Status:   db.TASK_STATUS_RUNNING,
+	}
+	assert.NoError(t, sim.d.PutTask(prev))
+	sim.bots[0].task = prev
+	sim.bots[0].taskEnd = c2Time.Add(5 * time.Minute)
+
+	// The second bot bisects, running a Build task at c1 which steals c1
+	// from the running task.
+	assert.NoError(t, sim.step(c2Time.Add(time.Minute)))
+	assert.NotNil(t, sim.bots[1].task)
+	testutils.AssertDeepEqual(t, []string{c1}, sim.bots[1].task.Commits)
+	testutils.AssertDeepEqual(t, []string{c2}, sim.bots[0].task.Commits)
+
+	// When the first task finishes, the stolen commit is not written back.
+	assert.NoError(t, sim.step(c2Time.Add(5*time.Minute)))
+	assert.Nil(t, sim.bots[0].task)
+	finished, err := sim.d.GetTaskById(prev.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, db.TASK_STATUS_SUCCESS, finished.Status)
+	testutils.AssertDeepEqual(t, []string{c2}, finished.Commits)
+	stealing, err := sim.d.GetTaskById(sim.bots[1].task.Id)
+	assert.NoError(t, err)
+	testutils.AssertDeepEqual(t, []string{c1}, stealing.Commits)
+	testutils.AssertDeepEqual(t, map[int]int{1: 1}, sim.result.BlamelistLengths)
+}
@@ -5,15 +5,42 @@ import (
 	"encoding/gob"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
 	"strings"
 	"sync"
 	"time"
 
+	"go.skia.org/infra/build_scheduler/go/db"
+	"go.skia.org/infra/go/exec"
 	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/util"
 )
 
 const (
 	TASKS_CFG_FILE = "infra/bots/tasks.json"
+
+	// DEFAULT_TASK_SPEC_MAX_ATTEMPTS is the maximum number of attempts for a
+	// TaskSpec which does not specify MaxAttempts, ie. a failed task is
+	// retried once.
+	DEFAULT_TASK_SPEC_MAX_ATTEMPTS = 2
+
+	// DEFAULT_TASK_SPEC_PRIORITY is the priority of a TaskSpec which does not
+	// specify Priority.
+	DEFAULT_TASK_SPEC_PRIORITY = 0.5
+
+	// DEFAULT_TASK_SPEC_EXECUTION_TIMEOUT is the execution timeout of a
+	// TaskSpec which does not specify ExecutionTimeout.
+	DEFAULT_TASK_SPEC_EXECUTION_TIMEOUT = 4 * time.Hour
+
+	// DEFAULT_TASK_SPEC_EXPIRATION is the expiration of a TaskSpec which does
+	// not specify Expiration.
+	DEFAULT_TASK_SPEC_EXPIRATION = 20 * time.Hour
+
+	// DIMENSION_CIPD_PACKAGE is the key of the Swarming bot dimension which
+	// lists the CIPD packages installed on a bot, in "<name>:<version>" form.
+	DIMENSION_CIPD_PACKAGE = "cipd_package"
 )
 
 // ParseTasksCfg parses the given task cfg file contents and returns the config.
@@ -45,6 +72,46 @@ func ReadTasksCfg(repo *gitinfo.GitInfo, commit string) (*TasksCfg, error) {
 	return ParseTasksCfg(contents)
 }
 
+// ReadTasksCfgWithPatch reads the task cfg file from the given repo at the
+// given commit, with the given patch applied, and returns it.
+func ReadTasksCfgWithPatch(repo *gitinfo.GitInfo, commit, patch string) (*TasksCfg, error) {
+	// If the patch doesn't touch the task cfg file, just use the base commit.
+	if !strings.Contains(patch, TASKS_CFG_FILE) {
+		return ReadTasksCfg(repo, commit)
+	}
+
+	// Write the task cfg file to a temporary directory and apply the relevant
+	// part of the patch. The file may not exist at the base commit if the patch
+	// adds it.
+	tmp, err := ioutil.TempDir("", "tasks_cfg")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read tasks cfg: could not create temp dir: %s", err)
+	}
+	defer util.RemoveAll(tmp)
+	cfgFile := path.Join(tmp, TASKS_CFG_FILE)
+	if err := os.MkdirAll(path.Dir(cfgFile), os.ModePerm); err != nil {
+		return nil, fmt.Errorf("Failed to read tasks cfg: could not create temp dir: %s", err)
+	}
+	if contents, err := repo.GetFile(TASKS_CFG_FILE, commit); err == nil {
+		if err := ioutil.WriteFile(cfgFile, []byte(contents), os.ModePerm); err != nil {
+			return nil, fmt.Errorf("Failed to read tasks cfg: could not write file: %s", err)
+		}
+	}
+	if _, err := exec.RunCommand(&exec.Command{
+		Name:  "git",
+		Args:  []string{"apply", "--include=" + TASKS_CFG_FILE, "-"},
+		Dir:   tmp,
+		Stdin: strings.NewReader(patch),
+	}); err != nil {
+		return nil, fmt.Errorf("Failed to read tasks cfg: could not apply patch: %s", err)
+	}
+	contents, err := ioutil.ReadFile(cfgFile)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read tasks cfg: could not read file: %s", err)
+	}
+	return ParseTasksCfg(string(contents))
+}
+
 // TasksCfg is a struct which describes all Swarming tasks for a repo at a
 // particular commit.
 type TasksCfg struct {
@@ -66,11 +133,86 @@ type TaskSpec struct {
 	// which may run this task.
 	Dimensions []string `json:"dimensions"`
 
+	// ExecutionTimeout is the maximum amount of time the task may run before
+	// it is killed. If zero, DEFAULT_TASK_SPEC_EXECUTION_TIMEOUT is used. In
+	// JSON it is given as a duration string, eg. "4h" or "90m".
+	ExecutionTimeout time.Duration `json:"-"`
+
+	// Expiration is the maximum amount of time the task may wait in the
+	// Swarming queue before it expires without running. If zero,
+	// DEFAULT_TASK_SPEC_EXPIRATION is used. In JSON it is given as a duration
+	// string, eg. "20h".
+	Expiration time.Duration `json:"-"`
+
 	// Isolate is the name of the isolate file used by this task.
 	Isolate string `json:"isolate"`
 
-	// Priority indicates the relative priority of the task, with 0 < p <= 1
+	// MaxAttempts is the maximum number of times a task may run at a given
+	// commit, including the first attempt. If zero,
+	// DEFAULT_TASK_SPEC_MAX_ATTEMPTS is used.
+	MaxAttempts int `json:"max_attempts"`
+
+	// MaxConcurrency is the maximum number of tasks for this TaskSpec which
+	// may be scheduled at once. If zero, there is no limit.
+	MaxConcurrency int `json:"max_concurrency"`
+
+	// Priority indicates the relative priority of the task, with 0 <= p <= 1.
+	// Candidate scores are scaled by the priority. Zero means that no
+	// priority was specified, in which case DEFAULT_TASK_SPEC_PRIORITY is
+	// used.
 	Priority float64 `json:"priority"`
+
+	// RetryOnInfraFailureOnly indicates that failed tasks should only be
+	// retried if the failure was due to an infrastructure problem, ie. the
+	// task had status TASK_STATUS_MISHAP or TASK_STATUS_EXPIRED. Otherwise,
+	// tasks are retried on any failure.
+	RetryOnInfraFailureOnly bool `json:"retry_on_infra_failure_only"`
+}
+
+// taskSpecAlias has the fields of TaskSpec but not its JSON methods.
+type taskSpecAlias TaskSpec
+
+// taskSpecJSON is the JSON representation of a TaskSpec, which uses duration
+// strings instead of time.Duration.
+type taskSpecJSON struct {
+	*taskSpecAlias
+	ExecutionTimeout string `json:"execution_timeout,omitempty"`
+	Expiration       string `json:"expiration,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler.
+func (t *TaskSpec) MarshalJSON() ([]byte, error) {
+	rv := taskSpecJSON{taskSpecAlias: (*taskSpecAlias)(t)}
+	if t.ExecutionTimeout != 0 {
+		rv.ExecutionTimeout = t.ExecutionTimeout.String()
+	}
+	if t.Expiration != 0 {
+		rv.Expiration = t.Expiration.String()
+	}
+	return json.Marshal(rv)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (t *TaskSpec) UnmarshalJSON(b []byte) error {
+	rv := taskSpecJSON{taskSpecAlias: (*taskSpecAlias)(t)}
+	if err := json.Unmarshal(b, &rv); err != nil {
+		return err
+	}
+	if rv.ExecutionTimeout != "" {
+		d, err := time.ParseDuration(rv.ExecutionTimeout)
+		if err != nil {
+			return fmt.Errorf("Invalid execution_timeout %q: %s", rv.ExecutionTimeout, err)
+		}
+		t.ExecutionTimeout = d
+	}
+	if rv.Expiration != "" {
+		d, err := time.ParseDuration(rv.Expiration)
+		if err != nil {
+			return fmt.Errorf("Invalid expiration %q: %s", rv.Expiration, err)
+		}
+		t.Expiration = d
+	}
+	return nil
 }
 
 // Validate ensures that the TaskSpec is defined properly.
@@ -95,9 +237,79 @@ func (t *TaskSpec) Validate(cfg *TasksCfg) error {
 		return fmt.Errorf("Isolate file is required.")
 	}
 
+	if t.MaxAttempts < 0 {
+		return fmt.Errorf("MaxAttempts must be non-negative; got %d", t.MaxAttempts)
+	}
+
+	if t.MaxConcurrency < 0 {
+		return fmt.Errorf("MaxConcurrency must be non-negative; got %d", t.MaxConcurrency)
+	}
+
+	if t.Priority < 0 || t.Priority > 1 {
+		return fmt.Errorf("Priority must be in the range [0, 1]; got %v", t.Priority)
+	}
+
+	if t.ExecutionTimeout < 0 {
+		return fmt.Errorf("ExecutionTimeout must be non-negative; got %s", t.ExecutionTimeout)
+	}
+
+	if t.Expiration < 0 {
+		return fmt.Errorf("Expiration must be non-negative; got %s", t.Expiration)
+	}
+
 	return nil
 }
 
+// GetMaxAttempts returns the maximum number of times a task may run at a
+// given commit, including the first attempt.
+func (t *TaskSpec) GetMaxAttempts() int {
+	if t.MaxAttempts == 0 {
+		return DEFAULT_TASK_SPEC_MAX_ATTEMPTS
+	}
+	return t.MaxAttempts
+}
+
+// GetPriority returns the priority of tasks generated from this TaskSpec.
+func (t *TaskSpec) GetPriority() float64 {
+	if t.Priority == 0 {
+		return DEFAULT_TASK_SPEC_PRIORITY
+	}
+	return t.Priority
+}
+
+// GetExecutionTimeout returns the maximum amount of time a task generated from
+// this TaskSpec may run.
+func (t *TaskSpec) GetExecutionTimeout() time.Duration {
+	if t.ExecutionTimeout == 0 {
+		return DEFAULT_TASK_SPEC_EXECUTION_TIMEOUT
+	}
+	return t.ExecutionTimeout
+}
+
+// GetExpiration returns the maximum amount of time a task generated from this
+// TaskSpec may wait in the Swarming queue.
+func (t *TaskSpec) GetExpiration() time.Duration {
+	if t.Expiration == 0 {
+		return DEFAULT_TASK_SPEC_EXPIRATION
+	}
+	return t.Expiration
+}
+
+// ShouldRetry returns true iff a task generated from this TaskSpec should be
+// retried, given the previous attempt.
+func (t *TaskSpec) ShouldRetry(prev *db.Task) bool {
+	if !prev.Done() || prev.Success() {
+		return false
+	}
+	if prev.Attempt+1 >= t.GetMaxAttempts() {
+		return false
+	}
+	if t.RetryOnInfraFailureOnly && prev.Status != db.TASK_STATUS_MISHAP && prev.Status != db.TASK_STATUS_EXPIRED {
+		return false
+	}
+	return true
+}
+
 // CipdPackage is a struct representing a CIPD package which needs to be
 // installed on a bot for a particular task.
 type CipdPackage struct {
@@ -106,6 +318,12 @@ type CipdPackage struct {
 	Version int64  `json:"version"`
 }
 
+// botDimension returns the Swarming bot dimension which indicates that the
+// CipdPackage is already installed on a bot.
+func (p *CipdPackage) botDimension() string {
+	return fmt.Sprintf("%s:%s:%d", DIMENSION_CIPD_PACKAGE, p.Name, p.Version)
+}
+
 // taskCfgCache is a struct used for caching tasks cfg files. The user should
 // periodically call Cleanup() to remove old entries.
 type taskCfgCache struct {
@@ -145,6 +363,14 @@ func (c *taskCfgCache) readTasksCfg(repo, commit string) (*TasksCfg, error) {
 	return c.cache[repo][commit], nil
 }
 
+// ReadTasksCfg returns the task cfg file for the given commit in the given
+// repo, reading it if it is not already cached.
+func (c *taskCfgCache) ReadTasksCfg(repo, commit string) (*TasksCfg, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.readTasksCfg(repo, commit)
+}
+
 // GetTaskSpecsForCommits returns a set of TaskSpecs for each of the
 // given set of commits, in the form of nested maps:
 //
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/build_scheduler/go/db"
 	"go.skia.org/infra/go/gitinfo"
 	"go.skia.org/infra/go/testutils"
 	"go.skia.org/infra/go/util"
@@ -138,3 +139,143 @@ func TestTasksCircularDependency(t *testing.T) {
 	}))
 	assert.NoError(t, err)
 }
+
+func TestTaskSpecShouldRetry(t *testing.T) {
+	spec := &TaskSpec{}
+	task := &db.Task{
+		Status: db.TASK_STATUS_PENDING,
+	}
+
+	// Don't retry unfinished or successful tasks.
+	assert.False(t, spec.ShouldRetry(task))
+	task.Status = db.TASK_STATUS_RUNNING
+	assert.False(t, spec.ShouldRetry(task))
+	task.Status = db.TASK_STATUS_SUCCESS
+	assert.False(t, spec.ShouldRetry(task))
+
+	// By default, retry once on any failure.
+	task.Status = db.TASK_STATUS_FAILURE
+	assert.True(t, spec.ShouldRetry(task))
+	task.Status = db.TASK_STATUS_MISHAP
+	assert.True(t, spec.ShouldRetry(task))
+	task.Attempt = 1
+	assert.False(t, spec.ShouldRetry(task))
+
+	// More attempts.
+	spec.MaxAttempts = 3
+	assert.True(t, spec.ShouldRetry(task))
+	task.Attempt = 2
+	assert.False(t, spec.ShouldRetry(task))
+
+	// No retries.
+	spec.MaxAttempts = 1
+	task.Attempt = 0
+	assert.False(t, spec.ShouldRetry(task))
+
+	// Only retry infra failures.
+	spec.MaxAttempts = 0
+	spec.RetryOnInfraFailureOnly = true
+	task.Status = db.TASK_STATUS_FAILURE
+	assert.False(t, spec.ShouldRetry(task))
+	task.Status = db.TASK_STATUS_MISHAP
+	assert.True(t, spec.ShouldRetry(task))
+	task.Status = db.TASK_STATUS_EXPIRED
+	assert.True(t, spec.ShouldRetry(task))
+
+	// Validation.
+	spec.Isolate = "abc123"
+	assert.NoError(t, spec.Validate(&TasksCfg{}))
+	spec.MaxAttempts = -1
+	assert.EqualError(t, spec.Validate(&TasksCfg{}), "MaxAttempts must be non-negative; got -1")
+	spec.MaxAttempts = 0
+	spec.MaxConcurrency = -1
+	assert.EqualError(t, spec.Validate(&TasksCfg{}), "MaxConcurrency must be non-negative; got -1")
+	spec.MaxConcurrency = 0
+	spec.Priority = 1.5
+	assert.EqualError(t, spec.Validate(&TasksCfg{}), "Priority must be in the range [0, 1]; got 1.5")
+	spec.Priority = -0.5
+	assert.EqualError(t, spec.Validate(&TasksCfg{}), "Priority must be in the range [0, 1]; got -0.5")
+	spec.Priority = 0
+	assert.NoError(t, spec.Validate(&TasksCfg{}))
+	assert.Equal(t, DEFAULT_TASK_SPEC_PRIORITY, spec.GetPriority())
+	spec.ExecutionTimeout = -time.Minute
+	assert.EqualError(t, spec.Validate(&TasksCfg{}), "ExecutionTimeout must be non-negative; got -1m0s")
+	spec.ExecutionTimeout = 0
+	spec.Expiration = -time.Minute
+	assert.EqualError(t, spec.Validate(&TasksCfg{}), "Expiration must be non-negative; got -1m0s")
+	spec.Expiration = 0
+	assert.NoError(t, spec.Validate(&TasksCfg{}))
+}
+
+func TestTaskSpecDefaults(t *testing.T) {
+	spec := &TaskSpec{}
+	assert.Equal(t, DEFAULT_TASK_SPEC_PRIORITY, spec.GetPriority())
+	assert.Equal(t, DEFAULT_TASK_SPEC_EXECUTION_TIMEOUT, spec.GetExecutionTimeout())
+	assert.Equal(t, DEFAULT_TASK_SPEC_EXPIRATION, spec.GetExpiration())
+
+	cfg, err := ParseTasksCfg(`{
+  "tasks": {
+    "a": {
+      "execution_timeout": "1h",
+      "expiration": "2h",
+      "isolate": "a.isolate",
+      "priority": 0.8
+    }
+  }
+}`)
+	assert.NoError(t, err)
+	spec = cfg.Tasks["a"]
+	assert.Equal(t, 0.8, spec.GetPriority())
+	assert.Equal(t, time.Hour, spec.GetExecutionTimeout())
+	assert.Equal(t, 2*time.Hour, spec.GetExpiration())
+
+	// Durations survive a round trip through JSON.
+	b, err := json.Marshal(spec)
+	assert.NoError(t, err)
+	spec2 := &TaskSpec{}
+	assert.NoError(t, json.Unmarshal(b, spec2))
+	testutils.AssertDeepEqual(t, spec, spec2)
+
+	_, err = ParseTasksCfg(`{"tasks": {"a": {"isolate": "a.isolate", "expiration": "2 hours"}}}`)
+	assert.Error(t, err)
+}
+
+// testPatch adds a task spec to the tasks cfg file in the test repo.
+const testPatch = `diff --git a/infra/bots/tasks.json b/infra/bots/tasks.json
+index da39876..e490f79 100644
+--- a/infra/bots/tasks.json
++++ b/infra/bots/tasks.json
+@@ -1,5 +1,10 @@
+ {
+   "tasks": {
++    "Housekeeper-Nightly-RecreateSKPs": {
++      "dimensions": ["pool:Skia", "os:Ubuntu"],
++      "isolate": "recreate_skps.isolate",
++      "priority": 0.8
++    },
+     "Build-Ubuntu-GCC-Arm7-Release-Android": {
+       "cipd_packages": [{
+         "name": "android_sdk",
+`
+
+func TestReadTasksCfgWithPatch(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	repo, err := repos.Repo("skia.git")
+	assert.NoError(t, err)
+	c2 := "21e4383ee704174c6ca715645181e076c4a30bdd"
+
+	// A patch which doesn't touch the tasks cfg file.
+	cfg, err := ReadTasksCfgWithPatch(repo, c2, "diff --git a/README b/README\n")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(cfg.Tasks))
+
+	// A patch which adds a task.
+	cfg, err = ReadTasksCfgWithPatch(repo, c2, testPatch)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(cfg.Tasks))
+	assert.NotNil(t, cfg.Tasks["Housekeeper-Nightly-RecreateSKPs"])
+}
@@ -1,38 +1,80 @@
 package task_scheduler
 
-import "go.skia.org/infra/build_scheduler/go/db"
+import (
+	"fmt"
+	"strings"
+
+	swarming_api "github.com/luci/luci-go/common/api/swarming/swarming/v1"
+	"go.skia.org/infra/build_scheduler/go/db"
+	"go.skia.org/infra/go/isolate"
+)
+
+const (
+	// SWARMING_PRIORITY_HIGHEST and SWARMING_PRIORITY_LOWEST are the range
+	// of Swarming priorities used for tasks. Note that Swarming runs tasks
+	// with lower priority values first.
+	SWARMING_PRIORITY_HIGHEST = 1
+	SWARMING_PRIORITY_LOWEST  = 255
+)
 
 // taskCandidate is a struct used for determining which tasks to schedule.
 type taskCandidate struct {
+	Attempt        int
 	Commits        []string
 	IsolatedHashes []string
+	IsolatedInput  string
+	Issue          string
 	Name           string
+	Patchset       string
 	Repo           string
+	RetryOf        *db.Task
 	Revision       string
 	Score          float64
+	Server         string
 	StealingFrom   *db.Task
 	TaskSpec       *TaskSpec
 }
 
+// IsTryJob returns true iff the taskCandidate runs with a patch applied.
+func (c *taskCandidate) IsTryJob() bool {
+	return c.Issue != ""
+}
+
 // MakeTask instantiates a db.Task from the taskCandidate.
 func (c *taskCandidate) MakeTask() *db.Task {
-	commits := make([]string, 0, len(c.Commits))
+	commits := make([]string, len(c.Commits))
 	copy(commits, c.Commits)
+	retryOf := ""
+	if c.RetryOf != nil {
+		retryOf = c.RetryOf.Id
+	}
 	return &db.Task{
+		Attempt:  c.Attempt,
 		Commits:  commits,
 		Id:       "", // Filled in when the task is inserted into the DB.
+		Issue:    c.Issue,
 		Name:     c.Name,
+		Patchset: c.Patchset,
 		Repo:     c.Repo,
+		RetryOf:  retryOf,
 		Revision: c.Revision,
+		Server:   c.Server,
 	}
 }
 
 // allDepsMet determines whether all dependencies for the given task candidate
-// have been satisfied, and if so, returns their isolated outputs.
+// have been satisfied, and if so, returns their isolated outputs. Try jobs
+// depend on tasks which ran with the same patch.
 func (c *taskCandidate) allDepsMet(cache *db.TaskCache) (bool, []string, error) {
 	isolatedHashes := make([]string, 0, len(c.TaskSpec.Dependencies))
 	for _, depName := range c.TaskSpec.Dependencies {
-		d, err := cache.GetTaskForCommit(depName, c.Revision)
+		var d *db.Task
+		var err error
+		if c.IsTryJob() {
+			d, err = cache.GetTaskForPatch(c.Server, c.Issue, c.Patchset, depName)
+		} else {
+			d, err = cache.GetTaskForCommit(depName, c.Revision)
+		}
 		if err != nil {
 			return false, nil, err
 		}
@@ -55,5 +97,58 @@ func (s taskCandidateSlice) Swap(i, j int) {
 	s[i], s[j] = s[j], s[i]
 }
 func (s taskCandidateSlice) Less(i, j int) bool {
+	// Try jobs sort before all other candidates.
+	if s[i].IsTryJob() != s[j].IsTryJob() {
+		return s[i].IsTryJob()
+	}
 	return s[i].Score > s[j].Score // candidates sort in decreasing order.
 }
+
+// swarmingPriority converts a TaskSpec priority, where 0 < p <= 1 and higher
+// values are more important, into a Swarming priority, where lower values are
+// more important.
+func swarmingPriority(p float64) int64 {
+	return SWARMING_PRIORITY_HIGHEST + int64((1.0-p)*float64(SWARMING_PRIORITY_LOWEST-SWARMING_PRIORITY_HIGHEST))
+}
+
+// MakeTaskRequest creates a SwarmingRpcsNewTaskRequest object from the
+// taskCandidate. The given id is the Id of the db.Task which will track the
+// Swarming task. The candidate's IsolatedInput must be set.
+func (c *taskCandidate) MakeTaskRequest(id string) *swarming_api.SwarmingRpcsNewTaskRequest {
+	dims := make([]*swarming_api.SwarmingRpcsStringPair, 0, len(c.TaskSpec.Dimensions))
+	for _, d := range c.TaskSpec.Dimensions {
+		split := strings.SplitN(d, ":", 2)
+		dims = append(dims, &swarming_api.SwarmingRpcsStringPair{
+			Key:   split[0],
+			Value: split[1],
+		})
+	}
+	tags := []string{
+		fmt.Sprintf("%s:%s", db.SWARMING_TAG_ID, id),
+		fmt.Sprintf("%s:%s", db.SWARMING_TAG_NAME, c.Name),
+		fmt.Sprintf("%s:%s", db.SWARMING_TAG_REPO, c.Repo),
+		fmt.Sprintf("%s:%s", db.SWARMING_TAG_REVISION, c.Revision),
+	}
+	if c.IsTryJob() {
+		tags = append(tags,
+			fmt.Sprintf("%s:%s", db.SWARMING_TAG_ISSUE, c.Issue),
+			fmt.Sprintf("%s:%s", db.SWARMING_TAG_PATCHSET, c.Patchset),
+			fmt.Sprintf("%s:%s", db.SWARMING_TAG_SERVER, c.Server),
+		)
+	}
+	return &swarming_api.SwarmingRpcsNewTaskRequest{
+		ExpirationSecs: int64(c.TaskSpec.GetExpiration().Seconds()),
+		Name:           c.Name,
+		Priority:       swarmingPriority(c.TaskSpec.GetPriority()),
+		Properties: &swarming_api.SwarmingRpcsTaskProperties{
+			Dimensions:           dims,
+			ExecutionTimeoutSecs: int64(c.TaskSpec.GetExecutionTimeout().Seconds()),
+			InputsRef: &swarming_api.SwarmingRpcsFilesRef{
+				Isolated:       c.IsolatedInput,
+				Isolatedserver: isolate.ISOLATE_SERVER_URL,
+				Namespace:      isolate.ISOLATE_NAMESPACE,
+			},
+		},
+		Tags: tags,
+	}
+}
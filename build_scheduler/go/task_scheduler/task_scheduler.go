@@ -3,15 +3,19 @@ package task_scheduler
 import (
 	"fmt"
 	"math"
+	"path"
 	"sort"
+	"strconv"
 	"sync"
 	"time"
 
 	swarming_api "github.com/luci/luci-go/common/api/swarming/swarming/v1"
 	"github.com/skia-dev/glog"
+	"go.skia.org/infra/build_scheduler/go/blacklist"
 	"go.skia.org/infra/build_scheduler/go/db"
 	"go.skia.org/infra/go/buildbot"
 	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/isolate"
 	"go.skia.org/infra/go/metrics2"
 	"go.skia.org/infra/go/swarming"
 	"go.skia.org/infra/go/util"
@@ -19,30 +23,77 @@ import (
 
 // TaskScheduler is a struct used for scheduling builds on bots.
 type TaskScheduler struct {
+	bl               *blacklist.Blacklist
 	cache            *db.TaskCache
+	db               db.DB
+	getPatch         func(string, int64, int64) (string, error)
+	isolateCache     *isolateCache
+	jobs             []*activeJob
+	jobsMtx          sync.Mutex
+	listBots         func() ([]*swarming_api.SwarmingRpcsBotInfo, error)
 	period           time.Duration
 	queue            []*taskCandidate
 	queueMtx         sync.RWMutex
 	repos            *gitinfo.RepoMap
 	taskCfgCache     *taskCfgCache
 	timeDecayAmt24Hr float64
+	triggerTask      func(*swarming_api.SwarmingRpcsNewTaskRequest) (*swarming_api.SwarmingRpcsTaskRequestMetadata, error)
+	tryMtx           sync.Mutex
+	tryRequests      []*TryRequest
 }
 
-func NewTaskScheduler(cache *db.TaskCache, period time.Duration, repos *gitinfo.RepoMap) *TaskScheduler {
+// NewTaskScheduler returns a TaskScheduler instance. Tasks which match a Rule
+// in the given Blacklist are not scheduled. The Blacklist may be nil.
+func NewTaskScheduler(d db.DB, bl *blacklist.Blacklist, cache *db.TaskCache, period time.Duration, repos *gitinfo.RepoMap) *TaskScheduler {
 	s := &TaskScheduler{
+		bl:               bl,
 		cache:            cache,
+		db:               d,
+		getPatch:         getPatchFromRietveld,
+		jobs:             []*activeJob{},
 		period:           period,
 		queue:            []*taskCandidate{},
 		queueMtx:         sync.RWMutex{},
 		repos:            repos,
 		taskCfgCache:     newTaskCfgCache(repos),
 		timeDecayAmt24Hr: 1.0,
+		tryRequests:      []*TryRequest{},
 	}
 	return s
 }
 
-// Start initiates the TaskScheduler's goroutines for scheduling tasks.
-func (s *TaskScheduler) Start() {
+// GetBlacklist returns the TaskScheduler's Blacklist.
+func (s *TaskScheduler) GetBlacklist() *blacklist.Blacklist {
+	return s.bl
+}
+
+// SetIsolateClient causes the TaskScheduler to isolate the inputs of the task
+// candidates which it schedules, using the given isolate.Client. Isolated
+// hashes are cached in the given file, so that they survive restarts.
+func (s *TaskScheduler) SetIsolateClient(c *isolate.Client, cacheFile string) error {
+	ic, err := newIsolateCache(cacheFile, s.repos, c.IsolateTasks)
+	if err != nil {
+		return err
+	}
+	s.isolateCache = ic
+	return nil
+}
+
+// SetSwarmingClient causes the TaskScheduler to trigger the tasks which it
+// schedules on the free bots of the given swarming.ApiClient.
+func (s *TaskScheduler) SetSwarmingClient(c *swarming.ApiClient) {
+	s.listBots = func() ([]*swarming_api.SwarmingRpcsBotInfo, error) {
+		return getFreeSwarmingBots(c)
+	}
+	s.triggerTask = c.TriggerTask
+}
+
+// Start loads the Jobs which were in progress when the TaskScheduler last
+// exited and initiates the TaskScheduler's goroutines for scheduling tasks.
+func (s *TaskScheduler) Start() error {
+	if err := s.loadUnfinishedJobs(time.Now(), JOB_RELOAD_PERIOD); err != nil {
+		return err
+	}
 	go func() {
 		lv := metrics2.NewLiveness("last-successful-queue-regeneration")
 		for _ = range time.Tick(time.Minute) {
@@ -53,6 +104,24 @@ func (s *TaskScheduler) Start() {
 			}
 		}
 	}()
+	if s.triggerTask != nil {
+		go func() {
+			lv := metrics2.NewLiveness("last-successful-task-trigger")
+			for _ = range time.Tick(time.Minute) {
+				bots, err := s.listBots()
+				if err != nil {
+					glog.Errorf("Failed to retrieve free bots: %s", err)
+					continue
+				}
+				if _, err := s.triggerTasks(bots, time.Now()); err != nil {
+					glog.Errorf("Failed to trigger tasks: %s", err)
+				} else {
+					lv.Reset()
+				}
+			}
+		}()
+	}
+	return nil
 }
 
 // ComputeBlamelist computes the blamelist for the given taskCandidate. Returns
@@ -74,7 +143,10 @@ func ComputeBlamelist(cache *db.TaskCache, repos *gitinfo.RepoMap, c *taskCandid
 	commits := map[string]bool{}
 	var stealFrom *db.Task
 
-	// TODO(borenet): If this is a try job, don't compute the blamelist.
+	// Try jobs are kept apart from the main-branch blamelists.
+	if c.IsTryJob() {
+		return []string{}, nil, nil
+	}
 
 	// If this is the first invocation of a given task spec, save time by
 	// setting the blamelist to only be c.Revision.
@@ -189,27 +261,38 @@ func (s *TaskScheduler) findTaskCandidates(commitsByRepo map[string][]string) ([
 		return nil, err
 	}
 	candidates := []*taskCandidate{}
+	now := time.Now()
 	for repo, commits := range specs {
 		for commit, tasks := range commits {
 			for name, task := range tasks {
+				// Skip blacklisted tasks.
+				if s.bl != nil && s.bl.MatchTask(name, task.Dimensions, commit, now) {
+					continue
+				}
+
 				// We shouldn't duplicate pending, in-progress,
-				// or successfully completed tasks.
+				// or successfully completed tasks. Failed tasks
+				// may be retried, up to the limit set by the
+				// TaskSpec.
 				previous, err := s.cache.GetTaskForCommit(name, commit)
 				if err != nil {
 					return nil, err
 				}
+				var retryOf *db.Task
+				attempt := 0
 				if previous != nil && previous.Revision == commit {
-					if previous.Status == db.TASK_STATUS_PENDING || previous.Status == db.TASK_STATUS_RUNNING {
-						continue
-					}
-					if previous.Success() {
+					if !task.ShouldRetry(previous) {
 						continue
 					}
+					retryOf = previous
+					attempt = previous.Attempt + 1
 				}
 				candidates = append(candidates, &taskCandidate{
+					Attempt:        attempt,
 					IsolatedHashes: nil,
 					Name:           name,
 					Repo:           repo,
+					RetryOf:        retryOf,
 					Revision:       commit,
 					Score:          0.0,
 					TaskSpec:       task,
@@ -236,8 +319,8 @@ func (s *TaskScheduler) findTaskCandidates(commitsByRepo map[string][]string) ([
 }
 
 // processTaskCandidates computes the remaining information about each task
-// candidate, eg. blamelists and scoring.
-func (s *TaskScheduler) processTaskCandidates(candidates []*taskCandidate) error {
+// candidate, eg. blamelists and scoring, as of the given time.
+func (s *TaskScheduler) processTaskCandidates(candidates []*taskCandidate, now time.Time) error {
 	// Compute blamelists.
 	for _, c := range candidates {
 		commits, stealingFrom, err := ComputeBlamelist(s.cache, s.repos, c)
@@ -249,22 +332,29 @@ func (s *TaskScheduler) processTaskCandidates(candidates []*taskCandidate) error
 	}
 
 	// Score the candidates.
-	now := time.Now()
 	for _, c := range candidates {
 		// The score for a candidate is based on the "testedness" increase
-		// provided by running the task.
-		stoleFromCommits := 0
-		if c.StealingFrom != nil {
-			stoleFromCommits = len(c.StealingFrom.Commits)
+		// provided by running the task. Retries don't change the
+		// testedness of their blamelist, so they are scored separately.
+		var score float64
+		if c.RetryOf != nil {
+			score = retryScore(len(c.Commits), c.Attempt)
+		} else {
+			stoleFromCommits := 0
+			if c.StealingFrom != nil {
+				stoleFromCommits = len(c.StealingFrom.Commits)
+			}
+			score = testednessIncrease(len(c.Commits), stoleFromCommits)
 		}
-		score := testednessIncrease(len(c.Commits), stoleFromCommits)
 
-		// Scale the score by other factors, eg. time decay.
+		// Scale the score by other factors, eg. time decay and the
+		// TaskSpec's priority.
 		decay, err := s.timeDecayForCommit(now, c.Repo, c.Revision)
 		if err != nil {
 			return err
 		}
 		score *= decay
+		score *= c.TaskSpec.GetPriority()
 
 		c.Score = score
 	}
@@ -280,6 +370,11 @@ func (s *TaskScheduler) regenerateTaskQueue() error {
 		return nil
 	}
 
+	// Update the status of any unfinished Jobs.
+	if err := s.updateJobs(time.Now()); err != nil {
+		return err
+	}
+
 	// Find the recent commits to use.
 	if err := s.repos.Update(); err != nil {
 		return err
@@ -294,15 +389,27 @@ func (s *TaskScheduler) regenerateTaskQueue() error {
 		commits[repoName] = repo.From(from)
 	}
 
+	// Record any retries which succeeded as flakes.
+	if err := s.classifyFlakes(commits); err != nil {
+		return err
+	}
+
 	// Find and process task candidates.
 	candidates, err := s.findTaskCandidates(commits)
 	if err != nil {
 		return err
 	}
-	if err := s.processTaskCandidates(candidates); err != nil {
+	if err := s.processTaskCandidates(candidates, time.Now()); err != nil {
 		return err
 	}
 
+	// Try jobs take priority over all other candidates.
+	tryCandidates, err := s.findTryCandidates()
+	if err != nil {
+		return err
+	}
+	candidates = append(tryCandidates, candidates...)
+
 	s.queueMtx.Lock()
 	defer s.queueMtx.Unlock()
 	// TODO(borenet): Find a faster data structure for matching candidates
@@ -312,69 +419,367 @@ func (s *TaskScheduler) regenerateTaskQueue() error {
 	return nil
 }
 
+// classifyFlakes finds retries which ran at the given commits and succeeded,
+// marks them as flaky, and writes them back to the database. Retries are only
+// triggered for failed tasks, so a successful retry means that the task passed
+// and failed at the same commit. The tasks are re-read from the database
+// before they are modified, so that updates which have not yet reached the
+// cache, eg. a task finishing, are not overwritten.
+func (s *TaskScheduler) classifyFlakes(commitsByRepo map[string][]string) error {
+	ids := map[string]bool{}
+	for _, commits := range commitsByRepo {
+		tasks, err := s.cache.GetTasksForCommits(commits)
+		if err != nil {
+			return err
+		}
+		for _, byName := range tasks {
+			for _, t := range byName {
+				if t.IsRetry() && t.Success() && !t.Flaky {
+					ids[t.Id] = true
+				}
+			}
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	update := make([]*db.Task, 0, len(ids))
+	for id, _ := range ids {
+		t, err := s.db.GetTaskById(id)
+		if err != nil {
+			return err
+		}
+		if t == nil || !t.IsRetry() || !t.Success() || t.Flaky {
+			continue
+		}
+		t.Flaky = true
+		update = append(update, t)
+	}
+	if len(update) > 0 {
+		if err := s.db.PutTasks(update); err != nil {
+			return err
+		}
+	}
+	return s.cache.Update()
+}
+
 // getCandidatesToSchedule matches the list of free Swarming bots to task
-// candidates in the queue and returns the candidates which should be run.
-// Assumes that the tasks are sorted in decreasing order by score.
-func getCandidatesToSchedule(bots []*swarming_api.SwarmingRpcsBotInfo, tasks []*taskCandidate) map[string]*taskCandidate {
-	// Create a bots-by-swarming-dimension mapping.
-	botsByDim := map[string]util.StringSet{}
+// candidates in the queue and returns the candidates which should be run,
+// keyed by bot ID. Assumes that the tasks are sorted in decreasing order by
+// score.
+//
+// Rather than greedily giving each candidate the first bot which matches, we
+// find a matching which maximizes the total score of the scheduled tasks. Since
+// a candidate's score does not depend on which bot runs it, this is a maximum
+// weight matching in a transversal matroid, which we obtain by considering the
+// candidates in decreasing order by score and adding each one if there is an
+// augmenting path for it, ie. if the bots already assigned can be shuffled to
+// make room for it.
+//
+// Each TaskSpec's MaxConcurrency limits the number of tasks for that TaskSpec
+// which may be pending or running at once. running holds the number of
+// unfinished tasks for each TaskSpec name, which count towards the limit.
+// Candidates for the same TaskSpec have the same dimensions, so the candidates
+// we skip because of the limit could not have been exchanged for
+// higher-scoring ones and the result is still optimal.
+func getCandidatesToSchedule(bots []*swarming_api.SwarmingRpcsBotInfo, tasks []*taskCandidate, running map[string]int) map[string]*taskCandidate {
+	// Find the dimensions of each bot.
+	botDims := make(map[string]util.StringSet, len(bots))
 	for _, b := range bots {
+		dims := util.StringSet{}
 		for _, dim := range b.Dimensions {
 			for _, val := range dim.Value {
-				d := fmt.Sprintf("%s:%s", dim.Key, val)
-				if _, ok := botsByDim[d]; !ok {
-					botsByDim[d] = util.StringSet{}
-				}
-				botsByDim[d][b.BotId] = true
+				dims[fmt.Sprintf("%s:%s", dim.Key, val)] = true
 			}
 		}
+		botDims[b.BotId] = dims
+	}
+	botIds := make([]string, 0, len(botDims))
+	for botId, _ := range botDims {
+		botIds = append(botIds, botId)
 	}
+	sort.Strings(botIds)
 
 	// Match bots to tasks.
-	// TODO(borenet): Some tasks require a more specialized bot. We should
-	// match so that less-specialized tasks don't "steal" more-specialized
-	// bots which they don't actually need.
-	rv := make(map[string]*taskCandidate, len(bots))
+	rv := make(map[string]*taskCandidate, len(botIds))
+	eligible := map[*taskCandidate][]string{}
+	scheduled := make(map[string]int, len(running))
+	for name, n := range running {
+		scheduled[name] = n
+	}
 	for _, c := range tasks {
-		// For each dimension of the task, find the set of bots which matches.
-		matches := util.StringSet{}
-		for i, d := range c.TaskSpec.Dimensions {
-			if i == 0 {
-				matches = matches.Union(botsByDim[d])
-			} else {
-				matches = matches.Intersect(botsByDim[d])
+		// If we've exhausted the bot list, stop here.
+		if len(rv) == len(botIds) {
+			break
+		}
+		if c.TaskSpec.MaxConcurrency > 0 && scheduled[c.Name] >= c.TaskSpec.MaxConcurrency {
+			continue
+		}
+		candidateBots := eligibleBots(c, botIds, botDims)
+		if len(candidateBots) == 0 {
+			continue
+		}
+		eligible[c] = candidateBots
+		if augmentMatching(c, eligible, rv, util.StringSet{}) {
+			scheduled[c.Name]++
+		} else {
+			delete(eligible, c)
+		}
+	}
+	return rv
+}
+
+// scheduleTasks matches the given free Swarming bots to candidates in the queue
+// and isolates the inputs of the chosen candidates, if an isolate client has
+// been set. Returns the candidates which should be run, keyed by bot ID.
+func (s *TaskScheduler) scheduleTasks(bots []*swarming_api.SwarmingRpcsBotInfo) (map[string]*taskCandidate, error) {
+	running, err := countUnfinishedTasks(s.cache)
+	if err != nil {
+		return nil, err
+	}
+	s.queueMtx.RLock()
+	schedule := getCandidatesToSchedule(bots, s.queue, running)
+	s.queueMtx.RUnlock()
+
+	if s.isolateCache != nil {
+		candidates := make([]*taskCandidate, 0, len(schedule))
+		for _, c := range schedule {
+			candidates = append(candidates, c)
+		}
+		if err := s.isolateCandidates(candidates); err != nil {
+			return nil, err
+		}
+	}
+	return schedule, nil
+}
+
+// triggerTasks schedules candidates from the queue on the given free bots,
+// triggers a Swarming task for each of them and inserts the corresponding
+// db.Tasks into the DB, along with the previous tasks whose blamelists were
+// stolen, then updates the task cache. Returns the inserted tasks. Candidates
+// which fail to trigger are logged and skipped; they remain in the queue and
+// are tried again next time.
+func (s *TaskScheduler) triggerTasks(bots []*swarming_api.SwarmingRpcsBotInfo, now time.Time) ([]*db.Task, error) {
+	schedule, err := s.scheduleTasks(bots)
+	if err != nil {
+		return nil, err
+	}
+	botIds := make([]string, 0, len(schedule))
+	for botId, _ := range schedule {
+		botIds = append(botIds, botId)
+	}
+	sort.Strings(botIds)
+
+	triggered := make([]*db.Task, 0, len(schedule))
+	triggeredCandidates := map[*taskCandidate]bool{}
+	stolen := map[string]*db.Task{}
+	for _, botId := range botIds {
+		c := schedule[botId]
+		t := c.MakeTask()
+		t.Created = now
+		if err := s.db.AssignId(t); err != nil {
+			return nil, err
+		}
+		resp, err := s.triggerTask(c.MakeTaskRequest(t.Id))
+		if err != nil {
+			glog.Errorf("Failed to trigger task %s at %s: %s", c.Name, c.Revision, err)
+			continue
+		}
+		t.SwarmingTaskId = resp.TaskId
+		if resp.Request != nil {
+			if created, err := swarming.Created(resp); err == nil {
+				t.Created = created
 			}
 		}
-		if len(matches) > 0 {
-			// We're going to run this task. Choose a bot. Sort the
-			// bots by ID so that the choice is deterministic.
-			choices := make([]string, 0, len(matches))
-			for botId, _ := range matches {
-				choices = append(choices, botId)
+		// Remove stolen commits from the blamelist of the previous task.
+		// Multiple candidates may steal from the same task.
+		if c.StealingFrom != nil {
+			prev, ok := stolen[c.StealingFrom.Id]
+			if !ok {
+				prev = c.StealingFrom.Copy()
+				stolen[prev.Id] = prev
 			}
-			sort.Strings(choices)
-			bot := choices[0]
-
-			// Remove the bot from consideration.
-			for dim, subset := range botsByDim {
-				delete(subset, bot)
-				if len(subset) == 0 {
-					delete(botsByDim, dim)
+			prev.Commits = util.NewStringSet(prev.Commits).Complement(util.NewStringSet(t.Commits)).Keys()
+			sort.Strings(prev.Commits)
+		}
+		triggered = append(triggered, t)
+		triggeredCandidates[c] = true
+	}
+
+	updated := make([]*db.Task, 0, len(triggered)+len(stolen))
+	updated = append(updated, triggered...)
+	for _, t := range stolen {
+		updated = append(updated, t)
+	}
+	if err := s.db.PutTasks(updated); err != nil {
+		return nil, err
+	}
+	// Update the cache so that the next call, which may happen before the
+	// queue is regenerated, counts the tasks triggered here against
+	// MaxConcurrency.
+	if err := s.cache.Update(); err != nil {
+		return nil, err
+	}
+
+	// Don't trigger the same candidates again before the queue is
+	// regenerated.
+	s.queueMtx.Lock()
+	defer s.queueMtx.Unlock()
+	queue := make([]*taskCandidate, 0, len(s.queue))
+	for _, c := range s.queue {
+		if !triggeredCandidates[c] {
+			queue = append(queue, c)
+		}
+	}
+	s.queue = queue
+	return triggered, nil
+}
+
+// countUnfinishedTasks returns the number of pending and running tasks in the
+// cache, keyed by task name.
+func countUnfinishedTasks(cache *db.TaskCache) (map[string]int, error) {
+	unfinished, err := cache.UnfinishedTasks()
+	if err != nil {
+		return nil, err
+	}
+	rv := map[string]int{}
+	for _, t := range unfinished {
+		rv[t.Name]++
+	}
+	return rv, nil
+}
+
+// isolateCandidates sets the IsolatedInput of each of the given candidates,
+// which includes the outputs of the candidate's dependencies, using the isolateCache so that candidates which share inputs with each other
+// or with previously-isolated candidates are not isolated again.
+func (s *TaskScheduler) isolateCandidates(candidates []*taskCandidate) error {
+	patches := map[string]string{}
+	reqs := make([]*isolateRequest, 0, len(candidates))
+	for _, c := range candidates {
+		r := &isolateRequest{
+			Deps:        c.IsolatedHashes,
+			IsolateFile: path.Join(ISOLATE_DIR, c.TaskSpec.Isolate),
+			Repo:        c.Repo,
+			Revision:    c.Revision,
+		}
+		if c.IsTryJob() {
+			r.PatchId = fmt.Sprintf("%s/%s/%s", c.Server, c.Issue, c.Patchset)
+			patch, ok := patches[r.PatchId]
+			if !ok {
+				issue, err := strconv.ParseInt(c.Issue, 10, 64)
+				if err != nil {
+					return fmt.Errorf("Invalid issue %q: %s", c.Issue, err)
+				}
+				patchset, err := strconv.ParseInt(c.Patchset, 10, 64)
+				if err != nil {
+					return fmt.Errorf("Invalid patchset %q: %s", c.Patchset, err)
+				}
+				patch, err = s.getPatch(c.Server, issue, patchset)
+				if err != nil {
+					return fmt.Errorf("Failed to retrieve patch for isolate: %s", err)
 				}
+				patches[r.PatchId] = patch
 			}
+			r.Patch = patch
+		}
+		reqs = append(reqs, r)
+	}
+	hashes, err := s.isolateCache.Isolate(reqs)
+	if err != nil {
+		return err
+	}
+	for i, c := range candidates {
+		c.IsolatedInput = hashes[i]
+	}
+	return nil
+}
 
-			// Add the task to the scheduling list.
-			rv[bot] = c
-
-			// If we've exhausted the bot list, stop here.
-			if len(botsByDim) == 0 {
+// eligibleBots returns the IDs of the bots which have all of the dimensions
+// required by the given task candidate, in order of preference. We prefer bots
+// which already have more of the candidate's CIPD packages installed, and
+// then bots with fewer dimensions, so that less-specialized tasks don't
+// "steal" more-specialized bots which they don't actually need. Remaining
+// ties are broken by bot ID so that the choice is deterministic.
+func eligibleBots(c *taskCandidate, botIds []string, botDims map[string]util.StringSet) []string {
+	rv := []string{}
+	for _, botId := range botIds {
+		dims := botDims[botId]
+		ok := true
+		for _, d := range c.TaskSpec.Dimensions {
+			if !dims[d] {
+				ok = false
 				break
 			}
 		}
+		if ok {
+			rv = append(rv, botId)
+		}
+	}
+	pref := &botPreference{
+		botIds:  rv,
+		cipd:    make([]int, len(rv)),
+		numDims: make([]int, len(rv)),
+	}
+	for i, botId := range rv {
+		for _, p := range c.TaskSpec.CipdPackages {
+			if botDims[botId][p.botDimension()] {
+				pref.cipd[i]++
+			}
+		}
+		pref.numDims[i] = len(botDims[botId])
 	}
+	sort.Stable(pref)
 	return rv
 }
 
+// botPreference is a helper used for sorting eligible bots in order of
+// preference for a task candidate.
+type botPreference struct {
+	botIds  []string
+	cipd    []int
+	numDims []int
+}
+
+func (p *botPreference) Len() int { return len(p.botIds) }
+func (p *botPreference) Less(i, j int) bool {
+	if p.cipd[i] != p.cipd[j] {
+		return p.cipd[i] > p.cipd[j]
+	}
+	return p.numDims[i] < p.numDims[j]
+}
+func (p *botPreference) Swap(i, j int) {
+	p.botIds[i], p.botIds[j] = p.botIds[j], p.botIds[i]
+	p.cipd[i], p.cipd[j] = p.cipd[j], p.cipd[i]
+	p.numDims[i], p.numDims[j] = p.numDims[j], p.numDims[i]
+}
+
+// augmentMatching attempts to assign a bot to the given task candidate,
+// reassigning other candidates in the matching as needed. Returns true on
+// success, in which case the matching has been updated.
+func augmentMatching(c *taskCandidate, eligible map[*taskCandidate][]string, matching map[string]*taskCandidate, visited util.StringSet) bool {
+	// Use a free bot if we can, so that we don't shuffle the matching
+	// unnecessarily.
+	for _, botId := range eligible[c] {
+		if _, ok := matching[botId]; !ok && !visited[botId] {
+			visited[botId] = true
+			matching[botId] = c
+			return true
+		}
+	}
+	// Otherwise, try to move the candidate assigned to each eligible bot.
+	for _, botId := range eligible[c] {
+		if visited[botId] {
+			continue
+		}
+		visited[botId] = true
+		if augmentMatching(matching[botId], eligible, matching, visited) {
+			matching[botId] = c
+			return true
+		}
+	}
+	return false
+}
+
 // timeDecay24Hr computes a linear time decay amount for the given duration,
 // given the requested decay amount at 24 hours.
 func timeDecay24Hr(decayAmt24Hr float64, elapsed time.Duration) float64 {
@@ -456,6 +861,19 @@ func testednessIncrease(blamelistLength, stoleFromBlamelistLength int) float64 {
 	}
 }
 
+// retryScore computes the score for a task candidate which retries a failed
+// task with the given blamelist length. A retry provides no increase in
+// "testedness", but it determines whether the previous failure was a flake.
+// The score is the testedness of the blamelist, halved for each previous
+// attempt, so that retries run after new commits are tested and repeated
+// retries become less important.
+func retryScore(blamelistLength, attempt int) float64 {
+	if blamelistLength <= 0 || attempt <= 0 {
+		return -1.0
+	}
+	return testedness(blamelistLength) * math.Pow(0.5, float64(attempt))
+}
+
 // getFreeSwarmingBots returns a slice of free swarming bots.
 func getFreeSwarmingBots(s *swarming.ApiClient) ([]*swarming_api.SwarmingRpcsBotInfo, error) {
 	bots, err := s.ListSkiaBots()
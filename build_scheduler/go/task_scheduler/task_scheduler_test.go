@@ -3,14 +3,17 @@ package task_scheduler
 import (
 	"fmt"
 	"math"
+	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"testing"
 	"time"
 
 	swarming_api "github.com/luci/luci-go/common/api/swarming/swarming/v1"
 	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/build_scheduler/go/blacklist"
 	"go.skia.org/infra/build_scheduler/go/db"
 	"go.skia.org/infra/go/gitinfo"
 	"go.skia.org/infra/go/testutils"
@@ -54,7 +57,7 @@ func TestFindTaskCandidates(t *testing.T) {
 	repos := gitinfo.NewRepoMap(tr.Dir)
 	_, err = repos.Repo(repo)
 	assert.NoError(t, err)
-	s := NewTaskScheduler(cache, time.Duration(math.MaxInt64), repos)
+	s := NewTaskScheduler(d, nil, cache, time.Duration(math.MaxInt64), repos)
 
 	// Check the initial set of task candidates. The two Build tasks
 	// should be the only ones available.
@@ -142,6 +145,183 @@ func TestFindTaskCandidates(t *testing.T) {
 	}
 }
 
+func TestBlacklist(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	// Setup.
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, time.Hour)
+	assert.NoError(t, err)
+	bl, err := blacklist.FromFile(path.Join(tr.Dir, "blacklist.json"))
+	assert.NoError(t, err)
+
+	c1 := "b993cfa023855f4e27f0280465d477b0e0969708"
+	c2 := "21e4383ee704174c6ca715645181e076c4a30bdd"
+	repo := "skia.git"
+	commits := map[string][]string{
+		repo: []string{c1, c2},
+	}
+
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	_, err = repos.Repo(repo)
+	assert.NoError(t, err)
+	s := NewTaskScheduler(d, bl, cache, time.Duration(math.MaxInt64), repos)
+	assert.Equal(t, bl, s.GetBlacklist())
+
+	findBuildCommits := func() []string {
+		c, err := s.findTaskCandidates(commits)
+		assert.NoError(t, err)
+		rv := []string{}
+		for _, candidate := range c {
+			if strings.HasPrefix(candidate.Name, "Build-") {
+				rv = append(rv, candidate.Revision)
+			}
+		}
+		sort.Strings(rv)
+		return rv
+	}
+
+	// No rules apply.
+	testutils.AssertDeepEqual(t, []string{c2, c1}, findBuildCommits())
+
+	// Blacklist the Build task at c1.
+	r1 := &blacklist.Rule{
+		AddedBy:         "test@google.com",
+		BuilderPatterns: []string{"^Build-"},
+		Commits:         []string{c1},
+		Name:            "Build@c1",
+	}
+	assert.NoError(t, bl.AddRule(r1, repos))
+	testutils.AssertDeepEqual(t, []string{c2}, findBuildCommits())
+	assert.NoError(t, bl.RemoveRule(r1.Name))
+
+	// Blacklist all tasks which run on Ubuntu.
+	r2 := &blacklist.Rule{
+		AddedBy:           "test@google.com",
+		DimensionPatterns: []string{"^os:Ubuntu$"},
+		Name:              "Ubuntu",
+	}
+	assert.NoError(t, bl.AddRule(r2, repos))
+	testutils.AssertDeepEqual(t, []string{}, findBuildCommits())
+	assert.NoError(t, bl.RemoveRule(r2.Name))
+
+	// A rule whose time window has passed does not apply.
+	r3 := &blacklist.Rule{
+		AddedBy:         "test@google.com",
+		BuilderPatterns: []string{".*"},
+		End:             time.Now().Add(-time.Hour),
+		Name:            "Expired",
+		Start:           time.Now().Add(-2 * time.Hour),
+	}
+	assert.NoError(t, bl.AddRule(r3, repos))
+	testutils.AssertDeepEqual(t, []string{c2, c1}, findBuildCommits())
+}
+
+func TestRetries(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	// Setup.
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, time.Hour)
+	assert.NoError(t, err)
+
+	c1 := "b993cfa023855f4e27f0280465d477b0e0969708"
+	repo := "skia.git"
+	buildTask := "Build-Ubuntu-GCC-Arm7-Release-Android"
+	commits := map[string][]string{
+		repo: []string{c1},
+	}
+
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	_, err = repos.Repo(repo)
+	assert.NoError(t, err)
+	s := NewTaskScheduler(d, nil, cache, time.Duration(math.MaxInt64), repos)
+
+	findBuild := func() *taskCandidate {
+		c, err := s.findTaskCandidates(commits)
+		assert.NoError(t, err)
+		for _, candidate := range c {
+			if candidate.Name == buildTask {
+				return candidate
+			}
+		}
+		return nil
+	}
+
+	// The first attempt is not a retry.
+	c := findBuild()
+	assert.NotNil(t, c)
+	assert.Equal(t, 0, c.Attempt)
+	assert.Nil(t, c.RetryOf)
+
+	// The first attempt fails. The task should be retried.
+	t1 := c.MakeTask()
+	t1.Commits = []string{c1}
+	t1.Created = time.Now()
+	t1.Status = db.TASK_STATUS_FAILURE
+	assert.NoError(t, d.PutTask(t1))
+	assert.NoError(t, cache.Update())
+
+	c = findBuild()
+	assert.NotNil(t, c)
+	assert.Equal(t, 1, c.Attempt)
+	assert.NotNil(t, c.RetryOf)
+	assert.Equal(t, t1.Id, c.RetryOf.Id)
+
+	// The retry links to the original task and is scored as a retry.
+	assert.NoError(t, s.processTaskCandidates([]*taskCandidate{c}, time.Now()))
+	assert.Equal(t, []string{c1}, c.Commits)
+	assert.Equal(t, retryScore(1, 1)*c.TaskSpec.Priority, c.Score)
+	t2 := c.MakeTask()
+	assert.Equal(t, []string{c1}, t2.Commits)
+	assert.Equal(t, 1, t2.Attempt)
+	assert.Equal(t, t1.Id, t2.RetryOf)
+
+	// The retry also fails. The default TaskSpec allows only one retry.
+	t2.Commits = []string{c1}
+	t2.Created = time.Now()
+	t2.Status = db.TASK_STATUS_FAILURE
+	t1.Commits = []string{}
+	assert.NoError(t, d.PutTasks([]*db.Task{t1, t2}))
+	assert.NoError(t, cache.Update())
+	assert.Nil(t, findBuild())
+
+	// The retry succeeds instead. It should be marked as flaky.
+	t2.Status = db.TASK_STATUS_SUCCESS
+	assert.NoError(t, d.PutTask(t2))
+	assert.NoError(t, cache.Update())
+	assert.Nil(t, findBuild())
+
+	// Updates which have not reached the cache yet are not overwritten.
+	t2.IsolatedOutput = "late isolated output"
+	assert.NoError(t, d.PutTask(t2))
+	assert.NoError(t, s.classifyFlakes(commits))
+	t2Again, err := d.GetTaskById(t2.Id)
+	assert.NoError(t, err)
+	assert.True(t, t2Again.Flaky)
+	assert.Equal(t, "late isolated output", t2Again.IsolatedOutput)
+	t1Again, err := d.GetTaskById(t1.Id)
+	assert.NoError(t, err)
+	assert.False(t, t1Again.Flaky)
+}
+
+func TestRetryScore(t *testing.T) {
+	// Invalid inputs.
+	assert.Equal(t, -1.0, retryScore(0, 1))
+	assert.Equal(t, -1.0, retryScore(1, 0))
+
+	assert.Equal(t, 0.5, retryScore(1, 1))
+	assert.Equal(t, 0.25, retryScore(1, 2))
+	assert.Equal(t, testedness(3)/2.0, retryScore(3, 1))
+
+	// Retries should score lower than testing a new commit.
+	assert.True(t, retryScore(1, 1) < testednessIncrease(1, 0))
+}
+
 func TestTestedness(t *testing.T) {
 	tc := []struct {
 		in  int
@@ -496,7 +676,7 @@ func TestRegenerateTaskQueue(t *testing.T) {
 	repos := gitinfo.NewRepoMap(tr.Dir)
 	_, err = repos.Repo(repo)
 	assert.NoError(t, err)
-	s := NewTaskScheduler(cache, time.Duration(math.MaxInt64), repos)
+	s := NewTaskScheduler(d, nil, cache, time.Duration(math.MaxInt64), repos)
 
 	// Ensure that the queue is initially empty.
 	assert.Equal(t, 0, len(s.queue))
@@ -521,10 +701,11 @@ func TestRegenerateTaskQueue(t *testing.T) {
 	// Since we haven't run any task yet, we should have the two Build
 	// tasks, each with a blamelist of 1 commit (since we don't go past
 	// taskCandidate.Revision when computing blamelists when we haven't run
-	// a given task spec before), and a score of 2.0.
+	// a given task spec before), and a score of 2.0, scaled by the task
+	// spec's priority of 0.8.
 	for _, c := range s.queue {
 		assert.Equal(t, buildTask, c.Name)
-		assert.Equal(t, 2.0, c.Score)
+		assert.Equal(t, 1.6, c.Score)
 		assert.Equal(t, []string{c.Revision}, c.Commits)
 	}
 
@@ -549,7 +730,7 @@ func TestRegenerateTaskQueue(t *testing.T) {
 	assert.Equal(t, 2, len(s.queue))
 	testSort()
 	for _, c := range s.queue {
-		assert.Equal(t, 2.0, c.Score)
+		assert.Equal(t, 1.6, c.Score)
 		assert.Equal(t, []string{c.Revision}, c.Commits)
 	}
 	buildIdx := 0
@@ -581,7 +762,7 @@ func TestRegenerateTaskQueue(t *testing.T) {
 		} else {
 			assert.Equal(t, c.Name, testTask)
 		}
-		assert.Equal(t, 2.0, c.Score)
+		assert.Equal(t, 1.6, c.Score)
 		assert.Equal(t, []string{c.Revision}, c.Commits)
 	}
 	assert.True(t, perfIdx > -1)
@@ -598,15 +779,16 @@ func TestRegenerateTaskQueue(t *testing.T) {
 	assert.NoError(t, s.regenerateTaskQueue())
 
 	// Now we expect the queue to contain one Test and one Perf task. The
-	// Test task is a backfill, and should have a score of 0.5.
+	// Test task is a backfill, and should have a score of 0.5, scaled by
+	// the task spec's priority of 0.8.
 	assert.Equal(t, 2, len(s.queue))
 	testSort()
 	// First candidate should be the perf task.
 	assert.Equal(t, perfTask, s.queue[0].Name)
-	assert.Equal(t, 2.0, s.queue[0].Score)
+	assert.Equal(t, 1.6, s.queue[0].Score)
 	// The test task is next, a backfill.
 	assert.Equal(t, testTask, s.queue[1].Name)
-	assert.Equal(t, 0.5, s.queue[1].Score)
+	assert.Equal(t, 0.4, s.queue[1].Score)
 }
 
 func makeTaskCandidate(name string, dims []string) *taskCandidate {
@@ -635,48 +817,48 @@ func makeSwarmingBot(id string, dims []string) *swarming_api.SwarmingRpcsBotInfo
 
 func TestGetCandidatesToSchedule(t *testing.T) {
 	// Empty lists.
-	rv := getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{}, []*taskCandidate{})
+	rv := getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{}, []*taskCandidate{}, nil)
 	assert.Equal(t, 0, len(rv))
 
 	t1 := makeTaskCandidate("task1", []string{"k:v"})
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{}, []*taskCandidate{t1})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{}, []*taskCandidate{t1}, nil)
 	assert.Equal(t, 0, len(rv))
 
 	b1 := makeSwarmingBot("bot1", []string{"k:v"})
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{}, nil)
 	assert.Equal(t, 0, len(rv))
 
 	// Single match.
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
 		b1.BotId: t1,
 	}, rv)
 
 	// No match.
 	t1.TaskSpec.Dimensions[0] = "k:v2"
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1}, nil)
 	assert.Equal(t, 0, len(rv))
 
 	// Add a task candidate to match b1.
 	t2 := makeTaskCandidate("task2", []string{"k:v"})
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1, t2})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1, t2}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
 		b1.BotId: t2,
 	}, rv)
 
 	// Switch the task order.
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t2, t1})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t2, t1}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
 		b1.BotId: t2,
 	}, rv)
 
 	// Make both tasks match the bot, ensure that we pick the first one.
 	t1.TaskSpec.Dimensions = []string{"k:v"}
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1, t2})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1, t2}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
 		b1.BotId: t1,
 	}, rv)
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t2, t1})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t2, t1}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
 		b1.BotId: t2,
 	}, rv)
@@ -688,26 +870,26 @@ func TestGetCandidatesToSchedule(t *testing.T) {
 	b2 := makeSwarmingBot("bot2", t1.TaskSpec.Dimensions)
 	t2.TaskSpec.Dimensions = dims
 	// In the first two cases, the task with fewer dimensions has the
-	// higher priority. It gets the bot with more dimensions because it
-	// is first in sorted order. The second task does not get scheduled
-	// because there is no bot available which can run it.
-	// TODO(borenet): Use a more optimal solution to avoid this case.
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t1, t2})
+	// higher priority. It gets the bot with fewer dimensions, leaving the
+	// bot with more dimensions for the second task.
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t1, t2}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
-		b1.BotId: t1,
+		b1.BotId: t2,
+		b2.BotId: t1,
 	}, rv)
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b2, b1}, []*taskCandidate{t1, t2})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b2, b1}, []*taskCandidate{t1, t2}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
-		b1.BotId: t1,
+		b1.BotId: t2,
+		b2.BotId: t1,
 	}, rv)
 	// In these two cases, the task with more dimensions has the higher
 	// priority. Both tasks get scheduled.
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t2, t1})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t2, t1}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
 		b1.BotId: t2,
 		b2.BotId: t1,
 	}, rv)
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b2, b1}, []*taskCandidate{t2, t1})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b2, b1}, []*taskCandidate{t2, t1}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
 		b1.BotId: t2,
 		b2.BotId: t1,
@@ -718,17 +900,347 @@ func TestGetCandidatesToSchedule(t *testing.T) {
 	b3 := makeSwarmingBot("bot3", dims)
 	t1.TaskSpec.Dimensions = dims
 	t3 := makeTaskCandidate("task3", dims)
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2, b3}, []*taskCandidate{t1, t2})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2, b3}, []*taskCandidate{t1, t2}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
 		b1.BotId: t1,
 		b2.BotId: t2,
 	}, rv)
 
 	// More tasks than bots.
-	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t1, t2, t3})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t1, t2, t3}, nil)
 	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
 		b1.BotId: t1,
 		b2.BotId: t2,
 	}, rv)
+}
+
+func TestGetCandidatesToScheduleMaxScore(t *testing.T) {
+	makeScored := func(name string, score float64, dims []string) *taskCandidate {
+		c := makeTaskCandidate(name, dims)
+		c.Score = score
+		return c
+	}
+
+	// A high-scoring task which no bot can run does not block other tasks.
+	t1 := makeScored("task1", 3.0, []string{"os:Mac"})
+	t2 := makeScored("task2", 2.0, []string{"os:Linux"})
+	b1 := makeSwarmingBot("bot1", []string{"os:Linux"})
+	rv := getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1}, []*taskCandidate{t1, t2}, nil)
+	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
+		b1.BotId: t2,
+	}, rv)
 
+	// The highest-scoring task can run on either bot, and it prefers bot1
+	// because bot1 has fewer dimensions. The second task can only run on
+	// bot1, so the first task is moved to bot2 to make room. The third
+	// task has a lower score and does not get scheduled.
+	b1 = makeSwarmingBot("bot1", []string{"os:Linux", "gpu:none"})
+	b2 := makeSwarmingBot("bot2", []string{"os:Linux", "cpu:x86", "zone:a"})
+	t1 = makeScored("task1", 3.0, []string{"os:Linux"})
+	t2 = makeScored("task2", 2.0, []string{"gpu:none"})
+	t3 := makeScored("task3", 1.0, []string{"gpu:none"})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t1, t2, t3}, nil)
+	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
+		b1.BotId: t2,
+		b2.BotId: t1,
+	}, rv)
+
+	// Three tasks, three bots, where the only complete matching requires
+	// reassigning a bot.
+	b1 = makeSwarmingBot("bot1", []string{"a:1", "b:1"})
+	b2 = makeSwarmingBot("bot2", []string{"b:1", "c:1"})
+	b3 := makeSwarmingBot("bot3", []string{"c:1"})
+	t1 = makeScored("task1", 3.0, []string{"b:1"})
+	t2 = makeScored("task2", 2.0, []string{"c:1"})
+	t3 = makeScored("task3", 1.0, []string{"a:1"})
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2, b3}, []*taskCandidate{t1, t2, t3}, nil)
+	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
+		b1.BotId: t3,
+		b2.BotId: t1,
+		b3.BotId: t2,
+	}, rv)
+}
+
+func TestGetCandidatesToScheduleMaxConcurrency(t *testing.T) {
+	dims := []string{"os:Linux"}
+	b1 := makeSwarmingBot("bot1", dims)
+	b2 := makeSwarmingBot("bot2", dims)
+	b3 := makeSwarmingBot("bot3", dims)
+	bots := []*swarming_api.SwarmingRpcsBotInfo{b1, b2, b3}
+
+	spec := &TaskSpec{
+		Dimensions:     dims,
+		MaxConcurrency: 2,
+	}
+	t1 := &taskCandidate{Name: "task", Revision: "c1", TaskSpec: spec}
+	t2 := &taskCandidate{Name: "task", Revision: "c2", TaskSpec: spec}
+	t3 := &taskCandidate{Name: "task", Revision: "c3", TaskSpec: spec}
+	t4 := makeTaskCandidate("other", dims)
+
+	// Only two of the three candidates for the limited TaskSpec run.
+	rv := getCandidatesToSchedule(bots, []*taskCandidate{t1, t2, t3, t4}, nil)
+	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
+		b1.BotId: t1,
+		b2.BotId: t2,
+		b3.BotId: t4,
+	}, rv)
+
+	// Unfinished tasks count towards the limit.
+	rv = getCandidatesToSchedule(bots, []*taskCandidate{t1, t2, t3, t4}, map[string]int{"task": 1})
+	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
+		b1.BotId: t1,
+		b2.BotId: t4,
+	}, rv)
+
+	// No limit.
+	spec.MaxConcurrency = 0
+	rv = getCandidatesToSchedule(bots, []*taskCandidate{t1, t2, t3, t4}, nil)
+	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
+		b1.BotId: t1,
+		b2.BotId: t2,
+		b3.BotId: t3,
+	}, rv)
+}
+
+func TestMaxConcurrencyAcrossRounds(t *testing.T) {
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, time.Hour)
+	assert.NoError(t, err)
+	s := NewTaskScheduler(d, nil, cache, time.Duration(math.MaxInt64), gitinfo.NewRepoMap(""))
+	s.triggerTask = func(req *swarming_api.SwarmingRpcsNewTaskRequest) (*swarming_api.SwarmingRpcsTaskRequestMetadata, error) {
+		return &swarming_api.SwarmingRpcsTaskRequestMetadata{TaskId: "swarming-id"}, nil
+	}
+
+	dims := []string{"os:Linux"}
+	bots := []*swarming_api.SwarmingRpcsBotInfo{
+		makeSwarmingBot("bot1", dims),
+		makeSwarmingBot("bot2", dims),
+	}
+	spec := &TaskSpec{
+		Dimensions:     dims,
+		MaxConcurrency: 2,
+	}
+	candidate := func(name, revision string) *taskCandidate {
+		return &taskCandidate{
+			Commits:  []string{revision},
+			Name:     name,
+			Repo:     "skia.git",
+			Revision: revision,
+			TaskSpec: spec,
+		}
+	}
+
+	// The first round uses up the limit.
+	s.queue = []*taskCandidate{candidate("task", "c1"), candidate("task", "c2"), candidate("task", "c3")}
+	tasks, err := s.triggerTasks(bots, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(tasks))
+	assert.NoError(t, cache.Update())
+
+	// While those tasks are running no more tasks for the spec are scheduled,
+	// even though bots are free.
+	other := makeTaskCandidate("other", dims)
+	other.Repo = "skia.git"
+	other.Revision = "c1"
+	s.queue = append(s.queue, other)
+	tasks, err = s.triggerTasks(bots, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, "other", tasks[0].Name)
+	assert.NoError(t, cache.Update())
+
+	// Once one of them finishes, one more may run.
+	unfinished, err := cache.UnfinishedTasks()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(unfinished))
+	var first *db.Task
+	for _, u := range unfinished {
+		if u.Name == "task" {
+			first = u
+			break
+		}
+	}
+	assert.NotNil(t, first)
+	first.Status = db.TASK_STATUS_SUCCESS
+	first.Finished = time.Now()
+	assert.NoError(t, d.PutTask(first))
+	assert.NoError(t, cache.Update())
+	tasks, err = s.triggerTasks(bots, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, "c3", tasks[0].Revision)
+}
+
+func TestMaxConcurrencyWithoutRegenerating(t *testing.T) {
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, time.Hour)
+	assert.NoError(t, err)
+	s := NewTaskScheduler(d, nil, cache, time.Duration(math.MaxInt64), gitinfo.NewRepoMap(""))
+	s.triggerTask = func(req *swarming_api.SwarmingRpcsNewTaskRequest) (*swarming_api.SwarmingRpcsTaskRequestMetadata, error) {
+		return &swarming_api.SwarmingRpcsTaskRequestMetadata{TaskId: "swarming-id"}, nil
+	}
+
+	dims := []string{"os:Linux"}
+	spec := &TaskSpec{
+		Dimensions:     dims,
+		MaxConcurrency: 2,
+	}
+	s.queue = []*taskCandidate{}
+	for _, revision := range []string{"c1", "c2", "c3", "c4"} {
+		s.queue = append(s.queue, &taskCandidate{
+			Commits:  []string{revision},
+			Name:     "task",
+			Repo:     "skia.git",
+			Revision: revision,
+			TaskSpec: spec,
+		})
+	}
+
+	// Trigger twice, without updating the cache or regenerating the queue
+	// in between. The tasks from the first call count against the limit in
+	// the second.
+	tasks, err := s.triggerTasks([]*swarming_api.SwarmingRpcsBotInfo{makeSwarmingBot("bot1", dims)}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	tasks, err = s.triggerTasks([]*swarming_api.SwarmingRpcsBotInfo{
+		makeSwarmingBot("bot2", dims),
+		makeSwarmingBot("bot3", dims),
+	}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	unfinished, err := cache.UnfinishedTasks()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(unfinished))
+}
+
+func TestGetCandidatesToScheduleCipdPackages(t *testing.T) {
+	dims := []string{"os:Android", "device_type:grouper"}
+	b1 := makeSwarmingBot("bot1", dims)
+	b2 := makeSwarmingBot("bot2", append(dims, "cipd_package:skp:3"))
+	t1 := makeTaskCandidate("task1", dims)
+	t1.TaskSpec.CipdPackages = []*CipdPackage{
+		&CipdPackage{
+			Name:    "skp",
+			Path:    "skp",
+			Version: 3,
+		},
+	}
+
+	// Prefer the bot which already has the package installed.
+	rv := getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t1}, nil)
+	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
+		b2.BotId: t1,
+	}, rv)
+
+	// A different version of the package doesn't count.
+	t1.TaskSpec.CipdPackages[0].Version = 4
+	rv = getCandidatesToSchedule([]*swarming_api.SwarmingRpcsBotInfo{b1, b2}, []*taskCandidate{t1}, nil)
+	testutils.AssertDeepEqual(t, map[string]*taskCandidate{
+		b1.BotId: t1,
+	}, rv)
+}
+
+func TestMakeTaskRequest(t *testing.T) {
+	c := &taskCandidate{
+		IsolatedInput: "abc123",
+		Name:          "Test-Something",
+		Repo:          "skia.git",
+		Revision:      "def456",
+		TaskSpec: &TaskSpec{
+			Dimensions:       []string{"pool:Skia", "os:Ubuntu"},
+			ExecutionTimeout: time.Hour,
+			Priority:         1.0,
+		},
+	}
+	req := c.MakeTaskRequest("task-id")
+	assert.Equal(t, "Test-Something", req.Name)
+	assert.Equal(t, int64(SWARMING_PRIORITY_HIGHEST), req.Priority)
+	assert.Equal(t, int64(DEFAULT_TASK_SPEC_EXPIRATION.Seconds()), req.ExpirationSecs)
+	assert.Equal(t, int64(3600), req.Properties.ExecutionTimeoutSecs)
+	assert.Equal(t, "abc123", req.Properties.InputsRef.Isolated)
+	testutils.AssertDeepEqual(t, []*swarming_api.SwarmingRpcsStringPair{
+		{Key: "pool", Value: "Skia"},
+		{Key: "os", Value: "Ubuntu"},
+	}, req.Properties.Dimensions)
+	testutils.AssertDeepEqual(t, []string{
+		"scheduler_id:task-id",
+		"name:Test-Something",
+		"repo:skia.git",
+		"revision:def456",
+	}, req.Tags)
+
+	// Try jobs are tagged with their issue, and lower priorities map to
+	// higher Swarming priority values.
+	c.Issue = "10101"
+	c.Patchset = "3"
+	c.Server = "https://codereview.chromium.org"
+	c.TaskSpec.Priority = 0.5
+	req = c.MakeTaskRequest("task-id")
+	assert.Equal(t, 7, len(req.Tags))
+	assert.Equal(t, "issue:10101", req.Tags[4])
+	assert.Equal(t, int64(128), req.Priority)
+	assert.True(t, swarmingPriority(0.8) < swarmingPriority(0.5))
+}
+
+func TestTriggerTasks(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, time.Hour)
+	assert.NoError(t, err)
+
+	repo := "skia.git"
+	buildTask := "Build-Ubuntu-GCC-Arm7-Release-Android"
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	_, err = repos.Repo(repo)
+	assert.NoError(t, err)
+	s := NewTaskScheduler(d, nil, cache, time.Duration(math.MaxInt64), repos)
+
+	reqs := []*swarming_api.SwarmingRpcsNewTaskRequest{}
+	var triggerErr error
+	s.triggerTask = func(req *swarming_api.SwarmingRpcsNewTaskRequest) (*swarming_api.SwarmingRpcsTaskRequestMetadata, error) {
+		if triggerErr != nil {
+			return nil, triggerErr
+		}
+		reqs = append(reqs, req)
+		return &swarming_api.SwarmingRpcsTaskRequestMetadata{
+			TaskId: fmt.Sprintf("swarming-%d", len(reqs)),
+		}, nil
+	}
+
+	// The queue contains the two Build tasks.
+	assert.NoError(t, s.regenerateTaskQueue())
+	assert.Equal(t, 2, len(s.queue))
+
+	// Trigger one of them. The request is built from the TaskSpec.
+	bot := makeSwarmingBot("bot1", []string{"pool:Skia", "os:Ubuntu"})
+	tasks, err := s.triggerTasks([]*swarming_api.SwarmingRpcsBotInfo{bot}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tasks))
+	assert.Equal(t, 1, len(reqs))
+	assert.Equal(t, buildTask, reqs[0].Name)
+	assert.Equal(t, swarmingPriority(0.8), reqs[0].Priority)
+	assert.Equal(t, int64(DEFAULT_TASK_SPEC_EXPIRATION.Seconds()), reqs[0].ExpirationSecs)
+	assert.Equal(t, int64(DEFAULT_TASK_SPEC_EXECUTION_TIMEOUT.Seconds()), reqs[0].Properties.ExecutionTimeoutSecs)
+	assert.Equal(t, fmt.Sprintf("%s:%s", db.SWARMING_TAG_ID, tasks[0].Id), reqs[0].Tags[0])
+
+	// The task was inserted into the DB and removed from the queue.
+	task, err := d.GetTaskById(tasks[0].Id)
+	assert.NoError(t, err)
+	assert.Equal(t, "swarming-1", task.SwarmingTaskId)
+	assert.Equal(t, buildTask, task.Name)
+	assert.Equal(t, 1, len(s.queue))
+
+	// Tasks which fail to trigger are not inserted into the DB and stay in
+	// the queue.
+	triggerErr = fmt.Errorf("Swarming is down")
+	tasks, err = s.triggerTasks([]*swarming_api.SwarmingRpcsBotInfo{bot}, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(tasks))
+	assert.Equal(t, 1, len(s.queue))
+	all, err := d.GetTasksFromDateRange(time.Time{}, time.Now().Add(time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(all))
 }
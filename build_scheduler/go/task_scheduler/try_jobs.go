@@ -0,0 +1,205 @@
+package task_scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.skia.org/infra/build_scheduler/go/db"
+	"go.skia.org/infra/go/rietveld"
+)
+
+// TryRequest is a request to run a set of TaskSpecs with a patch applied.
+type TryRequest struct {
+	// Server is the URL of the code review server which hosts Issue.
+	Server string `json:"server"`
+
+	// Issue is the code review issue containing the patch.
+	Issue int64 `json:"issue"`
+
+	// Patchset is the patchset of Issue to apply.
+	Patchset int64 `json:"patchset"`
+
+	// Repo is the repository to which the patch applies.
+	Repo string `json:"repo"`
+
+	// Revision is the commit to which the patch is applied.
+	Revision string `json:"revision"`
+
+	// TaskNames are the names of the requested TaskSpecs. Their dependencies
+	// are run as well.
+	TaskNames []string `json:"task_names"`
+
+	// cfg is the TasksCfg read from the patched tree.
+	cfg *TasksCfg
+}
+
+// issue returns the Issue in the form used by db.Task.
+func (r *TryRequest) issue() string {
+	return strconv.FormatInt(r.Issue, 10)
+}
+
+// patchset returns the Patchset in the form used by db.Task.
+func (r *TryRequest) patchset() string {
+	return strconv.FormatInt(r.Patchset, 10)
+}
+
+// getPatchFromRietveld retrieves the diff for the given patchset from the
+// given Rietveld server.
+func getPatchFromRietveld(server string, issue, patchset int64) (string, error) {
+	return rietveld.New(server, nil).GetPatch(issue, patchset)
+}
+
+// AddTryRequest validates the TryRequest, reads the tasks cfg file from the
+// patched tree, and adds the request to the set of try jobs to schedule.
+// Returns a Job which tracks the progress of the request.
+func (s *TaskScheduler) AddTryRequest(r *TryRequest) (*db.Job, error) {
+	if r.Server == "" || r.Issue <= 0 || r.Patchset <= 0 {
+		return nil, fmt.Errorf("Try request must specify server, issue, and patchset.")
+	}
+	if r.Revision == "" {
+		return nil, fmt.Errorf("Try request must specify a revision.")
+	}
+	if len(r.TaskNames) == 0 {
+		return nil, fmt.Errorf("Try request must specify at least one task.")
+	}
+	if err := s.readTryCfg(r); err != nil {
+		return nil, err
+	}
+
+	job, err := s.newJob(r.cfg, &db.Job{
+		Issue:         r.issue(),
+		Patchset:      r.patchset(),
+		Repo:          r.Repo,
+		Revision:      r.Revision,
+		Server:        r.Server,
+		TriggerReason: "try request",
+	}, r.TaskNames)
+	if err != nil {
+		return nil, err
+	}
+
+	s.tryMtx.Lock()
+	defer s.tryMtx.Unlock()
+	s.tryRequests = append(s.tryRequests, r)
+	return job, nil
+}
+
+// readTryCfg reads the tasks cfg file from the tree of the TryRequest, with
+// its patch applied, and checks that it contains the requested TaskSpecs.
+func (s *TaskScheduler) readTryCfg(r *TryRequest) error {
+	repo, err := s.repos.Repo(r.Repo)
+	if err != nil {
+		return fmt.Errorf("Invalid repo for try request: %s", err)
+	}
+	patch, err := s.getPatch(r.Server, r.Issue, r.Patchset)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve patch for try request: %s", err)
+	}
+	cfg, err := ReadTasksCfgWithPatch(repo, r.Revision, patch)
+	if err != nil {
+		return err
+	}
+	for _, name := range r.TaskNames {
+		if _, ok := cfg.Tasks[name]; !ok {
+			return fmt.Errorf("Unknown task %q in try request.", name)
+		}
+	}
+	r.cfg = cfg
+	return nil
+}
+
+// tryRequestFromJob recreates the TryRequest tracked by the given try job.
+func tryRequestFromJob(j *db.Job) (*TryRequest, error) {
+	issue, err := strconv.ParseInt(j.Issue, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid issue %q: %s", j.Issue, err)
+	}
+	patchset, err := strconv.ParseInt(j.Patchset, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid patchset %q: %s", j.Patchset, err)
+	}
+	return &TryRequest{
+		Server:    j.Server,
+		Issue:     issue,
+		Patchset:  patchset,
+		Repo:      j.Repo,
+		Revision:  j.Revision,
+		TaskNames: strings.Split(j.Name, ","),
+	}, nil
+}
+
+// findTryCandidatesForRequest returns the task candidates for the given
+// TryRequest, and whether all of its tasks have finished. Blacklisted tasks
+// are not returned as candidates; the request waits until the rule is removed.
+func (s *TaskScheduler) findTryCandidatesForRequest(r *TryRequest, now time.Time) ([]*taskCandidate, bool, error) {
+	candidates := []*taskCandidate{}
+	getTask := func(name string) (*db.Task, error) {
+		return s.cache.GetTaskForPatch(r.Server, r.issue(), r.patchset(), name)
+	}
+	candidate := func(name string, spec *TaskSpec, retryOf *db.Task, attempt int) error {
+		// Skip blacklisted tasks.
+		if s.bl != nil && s.bl.MatchTask(name, spec.Dimensions, r.Revision, now) {
+			return nil
+		}
+		c := &taskCandidate{
+			Attempt:  attempt,
+			Commits:  []string{},
+			Issue:    r.issue(),
+			Name:     name,
+			Patchset: r.patchset(),
+			Repo:     r.Repo,
+			RetryOf:  retryOf,
+			Revision: r.Revision,
+			Score:    0.0,
+			Server:   r.Server,
+			TaskSpec: spec,
+		}
+		depsMet, hashes, err := c.allDepsMet(s.cache)
+		if err != nil {
+			return err
+		}
+		if depsMet {
+			c.IsolatedHashes = hashes
+			candidates = append(candidates, c)
+		}
+		return nil
+	}
+	states, err := jobTaskStates(r.cfg, r.TaskNames, getTask, candidate)
+	if err != nil {
+		return nil, false, err
+	}
+
+	done := true
+	for _, name := range r.TaskNames {
+		if states[name] == jobTaskPending {
+			done = false
+		}
+	}
+	return candidates, done, nil
+}
+
+// findTryCandidates returns task candidates for all outstanding TryRequests
+// and removes TryRequests whose tasks have all finished. Candidates are
+// returned in the order in which the TryRequests were added.
+func (s *TaskScheduler) findTryCandidates() ([]*taskCandidate, error) {
+	s.tryMtx.Lock()
+	defer s.tryMtx.Unlock()
+
+	candidates := []*taskCandidate{}
+	now := time.Now()
+	remaining := make([]*TryRequest, 0, len(s.tryRequests))
+	for _, r := range s.tryRequests {
+		c, done, err := s.findTryCandidatesForRequest(r, now)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, c...)
+		if !done {
+			remaining = append(remaining, r)
+		}
+	}
+	s.tryRequests = remaining
+	return candidates, nil
+}
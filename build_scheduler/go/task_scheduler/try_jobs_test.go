@@ -0,0 +1,221 @@
+package task_scheduler
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"sort"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/build_scheduler/go/blacklist"
+	"go.skia.org/infra/build_scheduler/go/db"
+	"go.skia.org/infra/go/gitinfo"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/util"
+)
+
+func TestTryJobs(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	// Setup.
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, time.Hour)
+	assert.NoError(t, err)
+
+	c2 := "21e4383ee704174c6ca715645181e076c4a30bdd"
+	repo := "skia.git"
+	buildTask := "Build-Ubuntu-GCC-Arm7-Release-Android"
+	testTask := "Test-Android-GCC-Nexus7-GPU-Tegra3-Arm7-Release"
+	houseTask := "Housekeeper-Nightly-RecreateSKPs"
+	server := "https://codereview.chromium.org"
+
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	_, err = repos.Repo(repo)
+	assert.NoError(t, err)
+	s := NewTaskScheduler(d, nil, cache, time.Duration(math.MaxInt64), repos)
+	s.getPatch = func(srv string, issue, patchset int64) (string, error) {
+		if srv != server || issue != 12345 || patchset != 1 {
+			return "", fmt.Errorf("No such patch.")
+		}
+		return testPatch, nil
+	}
+
+	// Invalid requests.
+	_, err = s.AddTryRequest(&TryRequest{})
+	assert.Error(t, err)
+	_, err = s.AddTryRequest(&TryRequest{
+		Server:    server,
+		Issue:     12345,
+		Patchset:  2,
+		Repo:      repo,
+		Revision:  c2,
+		TaskNames: []string{testTask},
+	})
+	assert.Error(t, err)
+	_, err = s.AddTryRequest(&TryRequest{
+		Server:    server,
+		Issue:     12345,
+		Patchset:  1,
+		Repo:      repo,
+		Revision:  c2,
+		TaskNames: []string{"bogus"},
+	})
+	assert.Error(t, err)
+
+	// Request the Test task, which depends on the Build task, and the
+	// Housekeeper task, which only exists in the patched tree.
+	job, err := s.AddTryRequest(&TryRequest{
+		Server:    server,
+		Issue:     12345,
+		Patchset:  1,
+		Repo:      repo,
+		Revision:  c2,
+		TaskNames: []string{testTask, houseTask},
+	})
+	assert.NoError(t, err)
+	assert.True(t, job.IsTryJob())
+	testutils.AssertDeepEqual(t, map[string][]string{
+		buildTask: []string{},
+		testTask:  []string{buildTask},
+		houseTask: []string{},
+	}, job.Dependencies)
+
+	findTryJob := func(candidates []*taskCandidate, name string) *taskCandidate {
+		for _, c := range candidates {
+			if c.IsTryJob() && c.Name == name {
+				return c
+			}
+		}
+		return nil
+	}
+
+	// The Build and Housekeeper tasks are try candidates, and they sort
+	// before all of the commit candidates.
+	assert.NoError(t, s.regenerateTaskQueue())
+	assert.True(t, s.queue[0].IsTryJob())
+	assert.True(t, s.queue[1].IsTryJob())
+	for _, c := range s.queue[2:] {
+		assert.False(t, c.IsTryJob())
+	}
+	build := findTryJob(s.queue, buildTask)
+	assert.NotNil(t, build)
+	assert.Equal(t, "12345", build.Issue)
+	assert.Equal(t, "1", build.Patchset)
+	assert.Equal(t, server, build.Server)
+	assert.Equal(t, 0, len(build.Commits))
+	assert.NotNil(t, findTryJob(s.queue, houseTask))
+	assert.Nil(t, findTryJob(s.queue, testTask))
+
+	// Run the try Build task. It should not count toward the commit
+	// candidates' blamelists.
+	t1 := build.MakeTask()
+	t1.Created = time.Now()
+	t1.Status = db.TASK_STATUS_SUCCESS
+	t1.IsolatedOutput = "fake isolated hash"
+	t2 := findTryJob(s.queue, houseTask).MakeTask()
+	t2.Created = time.Now()
+	t2.Status = db.TASK_STATUS_RUNNING
+	assert.NoError(t, d.PutTasks([]*db.Task{t1, t2}))
+	assert.NoError(t, s.regenerateTaskQueue())
+	assert.False(t, cache.KnownTaskName(buildTask))
+	prev, err := cache.GetTaskForCommit(buildTask, c2)
+	assert.NoError(t, err)
+	assert.Nil(t, prev)
+
+	// Now the Test task is a candidate.
+	test := findTryJob(s.queue, testTask)
+	assert.NotNil(t, test)
+	testutils.AssertDeepEqual(t, []string{t1.IsolatedOutput}, test.IsolatedHashes)
+	assert.Nil(t, findTryJob(s.queue, buildTask))
+	assert.Nil(t, findTryJob(s.queue, houseTask))
+
+	// Finish the remaining tasks. The request should be removed.
+	t2.Status = db.TASK_STATUS_SUCCESS
+	t3 := test.MakeTask()
+	t3.Created = time.Now()
+	t3.Status = db.TASK_STATUS_SUCCESS
+	assert.NoError(t, d.PutTasks([]*db.Task{t2, t3}))
+	assert.Equal(t, 1, len(s.tryRequests))
+	assert.NoError(t, s.regenerateTaskQueue())
+	assert.Equal(t, 0, len(s.tryRequests))
+	for _, c := range s.queue {
+		assert.False(t, c.IsTryJob())
+	}
+
+	// The Job has finished.
+	job, err = d.GetJobById(job.Id)
+	assert.NoError(t, err)
+	assert.Equal(t, db.JobStatus(db.JOB_STATUS_SUCCESS), job.Status)
+	assert.False(t, util.TimeIsZero(job.Finished))
+	assert.Equal(t, 3, len(job.Tasks))
+}
+
+func TestTryJobsBlacklist(t *testing.T) {
+	testutils.SkipIfShort(t)
+
+	// Setup.
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+	d := db.NewInMemoryDB()
+	cache, err := db.NewTaskCache(d, time.Hour)
+	assert.NoError(t, err)
+	bl, err := blacklist.FromFile(path.Join(tr.Dir, "blacklist.json"))
+	assert.NoError(t, err)
+
+	c2 := "21e4383ee704174c6ca715645181e076c4a30bdd"
+	repo := "skia.git"
+	buildTask := "Build-Ubuntu-GCC-Arm7-Release-Android"
+	houseTask := "Housekeeper-Nightly-RecreateSKPs"
+	server := "https://codereview.chromium.org"
+
+	repos := gitinfo.NewRepoMap(tr.Dir)
+	_, err = repos.Repo(repo)
+	assert.NoError(t, err)
+	s := NewTaskScheduler(d, bl, cache, time.Duration(math.MaxInt64), repos)
+	s.getPatch = func(srv string, issue, patchset int64) (string, error) {
+		return testPatch, nil
+	}
+	_, err = s.AddTryRequest(&TryRequest{
+		Server:    server,
+		Issue:     12345,
+		Patchset:  1,
+		Repo:      repo,
+		Revision:  c2,
+		TaskNames: []string{buildTask, houseTask},
+	})
+	assert.NoError(t, err)
+
+	findTryNames := func() []string {
+		c, err := s.findTryCandidates()
+		assert.NoError(t, err)
+		rv := []string{}
+		for _, candidate := range c {
+			rv = append(rv, candidate.Name)
+		}
+		sort.Strings(rv)
+		return rv
+	}
+
+	// No rules apply.
+	testutils.AssertDeepEqual(t, []string{buildTask, houseTask}, findTryNames())
+
+	// Blacklist the Build task at the request's revision. The request
+	// stays outstanding while its Build task is blacklisted.
+	r1 := &blacklist.Rule{
+		AddedBy:         "test@google.com",
+		BuilderPatterns: []string{"^Build-"},
+		Commits:         []string{c2},
+		Name:            "Build@c2",
+	}
+	assert.NoError(t, bl.AddRule(r1, repos))
+	testutils.AssertDeepEqual(t, []string{houseTask}, findTryNames())
+	assert.Equal(t, 1, len(s.tryRequests))
+
+	// Removing the rule makes the Build task a candidate again.
+	assert.NoError(t, bl.RemoveRule(r1.Name))
+	testutils.AssertDeepEqual(t, []string{buildTask, houseTask}, findTryNames())
+}
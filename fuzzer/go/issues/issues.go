@@ -77,7 +77,8 @@ func (im *IssuesManager) CreateBadBugIssue(p IssueReportingPackage, desc string)
 		}
 	}
 
-	return tracker.AddIssue(req)
+	_, err = tracker.AddIssue(req)
+	return err
 }
 
 func (im *IssuesManager) CreateBadBugURL(p IssueReportingPackage) (string, error) {
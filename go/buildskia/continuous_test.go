@@ -69,6 +69,7 @@ type mockVcs struct {
 
 func (m *mockVcs) Update(pull, allBranches bool) error { return nil }
 func (m *mockVcs) From(start time.Time) []string       { return nil }
+func (m *mockVcs) IndexOf(hash string) (int, error)    { return -1, nil }
 
 // Details returns the full commit information for the given hash.
 // If includeBranchInfo is true the Branches field of the returned
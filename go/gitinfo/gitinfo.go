@@ -68,6 +68,11 @@ func CloneOrUpdate(repoUrl, dir string, allBranches bool) (*GitInfo, error) {
 	return nil, err
 }
 
+// Dir returns the working directory of the GitInfo.
+func (g *GitInfo) Dir() string {
+	return g.dir
+}
+
 // Update refreshes the history that GitInfo stores for the repo. If pull is
 // true then git pull is performed before refreshing.
 func (g *GitInfo) Update(pull, allBranches bool) error {
@@ -202,6 +207,38 @@ func (g *GitInfo) From(start time.Time) []string {
 	return ret
 }
 
+// Range returns all commits with timestamps in [begin, end), ordered from
+// oldest to newest, along with their indices.
+func (g *GitInfo) Range(begin, end time.Time) []*vcsinfo.IndexCommit {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	ret := []*vcsinfo.IndexCommit{}
+	for i, h := range g.hashes {
+		ts := g.timestamps[h]
+		if !ts.Before(begin) && ts.Before(end) {
+			ret = append(ret, &vcsinfo.IndexCommit{
+				Hash:      h,
+				Index:     i,
+				Timestamp: ts,
+			})
+		}
+	}
+	return ret
+}
+
+// IndexOf returns the index of the commit hash, where 0 is the index of the
+// initial commit.
+func (g *GitInfo) IndexOf(hash string) (int, error) {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	for i, h := range g.hashes {
+		if h == hash {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("Could not find hash %s", hash)
+}
+
 // LastN returns the last N commits.
 func (g *GitInfo) LastN(N int) []string {
 	g.mutex.Lock()
@@ -543,6 +580,14 @@ func (m *RepoMap) Repo(r string) (*GitInfo, error) {
 	return repo, nil
 }
 
+// Add inserts the given GitInfo into the RepoMap for the given repo URL. This
+// allows the use of an existing checkout which should not be synced.
+func (m *RepoMap) Add(r string, repo *GitInfo) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.repos[r] = repo
+}
+
 // RepoForCommit attempts to determine which repository the given commit hash
 // belongs to and returns the associated repo URL if found. This is fragile,
 // because it's possible, though very unlikely, that there may be collisions
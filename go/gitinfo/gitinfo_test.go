@@ -279,6 +279,39 @@ func TestNumCommits(t *testing.T) {
 	}
 }
 
+func TestRangeAndIndexOf(t *testing.T) {
+	tr := util.NewTempRepo()
+	defer tr.Cleanup()
+
+	r, err := NewGitInfo(filepath.Join(tr.Dir, "testrepo"), false, false)
+	assert.NoError(t, err)
+
+	first := "7a669cfa3f4cd3482a4fd03989f75efcc7595f7f"
+	second := "8652a6df7dc8a7e6addee49f6ed3c2308e36bd18"
+
+	idx, err := r.IndexOf(first)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, idx)
+	idx, err = r.IndexOf(second)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, idx)
+	_, err = r.IndexOf("bogus")
+	assert.Error(t, err)
+
+	// The two commits in the repo have timestamps of:
+	// 1406721642 and 1406721715.
+	commits := r.Range(time.Unix(1406721642, 0), time.Unix(1406721716, 0))
+	assert.Equal(t, 2, len(commits))
+	assert.Equal(t, first, commits[0].Hash)
+	assert.Equal(t, 0, commits[0].Index)
+	assert.Equal(t, second, commits[1].Hash)
+	assert.Equal(t, 1, commits[1].Index)
+	assert.Equal(t, int64(1406721715), commits[1].Timestamp.Unix())
+
+	commits = r.Range(time.Unix(1406721643, 0), time.Unix(1406721715, 0))
+	assert.Equal(t, 0, len(commits))
+}
+
 func TestRevList(t *testing.T) {
 	tr := util.NewTempRepo()
 	defer tr.Cleanup()
@@ -42,6 +42,15 @@ func (m mockVCS) Details(hash string, getBranches bool) (*vcsinfo.LongCommit, er
 	return nil, fmt.Errorf("Unable to find commit")
 }
 
+func (m mockVCS) IndexOf(hash string) (int, error) {
+	for i, commit := range m {
+		if commit.Hash == hash {
+			return i, nil
+		}
+	}
+	return -1, fmt.Errorf("Unable to find commit")
+}
+
 // StartTestTraceDBServer starts up a traceDB server for testing. It stores its
 // data at the given path and returns the address at which the server is
 // listening as the second return value.
@@ -9,7 +9,6 @@ import (
 	"path"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 
@@ -25,6 +24,7 @@ const (
 	ISOLATE_EXE_SHA1       = "cf7c1fac12790056ac393774827a5720c7590bac"
 	ISOLATESERVER_EXE_SHA1 = "e45ffb5b03c3e94d07e4bbd1bda51b9f12590177"
 	ISOLATE_SERVER_URL     = "https://isolateserver.appspot.com"
+	ISOLATE_NAMESPACE      = "default-gzip"
 	ISOLATE_VERSION        = 1
 	GS_BUCKET              = "chromium-luci"
 	GS_SUBDIR              = ""
@@ -275,8 +275,10 @@ func (c *Client) IsolateTasks(tasks []*Task) ([]string, error) {
 	// Write the .isolated.gen.json files.
 	genJsonFiles := make([]string, 0, len(tasks))
 	isolatedFiles := make([]string, 0, len(tasks))
+	taskIds := make([]string, 0, len(tasks))
 	for i, t := range tasks {
 		taskId := fmt.Sprintf(TASK_ID_TMPL, strconv.Itoa(i))
+		taskIds = append(taskIds, taskId)
 		genJsonFile := path.Join(tmpDir, fmt.Sprintf("%s.isolated.gen.json", taskId))
 		isolatedFile := path.Join(tmpDir, fmt.Sprintf("%s.isolated", taskId))
 		if err := WriteIsolatedGenJson(t, genJsonFile, isolatedFile); err != nil {
@@ -315,7 +317,6 @@ func (c *Client) IsolateTasks(tasks []*Task) ([]string, error) {
 	}
 
 	// Parse isolated hash for each task from the output.
-	taskIds := []string{}
 	hashes := map[string]string{}
 	for _, line := range strings.Split(string(output), "\n") {
 		m := isolatedHashRegexp.FindStringSubmatch(line)
@@ -324,16 +325,20 @@ func (c *Client) IsolateTasks(tasks []*Task) ([]string, error) {
 				return nil, fmt.Errorf("Isolated output regexp returned invalid match: %v", m)
 			}
 			hashes[m[2]] = m[1]
-			taskIds = append(taskIds, m[2])
 		}
 	}
 	if len(hashes) != len(tasks) {
 		return nil, fmt.Errorf("Ended up with an incorrect number of isolated hashes!")
 	}
-	sort.Strings(taskIds)
+	// Return the hashes in the same order as the tasks. Sorting the task
+	// IDs would put "task_10" before "task_2".
 	rv := make([]string, 0, len(taskIds))
 	for _, id := range taskIds {
-		rv = append(rv, hashes[id])
+		h, ok := hashes[id]
+		if !ok {
+			return nil, fmt.Errorf("No isolated hash for %s", id)
+		}
+		rv = append(rv, h)
 	}
 	return rv, nil
 }
@@ -21,17 +21,27 @@ const (
 type IssueTracker interface {
 	// FromQueury returns issue that match the given query string.
 	FromQuery(q string) ([]Issue, error)
+	// Get returns the issue with the given id.
+	Get(id int64) (*Issue, error)
 	// AddComment adds a comment to the issue with the given id
 	AddComment(id string, comment CommentRequest) error
-	// AddIssue creates an issue with the passed in params.
-	AddIssue(issue IssueRequest) error
+	// AddIssue creates an issue with the passed in params and returns the
+	// newly created issue.
+	AddIssue(issue IssueRequest) (*Issue, error)
+	// SetStatus changes the status of the issue with the given id, e.g. to
+	// "Fixed".
+	SetStatus(id int64, status string) error
+	// AddLabels adds the given labels to the issue with the given id.
+	AddLabels(id int64, labels []string) error
 }
 
 // Issue is an individual issue returned from the project hosting response.
 type Issue struct {
-	ID    int64  `json:"id"`
-	Title string `json:"title"`
-	State string `json:"state"`
+	ID     int64    `json:"id"`
+	Title  string   `json:"title"`
+	State  string   `json:"state"`
+	Status string   `json:"status"`
+	Labels []string `json:"labels"`
 }
 
 // IssueResponse is used to decode JSON responses from the project hosting API.
@@ -40,7 +50,14 @@ type IssueResponse struct {
 }
 
 type CommentRequest struct {
-	Content string `json:"content"`
+	Content string          `json:"content"`
+	Updates *CommentUpdates `json:"updates,omitempty"`
+}
+
+// CommentUpdates are changes to an issue that are made along with a comment.
+type CommentUpdates struct {
+	Status string   `json:"status,omitempty"`
+	Labels []string `json:"labels,omitempty"`
 }
 
 type MonorailPerson struct {
@@ -78,39 +95,80 @@ func NewMonorailIssueTracker(client *http.Client) IssueTracker {
 func (m *MonorailIssueTracker) FromQuery(q string) ([]Issue, error) {
 	query := url.Values{}
 	query.Add("q", q)
-	query.Add("fields", "items/id,items/state,items/title")
-	return get(m.client, MONORAIL_BASE_URL+"?"+query.Encode())
+	query.Add("fields", "items/id,items/state,items/title,items/status,items/labels")
+	issueResponse := &IssueResponse{
+		Items: []Issue{},
+	}
+	if err := get(m.client, MONORAIL_BASE_URL+"?"+query.Encode(), issueResponse); err != nil {
+		return nil, err
+	}
+	return issueResponse.Items, nil
+}
+
+// Get is part of the IssueTracker interface. See documentation there.
+func (m *MonorailIssueTracker) Get(id int64) (*Issue, error) {
+	issue := &Issue{}
+	if err := get(m.client, fmt.Sprintf("%s/%d", MONORAIL_BASE_URL, id), issue); err != nil {
+		return nil, err
+	}
+	return issue, nil
 }
 
 // AddComment adds a comment to the issue with the given id
 func (m *MonorailIssueTracker) AddComment(id string, comment CommentRequest) error {
 	u := fmt.Sprintf("%s/%s/comments", MONORAIL_BASE_URL, id)
-	return post(m.client, u, comment)
+	return post(m.client, u, comment, nil)
 }
 
 // AddIssue creates an issue with the passed in params.
-func (m *MonorailIssueTracker) AddIssue(issue IssueRequest) error {
-	return post(m.client, MONORAIL_BASE_URL, issue)
+func (m *MonorailIssueTracker) AddIssue(issue IssueRequest) (*Issue, error) {
+	ret := &Issue{}
+	if err := post(m.client, MONORAIL_BASE_URL, issue, ret); err != nil {
+		return nil, err
+	}
+	return ret, nil
 }
 
-func get(client *http.Client, u string) ([]Issue, error) {
+// SetStatus is part of the IssueTracker interface. See documentation there.
+//
+// Monorail only changes issues by adding a comment, so an empty comment is
+// added with the status update.
+func (m *MonorailIssueTracker) SetStatus(id int64, status string) error {
+	return m.AddComment(fmt.Sprintf("%d", id), CommentRequest{
+		Updates: &CommentUpdates{
+			Status: status,
+		},
+	})
+}
+
+// AddLabels is part of the IssueTracker interface. See documentation there.
+func (m *MonorailIssueTracker) AddLabels(id int64, labels []string) error {
+	return m.AddComment(fmt.Sprintf("%d", id), CommentRequest{
+		Updates: &CommentUpdates{
+			Labels: labels,
+		},
+	})
+}
+
+// get does a GET request to the given URL and decodes the JSON response into
+// 'response'.
+func get(client *http.Client, u string, response interface{}) error {
 	resp, err := client.Get(u)
 	if err != nil || resp == nil || resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Failed to retrieve issue tracker response: %s", err)
+		return fmt.Errorf("Failed to retrieve issue tracker response: %s", err)
 	}
 	defer util.Close(resp.Body)
 
-	issueResponse := &IssueResponse{
-		Items: []Issue{},
-	}
-	if err := json.NewDecoder(resp.Body).Decode(&issueResponse); err != nil {
-		return nil, err
+	if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+		return err
 	}
-
-	return issueResponse.Items, nil
+	return nil
 }
 
-func post(client *http.Client, dst string, request interface{}) error {
+// post sends the JSON encoded request to the given URL. If response is not
+// nil then the JSON response is decoded into it, otherwise the response is
+// logged.
+func post(client *http.Client, dst string, request interface{}, response interface{}) error {
 	b := new(bytes.Buffer)
 	e := json.NewEncoder(b)
 	if err := e.Encode(request); err != nil {
@@ -123,6 +181,12 @@ func post(client *http.Client, dst string, request interface{}) error {
 		return fmt.Errorf("Failed to retrieve issue tracker response: %s", err)
 	}
 	defer util.Close(resp.Body)
+	if response != nil {
+		if err := json.NewDecoder(resp.Body).Decode(response); err != nil {
+			return fmt.Errorf("Failed to decode issue tracker response: %s", err)
+		}
+		return nil
+	}
 	msg, err := ioutil.ReadAll(resp.Body)
 	glog.Infof("%s\n\nErr: %v", string(msg), err)
 	return nil
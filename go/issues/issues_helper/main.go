@@ -105,9 +105,12 @@ func handleCreate() {
 		Summary:     *summary,
 		Description: *description,
 	}
-	if err := tracker.AddIssue(req); err != nil {
+	issue, err := tracker.AddIssue(req)
+	if err != nil {
 		glog.Errorf("Failed to add issue: %s", err)
+		return
 	}
+	fmt.Printf("Created: %d\n", issue.ID)
 }
 
 func checkCreateFlags() bool {
@@ -0,0 +1,263 @@
+package issues
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"go.skia.org/infra/go/util"
+)
+
+// CLOSED_STATUSES are the issue statuses that close an issue.
+var CLOSED_STATUSES = []string{"Fixed", "Verified", "Duplicate", "WontFix", "Done", "Archived", "Invalid"}
+
+// localIssue is an issue as stored by LocalIssueTracker.
+type localIssue struct {
+	Issue
+	Owner       string   `json:"owner"`
+	CC          []string `json:"cc"`
+	Description string   `json:"description"`
+	Comments    []string `json:"comments"`
+}
+
+// localIssues is the contents of the file used by LocalIssueTracker.
+type localIssues struct {
+	NextID int64         `json:"next_id"`
+	Issues []*localIssue `json:"issues"`
+}
+
+// LocalIssueTracker implements IssueTracker by storing all the issues in a
+// single JSON file. It is intended for tests and for deployments that can't
+// reach Monorail.
+type LocalIssueTracker struct {
+	filename string
+
+	// mutex protects access to the file.
+	mutex sync.Mutex
+}
+
+// NewLocalIssueTracker returns an IssueTracker that stores issues in the given
+// file. The file is created when the first issue is added.
+func NewLocalIssueTracker(filename string) IssueTracker {
+	return &LocalIssueTracker{
+		filename: filename,
+	}
+}
+
+// load reads all the issues from the file. The caller must hold the mutex.
+func (l *LocalIssueTracker) load() (*localIssues, error) {
+	ret := &localIssues{
+		NextID: 1,
+		Issues: []*localIssue{},
+	}
+	f, err := os.Open(l.filename)
+	if os.IsNotExist(err) {
+		return ret, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("Failed to open issues file: %s", err)
+	}
+	defer util.Close(f)
+	if err := json.NewDecoder(f).Decode(ret); err != nil {
+		return nil, fmt.Errorf("Failed to decode issues file: %s", err)
+	}
+	return ret, nil
+}
+
+// save writes all the issues to the file. The caller must hold the mutex.
+func (l *LocalIssueTracker) save(all *localIssues) error {
+	b, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return fmt.Errorf("Failed to encode issues: %s", err)
+	}
+	if err := ioutil.WriteFile(l.filename, b, 0644); err != nil {
+		return fmt.Errorf("Failed to write issues file: %s", err)
+	}
+	return nil
+}
+
+// update applies f to the issue with the given id and saves the result.
+func (l *LocalIssueTracker) update(id int64, f func(*localIssue)) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	all, err := l.load()
+	if err != nil {
+		return err
+	}
+	issue, err := find(all, id)
+	if err != nil {
+		return err
+	}
+	f(issue)
+	return l.save(all)
+}
+
+// find returns the issue with the given id.
+func find(all *localIssues, id int64) (*localIssue, error) {
+	for _, issue := range all.Issues {
+		if issue.ID == id {
+			return issue, nil
+		}
+	}
+	return nil, fmt.Errorf("Issue %d not found.", id)
+}
+
+// matches returns true if every word in the query appears in the issue's
+// title, description, comments, or labels, ignoring case. See containsWord.
+func (issue *localIssue) matches(q string) bool {
+	fields := []string{issue.Title, issue.Description}
+	fields = append(fields, issue.Comments...)
+	fields = append(fields, issue.Labels...)
+	text := strings.ToLower(strings.Join(fields, "\n"))
+	for _, term := range strings.Fields(strings.ToLower(q)) {
+		if !containsWord(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsWord returns true if term appears in text and isn't directly
+// followed by a letter or digit, so that a query for the URL ".../cl/12" does
+// not match an issue that only contains ".../cl/123".
+func containsWord(text, term string) bool {
+	for offset := 0; offset <= len(text); {
+		i := strings.Index(text[offset:], term)
+		if i < 0 {
+			return false
+		}
+		end := offset + i + len(term)
+		if end == len(text) {
+			return true
+		}
+		if r, _ := utf8.DecodeRuneInString(text[end:]); !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return true
+		}
+		offset += i + 1
+	}
+	return false
+}
+
+// setStatus sets the status of the issue and updates its state to match.
+func (issue *localIssue) setStatus(status string) {
+	issue.Status = status
+	issue.State = "open"
+	if util.In(status, CLOSED_STATUSES) {
+		issue.State = "closed"
+	}
+}
+
+// addLabels adds the labels to the issue, ignoring duplicates.
+func (issue *localIssue) addLabels(labels []string) {
+	issue.Labels = util.NewStringSet(issue.Labels, labels).Keys()
+	sort.Strings(issue.Labels)
+}
+
+// FromQuery is part of the IssueTracker interface. See documentation there.
+//
+// Unlike Monorail, the query is only a list of words, and an issue matches if
+// it contains all of them, see localIssue.matches.
+func (l *LocalIssueTracker) FromQuery(q string) ([]Issue, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	all, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	ret := []Issue{}
+	for _, issue := range all.Issues {
+		if issue.matches(q) {
+			ret = append(ret, issue.Issue)
+		}
+	}
+	return ret, nil
+}
+
+// Get is part of the IssueTracker interface. See documentation there.
+func (l *LocalIssueTracker) Get(id int64) (*Issue, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	all, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	issue, err := find(all, id)
+	if err != nil {
+		return nil, err
+	}
+	ret := issue.Issue
+	return &ret, nil
+}
+
+// AddComment is part of the IssueTracker interface. See documentation there.
+func (l *LocalIssueTracker) AddComment(id string, comment CommentRequest) error {
+	var issueID int64
+	if _, err := fmt.Sscanf(id, "%d", &issueID); err != nil {
+		return fmt.Errorf("Invalid issue id %q: %s", id, err)
+	}
+	return l.update(issueID, func(issue *localIssue) {
+		if comment.Content != "" {
+			issue.Comments = append(issue.Comments, comment.Content)
+		}
+		if comment.Updates != nil {
+			if comment.Updates.Status != "" {
+				issue.setStatus(comment.Updates.Status)
+			}
+			issue.addLabels(comment.Updates.Labels)
+		}
+	})
+}
+
+// AddIssue is part of the IssueTracker interface. See documentation there.
+func (l *LocalIssueTracker) AddIssue(req IssueRequest) (*Issue, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	all, err := l.load()
+	if err != nil {
+		return nil, err
+	}
+	issue := &localIssue{
+		Issue: Issue{
+			ID:     all.NextID,
+			Title:  req.Summary,
+			Labels: req.Labels,
+		},
+		Owner:       req.Owner.Name,
+		CC:          []string{},
+		Description: req.Description,
+		Comments:    []string{},
+	}
+	if issue.Labels == nil {
+		issue.Labels = []string{}
+	}
+	for _, p := range req.CC {
+		issue.CC = append(issue.CC, p.Name)
+	}
+	issue.setStatus(req.Status)
+	all.NextID += 1
+	all.Issues = append(all.Issues, issue)
+	if err := l.save(all); err != nil {
+		return nil, err
+	}
+	ret := issue.Issue
+	return &ret, nil
+}
+
+// SetStatus is part of the IssueTracker interface. See documentation there.
+func (l *LocalIssueTracker) SetStatus(id int64, status string) error {
+	return l.update(id, func(issue *localIssue) {
+		issue.setStatus(status)
+	})
+}
+
+// AddLabels is part of the IssueTracker interface. See documentation there.
+func (l *LocalIssueTracker) AddLabels(id int64, labels []string) error {
+	return l.update(id, func(issue *localIssue) {
+		issue.addLabels(labels)
+	})
+}
@@ -0,0 +1,92 @@
+package issues
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+)
+
+func TestLocalIssueTracker(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issues")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	filename := filepath.Join(dir, "issues.json")
+
+	tracker := NewLocalIssueTracker(filename)
+
+	// No file yet, so no issues.
+	found, err := tracker.FromQuery("anything")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(found))
+
+	issue, err := tracker.AddIssue(IssueRequest{
+		Status:      "New",
+		Summary:     "Perf regression in desk_nytimes",
+		Description: "See https://perf.skia.org/cl/12",
+		Labels:      []string{"FromSkiaPerf"},
+		Owner:       MonorailPerson{Name: "fred@example.com"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), issue.ID)
+	assert.Equal(t, "open", issue.State)
+
+	issue2, err := tracker.AddIssue(IssueRequest{
+		Status:  "Fixed",
+		Summary: "Something else",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), issue2.ID)
+	assert.Equal(t, "closed", issue2.State)
+
+	// Issues persist across instances.
+	tracker = NewLocalIssueTracker(filename)
+
+	found, err = tracker.FromQuery("https://perf.skia.org/cl/12")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, int64(1), found[0].ID)
+
+	// URLs that only share a prefix don't match.
+	found, err = tracker.FromQuery("https://perf.skia.org/cl/1")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(found))
+	_, err = tracker.AddIssue(IssueRequest{
+		Status:      "New",
+		Summary:     "Perf regression in desk_wikipedia",
+		Description: "See https://perf.skia.org/cl/123",
+	})
+	assert.NoError(t, err)
+	found, err = tracker.FromQuery("https://perf.skia.org/cl/12")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, int64(1), found[0].ID)
+
+	found, err = tracker.FromQuery("nytimes fromskiaperf")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(found))
+
+	found, err = tracker.FromQuery("")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(found))
+
+	assert.NoError(t, tracker.AddComment("1", CommentRequest{Content: "Bisected to abc123."}))
+	found, err = tracker.FromQuery("abc123")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(found))
+
+	assert.NoError(t, tracker.AddLabels(1, []string{"Priority-Medium", "FromSkiaPerf"}))
+	assert.NoError(t, tracker.SetStatus(1, "WontFix"))
+	got, err := tracker.Get(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "WontFix", got.Status)
+	assert.Equal(t, "closed", got.State)
+	assert.Equal(t, []string{"FromSkiaPerf", "Priority-Medium"}, got.Labels)
+
+	_, err = tracker.Get(4)
+	assert.Error(t, err)
+	assert.Error(t, tracker.SetStatus(4, "Fixed"))
+	assert.Error(t, tracker.AddComment("foo", CommentRequest{Content: "bar"}))
+}
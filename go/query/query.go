@@ -34,6 +34,10 @@ import (
 var (
 	keyRe   = regexp.MustCompile("^,([a-zA-Z0-9._\\-]+=[a-zA-Z0-9._\\-]+,)+$")
 	paramRe = regexp.MustCompile("^[a-zA-Z0-9._\\-]+$")
+
+	// invalidChar matches any char that is not allowed in a parameter name or
+	// value.
+	invalidChar = regexp.MustCompile("[^a-zA-Z0-9._\\-]")
 )
 
 // ValidateKey returns true if a key is valid, i.e. if the parameter names are
@@ -90,6 +94,36 @@ func MakeKey(m map[string]string) (string, error) {
 	return ret, nil
 }
 
+// ParseKey parses the structured key, and if valid, returns the parsed values
+// as a map[string]string, otherwise it returns a non-nil error.
+func ParseKey(key string) (map[string]string, error) {
+	if !ValidateKey(key) {
+		return nil, fmt.Errorf("Key is not valid: %q", key)
+	}
+	ret := map[string]string{}
+	for _, pair := range strings.Split(key[1:len(key)-1], ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		ret[parts[0]] = parts[1]
+	}
+	return ret, nil
+}
+
+// ForceValid returns a copy of the given map[string]string with all invalid
+// chars in the parameter names and values replaced with underscores, so that
+// it can always be passed to MakeKey. Empty names and values are dropped.
+// Distinct names or values can become equal, e.g. "a b" and "a/b", so callers
+// that need unique keys must check for collisions.
+func ForceValid(m map[string]string) map[string]string {
+	ret := make(map[string]string, len(m))
+	for k, v := range m {
+		if k == "" || v == "" {
+			continue
+		}
+		ret[invalidChar.ReplaceAllLiteralString(k, "_")] = invalidChar.ReplaceAllLiteralString(v, "_")
+	}
+	return ret
+}
+
 // queryParam represents a query on a particular parameter in a key.
 type queryParam struct {
 	keyMatch    string   // The param key, including the leading "," and trailing "=".
@@ -120,6 +120,35 @@ func TestMakeKey(t *testing.T) {
 	}
 }
 
+func TestParseKey(t *testing.T) {
+	m, err := ParseKey(",arch=x86,config=565,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"arch": "x86", "config": "565"}, m)
+
+	_, err = ParseKey(",config=565,arch=x86,")
+	assert.Error(t, err)
+	_, err = ParseKey("arch=x86")
+	assert.Error(t, err)
+}
+
+func TestForceValid(t *testing.T) {
+	m := ForceValid(map[string]string{
+		"arch":     "x86",
+		"bad,key":  "bad value",
+		"empty":    "",
+		"":         "noname",
+		"test_sub": "a/b:c",
+	})
+	assert.Equal(t, map[string]string{
+		"arch":     "x86",
+		"bad_key":  "bad_value",
+		"test_sub": "a_b_c",
+	}, m)
+	key, err := MakeKey(m)
+	assert.NoError(t, err)
+	assert.True(t, ValidateKey(key))
+}
+
 func TestNew(t *testing.T) {
 	q := New(url.Values{"config": []string{"565", "8888"}})
 	assert.Equal(t, 1, len(q.params))
@@ -397,6 +397,24 @@ func (r *Rietveld) GetPatchset(issueID int64, patchsetID int64) (*Patchset, erro
 	return patchset, nil
 }
 
+// GetPatch returns the raw diff for the given patchset.
+func (r *Rietveld) GetPatch(issueID int64, patchsetID int64) (string, error) {
+	url := fmt.Sprintf("%s/download/issue%d_%d.diff", r.url, issueID, patchsetID)
+	resp, err := r.client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("Failed to GET %s: %s", url, err)
+	}
+	defer util.Close(resp.Body)
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("Error retrieving %s: %d %s", url, resp.StatusCode, resp.Status)
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read patch from %s: %s", url, err)
+	}
+	return string(b), nil
+}
+
 // GetTrybotResults returns trybot results for the given Issue and Patchset.
 func (r *Rietveld) GetTrybotResults(issueID int64, patchsetID int64) ([]*buildbucket.Build, error) {
 	return buildbucket.NewClient(r.client).GetTrybotsForCL(issueID, patchsetID)
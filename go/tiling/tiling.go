@@ -221,6 +221,10 @@ type TraceGUI struct {
 	Data   [][2]float64      `json:"data"`
 	Label  string            `json:"label"`
 	Params map[string]string `json:"_params"`
+
+	// ErrorBand is an optional band of [x, low, high] points drawn around the
+	// trace.
+	ErrorBand [][3]float64 `json:"error_band,omitempty"`
 }
 
 // TileGUI is the JSON the server serves for tile requests.
@@ -18,6 +18,14 @@ type LongCommit struct {
 	Branches  map[string]bool `json:"-"`
 }
 
+// IndexCommit is information about a commit that includes the offset from
+// the first commit.
+type IndexCommit struct {
+	Hash      string
+	Index     int
+	Timestamp time.Time
+}
+
 // VCS is a generic interface to the information contained in a version
 // control system.
 type VCS interface {
@@ -32,4 +40,8 @@ type VCS interface {
 	// result will contain all branches that contain the given commit,
 	// otherwise Branches will be empty.
 	Details(hash string, includeBranchInfo bool) (*LongCommit, error)
+
+	// IndexOf returns the index of the commit hash, where 0 is the index of
+	// the initial commit.
+	IndexOf(hash string) (int, error)
 }
@@ -0,0 +1,190 @@
+package autotriage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/tally"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// Triage labels untriaged digests in the tile as positive. A digest is
+// labeled if one of the rules that match its traces finds it within the
+// rule's thresholds of a positive digest of the same test. The positive
+// digests of a test are the ones in 'tallies' for that test, the same as
+// digesttools.ClosestDigest uses, except for digests that were labeled by
+// auto-triage themselves and not triaged by a human since, see
+// NewHumanTriageStore. Otherwise labels could drift step by step away from
+// the digests that a human has triaged.
+//
+// The digests labeled by each rule are added to the expectations store as a
+// separate change that is attributed to Rule.TriageUser, so they show up in
+// the triage log and can be undone. It returns the number of digests that
+// were labeled.
+func Triage(tile *tiling.Tile, tallies map[string]tally.Tally, ruleStore RuleStore, expStore expstorage.ExpectationsStore, diffStore diff.DiffStore) (int, error) {
+	matcher, err := ruleStore.BuildRuleMatcher()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to build auto-triage rule matcher: %s", err)
+	}
+	exp, err := expStore.Get()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to load expectations: %s", err)
+	}
+	autoTriaged, err := ruleStore.Triaged()
+	if err != nil {
+		return 0, fmt.Errorf("Failed to load auto-triaged digests: %s", err)
+	}
+
+	// Find the untriaged digests and the rules that apply to them.
+	// candidates[test][digest][rule.ID]*Rule
+	candidates := map[string]map[string]map[int]*Rule{}
+	for _, trace := range tile.Traces {
+		gTrace := trace.(*types.GoldenTrace)
+		rules, ok := matcher(gTrace.Params_)
+		if !ok {
+			continue
+		}
+		test := gTrace.Params_[types.PRIMARY_KEY_FIELD]
+		for _, digest := range gTrace.Values {
+			if digest == types.MISSING_DIGEST || exp.Classification(test, digest) != types.UNTRIAGED {
+				continue
+			}
+			if _, ok := candidates[test]; !ok {
+				candidates[test] = map[string]map[int]*Rule{}
+			}
+			if _, ok := candidates[test][digest]; !ok {
+				candidates[test][digest] = map[int]*Rule{}
+			}
+			for _, r := range rules {
+				candidates[test][digest][r.ID] = r
+			}
+		}
+	}
+
+	unavailable := diffStore.UnavailableDigests()
+	// changes[rule.ID] are the digests labeled by the rule.
+	changes := map[int]map[string]types.TestClassification{}
+	labelers := map[int]*Rule{}
+	for test, digests := range candidates {
+		positives := []string{}
+		for d := range tallies[test] {
+			if _, ok := unavailable[d]; !ok && !autoTriaged[test][d] && exp.Classification(test, d) == types.POSITIVE {
+				positives = append(positives, d)
+			}
+		}
+		if len(positives) == 0 {
+			continue
+		}
+
+		for digest, rules := range digests {
+			if _, ok := unavailable[digest]; ok {
+				continue
+			}
+			diffMetrics, err := diffStore.Get(digest, positives)
+			if err != nil {
+				glog.Errorf("Failed to diff %s against the positive digests of %s: %s", digest, test, err)
+				continue
+			}
+			rule := firstWithin(rules, diffMetrics)
+			if rule == nil {
+				continue
+			}
+			if _, ok := changes[rule.ID]; !ok {
+				changes[rule.ID] = map[string]types.TestClassification{}
+				labelers[rule.ID] = rule
+			}
+			if _, ok := changes[rule.ID][test]; !ok {
+				changes[rule.ID][test] = types.TestClassification{}
+			}
+			changes[rule.ID][test][digest] = types.POSITIVE
+		}
+	}
+
+	ids := make([]int, 0, len(changes))
+	for id := range changes {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	count := 0
+	for _, id := range ids {
+		// Record the digests before labeling them, so that a labeled digest is
+		// never used as a positive.
+		if err := ruleStore.AddTriaged(id, changes[id]); err != nil {
+			return count, fmt.Errorf("Failed to record the digests labeled by auto-triage rule %d: %s", id, err)
+		}
+		if err := expStore.AddChange(changes[id], labelers[id].TriageUser()); err != nil {
+			return count, fmt.Errorf("Failed to store the changes of auto-triage rule %d: %s", id, err)
+		}
+		for _, digests := range changes[id] {
+			count += len(digests)
+		}
+	}
+	return count, nil
+}
+
+// firstWithin returns the rule with the lowest ID for which one of the diffs is
+// within its thresholds, or nil if there is no such rule.
+func firstWithin(rules map[int]*Rule, diffMetrics map[string]*diff.DiffMetrics) *Rule {
+	ids := make([]int, 0, len(rules))
+	for id := range rules {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	for _, id := range ids {
+		for _, dm := range diffMetrics {
+			if rules[id].Within(dm) {
+				return rules[id]
+			}
+		}
+	}
+	return nil
+}
+
+// humanTriageStore is an ExpectationsStore that forgets that digests were
+// labeled by auto-triage once anyone else changes their expectations.
+type humanTriageStore struct {
+	expstorage.ExpectationsStore
+	ruleStore RuleStore
+}
+
+// NewHumanTriageStore wraps expStore so that digests that are triaged by a
+// user other than an auto-triage rule, or whose changes are undone, are
+// removed from ruleStore.Triaged(). Triage uses them as positives again once
+// a human has confirmed them.
+func NewHumanTriageStore(expStore expstorage.ExpectationsStore, ruleStore RuleStore) expstorage.ExpectationsStore {
+	return &humanTriageStore{
+		ExpectationsStore: expStore,
+		ruleStore:         ruleStore,
+	}
+}
+
+// AddChange, see ExpectationsStore interface.
+func (h *humanTriageStore) AddChange(changes map[string]types.TestClassification, userId string) error {
+	if err := h.ExpectationsStore.AddChange(changes, userId); err != nil {
+		return err
+	}
+	if strings.HasPrefix(userId, TRIAGE_USER_PREFIX) {
+		return nil
+	}
+	if err := h.ruleStore.RemoveTriaged(changes); err != nil {
+		return fmt.Errorf("Failed to forget the auto-triaged digests triaged by %s: %s", userId, err)
+	}
+	return nil
+}
+
+// UndoChange, see ExpectationsStore interface.
+func (h *humanTriageStore) UndoChange(changeID int, userID string) (map[string]types.TestClassification, error) {
+	changes, err := h.ExpectationsStore.UndoChange(changeID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.ruleStore.RemoveTriaged(changes); err != nil {
+		return changes, fmt.Errorf("Failed to forget the auto-triaged digests of undone change %d: %s", changeID, err)
+	}
+	return changes, nil
+}
@@ -0,0 +1,136 @@
+package autotriage
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/tally"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// mockDiffStore returns the DiffMetrics in pairs for the main digest compared
+// to a specific digest, otherwise the DiffMetrics in diffs for each digest
+// compared to the main digest, and an empty diff for any other digest.
+type mockDiffStore struct {
+	diffs map[string]*diff.DiffMetrics
+	pairs map[string]map[string]*diff.DiffMetrics
+}
+
+func (m mockDiffStore) AbsPath(digest []string) map[string]string                { return nil }
+func (m mockDiffStore) UnavailableDigests() map[string]*diff.DigestFailure       { return nil }
+func (m mockDiffStore) PurgeDigests(digests []string, purgeGS bool) error        { return nil }
+func (m mockDiffStore) SetDigestSets(namedDigestSets map[string]map[string]bool) {}
+
+func (m mockDiffStore) Get(dMain string, dRest []string) (map[string]*diff.DiffMetrics, error) {
+	result := map[string]*diff.DiffMetrics{}
+	for _, d := range dRest {
+		if dm, ok := m.pairs[dMain][d]; ok {
+			result[d] = dm
+		} else if dm, ok := m.diffs[dMain]; ok {
+			result[d] = dm
+		} else {
+			result[d] = &diff.DiffMetrics{MaxRGBADiffs: []int{0, 0, 0, 0}}
+		}
+	}
+	return result, nil
+}
+
+// recordingExpStore records the user of every change.
+type recordingExpStore struct {
+	expstorage.ExpectationsStore
+	users []string
+}
+
+func (r *recordingExpStore) AddChange(changes map[string]types.TestClassification, userId string) error {
+	r.users = append(r.users, userId)
+	return r.ExpectationsStore.AddChange(changes, userId)
+}
+
+func TestTriage(t *testing.T) {
+	tile := tiling.NewTile()
+	addTrace := func(id, test, config string, values ...string) {
+		tr := types.NewGoldenTraceN(len(values))
+		copy(tr.Values, values)
+		tr.Params_[types.PRIMARY_KEY_FIELD] = test
+		tr.Params_["config"] = config
+		tile.Traces[id] = tr
+	}
+	addTrace("foo-gpu", "foo", "gpu", "pos1", "noise", "bigdiff", "drift")
+	addTrace("foo-8888", "foo", "8888", "pos1", "cpunoise", types.MISSING_DIGEST)
+	addTrace("bar-gpu", "bar", "gpu", "barnoise", "barnoise")
+
+	expStore := &recordingExpStore{ExpectationsStore: expstorage.NewMemExpectationsStore(nil)}
+	assert.NoError(t, expStore.AddChange(map[string]types.TestClassification{
+		"foo": {"pos1": types.POSITIVE},
+	}, "jon@example.com"))
+	tallies := map[string]tally.Tally{
+		"foo": {"pos1": 2, "noise": 1, "cpunoise": 1, "bigdiff": 1, "drift": 1},
+		"bar": {"barnoise": 2},
+	}
+	diffStore := mockDiffStore{
+		diffs: map[string]*diff.DiffMetrics{
+			"noise":    {NumDiffPixels: 5, MaxRGBADiffs: []int{2, 1, 0, 0}, PerceptualDiff: 0.2},
+			"cpunoise": {NumDiffPixels: 5, MaxRGBADiffs: []int{2, 1, 0, 0}, PerceptualDiff: 0.2},
+			"bigdiff":  {NumDiffPixels: 500, MaxRGBADiffs: []int{255, 1, 0, 0}, PerceptualDiff: 20},
+		},
+		// drift is close to noise, but not to the human triaged pos1.
+		pairs: map[string]map[string]*diff.DiffMetrics{
+			"drift": {
+				"pos1":  {NumDiffPixels: 50, MaxRGBADiffs: []int{4, 1, 0, 0}, PerceptualDiff: 2},
+				"noise": {NumDiffPixels: 5, MaxRGBADiffs: []int{2, 1, 0, 0}, PerceptualDiff: 0.2},
+			},
+		},
+	}
+
+	// The rule only applies to the gpu config, and bar has no positive digests.
+	ruleStore := NewMemRuleStore()
+	assert.NoError(t, ruleStore.Create(NewRule("jon@example.com", "name=foo&name=bar&config=gpu", 10, 2, 1, "")))
+	assert.NoError(t, ruleStore.Create(NewRule("jon@example.com", "name=foo&config=gpu", 10, 2, 0.1, "")))
+
+	n, err := Triage(tile, tallies, ruleStore, expStore, diffStore)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"jon@example.com", TRIAGE_USER_PREFIX + "0"}, expStore.users)
+
+	exp, err := expStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.POSITIVE, exp.Classification("foo", "noise"))
+	assert.Equal(t, types.UNTRIAGED, exp.Classification("foo", "cpunoise"))
+	assert.Equal(t, types.UNTRIAGED, exp.Classification("foo", "bigdiff"))
+	assert.Equal(t, types.UNTRIAGED, exp.Classification("bar", "barnoise"))
+	assert.Equal(t, types.UNTRIAGED, exp.Classification("foo", "drift"))
+
+	triaged, err := ruleStore.Triaged()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]bool{"foo": {"noise": true}}, triaged)
+
+	// Running again doesn't change anything. In particular drift is not
+	// labeled, because the auto-triaged noise is not used as a positive.
+	n, err = Triage(tile, tallies, ruleStore, expStore, diffStore)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, 2, len(expStore.users))
+
+	// Once a human confirms noise it is used as a positive again, so drift
+	// is labeled. Labels by auto-triage itself are still recorded.
+	humanStore := NewHumanTriageStore(expStore, ruleStore)
+	assert.NoError(t, humanStore.AddChange(map[string]types.TestClassification{
+		"foo": {"noise": types.POSITIVE},
+	}, "jon@example.com"))
+	triaged, err = ruleStore.Triaged()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]bool{}, triaged)
+
+	n, err = Triage(tile, tallies, ruleStore, humanStore, diffStore)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+	exp, err = expStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.POSITIVE, exp.Classification("foo", "drift"))
+	triaged, err = ruleStore.Triaged()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]bool{"foo": {"drift": true}}, triaged)
+}
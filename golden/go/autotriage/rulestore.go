@@ -0,0 +1,281 @@
+// Package autotriage labels untriaged digests as positive if they are close
+// enough to an existing positive digest of the same test, based on rules
+// that are stored like ignore rules.
+package autotriage
+
+import (
+	"fmt"
+	"net/url"
+	"sync"
+
+	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/ignore"
+	"go.skia.org/infra/golden/go/types"
+)
+
+const (
+	// TRIAGE_USER_PREFIX is the prefix of the user that auto-triage changes
+	// are attributed to in the triage log. It's followed by the rule ID.
+	TRIAGE_USER_PREFIX = "autotriage:rule-"
+)
+
+// RuleMatcher returns a list of rules in the RuleStore that match the given
+// set of parameters.
+type RuleMatcher func(map[string]string) ([]*Rule, bool)
+
+// RuleStore stores and matches auto-triage rules.
+type RuleStore interface {
+	// Create adds a new rule to the store.
+	Create(*Rule) error
+
+	// List returns all rules in the store, ordered by ID.
+	List() ([]*Rule, error)
+
+	// Update updates a Rule.
+	Update(id int, rule *Rule) error
+
+	// Delete removes a Rule from the store.
+	Delete(id int, userId string) (int, error)
+
+	// BuildRuleMatcher returns a RuleMatcher based on the current content
+	// of the store.
+	BuildRuleMatcher() (RuleMatcher, error)
+
+	// AddTriaged records that the given digests were labeled by the rule
+	// with the given id.
+	AddTriaged(id int, changes map[string]types.TestClassification) error
+
+	// Triaged returns all digests that were labeled by auto-triage rules,
+	// keyed by test name and digest.
+	Triaged() (map[string]map[string]bool, error)
+
+	// RemoveTriaged forgets that the given digests were labeled by
+	// auto-triage, e.g. because a human has triaged them since.
+	RemoveTriaged(changes map[string]types.TestClassification) error
+}
+
+// Rule is an auto-triage rule. Untriaged digests of traces that match Query
+// are labeled positive if the diff to a positive digest of the same test is
+// within all of the thresholds.
+type Rule struct {
+	ID        int    `json:"id"`
+	Name      string `json:"name"`
+	UpdatedBy string `json:"updatedBy"`
+	Query     string `json:"query"`
+	Note      string `json:"note"`
+
+	// MaxDiffPixels is the maximum number of pixels that may differ.
+	MaxDiffPixels int `json:"maxDiffPixels"`
+
+	// MaxRGBADiff is the maximum difference in any of the channels.
+	MaxRGBADiff int `json:"maxRGBADiff"`
+
+	// MaxPerceptualDiff is the maximum diff.DiffMetrics.PerceptualDiff. It's
+	// not checked if zero.
+	MaxPerceptualDiff float32 `json:"maxPerceptualDiff"`
+}
+
+func NewRule(name, queryStr string, maxDiffPixels, maxRGBADiff int, maxPerceptualDiff float32, note string) *Rule {
+	return &Rule{
+		Name:              name,
+		UpdatedBy:         name,
+		Query:             queryStr,
+		Note:              note,
+		MaxDiffPixels:     maxDiffPixels,
+		MaxRGBADiff:       maxRGBADiff,
+		MaxPerceptualDiff: maxPerceptualDiff,
+	}
+}
+
+// Validate returns an error if the rule is not valid. Rules are per test, so
+// the query must contain the test name.
+func (r *Rule) Validate() error {
+	q, err := url.ParseQuery(r.Query)
+	if err != nil {
+		return fmt.Errorf("Invalid query %q: %s", r.Query, err)
+	}
+	if len(q[types.PRIMARY_KEY_FIELD]) == 0 {
+		return fmt.Errorf("The query must contain the test name (%q).", types.PRIMARY_KEY_FIELD)
+	}
+	if r.MaxDiffPixels < 0 {
+		return fmt.Errorf("MaxDiffPixels must be >= 0, got %d.", r.MaxDiffPixels)
+	}
+	if r.MaxRGBADiff < 0 || r.MaxRGBADiff > 255 {
+		return fmt.Errorf("MaxRGBADiff must be in [0, 255], got %d.", r.MaxRGBADiff)
+	}
+	if r.MaxPerceptualDiff < 0 {
+		return fmt.Errorf("MaxPerceptualDiff must be >= 0, got %f.", r.MaxPerceptualDiff)
+	}
+	return nil
+}
+
+// Within returns true if the given diff is within all of the thresholds of
+// the rule. Images with different dimensions are never within the thresholds.
+func (r *Rule) Within(dm *diff.DiffMetrics) bool {
+	if dm.DimDiffer || dm.NumDiffPixels > r.MaxDiffPixels {
+		return false
+	}
+	for _, c := range dm.MaxRGBADiffs {
+		if c > r.MaxRGBADiff {
+			return false
+		}
+	}
+	return r.MaxPerceptualDiff == 0 || dm.PerceptualDiff <= r.MaxPerceptualDiff
+}
+
+// TriageUser returns the user that changes made by this rule are attributed
+// to in the triage log.
+func (r *Rule) TriageUser() string {
+	return fmt.Sprintf("%s%d", TRIAGE_USER_PREFIX, r.ID)
+}
+
+// MemRuleStore is an in-memory implementation of RuleStore.
+type MemRuleStore struct {
+	rules   []*Rule
+	triaged map[string]map[string]bool
+	mutex   sync.Mutex
+	nextId  int
+}
+
+func NewMemRuleStore() RuleStore {
+	return &MemRuleStore{
+		rules:   []*Rule{},
+		triaged: map[string]map[string]bool{},
+	}
+}
+
+// Create, see RuleStore interface.
+func (m *MemRuleStore) Create(rule *Rule) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	rule.ID = m.nextId
+	m.nextId++
+	m.rules = append(m.rules, rule)
+	return nil
+}
+
+// List, see RuleStore interface.
+func (m *MemRuleStore) List() ([]*Rule, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	result := make([]*Rule, len(m.rules))
+	copy(result, m.rules)
+	return result, nil
+}
+
+// Update, see RuleStore interface.
+func (m *MemRuleStore) Update(id int, updated *Rule) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, rule := range m.rules {
+		if rule.ID == id {
+			updated.ID = id
+			m.rules[i] = updated
+			return nil
+		}
+	}
+
+	return fmt.Errorf("Did not find an auto-triage rule with id: %d", id)
+}
+
+// Delete, see RuleStore interface.
+func (m *MemRuleStore) Delete(id int, userId string) (int, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for idx, rule := range m.rules {
+		if rule.ID == id {
+			m.rules = append(m.rules[:idx], m.rules[idx+1:]...)
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// BuildRuleMatcher, see RuleStore interface.
+func (m *MemRuleStore) BuildRuleMatcher() (RuleMatcher, error) {
+	return buildRuleMatcher(m)
+}
+
+// AddTriaged, see RuleStore interface.
+func (m *MemRuleStore) AddTriaged(id int, changes map[string]types.TestClassification) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for testName, digests := range changes {
+		if _, ok := m.triaged[testName]; !ok {
+			m.triaged[testName] = map[string]bool{}
+		}
+		for d := range digests {
+			m.triaged[testName][d] = true
+		}
+	}
+	return nil
+}
+
+// Triaged, see RuleStore interface.
+func (m *MemRuleStore) Triaged() (map[string]map[string]bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ret := make(map[string]map[string]bool, len(m.triaged))
+	for testName, digests := range m.triaged {
+		ret[testName] = make(map[string]bool, len(digests))
+		for d := range digests {
+			ret[testName][d] = true
+		}
+	}
+	return ret, nil
+}
+
+// RemoveTriaged, see RuleStore interface.
+func (m *MemRuleStore) RemoveTriaged(changes map[string]types.TestClassification) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for testName, digests := range changes {
+		for d := range digests {
+			delete(m.triaged[testName], d)
+		}
+		if len(m.triaged[testName]) == 0 {
+			delete(m.triaged, testName)
+		}
+	}
+	return nil
+}
+
+func noopRuleMatcher(p map[string]string) ([]*Rule, bool) {
+	return nil, false
+}
+
+func buildRuleMatcher(store RuleStore) (RuleMatcher, error) {
+	rulesList, err := store.List()
+	if err != nil {
+		return noopRuleMatcher, err
+	}
+
+	queryRules := make([]ignore.QueryRule, len(rulesList))
+	for idx, rawRule := range rulesList {
+		parsedQuery, err := url.ParseQuery(rawRule.Query)
+		if err != nil {
+			return noopRuleMatcher, err
+		}
+		queryRules[idx] = ignore.NewQueryRule(parsedQuery)
+	}
+
+	return func(params map[string]string) ([]*Rule, bool) {
+		result := []*Rule{}
+
+		for ruleIdx, rule := range queryRules {
+			if rule.IsMatch(params) {
+				result = append(result, rulesList[ruleIdx])
+			}
+		}
+
+		return result, len(result) > 0
+	}, nil
+}
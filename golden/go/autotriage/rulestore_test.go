@@ -0,0 +1,132 @@
+package autotriage
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/types"
+)
+
+func TestMemRuleStore(t *testing.T) {
+	testRuleStore(t, NewMemRuleStore())
+}
+
+func testRuleStore(t *testing.T, store RuleStore) {
+	r1 := NewRule("jon@example.com", "name=foo&config=gpu", 10, 2, 0, "GPU noise.")
+	r2 := NewRule("jim@example.com", "name=foo", 0, 0, 1.5, "")
+	r3 := NewRule("jon@example.com", "name=bar&config=gpu&config=8888", 100, 5, 0, "")
+	assert.NoError(t, store.Create(r1))
+	assert.NoError(t, store.Create(r2))
+	assert.NoError(t, store.Create(r3))
+
+	allRules, err := store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(allRules))
+	assert.Equal(t, []int{r1.ID, r2.ID, r3.ID}, []int{allRules[0].ID, allRules[1].ID, allRules[2].ID})
+	assert.Equal(t, float32(1.5), allRules[1].MaxPerceptualDiff)
+
+	// Test the rule matcher.
+	matcher, err := store.BuildRuleMatcher()
+	assert.NoError(t, err)
+	found, ok := matcher(map[string]string{"name": "baz", "config": "gpu"})
+	assert.False(t, ok)
+	assert.Equal(t, []*Rule{}, found)
+	found, ok = matcher(map[string]string{"name": "foo", "config": "8888"})
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, r2.ID, found[0].ID)
+	found, ok = matcher(map[string]string{"name": "foo", "config": "gpu"})
+	assert.True(t, ok)
+	assert.Equal(t, 2, len(found))
+	found, ok = matcher(map[string]string{"name": "bar", "config": "8888"})
+	assert.True(t, ok)
+	assert.Equal(t, 1, len(found))
+	assert.Equal(t, r3.ID, found[0].ID)
+
+	// Update a rule.
+	updated := NewRule("jim@example.com", "name=foo&config=565", 20, 3, 2, "Updated.")
+	updated.UpdatedBy = "jane@example.com"
+	assert.NoError(t, store.Update(r1.ID, updated))
+	allRules, err = store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, "name=foo&config=565", allRules[0].Query)
+	assert.Equal(t, "jane@example.com", allRules[0].UpdatedBy)
+	assert.Equal(t, 20, allRules[0].MaxDiffPixels)
+	assert.Equal(t, 3, allRules[0].MaxRGBADiff)
+	assert.Equal(t, float32(2), allRules[0].MaxPerceptualDiff)
+	assert.Error(t, store.Update(100000, updated))
+
+	// Delete a rule.
+	delCount, err := store.Delete(r2.ID, "jon@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, delCount)
+	delCount, err = store.Delete(r2.ID, "jon@example.com")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, delCount)
+	allRules, err = store.List()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(allRules))
+
+	// Record auto-triaged digests.
+	triaged, err := store.Triaged()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]bool{}, triaged)
+	assert.NoError(t, store.AddTriaged(r1.ID, map[string]types.TestClassification{
+		"foo": {"aaa": types.POSITIVE, "bbb": types.POSITIVE},
+	}))
+	assert.NoError(t, store.AddTriaged(r3.ID, map[string]types.TestClassification{
+		"foo": {"aaa": types.POSITIVE},
+		"bar": {"ccc": types.POSITIVE},
+	}))
+	triaged, err = store.Triaged()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]bool{
+		"foo": {"aaa": true, "bbb": true},
+		"bar": {"ccc": true},
+	}, triaged)
+
+	// Forget digests that were triaged by a human.
+	assert.NoError(t, store.RemoveTriaged(map[string]types.TestClassification{
+		"foo": {"aaa": types.NEGATIVE, "ddd": types.POSITIVE},
+		"bar": {"ccc": types.POSITIVE},
+	}))
+	triaged, err = store.Triaged()
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]map[string]bool{"foo": {"bbb": true}}, triaged)
+	assert.NoError(t, store.RemoveTriaged(map[string]types.TestClassification{
+		"foo": {"bbb": types.POSITIVE},
+	}))
+
+	// Clean up.
+	for _, r := range allRules {
+		_, err = store.Delete(r.ID, "jon@example.com")
+		assert.NoError(t, err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.NoError(t, NewRule("jon@example.com", "name=foo", 10, 2, 1, "").Validate())
+
+	assert.Error(t, NewRule("jon@example.com", "%zz", 10, 2, 1, "").Validate())
+	assert.Error(t, NewRule("jon@example.com", "config=gpu", 10, 2, 1, "").Validate())
+	assert.Error(t, NewRule("jon@example.com", "name=foo", -1, 2, 1, "").Validate())
+	assert.Error(t, NewRule("jon@example.com", "name=foo", 10, 256, 1, "").Validate())
+	assert.Error(t, NewRule("jon@example.com", "name=foo", 10, 2, -1, "").Validate())
+}
+
+func TestWithin(t *testing.T) {
+	dm := &diff.DiffMetrics{
+		NumDiffPixels:  10,
+		MaxRGBADiffs:   []int{1, 2, 0, 0},
+		PerceptualDiff: 0.5,
+	}
+	assert.True(t, NewRule("", "name=foo", 10, 2, 0, "").Within(dm))
+	assert.True(t, NewRule("", "name=foo", 10, 2, 0.5, "").Within(dm))
+	assert.False(t, NewRule("", "name=foo", 9, 2, 0, "").Within(dm))
+	assert.False(t, NewRule("", "name=foo", 10, 1, 0, "").Within(dm))
+	assert.False(t, NewRule("", "name=foo", 10, 2, 0.4, "").Within(dm))
+
+	dm.DimDiffer = true
+	assert.False(t, NewRule("", "name=foo", 10, 2, 0, "").Within(dm))
+}
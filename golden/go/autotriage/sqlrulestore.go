@@ -0,0 +1,163 @@
+package autotriage
+
+import (
+	"fmt"
+
+	"go.skia.org/infra/go/database"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/types"
+)
+
+type SQLRuleStore struct {
+	vdb *database.VersionedDB
+}
+
+// NewSQLRuleStore creates a new SQL based RuleStore.
+func NewSQLRuleStore(vdb *database.VersionedDB) RuleStore {
+	return &SQLRuleStore{
+		vdb: vdb,
+	}
+}
+
+// Create, see RuleStore interface.
+func (m *SQLRuleStore) Create(rule *Rule) error {
+	stmt := `INSERT INTO autotriagerule (userid, updated_by, query, note, max_diff_pixels, max_rgba_diff, max_perceptual_diff)
+	         VALUES(?,?,?,?,?,?,?)`
+
+	ret, err := m.vdb.DB.Exec(stmt, rule.Name, rule.Name, rule.Query, rule.Note, rule.MaxDiffPixels, rule.MaxRGBADiff, rule.MaxPerceptualDiff)
+	if err != nil {
+		return err
+	}
+	createdId, err := ret.LastInsertId()
+	if err != nil {
+		return err
+	}
+	rule.ID = int(createdId)
+	return nil
+}
+
+// Update, see RuleStore interface.
+func (m *SQLRuleStore) Update(id int, rule *Rule) error {
+	stmt := `UPDATE autotriagerule
+	         SET updated_by=?, query=?, note=?, max_diff_pixels=?, max_rgba_diff=?, max_perceptual_diff=?
+	         WHERE id=?`
+
+	res, err := m.vdb.DB.Exec(stmt, rule.UpdatedBy, rule.Query, rule.Note, rule.MaxDiffPixels, rule.MaxRGBADiff, rule.MaxPerceptualDiff, id)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err == nil && n == 0 {
+		return fmt.Errorf("Did not find an auto-triage rule with id: %d", id)
+	}
+	return nil
+}
+
+// List, see RuleStore interface.
+func (m *SQLRuleStore) List() ([]*Rule, error) {
+	stmt := `SELECT id, userid, updated_by, query, note, max_diff_pixels, max_rgba_diff, max_perceptual_diff
+	         FROM autotriagerule
+	         ORDER BY id ASC`
+	rows, err := m.vdb.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer util.Close(rows)
+
+	result := []*Rule{}
+	for rows.Next() {
+		target := &Rule{}
+		err := rows.Scan(&target.ID, &target.Name, &target.UpdatedBy, &target.Query, &target.Note, &target.MaxDiffPixels, &target.MaxRGBADiff, &target.MaxPerceptualDiff)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, target)
+	}
+	return result, nil
+}
+
+// Delete, see RuleStore interface.
+func (m *SQLRuleStore) Delete(id int, userId string) (int, error) {
+	stmt := "DELETE FROM autotriagerule WHERE id=?"
+	ret, err := m.vdb.DB.Exec(stmt, id)
+	if err != nil {
+		return 0, err
+	}
+	rowsAffected, err := ret.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int(rowsAffected), nil
+}
+
+// BuildRuleMatcher, see RuleStore interface.
+func (m *SQLRuleStore) BuildRuleMatcher() (RuleMatcher, error) {
+	return buildRuleMatcher(m)
+}
+
+// AddTriaged, see RuleStore interface.
+func (m *SQLRuleStore) AddTriaged(id int, changes map[string]types.TestClassification) (retErr error) {
+	const insertDigest = `INSERT INTO autotriaged (name, digest, ruleid, ts)
+	                      VALUES (?, ?, ?, ?)
+	                      ON DUPLICATE KEY UPDATE ruleid=VALUES(ruleid), ts=VALUES(ts)`
+
+	tx, err := m.vdb.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() { retErr = database.CommitOrRollback(tx, retErr) }()
+
+	now := util.TimeStampMs()
+	for testName, digests := range changes {
+		for d := range digests {
+			if _, err := tx.Exec(insertDigest, testName, d, id, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RemoveTriaged, see RuleStore interface.
+func (m *SQLRuleStore) RemoveTriaged(changes map[string]types.TestClassification) (retErr error) {
+	const deleteDigest = `DELETE FROM autotriaged WHERE name=? AND digest=?`
+
+	tx, err := m.vdb.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() { retErr = database.CommitOrRollback(tx, retErr) }()
+
+	for testName, digests := range changes {
+		for d := range digests {
+			if _, err := tx.Exec(deleteDigest, testName, d); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Triaged, see RuleStore interface.
+func (m *SQLRuleStore) Triaged() (map[string]map[string]bool, error) {
+	rows, err := m.vdb.DB.Query(`SELECT name, digest FROM autotriaged`)
+	if err != nil {
+		return nil, err
+	}
+	defer util.Close(rows)
+
+	ret := map[string]map[string]bool{}
+	for rows.Next() {
+		var testName, digest string
+		if err := rows.Scan(&testName, &digest); err != nil {
+			return nil, err
+		}
+		if _, ok := ret[testName]; !ok {
+			ret[testName] = map[string]bool{}
+		}
+		ret[testName][digest] = true
+	}
+	return ret, nil
+}
@@ -0,0 +1,24 @@
+package autotriage
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/database/testutil"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/golden/go/db"
+)
+
+func TestSQLRuleStore(t *testing.T) {
+	// Set up the database. This also locks the db until this test is finished
+	// causing similar tests to wait.
+	migrationSteps := db.MigrationSteps()
+	mysqlDB := testutil.SetupMySQLTestDatabase(t, migrationSteps)
+	defer mysqlDB.Close(t)
+
+	vdb, err := testutil.LocalTestDatabaseConfig(migrationSteps).NewVersionedDB()
+	assert.NoError(t, err)
+	defer testutils.AssertCloses(t, vdb)
+
+	testRuleStore(t, NewSQLRuleStore(vdb))
+}
@@ -0,0 +1,201 @@
+// baseline contains the baselines that Gold serves to clients so they can
+// check rendering results locally, i.e. without uploading them first.
+package baseline
+
+import (
+	"crypto/md5"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/goldingestion"
+	"go.skia.org/infra/golden/go/types"
+)
+
+const (
+	// VERSION is the version of the Baseline format. It has to be incremented
+	// whenever the format changes in a way that breaks clients.
+	VERSION = 1
+)
+
+// DigestBaseline is the classification of one digest of a test together with
+// the paramset of the traces the digest was observed in.
+type DigestBaseline struct {
+	Label  string              `json:"label"`
+	Params map[string][]string `json:"params"`
+}
+
+// Baseline contains the positive and negative digests of every test.
+type Baseline struct {
+	Version int    `json:"version"`
+	Issue   string `json:"issue,omitempty"`
+
+	// Tests maps test names to digests to their baseline.
+	Tests map[string]map[string]*DigestBaseline `json:"tests"`
+}
+
+// ParamsFn returns the paramset of the traces of the given test that
+// contain the given digest.
+type ParamsFn func(testName, digest string) map[string][]string
+
+// New creates a Baseline from the given expectations. Untriaged digests are
+// left out. issueID is the Rietveld issue whose expectations were overlaid on
+// exp and can be empty.
+func New(exp *expstorage.Expectations, issueID string, paramsFn ParamsFn) *Baseline {
+	ret := &Baseline{
+		Version: VERSION,
+		Issue:   issueID,
+		Tests:   make(map[string]map[string]*DigestBaseline, len(exp.Tests)),
+	}
+	for testName, digests := range exp.Tests {
+		for digest, label := range digests {
+			if label == types.UNTRIAGED {
+				continue
+			}
+			if _, ok := ret.Tests[testName]; !ok {
+				ret.Tests[testName] = map[string]*DigestBaseline{}
+			}
+			params := paramsFn(testName, digest)
+			if params == nil {
+				params = map[string][]string{}
+			}
+			ret.Tests[testName][digest] = &DigestBaseline{
+				Label:  label.String(),
+				Params: params,
+			}
+		}
+	}
+	return ret
+}
+
+// Classification returns the label of the given digest of the given test.
+func (b *Baseline) Classification(testName, digest string) types.Label {
+	if db, ok := b.Tests[testName][digest]; ok {
+		return types.LabelFromString(db.Label)
+	}
+	return types.UNTRIAGED
+}
+
+// CheckResult is the outcome of checking one DM result against a Baseline.
+type CheckResult struct {
+	TestName string
+	Params   map[string]string
+
+	// Digest is the digest reported by DM, which is what Gold classifies.
+	Digest string
+	Label  types.Label
+
+	// Path is the image DM wrote for the result. It is empty if the image
+	// could not be found.
+	Path string
+
+	// ImageDigest is the digest of the pixels in Path, calculated the same
+	// way DM calculates Digest. It is empty if Path is empty.
+	ImageDigest string
+}
+
+// Mismatch returns true if the image found on disk does not have the digest
+// that DM reported for it, e.g. because dm.json and the images are from
+// different runs.
+func (c *CheckResult) Mismatch() bool {
+	return c.ImageDigest != "" && c.ImageDigest != c.Digest
+}
+
+// CheckResultSlice sorts CheckResults by test name and digest.
+type CheckResultSlice []*CheckResult
+
+func (c CheckResultSlice) Len() int { return len(c) }
+func (c CheckResultSlice) Less(i, j int) bool {
+	if c[i].TestName == c[j].TestName {
+		return c[i].Digest < c[j].Digest
+	}
+	return c[i].TestName < c[j].TestName
+}
+func (c CheckResultSlice) Swap(i, j int) { c[i], c[j] = c[j], c[i] }
+
+// Check classifies the PNG results in dmResults against the baseline, using
+// the digests reported by DM. The images are expected in imgDir in the layout
+// DM writes them, i.e. as <config>/<source_type>/<name>.png. Images that are
+// found are hashed to detect whether they match the reported digests.
+func (b *Baseline) Check(dmResults *goldingestion.DMResults, imgDir string) ([]*CheckResult, error) {
+	ret := make([]*CheckResult, 0, len(dmResults.Results))
+	for _, result := range dmResults.Results {
+		if result.Options["ext"] != "png" {
+			continue
+		}
+
+		params := make(map[string]string, len(dmResults.Key)+len(result.Key))
+		for k, v := range dmResults.Key {
+			params[k] = v
+		}
+		for k, v := range result.Key {
+			params[k] = v
+		}
+
+		testName := params[types.PRIMARY_KEY_FIELD]
+		path := filepath.Join(imgDir, params["config"], params[types.CORPUS_FIELD], testName+".png")
+		imgDigest, err := HashImage(path)
+		if os.IsNotExist(err) {
+			path = ""
+		} else if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, &CheckResult{
+			TestName:    testName,
+			Params:      params,
+			Digest:      result.Digest,
+			Label:       b.Classification(testName, result.Digest),
+			Path:        path,
+			ImageDigest: imgDigest,
+		})
+	}
+	sort.Sort(CheckResultSlice(ret))
+	return ret, nil
+}
+
+// HashImage returns the digest of the given PNG file the way DM calculates
+// it: the hex encoded MD5 hash of the premultiplied pixels in BGRA order, i.e.
+// of the N32 bitmap DM rendered on a little endian machine. PNGs store
+// unpremultiplied pixels, so the digest is only guaranteed to match DM's for
+// opaque images.
+func HashImage(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer util.Close(f)
+
+	img, err := png.Decode(f)
+	if err != nil {
+		return "", fmt.Errorf("Failed to decode %s: %s", path, err)
+	}
+	nrgba, ok := img.(*image.NRGBA)
+	if !ok {
+		nrgba = image.NewNRGBA(img.Bounds())
+		draw.Draw(nrgba, img.Bounds(), img, img.Bounds().Min, draw.Src)
+	}
+
+	w, h := nrgba.Bounds().Dx(), nrgba.Bounds().Dy()
+	pixels := make([]byte, 0, w*h*4)
+	for y := 0; y < h; y++ {
+		row := nrgba.Pix[y*nrgba.Stride : y*nrgba.Stride+w*4]
+		for x := 0; x < len(row); x += 4 {
+			r, g, b, a := row[x], row[x+1], row[x+2], row[x+3]
+			pixels = append(pixels, premul(b, a), premul(g, a), premul(r, a), a)
+		}
+	}
+	return fmt.Sprintf("%x", md5.Sum(pixels)), nil
+}
+
+// premul multiplies the color component c by alpha a, rounding like Skia.
+func premul(c, a uint8) uint8 {
+	prod := uint32(c)*uint32(a) + 128
+	return uint8((prod + (prod >> 8)) >> 8)
+}
@@ -0,0 +1,107 @@
+package baseline
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/goldingestion"
+	"go.skia.org/infra/golden/go/types"
+)
+
+const (
+	// Digests reported by DM. The PNGs in testdata/8888/gm were written by DM
+	// and have the digests HELLO_DIGEST (foo) and OTHER_DIGEST (bar).
+	HELLO_DIGEST = "df1591dde35907399734ea19feb76663"
+	WORLD_DIGEST = "ffce5042b4ac4a57bd7c8657b557d495"
+	OTHER_DIGEST = "fffbcca7e8913ec45b88cc2c6a3a73ad"
+)
+
+func testBaseline() *Baseline {
+	exp := expstorage.NewExpectations()
+	exp.AddDigests(map[string]types.TestClassification{
+		"foo": {HELLO_DIGEST: types.POSITIVE, "aaa": types.UNTRIAGED},
+		"bar": {WORLD_DIGEST: types.NEGATIVE},
+		"baz": {"bbb": types.UNTRIAGED},
+	})
+	return New(exp, "1234", func(testName, digest string) map[string][]string {
+		if testName == "foo" {
+			return map[string][]string{"config": []string{"8888", "565"}}
+		}
+		return nil
+	})
+}
+
+func TestNew(t *testing.T) {
+	b := testBaseline()
+	assert.Equal(t, VERSION, b.Version)
+	assert.Equal(t, "1234", b.Issue)
+	assert.Equal(t, map[string]map[string]*DigestBaseline{
+		"foo": {
+			HELLO_DIGEST: {Label: "positive", Params: map[string][]string{"config": []string{"8888", "565"}}},
+		},
+		"bar": {
+			WORLD_DIGEST: {Label: "negative", Params: map[string][]string{}},
+		},
+	}, b.Tests)
+
+	assert.Equal(t, types.POSITIVE, b.Classification("foo", HELLO_DIGEST))
+	assert.Equal(t, types.NEGATIVE, b.Classification("bar", WORLD_DIGEST))
+	assert.Equal(t, types.UNTRIAGED, b.Classification("foo", "aaa"))
+	assert.Equal(t, types.UNTRIAGED, b.Classification("unknown", HELLO_DIGEST))
+}
+
+func TestCheck(t *testing.T) {
+	// testdata contains the dm.json of a DM run and real PNGs written by DM.
+	// The image of bar is not the one dm.json reports and the image of foo
+	// in 565 is missing.
+	f, err := os.Open(filepath.Join("testdata", "dm.json"))
+	assert.NoError(t, err)
+	dmResults, err := goldingestion.ParseDMResultsFromReader(f)
+	assert.NoError(t, err)
+
+	results, err := testBaseline().Check(dmResults, "testdata")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(results))
+
+	assert.Equal(t, "bar", results[0].TestName)
+	assert.Equal(t, WORLD_DIGEST, results[0].Digest)
+	assert.Equal(t, types.NEGATIVE, results[0].Label)
+	assert.Equal(t, OTHER_DIGEST, results[0].ImageDigest)
+	assert.True(t, results[0].Mismatch())
+	assert.Equal(t, map[string]string{"arch": "x86_64", "name": "bar", "config": "8888", "source_type": "gm"}, results[0].Params)
+
+	for _, r := range results[1:] {
+		assert.Equal(t, "foo", r.TestName)
+		assert.Equal(t, HELLO_DIGEST, r.Digest)
+		assert.Equal(t, types.POSITIVE, r.Label)
+		assert.False(t, r.Mismatch())
+	}
+	assert.Equal(t, 1, countEmptyPaths(results[1:]))
+}
+
+func TestHashImage(t *testing.T) {
+	d, err := HashImage(filepath.Join("testdata", "8888", "gm", "foo.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, HELLO_DIGEST, d)
+	d, err = HashImage(filepath.Join("testdata", "8888", "gm", "bar.png"))
+	assert.NoError(t, err)
+	assert.Equal(t, OTHER_DIGEST, d)
+
+	_, err = HashImage(filepath.Join("testdata", "dm.json"))
+	assert.Error(t, err)
+	_, err = HashImage(filepath.Join("testdata", "missing.png"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func countEmptyPaths(results []*CheckResult) int {
+	ret := 0
+	for _, r := range results {
+		if r.Path == "" {
+			ret++
+		}
+	}
+	return ret
+}
@@ -169,6 +169,76 @@ var migrationSteps = []database.MigrationStep{
 		},
 	},
 
+	// Add the auto-triage rules.
+	// version 11
+	{
+		MySQLUp: []string{
+			`CREATE TABLE autotriagerule (
+				id                  INT     NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				userid              TEXT    NOT NULL,
+				updated_by          TEXT    NOT NULL,
+				query               TEXT    NOT NULL,
+				note                TEXT    NOT NULL,
+				max_diff_pixels     INT     NOT NULL,
+				max_rgba_diff       INT     NOT NULL,
+				max_perceptual_diff DOUBLE  NOT NULL
+			)`,
+		},
+		MySQLDown: []string{
+			`DROP TABLE IF EXISTS autotriagerule`,
+		},
+	},
+
+	// Add the expectations of Rietveld issues.
+	// version 12
+	{
+		MySQLUp: []string{
+			`CREATE TABLE exp_issue (
+				issue         VARCHAR(255)  NOT NULL,
+				name          VARCHAR(255)  NOT NULL,
+				digest        VARCHAR(255)  NOT NULL,
+				label         VARCHAR(255)  NOT NULL,
+				userid        VARCHAR(255)  NOT NULL,
+				ts            BIGINT        NOT NULL,
+				PRIMARY KEY (issue, name, digest)
+			)`,
+		},
+		MySQLDown: []string{
+			`DROP TABLE IF EXISTS exp_issue`,
+		},
+	},
+
+	// Record the digests that were labeled by auto-triage rules.
+	// version 13
+	{
+		MySQLUp: []string{
+			`CREATE TABLE autotriaged (
+				name          VARCHAR(255)  NOT NULL,
+				digest        VARCHAR(255)  NOT NULL,
+				ruleid        INT           NOT NULL,
+				ts            BIGINT        NOT NULL,
+				PRIMARY KEY (name, digest)
+			)`,
+		},
+		MySQLDown: []string{
+			`DROP TABLE IF EXISTS autotriaged`,
+		},
+	},
+
+	// Record up to which master commit landed issues have been merged.
+	// version 14
+	{
+		MySQLUp: []string{
+			`CREATE TABLE exp_issue_merged (
+				id            INT           NOT NULL PRIMARY KEY,
+				commit_ts     BIGINT        NOT NULL
+			)`,
+		},
+		MySQLDown: []string{
+			`DROP TABLE IF EXISTS exp_issue_merged`,
+		},
+	},
+
 	// Use this is a template for more migration steps.
 	// version x
 	// {
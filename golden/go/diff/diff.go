@@ -64,6 +64,13 @@ type DiffMetrics struct {
 	MaxRGBADiffs []int
 	// True if the dimensions of the compared images are different.
 	DimDiffer bool
+	// PerceptualDiff is the mean perceptual difference over all pixels, where
+	// the difference of each pixel is the CIE L*a*b* color difference (see
+	// deltaE), and pixels that only exist in one image count as
+	// MISSING_PIXEL_DELTA_E. A difference of about 2.3 is just noticeable, so
+	// small anti-aliasing changes score much lower than real differences even
+	// if they touch many pixels. It's only zero if NumDiffPixels is zero.
+	PerceptualDiff float32
 }
 
 // Diff error to indicate different error conditions during diffing.
@@ -244,6 +251,7 @@ func Diff(img1, img2 image.Image) (*DiffMetrics, *image.NRGBA) {
 	// and there is an area not inspected by the loop.
 	numDiffPixels := totalPixels
 	maxRGBADiffs := make([]int, 4)
+	sumDeltaE := float64(totalPixels-cmpWidth*cmpHeight) * MISSING_PIXEL_DELTA_E
 
 	// Pix is a []uint8 rotating through R, G, B, A, R, G, B, A, ...
 	p1 := GetNRGBA(img1).Pix
@@ -278,6 +286,7 @@ func Diff(img1, img2 image.Image) (*DiffMetrics, *image.NRGBA) {
 					maxRGBADiffs[1] = util.MaxInt(dg, maxRGBADiffs[1])
 					maxRGBADiffs[2] = util.MaxInt(db, maxRGBADiffs[2])
 					maxRGBADiffs[3] = util.MaxInt(da, maxRGBADiffs[3])
+					sumDeltaE += deltaE(r, g, b, a, R, G, B, A)
 					if dr+dg+db > 0 {
 						copy(resultImg.Pix[off+i:], PixelDiffColor[deltaOffset(dr+dg+db+da)])
 					} else {
@@ -299,6 +308,10 @@ func Diff(img1, img2 image.Image) (*DiffMetrics, *image.NRGBA) {
 				dc := diffColors(color1, color2, maxRGBADiffs)
 				if dc == PixelMatchColor {
 					numDiffPixels--
+				} else {
+					c1 := color.NRGBAModel.Convert(color1).(color.NRGBA)
+					c2 := color.NRGBAModel.Convert(color2).(color.NRGBA)
+					sumDeltaE += deltaE(c1.R, c1.G, c1.B, c1.A, c2.R, c2.G, c2.B, c2.A)
 				}
 				resultImg.Set(x, y, dc)
 			}
@@ -309,5 +322,6 @@ func Diff(img1, img2 image.Image) (*DiffMetrics, *image.NRGBA) {
 		NumDiffPixels:    numDiffPixels,
 		PixelDiffPercent: getPixelDiffPercent(numDiffPixels, totalPixels),
 		MaxRGBADiffs:     maxRGBADiffs,
-		DimDiffer:        (cmpWidth != resultWidth) || (cmpHeight != resultHeight)}, resultImg
+		DimDiffer:        (cmpWidth != resultWidth) || (cmpHeight != resultHeight),
+		PerceptualDiff:   float32(sumDeltaE / float64(totalPixels))}, resultImg
 }
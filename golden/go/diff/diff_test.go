@@ -3,6 +3,7 @@ package diff
 import (
 	"bytes"
 	"image"
+	"math"
 	"path/filepath"
 	"reflect"
 	"strings"
@@ -26,14 +27,16 @@ func TestDiffMetrics(t *testing.T) {
 			PixelDiffPercent:  0.0064,
 			PixelDiffFilePath: "",
 			MaxRGBADiffs:      []int{54, 100, 125, 0},
-			DimDiffer:         false})
+			DimDiffer:         false,
+			PerceptualDiff:    0.0015004426})
 	assertDiffs(t, "5024150605949408692", "11069776588985027208",
 		&DiffMetrics{
 			NumDiffPixels:     2233,
 			PixelDiffPercent:  0.8932,
 			PixelDiffFilePath: "",
 			MaxRGBADiffs:      []int{0, 0, 1, 0},
-			DimDiffer:         false})
+			DimDiffer:         false,
+			PerceptualDiff:    0.005917143})
 	// Assert the same image.
 	assertDiffs(t, "5024150605949408692", "5024150605949408692",
 		&DiffMetrics{
@@ -41,7 +44,8 @@ func TestDiffMetrics(t *testing.T) {
 			PixelDiffPercent:  0,
 			PixelDiffFilePath: "",
 			MaxRGBADiffs:      []int{0, 0, 0, 0},
-			DimDiffer:         false})
+			DimDiffer:         false,
+			PerceptualDiff:    0})
 	// Assert different images with different dimensions.
 	assertDiffs(t, "ffce5042b4ac4a57bd7c8657b557d495", "fffbcca7e8913ec45b88cc2c6a3a73ad",
 		&DiffMetrics{
@@ -49,7 +53,8 @@ func TestDiffMetrics(t *testing.T) {
 			PixelDiffPercent:  89.324066,
 			PixelDiffFilePath: "",
 			MaxRGBADiffs:      []int{255, 255, 255, 0},
-			DimDiffer:         true})
+			DimDiffer:         true,
+			PerceptualDiff:    89.224724})
 	// Assert with images that match in dimensions but where all pixels differ.
 	assertDiffs(t, "4029959456464745507", "4029959456464745507-inverted",
 		&DiffMetrics{
@@ -57,7 +62,8 @@ func TestDiffMetrics(t *testing.T) {
 			PixelDiffPercent:  100.0,
 			PixelDiffFilePath: "",
 			MaxRGBADiffs:      []int{255, 255, 255, 0},
-			DimDiffer:         false})
+			DimDiffer:         false,
+			PerceptualDiff:    105.3954})
 
 	// Assert different images where neither fits into the other.
 	assertDiffs(t, "fffbcca7e8913ec45b88cc2c6a3a73ad", "fffbcca7e8913ec45b88cc2c6a3a73ad-rotated",
@@ -66,7 +72,8 @@ func TestDiffMetrics(t *testing.T) {
 			PixelDiffPercent:  74.8550347222,
 			PixelDiffFilePath: "",
 			MaxRGBADiffs:      []int{255, 255, 255, 0},
-			DimDiffer:         true})
+			DimDiffer:         true,
+			PerceptualDiff:    74.50721})
 	// Make sure the metric is symmetric.
 	assertDiffs(t, "fffbcca7e8913ec45b88cc2c6a3a73ad-rotated", "fffbcca7e8913ec45b88cc2c6a3a73ad",
 		&DiffMetrics{
@@ -74,7 +81,8 @@ func TestDiffMetrics(t *testing.T) {
 			PixelDiffPercent:  74.8550347222,
 			PixelDiffFilePath: "",
 			MaxRGBADiffs:      []int{255, 255, 255, 0},
-			DimDiffer:         true})
+			DimDiffer:         true,
+			PerceptualDiff:    74.50721})
 
 	// Compare two images where one has an alpha channel and the other doesn't.
 	assertDiffs(t, "b716a12d5b98d04b15db1d9dd82c82ea", "df1591dde35907399734ea19feb76663",
@@ -83,7 +91,8 @@ func TestDiffMetrics(t *testing.T) {
 			PixelDiffPercent:  2.8483074,
 			PixelDiffFilePath: "",
 			MaxRGBADiffs:      []int{255, 2, 255, 0},
-			DimDiffer:         false})
+			DimDiffer:         false,
+			PerceptualDiff:    3.2210386})
 
 	// Compare two images where the alpha differs.
 	assertDiffs(t, "df1591dde35907399734ea19feb76663", "df1591dde35907399734ea19feb76663-6-alpha-diff",
@@ -92,7 +101,8 @@ func TestDiffMetrics(t *testing.T) {
 			PixelDiffPercent:  0.001953125,
 			PixelDiffFilePath: "",
 			MaxRGBADiffs:      []int{0, 0, 0, 235},
-			DimDiffer:         false})
+			DimDiffer:         false,
+			PerceptualDiff:    0.0011169832})
 }
 
 const SRC1 = `! SKTEXTSIMPLE
@@ -238,7 +248,7 @@ func assertDiffMatch(t *testing.T, expected, src1, src2 string, expectedDiffMetr
 	assertImagesEqual(t, got, want)
 
 	for _, expDM := range expectedDiffMetrics {
-		assert.Equal(t, expDM, dm)
+		assertDiffMetricsEqual(t, expDM, dm)
 	}
 }
 
@@ -258,6 +268,7 @@ func TestDiffImages(t *testing.T) {
 		PixelDiffFilePath: "",
 		MaxRGBADiffs:      []int{0, 0, 0, 0},
 		DimDiffer:         true,
+		PerceptualDiff:    24 * MISSING_PIXEL_DELTA_E / 25,
 	})
 }
 
@@ -277,7 +288,19 @@ func assertDiffs(t *testing.T, d1, d2 string, expectedDiffMetrics *DiffMetrics)
 	if err != nil {
 		t.Error("Unexpected error: ", err)
 	}
-	if got, want := diffMetrics, expectedDiffMetrics; !reflect.DeepEqual(got, want) {
+	assertDiffMetricsEqual(t, expectedDiffMetrics, diffMetrics)
+}
+
+// assertDiffMetricsEqual asserts that the DiffMetrics are equal. Since
+// PerceptualDiff depends on floating point rounding it's only compared to
+// within a small relative error.
+func assertDiffMetricsEqual(t *testing.T, want, got *DiffMetrics) {
+	wantCopy := *want
+	gotCopy := *got
+	assert.InDelta(t, want.PerceptualDiff, got.PerceptualDiff, 0.00001*math.Max(1, float64(want.PerceptualDiff)))
+	wantCopy.PerceptualDiff = 0
+	gotCopy.PerceptualDiff = 0
+	if !reflect.DeepEqual(&gotCopy, &wantCopy) {
 		t.Errorf("Image Diff: Got %v Want %v", got, want)
 	}
 }
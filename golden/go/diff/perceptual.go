@@ -0,0 +1,43 @@
+package diff
+
+import (
+	"math"
+
+	"go.skia.org/infra/perdiff/go/colorspace"
+)
+
+const (
+	// GAMMA is used to convert 8 bit channel values into linear values before
+	// converting them to L*a*b*.
+	GAMMA = 2.2
+
+	// MISSING_PIXEL_DELTA_E is the color difference used for pixels that only
+	// exist in one of the images, i.e. the difference between black and white.
+	MISSING_PIXEL_DELTA_E = 100.0
+)
+
+// linear maps 8 bit channel values to linear values in [0, 1].
+var linear [256]float64
+
+func init() {
+	for i := range linear {
+		linear[i] = math.Pow(float64(i)/255.0, GAMMA)
+	}
+}
+
+// toLAB converts non-premultiplied 8 bit RGB values to L*a*b*.
+func toLAB(r, g, b uint8) (float64, float64, float64) {
+	return colorspace.ConvertXYZToLAB(colorspace.ConvertAdobeRGBToXYZ(linear[r], linear[g], linear[b]))
+}
+
+// deltaE returns the perceptual difference between two non-premultiplied
+// RGBA colors. It's the CIE76 color difference between the L*a*b* values of
+// the colors, with the difference in alpha, scaled to [0, 100] like L*, added
+// as a fourth dimension. It's only zero if the colors are identical.
+func deltaE(r1, g1, b1, a1, r2, g2, b2, a2 uint8) float64 {
+	L1, A1, B1 := toLAB(r1, g1, b1)
+	L2, A2, B2 := toLAB(r2, g2, b2)
+	dE := colorspace.DeltaE(L1, A1, B1, L2, A2, B2)
+	dAlpha := 100.0 * (float64(a1) - float64(a2)) / 255.0
+	return math.Sqrt(dE*dE + dAlpha*dAlpha)
+}
@@ -3,8 +3,8 @@
 //
 // It consists of multiple components:
 //
-// - ImageLoader: Downloads images from Google storage and caches them on local
-//                disk and in Redis. It aims that proactively fetching images
+// - ImageLoader: Downloads images from an image source (e.g. Google storage)
+//                and caches them on local disk and in Redis. It aims that proactively fetching images
 //                so that they are always in Redis when they are needed for
 //                calculating diffs. Making real time diffs fast, because we
 //                don't have to load anything from disk.
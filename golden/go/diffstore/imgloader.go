@@ -2,28 +2,17 @@ package diffstore
 
 import (
 	"bytes"
-	"crypto/md5"
-	"fmt"
 	"image"
-	"io"
-	"net/http"
-	"path/filepath"
 	"sync"
 
-	"cloud.google.com/go/storage"
 	"github.com/skia-dev/glog"
 	"go.skia.org/infra/go/fileutil"
 	"go.skia.org/infra/go/redisutil"
 	"go.skia.org/infra/go/rtcache"
-	"go.skia.org/infra/go/util"
-	"golang.org/x/net/context"
-	"google.golang.org/api/option"
+	"go.skia.org/infra/golden/go/imagesource"
 )
 
 const (
-	// MAX_URI_GET_TRIES is the number of tries we do to load an image.
-	MAX_URI_GET_TRIES = 4
-
 	// Number of concurrent workers downloading images.
 	N_IMG_WORKERS = 10
 )
@@ -40,8 +29,8 @@ const (
 // ImageLoader facilitates to continously download images and cache them
 // in Redis.
 type ImageLoader struct {
-	// client is the Google storage client to local content form GS.
-	storageClient *storage.Client
+	// imgSource is where images are retrieved from if they are not on disk.
+	imgSource imagesource.ImageSource
 
 	// redisPool is the connection to Redis.
 	redisPool *redisutil.RedisPool
@@ -49,12 +38,6 @@ type ImageLoader struct {
 	// localImgDir is the local directory where images should be written to.
 	localImgDir string
 
-	// gsBucketName is the GS bucket where images are stored.
-	gsBucketName string
-
-	// gsImageBaseDir is the GS directory (prefix) where images are stored.
-	gsImageBaseDir string
-
 	// imageCache caches and calculates images.
 	imageCache rtcache.ReadThroughCache
 
@@ -65,22 +48,16 @@ type ImageLoader struct {
 // Creates a new instance of ImageLoader. isMaster indicates whether
 // this is running on the master node and files loaded on slave nodes
 // should be pushed to Redis for caching on the master.
-func newImgLoader(client *http.Client, imgDir, gsBucketName, gsImageBaseDir string, redisPool *redisutil.RedisPool, isMaster bool) (*ImageLoader, error) {
-	storageClient, err := storage.NewClient(context.Background(), option.WithHTTPClient(client))
-	if err != nil {
-		return nil, err
-	}
-
+func newImgLoader(imgSource imagesource.ImageSource, imgDir string, redisPool *redisutil.RedisPool, isMaster bool) (*ImageLoader, error) {
 	ret := &ImageLoader{
-		redisPool:      redisPool,
-		storageClient:  storageClient,
-		localImgDir:    imgDir,
-		gsBucketName:   gsBucketName,
-		gsImageBaseDir: gsImageBaseDir,
-		isMaster:       isMaster,
+		redisPool:   redisPool,
+		imgSource:   imgSource,
+		localImgDir: imgDir,
+		isMaster:    isMaster,
 	}
 
 	// Set up the work queues that balance the load.
+	var err error
 	if ret.imageCache, err = redisutil.NewReadThroughCache(redisPool, R_IMG_QUEUE, ret.imageLoadWorker, NRGBACodec{}, N_IMG_WORKERS); err != nil {
 		return nil, err
 	}
@@ -128,7 +105,7 @@ func (il *ImageLoader) Get(priority int64, digest string) (*image.NRGBA, error)
 }
 
 // imageLoadWorker implements the rtcache.ReadThroughFunc signature.
-// It loads an image file either from disk or from Google storage.
+// It loads an image file either from disk or from the image source.
 func (il *ImageLoader) imageLoadWorker(priority int64, digest string) (interface{}, error) {
 	// Check if the image is in the disk cache.
 	imageFileName := getDigestImageFileName(digest)
@@ -138,10 +115,12 @@ func (il *ImageLoader) imageLoadWorker(priority int64, digest string) (interface
 	}
 
 	// Download the image
-	imgBytes, err := il.downloadImg(digest)
+	glog.Infof("Starting download for: %s", digest)
+	imgBytes, err := il.imgSource.Get(digest)
 	if err != nil {
 		return nil, err
 	}
+	glog.Infof("Done downloading image for: %s. Length: %d", digest, len(imgBytes))
 
 	// Store it on disk and send it to the master if necessary.
 	saveFileAsyncRadixPath(il.localImgDir, imageFileName, bytes.NewBuffer(imgBytes))
@@ -152,56 +131,3 @@ func (il *ImageLoader) imageLoadWorker(priority int64, digest string) (interface
 	// Decode it and return it.
 	return decodeImg(bytes.NewBuffer(imgBytes))
 }
-
-// downloadImg retrieves the given image from Google storage.
-func (il *ImageLoader) downloadImg(digest string) ([]byte, error) {
-	glog.Infof("Starting download for for: %s", digest)
-	objLocation := filepath.Join(il.gsImageBaseDir, getDigestImageFileName(digest))
-	ctx := context.Background()
-
-	// Retrieve the attributes.
-	attrs, err := il.storageClient.Bucket(il.gsBucketName).Object(objLocation).Attrs(ctx)
-	if err != nil {
-		return nil, fmt.Errorf("Unable to retrieve attributes for %s/%s: %s", il.gsBucketName, objLocation, err)
-	}
-
-	var buf *bytes.Buffer
-	for i := 0; i < MAX_URI_GET_TRIES; i++ {
-		err = func() error {
-			reader, err := il.storageClient.Bucket(il.gsBucketName).Object(objLocation).NewReader(ctx)
-			if err != nil {
-				return fmt.Errorf("New reader failed for %s/%s: %s", il.gsBucketName, objLocation, err)
-			}
-			defer util.Close(reader)
-
-			size := reader.Size()
-			buf = bytes.NewBuffer(make([]byte, 0, size))
-			md5Hash := md5.New()
-			multiOut := io.MultiWriter(md5Hash, buf)
-
-			if _, err = io.Copy(multiOut, reader); err != nil {
-				return err
-			}
-
-			// Check the MD5.
-			if !bytes.Equal(md5Hash.Sum(nil), attrs.MD5) {
-				return fmt.Errorf("MD5 hash for digest %s incorrect.", digest)
-			}
-
-			return nil
-		}()
-
-		if err == nil {
-			break
-		}
-		glog.Errorf("Error fetching file for digest %s: %s", digest, err)
-	}
-
-	if err != nil {
-		glog.Errorf("Failed fetching file after %d attempts", MAX_URI_GET_TRIES)
-		return nil, err
-	}
-
-	glog.Infof("Done downloading image for: %s. Length: %d", digest, buf.Len())
-	return buf.Bytes(), err
-}
@@ -16,6 +16,7 @@ import (
 	"go.skia.org/infra/go/tiling"
 	"go.skia.org/infra/go/timer"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/imagesource"
 	"go.skia.org/infra/golden/go/mocks"
 	"go.skia.org/infra/golden/go/types"
 )
@@ -99,7 +100,9 @@ func getImageLoaderAndTile(t assert.TestingT) (string, *tiling.Tile, *ImageLoade
 	rp := redisutil.NewRedisPool(REDIS_SERVER_ADDRESS, REDIS_DB)
 	assert.NoError(t, rp.FlushDB())
 
-	imgLoader, err := newImgLoader(client, workingDir, gsBucketName, gsImageDir, rp, true)
+	imgSource, err := imagesource.NewGSImageSource(client, gsBucketName, gsImageDir)
+	assert.NoError(t, err)
+	imgLoader, err := newImgLoader(imgSource, workingDir, rp, true)
 	assert.NoError(t, err)
 	return workingDir, tile, imgLoader
 }
@@ -11,11 +11,24 @@ import (
 	"go.skia.org/infra/golden/go/types"
 )
 
+const (
+	// METRIC_COMBINED ranks digests by combinedDiffMetric.
+	METRIC_COMBINED = "combined"
+
+	// METRIC_PERCEPTUAL ranks digests by diff.DiffMetrics.PerceptualDiff, which
+	// scores anti-aliasing noise lower than METRIC_COMBINED does.
+	METRIC_PERCEPTUAL = "perceptual"
+)
+
+// METRICS is the list of metrics that digests can be ranked by.
+var METRICS = []string{METRIC_COMBINED, METRIC_PERCEPTUAL}
+
 // Closest describes one digest that is the closest another digest.
 type Closest struct {
 	Digest     string  `json:"digest"`     // The closest digest, empty if there are no digests to compare to.
 	Diff       float32 `json:"diff"`       // A percent value.
 	DiffPixels float32 `json:"diffPixels"` // A percent value.
+	Perceptual float32 `json:"perceptual"` // The mean CIE Lab delta E, see diff.DiffMetrics.
 	MaxRGBA    []int   `json:"maxRGBA"`
 }
 
@@ -23,14 +36,16 @@ func newClosest() *Closest {
 	return &Closest{
 		Diff:       math.MaxFloat32,
 		DiffPixels: math.MaxFloat32,
+		Perceptual: math.MaxFloat32,
 		MaxRGBA:    []int{},
 	}
 }
 
 // ClosestDigest returns the closest digest of type 'label' to 'digest', or "" if there aren't any positive digests.
+// Digests are ranked by 'metric', one of METRICS, which defaults to METRIC_COMBINED.
 //
 // If no digest of type 'label' is found then Closest.Digest is the empty string.
-func ClosestDigest(test string, digest string, exp *expstorage.Expectations, tallies tally.Tally, diffStore diff.DiffStore, label types.Label) *Closest {
+func ClosestDigest(test string, digest string, exp *expstorage.Expectations, tallies tally.Tally, diffStore diff.DiffStore, label types.Label, metric string) *Closest {
 	ret := newClosest()
 	unavailableDigests := diffStore.UnavailableDigests()
 
@@ -54,10 +69,11 @@ func ClosestDigest(test string, digest string, exp *expstorage.Expectations, tal
 		return ret
 	} else {
 		for digest, diff := range diffMetrics {
-			if delta := combinedDiffMetric(diff.PixelDiffPercent, diff.MaxRGBADiffs); delta < ret.Diff {
+			if delta := diffMetric(diff, metric); delta < ret.Diff {
 				ret.Digest = digest
 				ret.Diff = delta
 				ret.DiffPixels = diff.PixelDiffPercent
+				ret.Perceptual = diff.PerceptualDiff
 				ret.MaxRGBA = diff.MaxRGBADiffs
 			}
 		}
@@ -71,10 +87,21 @@ func ClosestFromDiffMetrics(diff *diff.DiffMetrics) *Closest {
 	return &Closest{
 		Diff:       combinedDiffMetric(diff.PixelDiffPercent, diff.MaxRGBADiffs),
 		DiffPixels: diff.PixelDiffPercent,
+		Perceptual: diff.PerceptualDiff,
 		MaxRGBA:    diff.MaxRGBADiffs,
 	}
 }
 
+// diffMetric returns a value that represents how large the diff is between two
+// images according to 'metric'. Values are in [0, 1] for METRIC_COMBINED, and
+// may exceed 1 for METRIC_PERCEPTUAL if most pixels have very different colors.
+func diffMetric(diff *diff.DiffMetrics, metric string) float32 {
+	if metric == METRIC_PERCEPTUAL {
+		return diff.PerceptualDiff / 100.0
+	}
+	return combinedDiffMetric(diff.PixelDiffPercent, diff.MaxRGBADiffs)
+}
+
 // combinedDiffMetric returns a value in [0, 1] that represents how large
 // the diff is between two images.
 func combinedDiffMetric(pixelDiffPercent float32, maxRGBA []int) float32 {
@@ -18,7 +18,8 @@ func (m MockDiffStore) UnavailableDigests() map[string]*diff.DigestFailure
 func (m MockDiffStore) PurgeDigests(digests []string, purgeGS bool) error        { return nil }
 func (m MockDiffStore) SetDigestSets(namedDigestSets map[string]map[string]bool) {}
 
-// Get always finds that digest "eee" is closest to dMain.
+// Get always finds that digest "eee" is closest to dMain, and that digest
+// "aaa" is perceptually closest to dMain.
 func (m MockDiffStore) Get(dMain string, dRest []string) (map[string]*diff.DiffMetrics, error) {
 	result := map[string]*diff.DiffMetrics{}
 	for i, d := range dRest {
@@ -26,9 +27,14 @@ func (m MockDiffStore) Get(dMain string, dRest []string) (map[string]*diff.DiffM
 		if d == "eee" {
 			diffPercent = 0.1
 		}
+		perceptualDiff := float32(50)
+		if d == "aaa" {
+			perceptualDiff = 0.5
+		}
 		result[d] = &diff.DiffMetrics{
 			PixelDiffPercent: diffPercent,
 			MaxRGBADiffs:     []int{5, 3, 4, 0},
+			PerceptualDiff:   perceptualDiff,
 		}
 	}
 	return result, nil
@@ -57,22 +63,28 @@ func TestClosestDigest(t *testing.T) {
 	}
 
 	// First test against a test that has positive digests.
-	c := ClosestDigest("foo", "fff", exp, tallies, diffStore, types.POSITIVE)
+	c := ClosestDigest("foo", "fff", exp, tallies, diffStore, types.POSITIVE, METRIC_COMBINED)
 	assert.InDelta(t, 0.0372, float64(c.Diff), 0.01)
 	assert.Equal(t, "eee", c.Digest)
 	assert.Equal(t, []int{5, 3, 4, 0}, c.MaxRGBA)
 
 	// Now test against a test with no positive digests.
-	c = ClosestDigest("bar", "fff", exp, tallies, diffStore, types.POSITIVE)
+	c = ClosestDigest("bar", "fff", exp, tallies, diffStore, types.POSITIVE, METRIC_COMBINED)
 	assert.Equal(t, float32(math.MaxFloat32), c.Diff)
 	assert.Equal(t, "", c.Digest)
 	assert.Equal(t, []int{}, c.MaxRGBA)
 
 	// Now test against negative digests.
-	c = ClosestDigest("foo", "fff", exp, tallies, diffStore, types.NEGATIVE)
+	c = ClosestDigest("foo", "fff", exp, tallies, diffStore, types.NEGATIVE, METRIC_COMBINED)
 	assert.InDelta(t, 0.166, float64(c.Diff), 0.01)
 	assert.Equal(t, "bbb", c.Digest)
 	assert.Equal(t, []int{5, 3, 4, 0}, c.MaxRGBA)
+
+	// Rank by the perceptual diff.
+	c = ClosestDigest("foo", "fff", exp, tallies, diffStore, types.POSITIVE, METRIC_PERCEPTUAL)
+	assert.InDelta(t, 0.005, float64(c.Diff), 0.0001)
+	assert.Equal(t, "aaa", c.Digest)
+	assert.Equal(t, float32(0.5), c.Perceptual)
 }
 
 func TestCombinedDiffMetric(t *testing.T) {
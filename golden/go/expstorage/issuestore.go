@@ -0,0 +1,152 @@
+package expstorage
+
+import (
+	"sort"
+	"sync"
+
+	"go.skia.org/infra/golden/go/types"
+)
+
+// IssueExpectationsStore stores expectations per Rietveld issue. The
+// expectations of an issue overlay the master expectations while the issue is
+// being viewed, so triaging trybot results doesn't change master until the
+// issue lands.
+type IssueExpectationsStore interface {
+	// Get returns the expectations of the given issue. They are empty if
+	// nothing has been triaged for the issue.
+	Get(issueID string) (*Expectations, error)
+
+	// AddChange adds the classified digests to the expectations of the issue.
+	AddChange(issueID string, changes map[string]types.TestClassification, userId string) error
+
+	// Issues returns the sorted ids of all issues that have expectations.
+	Issues() ([]string, error)
+
+	// Delete removes the expectations of the given issue.
+	Delete(issueID string) error
+
+	// RemoveChange removes the classified digests from the expectations of
+	// the issue. Digests whose label differs from the one in changes are
+	// kept, because they have been triaged again since.
+	RemoveChange(issueID string, changes map[string]types.TestClassification) error
+
+	// LastMergedCommit returns the timestamp of the newest master commit that
+	// has been searched for landed issues, or 0 if there is none.
+	LastMergedCommit() (int64, error)
+
+	// SetLastMergedCommit sets the timestamp returned by LastMergedCommit.
+	SetLastMergedCommit(ts int64) error
+}
+
+// Overlay returns the expectations with the classifications in overlay
+// added, overriding existing ones. It returns e itself if overlay is empty,
+// so the result must not be modified.
+func (e *Expectations) Overlay(overlay *Expectations) *Expectations {
+	if overlay == nil || len(overlay.Tests) == 0 {
+		return e
+	}
+	ret := e.DeepCopy()
+	ret.AddDigests(overlay.Tests)
+	return ret
+}
+
+// MemIssueExpectationsStore implements IssueExpectationsStore in memory for
+// prototyping and testing.
+type MemIssueExpectationsStore struct {
+	issues     map[string]*Expectations
+	lastMerged int64
+	mutex      sync.Mutex
+}
+
+// NewMemIssueExpectationsStore returns a new memory backed
+// IssueExpectationsStore.
+func NewMemIssueExpectationsStore() IssueExpectationsStore {
+	return &MemIssueExpectationsStore{
+		issues: map[string]*Expectations{},
+	}
+}
+
+// Get, see IssueExpectationsStore interface.
+func (m *MemIssueExpectationsStore) Get(issueID string) (*Expectations, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if exp, ok := m.issues[issueID]; ok {
+		return exp.DeepCopy(), nil
+	}
+	return NewExpectations(), nil
+}
+
+// AddChange, see IssueExpectationsStore interface.
+func (m *MemIssueExpectationsStore) AddChange(issueID string, changes map[string]types.TestClassification, userId string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.issues[issueID]; !ok {
+		m.issues[issueID] = NewExpectations()
+	}
+	m.issues[issueID].AddDigests(changes)
+	return nil
+}
+
+// Issues, see IssueExpectationsStore interface.
+func (m *MemIssueExpectationsStore) Issues() ([]string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	ret := make([]string, 0, len(m.issues))
+	for issueID := range m.issues {
+		ret = append(ret, issueID)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+// Delete, see IssueExpectationsStore interface.
+func (m *MemIssueExpectationsStore) Delete(issueID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.issues, issueID)
+	return nil
+}
+
+// RemoveChange, see IssueExpectationsStore interface.
+func (m *MemIssueExpectationsStore) RemoveChange(issueID string, changes map[string]types.TestClassification) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	exp, ok := m.issues[issueID]
+	if !ok {
+		return nil
+	}
+	for testName, digests := range changes {
+		for d, label := range digests {
+			if current, ok := exp.Tests[testName][d]; ok && current == label {
+				delete(exp.Tests[testName], d)
+			}
+		}
+		if len(exp.Tests[testName]) == 0 {
+			delete(exp.Tests, testName)
+		}
+	}
+	if len(exp.Tests) == 0 {
+		delete(m.issues, issueID)
+	}
+	return nil
+}
+
+// LastMergedCommit, see IssueExpectationsStore interface.
+func (m *MemIssueExpectationsStore) LastMergedCommit() (int64, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	return m.lastMerged, nil
+}
+
+// SetLastMergedCommit, see IssueExpectationsStore interface.
+func (m *MemIssueExpectationsStore) SetLastMergedCommit(ts int64) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.lastMerged = ts
+	return nil
+}
@@ -0,0 +1,112 @@
+package expstorage
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/database/testutil"
+	"go.skia.org/infra/golden/go/db"
+	"go.skia.org/infra/golden/go/types"
+)
+
+func TestMemIssueExpectationsStore(t *testing.T) {
+	testIssueExpectationsStore(t, NewMemIssueExpectationsStore())
+}
+
+func TestMySQLIssueExpectationsStore(t *testing.T) {
+	testDb := testutil.SetupMySQLTestDatabase(t, db.MigrationSteps())
+	defer testDb.Close(t)
+
+	conf := testutil.LocalTestDatabaseConfig(db.MigrationSteps())
+	vdb, err := conf.NewVersionedDB()
+	assert.NoError(t, err)
+
+	testIssueExpectationsStore(t, NewSQLIssueExpectationsStore(vdb))
+}
+
+func testIssueExpectationsStore(t *testing.T, store IssueExpectationsStore) {
+	exp, err := store.Get("1234")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(exp.Tests))
+
+	assert.NoError(t, store.AddChange("1234", map[string]types.TestClassification{
+		"foo": {"a": types.POSITIVE, "b": types.NEGATIVE},
+	}, "jon@example.com"))
+	assert.NoError(t, store.AddChange("1234", map[string]types.TestClassification{
+		"foo": {"b": types.POSITIVE},
+		"bar": {"c": types.POSITIVE},
+	}, "jim@example.com"))
+	assert.NoError(t, store.AddChange("5678", map[string]types.TestClassification{
+		"foo": {"d": types.NEGATIVE},
+	}, "jon@example.com"))
+
+	exp, err = store.Get("1234")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]types.TestClassification{
+		"foo": {"a": types.POSITIVE, "b": types.POSITIVE},
+		"bar": {"c": types.POSITIVE},
+	}, exp.Tests)
+
+	issues, err := store.Issues()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1234", "5678"}, issues)
+
+	// Only digests with an unchanged label are removed.
+	assert.NoError(t, store.RemoveChange("1234", map[string]types.TestClassification{
+		"foo": {"a": types.POSITIVE, "b": types.NEGATIVE},
+	}))
+	exp, err = store.Get("1234")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]types.TestClassification{
+		"foo": {"b": types.POSITIVE},
+		"bar": {"c": types.POSITIVE},
+	}, exp.Tests)
+
+	assert.NoError(t, store.Delete("1234"))
+	exp, err = store.Get("1234")
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(exp.Tests))
+	issues, err = store.Issues()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"5678"}, issues)
+	assert.NoError(t, store.RemoveChange("5678", map[string]types.TestClassification{
+		"foo": {"d": types.NEGATIVE},
+	}))
+	issues, err = store.Issues()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, issues)
+
+	ts, err := store.LastMergedCommit()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), ts)
+	assert.NoError(t, store.SetLastMergedCommit(1234))
+	assert.NoError(t, store.SetLastMergedCommit(5678))
+	ts, err = store.LastMergedCommit()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(5678), ts)
+}
+
+func TestOverlay(t *testing.T) {
+	master := NewExpectations()
+	master.AddDigests(map[string]types.TestClassification{
+		"foo": {"a": types.POSITIVE, "b": types.NEGATIVE},
+	})
+
+	// An empty overlay returns master itself.
+	assert.True(t, master == master.Overlay(NewExpectations()))
+	assert.True(t, master == master.Overlay(nil))
+
+	overlay := NewExpectations()
+	overlay.AddDigests(map[string]types.TestClassification{
+		"foo": {"b": types.POSITIVE},
+		"bar": {"c": types.POSITIVE},
+	})
+	merged := master.Overlay(overlay)
+	assert.Equal(t, types.POSITIVE, merged.Classification("foo", "a"))
+	assert.Equal(t, types.POSITIVE, merged.Classification("foo", "b"))
+	assert.Equal(t, types.POSITIVE, merged.Classification("bar", "c"))
+
+	// Master is unchanged.
+	assert.Equal(t, types.NEGATIVE, master.Classification("foo", "b"))
+	assert.Equal(t, types.UNTRIAGED, master.Classification("bar", "c"))
+}
@@ -0,0 +1,135 @@
+package expstorage
+
+import (
+	"database/sql"
+
+	"go.skia.org/infra/go/database"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// SQLIssueExpectationsStore stores the expectations of Rietveld issues in an
+// SQL database without any caching.
+type SQLIssueExpectationsStore struct {
+	vdb *database.VersionedDB
+}
+
+func NewSQLIssueExpectationsStore(vdb *database.VersionedDB) IssueExpectationsStore {
+	return &SQLIssueExpectationsStore{
+		vdb: vdb,
+	}
+}
+
+// Get, see IssueExpectationsStore interface.
+func (s *SQLIssueExpectationsStore) Get(issueID string) (*Expectations, error) {
+	const stmt = `SELECT name, digest, label FROM exp_issue WHERE issue=?`
+
+	rows, err := s.vdb.DB.Query(stmt, issueID)
+	if err != nil {
+		return nil, err
+	}
+	defer util.Close(rows)
+
+	ret := NewExpectations()
+	for rows.Next() {
+		var testName, digest, label string
+		if err = rows.Scan(&testName, &digest, &label); err != nil {
+			return nil, err
+		}
+		if _, ok := ret.Tests[testName]; !ok {
+			ret.Tests[testName] = types.TestClassification{}
+		}
+		ret.Tests[testName][digest] = types.LabelFromString(label)
+	}
+	return ret, nil
+}
+
+// AddChange, see IssueExpectationsStore interface.
+func (s *SQLIssueExpectationsStore) AddChange(issueID string, changes map[string]types.TestClassification, userId string) (retErr error) {
+	const insertDigest = `INSERT INTO exp_issue (issue, name, digest, label, userid, ts)
+	                      VALUES (?, ?, ?, ?, ?, ?)
+	                      ON DUPLICATE KEY UPDATE label=VALUES(label), userid=VALUES(userid), ts=VALUES(ts)`
+
+	tx, err := s.vdb.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() { retErr = database.CommitOrRollback(tx, retErr) }()
+
+	now := util.TimeStampMs()
+	for testName, digests := range changes {
+		for d, label := range digests {
+			if _, err := tx.Exec(insertDigest, issueID, testName, d, label.String(), userId, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Issues, see IssueExpectationsStore interface.
+func (s *SQLIssueExpectationsStore) Issues() ([]string, error) {
+	const stmt = `SELECT DISTINCT issue FROM exp_issue ORDER BY issue`
+
+	rows, err := s.vdb.DB.Query(stmt)
+	if err != nil {
+		return nil, err
+	}
+	defer util.Close(rows)
+
+	ret := []string{}
+	for rows.Next() {
+		var issueID string
+		if err = rows.Scan(&issueID); err != nil {
+			return nil, err
+		}
+		ret = append(ret, issueID)
+	}
+	return ret, nil
+}
+
+// Delete, see IssueExpectationsStore interface.
+func (s *SQLIssueExpectationsStore) Delete(issueID string) error {
+	_, err := s.vdb.DB.Exec(`DELETE FROM exp_issue WHERE issue=?`, issueID)
+	return err
+}
+
+// RemoveChange, see IssueExpectationsStore interface.
+func (s *SQLIssueExpectationsStore) RemoveChange(issueID string, changes map[string]types.TestClassification) (retErr error) {
+	const deleteDigest = `DELETE FROM exp_issue WHERE issue=? AND name=? AND digest=? AND label=?`
+
+	tx, err := s.vdb.DB.Begin()
+	if err != nil {
+		return err
+	}
+
+	defer func() { retErr = database.CommitOrRollback(tx, retErr) }()
+
+	for testName, digests := range changes {
+		for d, label := range digests {
+			if _, err := tx.Exec(deleteDigest, issueID, testName, d, label.String()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LastMergedCommit, see IssueExpectationsStore interface.
+func (s *SQLIssueExpectationsStore) LastMergedCommit() (int64, error) {
+	var ts int64
+	err := s.vdb.DB.QueryRow(`SELECT commit_ts FROM exp_issue_merged WHERE id=1`).Scan(&ts)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return ts, err
+}
+
+// SetLastMergedCommit, see IssueExpectationsStore interface.
+func (s *SQLIssueExpectationsStore) SetLastMergedCommit(ts int64) error {
+	const stmt = `INSERT INTO exp_issue_merged (id, commit_ts) VALUES (1, ?)
+	              ON DUPLICATE KEY UPDATE commit_ts=VALUES(commit_ts)`
+	_, err := s.vdb.DB.Exec(stmt, ts)
+	return err
+}
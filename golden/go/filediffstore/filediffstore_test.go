@@ -16,6 +16,7 @@ import (
 	"go.skia.org/infra/go/testutils"
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/imagesource"
 )
 
 const (
@@ -64,7 +65,9 @@ func getTestFileDiffStore(t *testing.T, storageDir, testDir string, cleanTestDir
 	}
 
 	gsBucketName := "chromium-skia-gm"
-	temp, err := NewFileDiffStore(nil, testDir, gsBucketName, storageDir, MemCacheFactory, RECOMMENDED_WORKER_POOL_SIZE)
+	imgSource, err := imagesource.NewGSImageSource(nil, gsBucketName, storageDir)
+	assert.NoError(t, err)
+	temp, err := NewFileDiffStore(imgSource, testDir, MemCacheFactory, RECOMMENDED_WORKER_POOL_SIZE)
 	assert.NoError(t, err)
 	ret := temp.(*FileDiffStore)
 	// Override the counters to avoid collisions between parallel tests.
@@ -80,6 +83,7 @@ func getTestFileDiffStore(t *testing.T, storageDir, testDir string, cleanTestDir
 		PixelDiffFilePath: diffpath1_2,
 		MaxRGBADiffs:      []int{0, 0, 1, 0},
 		DimDiffer:         false,
+		PerceptualDiff:    0.005917143,
 	}
 	relExpectedDiffMetrics1_2 = &diff.DiffMetrics{}
 	*relExpectedDiffMetrics1_2 = *expectedDiffMetrics1_2
@@ -136,13 +140,26 @@ func TestGetDiffMetricFromDir(t *testing.T) {
 	}
 }
 
+func TestGetOneRecalculatesStaleDiffMetrics(t *testing.T) {
+	testDir := makeTestDir(t)
+	fds := getTestFileDiffStore(t, "", testDir, true)
+
+	// DiffMetrics written before PerceptualDiff existed.
+	stale := &diff.DiffMetrics{}
+	*stale = *relExpectedDiffMetrics1_2
+	stale.PerceptualDiff = 0
+	assert.NoError(t, fds.writeDiffMetricsToFileCache(getDiffBasename(TEST_DIGEST1, TEST_DIGEST2), stale))
+
+	assert.Equal(t, expectedDiffMetrics1_2, fds.getOne(TEST_DIGEST1, TEST_DIGEST2))
+}
+
 func TestCacheImageFromGS(t *testing.T) {
 	testDir := makeTestDir(t)
 	fds := getTestFileDiffStore(t, TESTDATA_DIR, testDir, true)
 	imgFilePath := filepath.Join(fds.localImgDir, TEST_DIGEST3[0:2], TEST_DIGEST3[2:4], fmt.Sprintf("%s.%s", TEST_DIGEST3, IMG_EXTENSION))
 	defer testutils.Remove(t, imgFilePath)
 
-	err := fds.cacheImageFromGS(TEST_DIGEST3)
+	err := fds.cacheImageFromSource(TEST_DIGEST3)
 	assert.NoError(t, err)
 
 	if _, err := os.Stat(imgFilePath); err != nil {
@@ -152,7 +169,7 @@ func TestCacheImageFromGS(t *testing.T) {
 
 	// Test error and assert the download failures map.
 	for i := 1; i < 6; i++ {
-		if err := fds.cacheImageFromGS(MISSING_DIGEST); err == nil {
+		if err := fds.cacheImageFromSource(MISSING_DIGEST); err == nil {
 			t.Error("Was expecting 404 error for missing digest")
 		}
 		assert.Equal(t, int64(1), fds.downloadSuccessCount.Get())
@@ -160,6 +177,38 @@ func TestCacheImageFromGS(t *testing.T) {
 	}
 }
 
+func TestCacheImageFromDir(t *testing.T) {
+	testDir := makeTestDir(t)
+	fds := getTestFileDiffStore(t, "", testDir, true)
+	fds.imgSource = imagesource.NewDirImageSource(filepath.Join(TESTDATA_DIR, "images"))
+
+	// Remove the image from the local cache, so it has to be retrieved again.
+	assert.NoError(t, fds.removeImageFromCache(TEST_DIGEST1))
+	found, err := fds.isDigestInCache(TEST_DIGEST1)
+	assert.NoError(t, err)
+	assert.False(t, found)
+
+	assert.NoError(t, fds.cacheImageFromSource(TEST_DIGEST1))
+	found, err = fds.isDigestInCache(TEST_DIGEST1)
+	assert.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, int64(1), fds.downloadSuccessCount.Get())
+
+	assert.Error(t, fds.cacheImageFromSource(MISSING_DIGEST))
+	assert.Equal(t, int64(1), fds.downloadSuccessCount.Get())
+	assert.Equal(t, int64(1), fds.downloadFailureCount.Get())
+
+	// If the image can't be moved into the cache the temp file is removed.
+	assert.NoError(t, fds.removeImageFromCache(TEST_DIGEST1))
+	blocker := filepath.Join(fds.getDigestImagePath(TEST_DIGEST1), "blocker")
+	assert.NoError(t, os.MkdirAll(blocker, 0700))
+	defer testutils.RemoveAll(t, fds.getDigestImagePath(TEST_DIGEST1))
+	assert.Error(t, fds.cacheImageFromSource(TEST_DIGEST1))
+	tempFiles, err := filepath.Glob(filepath.Join(fds.localTempFileDir, "tempfile-*"))
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(tempFiles))
+}
+
 func TestDiff(t *testing.T) {
 	testDir := makeTestDir(t)
 	fds := getTestFileDiffStore(t, "", testDir, true)
@@ -177,6 +226,16 @@ func TestDiff(t *testing.T) {
 	assert.Equal(t, relExpectedDiffMetrics1_2, diffMetrics)
 }
 
+// assertDiffMetrics1_3 asserts that got matches expectedDiffMetrics1_3. Since
+// all the pixels differ only check that PerceptualDiff was calculated.
+func assertDiffMetrics1_3(t *testing.T, got *diff.DiffMetrics) {
+	assert.NotNil(t, got)
+	assert.True(t, got.PerceptualDiff > 0)
+	gotCopy := *got
+	gotCopy.PerceptualDiff = 0
+	assert.Equal(t, expectedDiffMetrics1_3, &gotCopy)
+}
+
 func assertFileExists(filePath string, t *testing.T) {
 	if _, err := os.Stat(filePath); err != nil {
 		_, _, line, _ := runtime.Caller(1)
@@ -331,7 +390,7 @@ func TestGet_e2e(t *testing.T) {
 	assertFileExists(diffFilePath, t)
 	assertFileExists(diffMetricsFilePath, t)
 	assert.Equal(t, 1, len(diffMetricsMap3))
-	assert.Equal(t, expectedDiffMetrics1_3, diffMetricsMap3[TEST_DIGEST3])
+	assertDiffMetrics1_3(t, diffMetricsMap3[TEST_DIGEST3])
 	assert.Equal(t, int64(1), fds3.downloadSuccessCount.Get())
 	assert.Equal(t, int64(1), fds3.downloadFailureCount.Get())
 
@@ -354,7 +413,7 @@ func TestGet_e2e(t *testing.T) {
 	assertFileExists(diffMetricsFilePath, t)
 	assert.Equal(t, 2, len(diffMetricsMap5))
 	assert.Equal(t, expectedDiffMetrics1_2, diffMetricsMap5[TEST_DIGEST2])
-	assert.Equal(t, expectedDiffMetrics1_3, diffMetricsMap5[TEST_DIGEST3])
+	assertDiffMetrics1_3(t, diffMetricsMap5[TEST_DIGEST3])
 	assert.Equal(t, int64(1), fds5.downloadFailureCount.Get())
 
 	// diffFilePath, diffMetricsFilePath, and newImageFilePath will be removed
@@ -0,0 +1,126 @@
+// Simple command line app that checks the output of a local DM run against
+// the baseline served by Gold and reports all untriaged and negative results.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	"go.skia.org/infra/go/common"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/baseline"
+	"go.skia.org/infra/golden/go/goldingestion"
+	"go.skia.org/infra/golden/go/types"
+)
+
+var (
+	goldURL      = flag.String("gold_url", "https://gold.skia.org", "URL of the Gold instance to retrieve the baseline from.")
+	issue        = flag.String("issue", "", "Rietveld issue whose expectations should overlay the master baseline.")
+	baselineFile = flag.String("baseline", "", "Read the baseline from this file instead of retrieving it from Gold.")
+	imgDir       = flag.String("img_dir", "", "Directory with the PNGs written by DM. Defaults to the directory of dm.json.")
+)
+
+func main() {
+	defer common.LogPanic()
+	common.Init()
+	if flag.NArg() != 1 {
+		log.Fatal("Usage: goldcheck [--gold_url url] [--issue id] [--baseline file] [--img_dir dir] path/to/dm.json\n")
+	}
+
+	b, err := loadBaseline()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if b.Version != baseline.VERSION {
+		log.Fatalf("Unsupported baseline version %d. Expected version %d.", b.Version, baseline.VERSION)
+	}
+
+	dmResults, err := loadDMResults(flag.Arg(0))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	dir := *imgDir
+	if dir == "" {
+		dir = filepath.Dir(flag.Arg(0))
+	}
+	results, err := b.Check(dmResults, dir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	failed := 0
+	for _, r := range results {
+		if r.Mismatch() {
+			fmt.Printf("WARNING: %s has digest %s, but DM reported %s for it.\n", r.Path, r.ImageDigest, r.Digest)
+		}
+		if r.Label == types.POSITIVE {
+			continue
+		}
+		failed++
+		path := r.Path
+		if path == "" {
+			path = "(image not found)"
+		}
+		fmt.Printf("%-9s %s %s %v %s\n", r.Label, r.TestName, r.Digest, r.Params, path)
+	}
+	fmt.Printf("%d of %d results are untriaged or negative.\n", failed, len(results))
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
+
+// loadDMResults parses the given dm.json file.
+func loadDMResults(path string) (*goldingestion.DMResults, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer util.Close(f)
+
+	// ParseDMResultsFromReader closes its argument, but f is closed above.
+	return goldingestion.ParseDMResultsFromReader(ioutil.NopCloser(f))
+}
+
+// loadBaseline reads the baseline either from the file given via --baseline
+// or from the Gold instance given via --gold_url.
+func loadBaseline() (*baseline.Baseline, error) {
+	var r io.ReadCloser
+	if *baselineFile != "" {
+		f, err := os.Open(*baselineFile)
+		if err != nil {
+			return nil, err
+		}
+		r = f
+	} else {
+		u := *goldURL + "/json/baseline"
+		if *issue != "" {
+			u += "?issue=" + url.QueryEscape(*issue)
+		}
+		resp, err := httputils.NewTimeoutClient().Get(u)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to retrieve baseline from %s: %s", u, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			util.Close(resp.Body)
+			return nil, fmt.Errorf("Failed to retrieve baseline from %s: %s", u, resp.Status)
+		}
+		r = resp.Body
+	}
+	defer util.Close(r)
+
+	ret := &baseline.Baseline{}
+	if err := json.NewDecoder(r).Decode(ret); err != nil {
+		return nil, fmt.Errorf("Failed to decode baseline: %s", err)
+	}
+	return ret, nil
+}
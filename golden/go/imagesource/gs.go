@@ -0,0 +1,126 @@
+package imagesource
+
+import (
+	"bytes"
+	"crypto/md5"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+
+	"cloud.google.com/go/storage"
+	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/httputils"
+	"go.skia.org/infra/go/util"
+	"golang.org/x/net/context"
+	"google.golang.org/api/option"
+)
+
+const (
+	// DEFAULT_GS_IMG_DIR_NAME is the directory in the GS bucket where DM
+	// uploads its images.
+	DEFAULT_GS_IMG_DIR_NAME = "dm-images-v1"
+
+	// MAX_URI_GET_TRIES is the number of tries we do to download an image.
+	MAX_URI_GET_TRIES = 4
+)
+
+// GSImageSource downloads images from Google Storage. It implements the
+// ImageSource interface.
+type GSImageSource struct {
+	// storageClient is the client to retrieve content from GS.
+	storageClient *storage.Client
+
+	// gsBucketName is the GS bucket where images are stored.
+	gsBucketName string
+
+	// gsImageBaseDir is the GS directory (prefix) where images are stored.
+	gsImageBaseDir string
+}
+
+// NewGSImageSource returns an ImageSource that downloads the images from the
+// given bucket in Google Storage. If client is nil a default client is used.
+// gsImageBaseDir is the directory in the bucket, if empty
+// DEFAULT_GS_IMG_DIR_NAME is used.
+func NewGSImageSource(client *http.Client, gsBucketName, gsImageBaseDir string) (ImageSource, error) {
+	if client == nil {
+		client = httputils.NewTimeoutClient()
+	}
+	if gsImageBaseDir == "" {
+		gsImageBaseDir = DEFAULT_GS_IMG_DIR_NAME
+	}
+
+	storageClient, err := storage.NewClient(context.Background(), option.WithHTTPClient(client))
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create interface to Google Storage: %s", err)
+	}
+
+	return &GSImageSource{
+		storageClient:  storageClient,
+		gsBucketName:   gsBucketName,
+		gsImageBaseDir: gsImageBaseDir,
+	}, nil
+}
+
+// Get, see ImageSource interface. It tries the download up to
+// MAX_URI_GET_TRIES times and verifies the MD5 hash of the downloaded content.
+func (g *GSImageSource) Get(digest string) ([]byte, error) {
+	objLocation := filepath.Join(g.gsImageBaseDir, getDigestImageFileName(digest))
+	ctx := context.Background()
+
+	// Retrieve the attributes.
+	attrs, err := g.storageClient.Bucket(g.gsBucketName).Object(objLocation).Attrs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to retrieve attributes for %s/%s: %s", g.gsBucketName, objLocation, err)
+	}
+
+	var buf *bytes.Buffer
+	for i := 0; i < MAX_URI_GET_TRIES; i++ {
+		if i > 0 {
+			glog.Warningf("%d. retry for digest %s", i, digest)
+		}
+
+		err = func() error {
+			reader, err := g.storageClient.Bucket(g.gsBucketName).Object(objLocation).NewReader(ctx)
+			if err != nil {
+				return fmt.Errorf("New reader failed for %s/%s: %s", g.gsBucketName, objLocation, err)
+			}
+			defer util.Close(reader)
+
+			buf = bytes.NewBuffer(make([]byte, 0, reader.Size()))
+			md5Hash := md5.New()
+			multiOut := io.MultiWriter(md5Hash, buf)
+
+			if _, err = io.Copy(multiOut, reader); err != nil {
+				return err
+			}
+
+			// Check the MD5.
+			if !bytes.Equal(md5Hash.Sum(nil), attrs.MD5) {
+				return fmt.Errorf("MD5 hash for digest %s incorrect.", digest)
+			}
+
+			return nil
+		}()
+
+		if err == nil {
+			break
+		}
+		glog.Errorf("Error fetching file for digest %s: %s", digest, err)
+	}
+
+	if err != nil {
+		glog.Errorf("Failed fetching file after %d attempts", MAX_URI_GET_TRIES)
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Delete, see ImageSource interface.
+func (g *GSImageSource) Delete(digest string) error {
+	objLocation := filepath.Join(g.gsImageBaseDir, getDigestImageFileName(digest))
+	if err := g.storageClient.Bucket(g.gsBucketName).Object(objLocation).Delete(context.Background()); err != nil {
+		return fmt.Errorf("Unable to delete %s/%s:  %s", g.gsBucketName, objLocation, err)
+	}
+	return nil
+}
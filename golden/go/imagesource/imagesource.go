@@ -0,0 +1,70 @@
+// imagesource provides the images of digests to the diff stores. Images can
+// either be downloaded from Google Storage or read from a local directory,
+// e.g. to run Gold on a dev box without network access.
+package imagesource
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"go.skia.org/infra/go/fileutil"
+)
+
+const (
+	// IMG_EXTENSION is the extension of the image files of digests.
+	IMG_EXTENSION = "png"
+)
+
+// ImageSource is the interface the diff stores use to retrieve the image of
+// a digest they don't have in their local cache.
+type ImageSource interface {
+	// Get returns the content of the image file of the given digest.
+	Get(digest string) ([]byte, error)
+
+	// Delete removes the image of the given digest from the source.
+	Delete(digest string) error
+}
+
+// DirImageSource reads images from a local directory, which it never
+// modifies. It implements the ImageSource interface.
+type DirImageSource struct {
+	dir string
+}
+
+// NewDirImageSource returns an ImageSource that reads the image of a digest
+// from <dir>/<digest>.png, which is the layout DM writes with --nameByHash.
+// If that file doesn't exist the two level radix layout of the FileDiffStore
+// image cache, i.e. <dir>/ab/cd/abcd....png, is tried.
+func NewDirImageSource(dir string) ImageSource {
+	return &DirImageSource{dir: dir}
+}
+
+// Get, see ImageSource interface.
+func (d *DirImageSource) Get(digest string) ([]byte, error) {
+	for _, path := range d.paths(digest) {
+		if fileutil.FileExists(path) {
+			return ioutil.ReadFile(path)
+		}
+	}
+	return nil, fmt.Errorf("Unable to find image for digest %s in %s", digest, d.dir)
+}
+
+// Delete, see ImageSource interface. The directory is treated as read-only,
+// since it usually holds the output of a local DM run, so nothing is deleted.
+func (d *DirImageSource) Delete(digest string) error {
+	return nil
+}
+
+// paths returns the candidate locations of the image of the given digest.
+func (d *DirImageSource) paths(digest string) []string {
+	fileName := getDigestImageFileName(digest)
+	return []string{
+		filepath.Join(d.dir, fileName),
+		fileutil.TwoLevelRadixPath(d.dir, fileName),
+	}
+}
+
+func getDigestImageFileName(digest string) string {
+	return fmt.Sprintf("%s.%s", digest, IMG_EXTENSION)
+}
@@ -0,0 +1,50 @@
+package imagesource
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/testutils"
+)
+
+const (
+	FLAT_DIGEST  = "11069776588985027208"
+	RADIX_DIGEST = "5024150605949408692"
+)
+
+func TestDirImageSource(t *testing.T) {
+	dir, err := ioutil.TempDir("", "imagesource_test")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+
+	// One image in the layout of DM and one in the layout of the FileDiffStore.
+	flatPath := filepath.Join(dir, FLAT_DIGEST+".png")
+	assert.NoError(t, ioutil.WriteFile(flatPath, []byte("flat"), 0644))
+	radixPath := filepath.Join(dir, "50", "24", RADIX_DIGEST+".png")
+	assert.NoError(t, os.MkdirAll(filepath.Dir(radixPath), 0755))
+	assert.NoError(t, ioutil.WriteFile(radixPath, []byte("radix"), 0644))
+
+	src := NewDirImageSource(dir)
+	content, err := src.Get(FLAT_DIGEST)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("flat"), content)
+
+	content, err = src.Get(RADIX_DIGEST)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("radix"), content)
+
+	_, err = src.Get("abcdef")
+	assert.Error(t, err)
+
+	// The images are never deleted from the directory.
+	assert.NoError(t, src.Delete(FLAT_DIGEST))
+	assert.NoError(t, src.Delete(RADIX_DIGEST))
+	assert.NoError(t, src.Delete("abcdef"))
+	_, err = src.Get(FLAT_DIGEST)
+	assert.NoError(t, err)
+	_, err = src.Get(RADIX_DIGEST)
+	assert.NoError(t, err)
+}
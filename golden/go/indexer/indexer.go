@@ -11,6 +11,7 @@ import (
 
 	"go.skia.org/infra/go/tiling"
 	"go.skia.org/infra/go/timer"
+	"go.skia.org/infra/golden/go/autotriage"
 	"go.skia.org/infra/golden/go/blame"
 	"go.skia.org/infra/golden/go/expstorage"
 	"go.skia.org/infra/golden/go/paramsets"
@@ -94,6 +95,14 @@ func (idx *SearchIndex) CalcSummaries(testNames []string, query url.Values, incl
 	return idx.summaries.CalcSummaries(idx.tilePair.Tile, testNames, query, head)
 }
 
+// Proxy to summary.CalcIssueSummaries.
+func (idx *SearchIndex) CalcIssueSummaries(testNames []string, query url.Values, includeIgnores, head bool, issueID string) (map[string]*summary.Summary, error) {
+	if includeIgnores {
+		return idx.summaries.CalcIssueSummaries(idx.tilePair.TileWithIgnores, testNames, query, head, issueID)
+	}
+	return idx.summaries.CalcIssueSummaries(idx.tilePair.Tile, testNames, query, head, issueID)
+}
+
 // Proxy to paramsets.Get
 func (idx *SearchIndex) GetParamsetSummary(test, digest string, includeIgnores bool) map[string][]string {
 	return idx.paramsetSummary.Get(test, digest, includeIgnores)
@@ -115,6 +124,9 @@ type Indexer struct {
 	lastIndex  *SearchIndex
 	testNames  []string
 	mutex      sync.RWMutex
+
+	// autoTriageMutex makes sure only one auto-triage pass runs at a time.
+	autoTriageMutex sync.Mutex
 }
 
 // New returns a new Indexer instance. It synchronously indexes the initiallly
@@ -141,6 +153,9 @@ func New(storages *storage.Storage, interval time.Duration) (*Indexer, error) {
 	// The warmer depends on tallies and summaries.
 	pdag.NewNode(runWarmer, summaryNode, tallyNode)
 
+	// Auto-triage depends on the tallies.
+	tallyNode.Child(ret.runAutoTriage)
+
 	// Set the result on the Indexer instance.
 	pdag.NewNode(ret.setIndex, summaryNode)
 
@@ -288,3 +303,29 @@ func runWarmer(state interface{}) error {
 	go idx.warmer.Run(idx.tilePair.TileWithIgnores, idx.summaries, idx.tallies)
 	return nil
 }
+
+// runAutoTriage is the pipeline function to apply the auto-triage rules. Like
+// the warmer it runs asynchronously. Labeled digests change the expectations,
+// which in turn re-index the affected tests.
+func (ixr *Indexer) runAutoTriage(state interface{}) error {
+	if ixr.storages.AutoTriageStore == nil {
+		return nil
+	}
+	idx := state.(*SearchIndex)
+	go func() {
+		ixr.autoTriageMutex.Lock()
+		defer ixr.autoTriageMutex.Unlock()
+		defer timer.New("autotriage").Stop()
+		// Only apply the rules to traces that are not ignored, the same as the
+		// tallies that provide the positive digests.
+		n, err := autotriage.Triage(idx.GetTile(false), idx.tallies.ByTest(), ixr.storages.AutoTriageStore, ixr.storages.ExpectationsStore, ixr.storages.DiffStore)
+		if err != nil {
+			glog.Errorf("Auto-triage failed: %s", err)
+			return
+		}
+		if n > 0 {
+			glog.Infof("Auto-triage labeled %d digests.", n)
+		}
+	}()
+	return nil
+}
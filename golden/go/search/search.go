@@ -100,8 +100,9 @@ type Query struct {
 	Issue          string
 	Patchsets      []string
 	CommitRange    CommitRange
-	Limit          int  // Only return this many items.
-	IncludeMaster  bool // Include digests from master when searching Rietveld issues.
+	Limit          int    // Only return this many items.
+	IncludeMaster  bool   // Include digests from master when searching Rietveld issues.
+	Metric         string // The metric to rank closest digests by, one of digesttools.METRICS.
 }
 
 // SearchResponse is the standard search response. Depending on the query some fields
@@ -170,6 +171,13 @@ func Search(q *Query, storages *storage.Storage, idx *indexer.SearchIndex) (*Sea
 		return nil, fmt.Errorf("Couldn't get expectations: %s", err)
 	}
 
+	// Triage made while viewing an issue overlays the master expectations.
+	if q.Issue != "" {
+		if e, err = storages.IssueExpectations(e, q.Issue); err != nil {
+			return nil, err
+		}
+	}
+
 	var ret []*Digest
 	var issueResponse *IssueResponse = nil
 	var commits []*tiling.Commit = nil
@@ -307,7 +315,7 @@ func searchByIssue(issueID string, q *Query, exp *expstorage.Expectations, parse
 	allDigests := make([]string, len(digestMap))
 	emptyTraces := &Traces{}
 	for _, digestEntry := range digestMap {
-		digestEntry.Diff = buildDiff(digestEntry.Test, digestEntry.Digest, exp, nil, talliesByTest, storages.DiffStore, idx, q.IncludeIgnores)
+		digestEntry.Diff = buildDiff(digestEntry.Test, digestEntry.Digest, exp, nil, talliesByTest, storages.DiffStore, idx, q.IncludeIgnores, q.Metric)
 		digestEntry.Traces = emptyTraces
 		ret = append(ret, digestEntry)
 		allDigests = append(allDigests, digestEntry.Digest)
@@ -372,12 +380,12 @@ func searchTile(q *Query, e *expstorage.Expectations, parsedQuery url.Values, st
 	ret := make([]*Digest, 0, len(inter))
 	for key, i := range inter {
 		parts := strings.Split(key, ":")
-		ret = append(ret, digestFromIntermediate(parts[0], parts[1], i, e, tile, idx, storages.DiffStore, q.IncludeIgnores))
+		ret = append(ret, digestFromIntermediate(parts[0], parts[1], i, e, tile, idx, storages.DiffStore, q.IncludeIgnores, q.Metric))
 	}
 	return ret, tile.Commits, nil
 }
 
-func digestFromIntermediate(test, digest string, inter *intermediate, e *expstorage.Expectations, tile *tiling.Tile, idx *indexer.SearchIndex, diffStore diff.DiffStore, includeIgnores bool) *Digest {
+func digestFromIntermediate(test, digest string, inter *intermediate, e *expstorage.Expectations, tile *tiling.Tile, idx *indexer.SearchIndex, diffStore diff.DiffStore, includeIgnores bool, metric string) *Digest {
 	traceTally := idx.TalliesByTrace()
 	ret := &Digest{
 		Test:     test,
@@ -385,13 +393,14 @@ func digestFromIntermediate(test, digest string, inter *intermediate, e *expstor
 		Status:   e.Classification(test, digest).String(),
 		ParamSet: idx.GetParamsetSummary(test, digest, includeIgnores),
 		Traces:   buildTraces(test, digest, inter.Traces, e, tile, traceTally),
-		Diff:     buildDiff(test, digest, e, tile, idx.TalliesByTest(), diffStore, idx, includeIgnores),
+		Diff:     buildDiff(test, digest, e, tile, idx.TalliesByTest(), diffStore, idx, includeIgnores, metric),
 	}
 	return ret
 }
 
-// buildDiff creates a Diff for the given intermediate.
-func buildDiff(test, digest string, e *expstorage.Expectations, tile *tiling.Tile, testTally map[string]tally.Tally, diffStore diff.DiffStore, idx *indexer.SearchIndex, includeIgnores bool) *Diff {
+// buildDiff creates a Diff for the given intermediate. The closest digests are
+// ranked by 'metric', see digesttools.ClosestDigest.
+func buildDiff(test, digest string, e *expstorage.Expectations, tile *tiling.Tile, testTally map[string]tally.Tally, diffStore diff.DiffStore, idx *indexer.SearchIndex, includeIgnores bool, metric string) *Diff {
 	ret := &Diff{
 		Diff: math.MaxFloat32,
 		Pos:  nil,
@@ -408,7 +417,7 @@ func buildDiff(test, digest string, e *expstorage.Expectations, tile *tiling.Til
 	}
 
 	var diffVal float32 = 0
-	if closest := digesttools.ClosestDigest(test, digest, e, t, diffStore, types.POSITIVE); closest.Digest != "" {
+	if closest := digesttools.ClosestDigest(test, digest, e, t, diffStore, types.POSITIVE, metric); closest.Digest != "" {
 		ret.Pos = &DiffDigest{
 			Closest: closest,
 		}
@@ -416,7 +425,7 @@ func buildDiff(test, digest string, e *expstorage.Expectations, tile *tiling.Til
 		diffVal = closest.Diff
 	}
 
-	if closest := digesttools.ClosestDigest(test, digest, e, t, diffStore, types.NEGATIVE); closest.Digest != "" {
+	if closest := digesttools.ClosestDigest(test, digest, e, t, diffStore, types.NEGATIVE, metric); closest.Digest != "" {
 		ret.Neg = &DiffDigest{
 			Closest: closest,
 		}
@@ -640,7 +649,7 @@ func GetDigestDetails(test, digest string, storages *storage.Storage, idx *index
 			Status:   exp.Classification(test, digest).String(),
 			ParamSet: idx.GetParamsetSummary(test, digest, true),
 			Traces:   buildTraces(test, digest, traces, exp, tile, idx.TalliesByTrace()),
-			Diff:     buildDiff(test, digest, exp, nil, idx.TalliesByTest(), storages.DiffStore, idx, true),
+			Diff:     buildDiff(test, digest, exp, nil, idx.TalliesByTest(), storages.DiffStore, idx, true, digesttools.METRIC_COMBINED),
 		},
 		Commits: tile.Commits,
 	}, nil
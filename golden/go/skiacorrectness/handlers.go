@@ -19,12 +19,16 @@ import (
 	"go.skia.org/infra/go/tiling"
 	"go.skia.org/infra/go/timer"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/autotriage"
+	"go.skia.org/infra/golden/go/baseline"
 	"go.skia.org/infra/golden/go/blame"
 	"go.skia.org/infra/golden/go/diff"
+	"go.skia.org/infra/golden/go/digesttools"
 	"go.skia.org/infra/golden/go/expstorage"
 	"go.skia.org/infra/golden/go/ignore"
 	"go.skia.org/infra/golden/go/indexer"
 	"go.skia.org/infra/golden/go/search"
+	"go.skia.org/infra/golden/go/storage"
 	"go.skia.org/infra/golden/go/summary"
 	"go.skia.org/infra/golden/go/trybot"
 	"go.skia.org/infra/golden/go/types"
@@ -442,6 +446,116 @@ func jsonIgnoresAddHandler(w http.ResponseWriter, r *http.Request) {
 	jsonIgnoresHandler(w, r)
 }
 
+// AutoTriageRequest encapsulates a single auto-triage rule that is submitted
+// for addition or update.
+type AutoTriageRequest struct {
+	Filter            string  `json:"filter"`
+	Note              string  `json:"note"`
+	MaxDiffPixels     int     `json:"maxDiffPixels"`
+	MaxRGBADiff       int     `json:"maxRGBADiff"`
+	MaxPerceptualDiff float32 `json:"maxPerceptualDiff"`
+}
+
+// parseAutoTriageRequest parses the submitted auto-triage rule and returns a
+// validated rule created by 'user'.
+func parseAutoTriageRequest(r *http.Request, user string) (*autotriage.Rule, error) {
+	req := &AutoTriageRequest{}
+	if err := parseJson(r, req); err != nil {
+		return nil, err
+	}
+	rule := autotriage.NewRule(user, req.Filter, req.MaxDiffPixels, req.MaxRGBADiff, req.MaxPerceptualDiff, req.Note)
+	if err := rule.Validate(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// jsonAutoTriageHandler returns the current auto-triage rules in JSON format.
+func jsonAutoTriageHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	rules, err := storages.AutoTriageStore.List()
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to retrieve auto-triage rules.")
+		return
+	}
+
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(rules); err != nil {
+		glog.Errorf("Failed to write or encode result: %s", err)
+	}
+}
+
+// jsonAutoTriageUpdateHandler updates an existing auto-triage rule.
+func jsonAutoTriageUpdateHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to update an auto-triage rule.")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		httputils.ReportError(w, r, err, "ID must be valid integer.")
+		return
+	}
+	rule, err := parseAutoTriageRequest(r, user)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid auto-triage rule.")
+		return
+	}
+	rule.ID = int(id)
+
+	if err := storages.AutoTriageStore.Update(int(id), rule); err != nil {
+		httputils.ReportError(w, r, err, "Unable to update auto-triage rule.")
+		return
+	}
+
+	// If update worked just list the current rules and return them.
+	jsonAutoTriageHandler(w, r)
+}
+
+// jsonAutoTriageDeleteHandler deletes an existing auto-triage rule.
+func jsonAutoTriageDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to delete an auto-triage rule.")
+		return
+	}
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 0)
+	if err != nil {
+		httputils.ReportError(w, r, err, "ID must be valid integer.")
+		return
+	}
+
+	if _, err = storages.AutoTriageStore.Delete(int(id), user); err != nil {
+		httputils.ReportError(w, r, err, "Unable to delete auto-triage rule.")
+	} else {
+		// If delete worked just list the current rules and return them.
+		jsonAutoTriageHandler(w, r)
+	}
+}
+
+// jsonAutoTriageAddHandler is for adding a new auto-triage rule.
+func jsonAutoTriageAddHandler(w http.ResponseWriter, r *http.Request) {
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to add an auto-triage rule.")
+		return
+	}
+	rule, err := parseAutoTriageRequest(r, user)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid auto-triage rule.")
+		return
+	}
+
+	if err := storages.AutoTriageStore.Create(rule); err != nil {
+		httputils.ReportError(w, r, err, "Failed to create auto-triage rule.")
+		return
+	}
+
+	jsonAutoTriageHandler(w, r)
+}
+
 // TriageRequest is the form of the JSON posted to jsonTriageHandler.
 type TriageRequest struct {
 	Test    string   `json:"test"`
@@ -452,6 +566,7 @@ type TriageRequest struct {
 	Filter  string   `json:"filter"`
 	Include bool     `json:"include"` // Include ignored digests.
 	Head    bool     `json:"head"`    // Only include digests at head if true.
+	Issue   string   `json:"issue"`   // If set, triage only for this Rietveld issue.
 }
 
 // jsonTriageHandler handles a request to change the triage status of one or more
@@ -482,6 +597,12 @@ func jsonTriageHandler(w http.ResponseWriter, r *http.Request) {
 			httputils.ReportError(w, r, err, "Failed to load expectations.")
 			return
 		}
+		if req.Issue != "" {
+			if exp, err = storages.IssueExpectations(exp, req.Issue); err != nil {
+				httputils.ReportError(w, r, err, "Failed to load issue expectations.")
+				return
+			}
+		}
 		e := exp.Tests[req.Test]
 		digests, err = filterDigests(req.Filter, req.Query, req.Test, e, req.Include, req.Head)
 		if err != nil {
@@ -500,8 +621,18 @@ func jsonTriageHandler(w http.ResponseWriter, r *http.Request) {
 		req.Test: labelledDigests,
 	}
 
-	// Otherwise update the expectations directly.
-	if err := storages.ExpectationsStore.AddChange(tc, user); err != nil {
+	// Changes made in the context of an issue only apply to that issue until
+	// it lands.
+	if req.Issue != "" {
+		if storages.IssueExpStore == nil {
+			httputils.ReportError(w, r, fmt.Errorf("No issue expectations store."), "Triaging for an issue is not supported.")
+			return
+		}
+		if err := storages.IssueExpStore.AddChange(req.Issue, tc, user); err != nil {
+			httputils.ReportError(w, r, err, "Failed to store the updated issue expectations.")
+			return
+		}
+	} else if err := storages.ExpectationsStore.AddChange(tc, user); err != nil {
 		httputils.ReportError(w, r, err, "Failed to store the updated expectations.")
 		return
 	}
@@ -693,6 +824,7 @@ type ClusterDiffResult struct {
 //  unt     - If true include tests that have untriaged digests. (true, false)
 //  pos     - If true include tests that have positive digests. (true, false)
 //  neg     - If true include tests that have negative digests. (true, false)
+//  issue   - If set, the expectations of this Rietveld issue overlay master.
 //
 // The return format looks like:
 //
@@ -725,7 +857,7 @@ func jsonListTestsHandler(w http.ResponseWriter, r *http.Request) {
 	idx := ixr.GetIndex()
 	corpus, hasSourceType := query.Query[types.CORPUS_FIELD]
 	sumSlice := []*summary.Summary{}
-	if !query.IncludeIgnores && query.Head && len(query.Query) == 1 && hasSourceType {
+	if !query.IncludeIgnores && query.Head && len(query.Query) == 1 && hasSourceType && query.Issue == "" {
 		sumMap := idx.GetSummaries()
 		for _, s := range sumMap {
 			if util.In(s.Corpus, corpus) && includeSummary(s, &query) {
@@ -734,7 +866,7 @@ func jsonListTestsHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	} else {
 		glog.Infof("%q %q %q", r.FormValue("query"), r.FormValue("include"), r.FormValue("head"))
-		sumMap, err := idx.CalcSummaries(nil, query.Query, query.IncludeIgnores, query.Head)
+		sumMap, err := idx.CalcIssueSummaries(nil, query.Query, query.IncludeIgnores, query.Head, query.Issue)
 		if err != nil {
 			httputils.ReportError(w, r, err, "Failed to calculate summaries.")
 			return
@@ -807,6 +939,14 @@ func parseQuery(r *http.Request, query *search.Query) error {
 	query.Issue = r.FormValue("issue")
 	query.IncludeMaster = r.FormValue("master") == "true"
 
+	query.Metric = r.FormValue("metric")
+	if query.Metric == "" {
+		query.Metric = digesttools.METRIC_COMBINED
+	}
+	if !util.In(query.Metric, digesttools.METRICS) {
+		return fmt.Errorf("Unknown metric: %s", query.Metric)
+	}
+
 	return nil
 }
 
@@ -979,6 +1119,46 @@ func jsonParamsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// jsonBaselineHandler returns the baseline.Baseline with the positive and
+// negative digests of every test, so that clients can check their results
+// locally.
+//
+// It takes these parameters:
+//  issue   - If set, the expectations of this Rietveld issue overlay master.
+//  include - If true the paramsets include ignored traces. (true, false)
+func jsonBaselineHandler(w http.ResponseWriter, r *http.Request) {
+	serveBaseline(w, r, storages, ixr.GetIndex().GetParamsetSummary)
+}
+
+// serveBaseline writes the baseline built from the expectations in stor to w.
+// paramsFn returns the paramset of the traces of a test that contain a digest.
+func serveBaseline(w http.ResponseWriter, r *http.Request, stor *storage.Storage, paramsFn func(testName, digest string, includeIgnores bool) map[string][]string) {
+	exp, err := stor.ExpectationsStore.Get()
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to load expectations.")
+		return
+	}
+
+	issueID := r.FormValue("issue")
+	if issueID != "" {
+		if exp, err = stor.IssueExpectations(exp, issueID); err != nil {
+			httputils.ReportError(w, r, err, "Failed to load issue expectations.")
+			return
+		}
+	}
+
+	includeIgnores := r.FormValue("include") == "true"
+	ret := baseline.New(exp, issueID, func(testName, digest string) map[string][]string {
+		return paramsFn(testName, digest, includeIgnores)
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	if err := enc.Encode(ret); err != nil {
+		glog.Errorf("Failed to write or encode result: %s", err)
+	}
+}
+
 // textAllHashesHandler returns the list of all hashes we currently know about
 // regardless of triage status.
 // Endpoint used by the buildbots to avoid transferring already known images.
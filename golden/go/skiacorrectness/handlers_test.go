@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/golden/go/baseline"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/storage"
+	"go.skia.org/infra/golden/go/types"
+)
+
+func TestServeBaseline(t *testing.T) {
+	stor := &storage.Storage{
+		ExpectationsStore: expstorage.NewMemExpectationsStore(nil),
+		IssueExpStore:     expstorage.NewMemIssueExpectationsStore(),
+	}
+	assert.NoError(t, stor.ExpectationsStore.AddChange(map[string]types.TestClassification{
+		"foo": {"aaa": types.POSITIVE, "bbb": types.NEGATIVE},
+		"bar": {"ccc": types.POSITIVE},
+	}, "user@example.com"))
+	// The issue flips bbb to positive, untriages ccc and adds ddd.
+	assert.NoError(t, stor.IssueExpStore.AddChange("1234", map[string]types.TestClassification{
+		"foo": {"bbb": types.POSITIVE},
+		"bar": {"ccc": types.UNTRIAGED, "ddd": types.NEGATIVE},
+	}, "user@example.com"))
+
+	paramsFn := func(testName, digest string, includeIgnores bool) map[string][]string {
+		if includeIgnores {
+			return map[string][]string{"config": []string{"8888", "565"}}
+		}
+		return map[string][]string{"config": []string{"8888"}}
+	}
+
+	get := func(query string) *baseline.Baseline {
+		r, err := http.NewRequest("GET", "/json/baseline"+query, nil)
+		assert.NoError(t, err)
+		w := httptest.NewRecorder()
+		serveBaseline(w, r, stor, paramsFn)
+		assert.Equal(t, http.StatusOK, w.Code)
+		ret := &baseline.Baseline{}
+		assert.NoError(t, json.NewDecoder(w.Body).Decode(ret))
+		assert.Equal(t, baseline.VERSION, ret.Version)
+		return ret
+	}
+
+	// Master only.
+	b := get("")
+	assert.Equal(t, "", b.Issue)
+	assert.Equal(t, types.POSITIVE, b.Classification("foo", "aaa"))
+	assert.Equal(t, types.NEGATIVE, b.Classification("foo", "bbb"))
+	assert.Equal(t, types.POSITIVE, b.Classification("bar", "ccc"))
+	assert.Equal(t, types.UNTRIAGED, b.Classification("bar", "ddd"))
+	assert.Equal(t, []string{"8888"}, b.Tests["foo"]["aaa"].Params["config"])
+
+	// The issue's expectations overlay master.
+	b = get("?issue=1234&include=true")
+	assert.Equal(t, "1234", b.Issue)
+	assert.Equal(t, types.POSITIVE, b.Classification("foo", "aaa"))
+	assert.Equal(t, types.POSITIVE, b.Classification("foo", "bbb"))
+	assert.Equal(t, types.NEGATIVE, b.Classification("bar", "ddd"))
+	_, ok := b.Tests["bar"]["ccc"]
+	assert.False(t, ok)
+	assert.Equal(t, []string{"8888", "565"}, b.Tests["foo"]["aaa"].Params["config"])
+
+	// Issues without expectations get the master baseline.
+	b = get("?issue=5678")
+	assert.Equal(t, "5678", b.Issue)
+	assert.Equal(t, types.NEGATIVE, b.Classification("foo", "bbb"))
+}
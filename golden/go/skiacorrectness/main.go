@@ -31,6 +31,7 @@ import (
 	"go.skia.org/infra/go/timer"
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/golden/go/autotriage"
 	"go.skia.org/infra/golden/go/db"
 	"go.skia.org/infra/golden/go/digeststore"
 	"go.skia.org/infra/golden/go/expstorage"
@@ -38,6 +39,7 @@ import (
 	"go.skia.org/infra/golden/go/goldingestion"
 	"go.skia.org/infra/golden/go/history"
 	"go.skia.org/infra/golden/go/ignore"
+	"go.skia.org/infra/golden/go/imagesource"
 	"go.skia.org/infra/golden/go/indexer"
 	"go.skia.org/infra/golden/go/status"
 	"go.skia.org/infra/golden/go/storage"
@@ -54,6 +56,7 @@ var (
 	forceLogin         = flag.Bool("force_login", false, "Force the user to be authenticated for all requests.")
 	gsBucketName       = flag.String("gs_bucket", "chromium-skia-gm", "Name of the google storage bucket that holds uploaded images.")
 	imageDir           = flag.String("image_dir", "/tmp/imagedir", "What directory to store test and diff images in.")
+	imageSourceDir     = flag.String("image_source_dir", "", "If set, images are read from this directory instead of the gs_bucket. It must contain <digest>.png files, e.g. written by DM with --nameByHash.")
 	issueTrackerKey    = flag.String("issue_tracker_key", "", "API Key for accessing the project hosting API.")
 	local              = flag.Bool("local", false, "Running locally if true. As opposed to in production.")
 	memProfile         = flag.Duration("memprofile", 0, "Duration for which to profile memory. After this duration the program writes the memory profile and exits.")
@@ -286,8 +289,16 @@ func main() {
 		}
 	}
 
+	// Set up where the images are retrieved from.
+	var imgSource imagesource.ImageSource
+	if *imageSourceDir != "" {
+		imgSource = imagesource.NewDirImageSource(*imageSourceDir)
+	} else if imgSource, err = imagesource.NewGSImageSource(client, *gsBucketName, imagesource.DEFAULT_GS_IMG_DIR_NAME); err != nil {
+		glog.Fatalf("Failed to create GS image source: %s", err)
+	}
+
 	// Get the expecations storage, the filediff storage and the tilestore.
-	diffStore, err := filediffstore.NewFileDiffStore(client, *imageDir, *gsBucketName, filediffstore.DEFAULT_GS_IMG_DIR_NAME, cacheFactory, filediffstore.RECOMMENDED_WORKER_POOL_SIZE)
+	diffStore, err := filediffstore.NewFileDiffStore(imgSource, *imageDir, cacheFactory, filediffstore.RECOMMENDED_WORKER_POOL_SIZE)
 	if err != nil {
 		glog.Fatalf("Allocating DiffStore failed: %s", err)
 	}
@@ -336,9 +347,13 @@ func main() {
 	if err != nil {
 		glog.Fatalf("Unable to open ingestion store: %s", err)
 	}
+	// Human triages of auto-triaged digests are tracked by the auto-triage
+	// store, so that they are used as positives again.
+	autoTriageStore := autotriage.NewSQLRuleStore(vdb)
 	storages = &storage.Storage{
 		DiffStore:         diffStore,
-		ExpectationsStore: expstorage.NewCachingExpectationStore(expstorage.NewSQLExpectationStore(vdb), evt),
+		ExpectationsStore: autotriage.NewHumanTriageStore(expstorage.NewCachingExpectationStore(expstorage.NewSQLExpectationStore(vdb), evt), autoTriageStore),
+		AutoTriageStore:   autoTriageStore,
 		MasterTileBuilder: masterTileBuilder,
 		BranchTileBuilder: branchTileBuilder,
 		DigestStore:       digestStore,
@@ -350,6 +365,7 @@ func main() {
 
 	// TODO(stephana): Remove this workaround to avoid circular dependencies once the 'storage' module is cleaned up.
 	storages.IgnoreStore = ignore.NewSQLIgnoreStore(vdb, storages.ExpectationsStore, storages.GetTileStreamNow(time.Minute))
+	storages.IssueExpStore = expstorage.NewSQLIssueExpectationsStore(vdb)
 
 	if err := history.Init(storages, *nTilesToBackfill); err != nil {
 		glog.Fatalf("Unable to initialize history package: %s", err)
@@ -359,6 +375,15 @@ func main() {
 		glog.Fatalf("Failed to start monitoring for expired ignore rules: %s", err)
 	}
 
+	// Merge the expectations of issues that have landed into master every five minutes.
+	go func() {
+		for _ = range time.Tick(5 * time.Minute) {
+			if _, err := storages.TrybotResults.MergeLandedIssues(storages.IssueExpStore, storages.ExpectationsStore); err != nil {
+				glog.Errorf("Failed to merge the expectations of landed issues: %s", err)
+			}
+		}
+	}()
+
 	// Rebuild the index every two minutes.
 	ixr, err = indexer.New(storages, 2*time.Minute)
 	if err != nil {
@@ -407,6 +432,7 @@ func main() {
 	router.HandleFunc("/json/byblame", jsonByBlameHandler).Methods("GET")
 	router.HandleFunc("/json/list", jsonListTestsHandler).Methods("GET")
 	router.HandleFunc("/json/paramset", jsonParamsHandler).Methods("GET")
+	router.HandleFunc("/json/baseline", jsonBaselineHandler).Methods("GET")
 	router.HandleFunc("/json/search", jsonSearchHandler).Methods("GET")
 	router.HandleFunc("/json/diff", jsonDiffHandler).Methods("GET")
 	router.HandleFunc("/json/details", jsonDetailsHandler).Methods("GET")
@@ -414,6 +440,10 @@ func main() {
 	router.HandleFunc("/json/ignores/add/", jsonIgnoresAddHandler).Methods("POST")
 	router.HandleFunc("/json/ignores/del/{id}", jsonIgnoresDeleteHandler).Methods("POST")
 	router.HandleFunc("/json/ignores/save/{id}", jsonIgnoresUpdateHandler).Methods("POST")
+	router.HandleFunc("/json/autotriage", jsonAutoTriageHandler).Methods("GET")
+	router.HandleFunc("/json/autotriage/add/", jsonAutoTriageAddHandler).Methods("POST")
+	router.HandleFunc("/json/autotriage/del/{id}", jsonAutoTriageDeleteHandler).Methods("POST")
+	router.HandleFunc("/json/autotriage/save/{id}", jsonAutoTriageUpdateHandler).Methods("POST")
 	router.HandleFunc("/json/triage", jsonTriageHandler).Methods("POST")
 	router.HandleFunc("/json/clusterdiff", jsonClusterDiffHandler).Methods("GET")
 	router.HandleFunc("/json/triagelog", jsonTriageLogHandler).Methods("GET")
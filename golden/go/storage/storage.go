@@ -10,6 +10,7 @@ import (
 	"go.skia.org/infra/go/rietveld"
 	"go.skia.org/infra/go/tiling"
 	tracedb "go.skia.org/infra/go/trace/db"
+	"go.skia.org/infra/golden/go/autotriage"
 	"go.skia.org/infra/golden/go/diff"
 	"go.skia.org/infra/golden/go/digeststore"
 	"go.skia.org/infra/golden/go/expstorage"
@@ -23,7 +24,9 @@ import (
 type Storage struct {
 	DiffStore         diff.DiffStore
 	ExpectationsStore expstorage.ExpectationsStore
+	IssueExpStore     expstorage.IssueExpectationsStore
 	IgnoreStore       ignore.IgnoreStore
+	AutoTriageStore   autotriage.RuleStore
 	MasterTileBuilder tracedb.MasterTileBuilder
 	BranchTileBuilder tracedb.BranchTileBuilder
 	DigestStore       digeststore.DigestStore
@@ -186,6 +189,20 @@ func (s *Storage) GetOrUpdateDigestInfo(testName, digest string, commit *tiling.
 	return digestInfo, nil
 }
 
+// IssueExpectations returns the given master expectations overlaid with the
+// expectations of the given Rietveld issue. If there is no IssueExpStore the
+// master expectations are returned unchanged.
+func (s *Storage) IssueExpectations(master *expstorage.Expectations, issueID string) (*expstorage.Expectations, error) {
+	if s.IssueExpStore == nil {
+		return master, nil
+	}
+	issueExp, err := s.IssueExpStore.Get(issueID)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't get expectations of issue %s: %s", issueID, err)
+	}
+	return master.Overlay(issueExp), nil
+}
+
 // GetTileFromTimeRange returns a tile that contains the commits in the given time range.
 func (s *Storage) GetTileFromTimeRange(begin, end time.Time) (*tiling.Tile, error) {
 	commitIDs, err := s.BranchTileBuilder.ListLong(begin, end, "master")
@@ -124,6 +124,13 @@ type TraceID struct {
 //   Only consider digests at head if true.
 //
 func (s *Summaries) CalcSummaries(tile *tiling.Tile, testNames []string, query url.Values, head bool) (map[string]*Summary, error) {
+	return s.CalcIssueSummaries(tile, testNames, query, head, "")
+}
+
+// CalcIssueSummaries works like CalcSummaries, but if issueID is not empty
+// the expectations of that Rietveld issue are overlaid on the master
+// expectations.
+func (s *Summaries) CalcIssueSummaries(tile *tiling.Tile, testNames []string, query url.Values, head bool, issueID string) (map[string]*Summary, error) {
 	defer timer.New("CalcSummaries").Stop()
 	glog.Infof("CalcSummaries: head %v", head)
 
@@ -135,6 +142,11 @@ func (s *Summaries) CalcSummaries(tile *tiling.Tile, testNames []string, query u
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't get expectations: %s", err)
 	}
+	if issueID != "" {
+		if e, err = s.storages.IssueExpectations(e, issueID); err != nil {
+			return nil, err
+		}
+	}
 
 	// Filter down to just the traces we are interested in, based on query.
 	filtered := map[string][]*TraceID{}
@@ -0,0 +1,114 @@
+package trybot
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/skia-dev/glog"
+	tracedb "go.skia.org/infra/go/trace/db"
+	"go.skia.org/infra/go/vcsinfo"
+	"go.skia.org/infra/golden/go/expstorage"
+)
+
+const (
+	// LANDED_USER_PREFIX is the prefix of the user that merged issue
+	// expectations are attributed to in the triage log. It's followed by the
+	// issue id.
+	LANDED_USER_PREFIX = "landed:"
+)
+
+// reviewURLRegexp matches the line that the commit queue adds to the
+// description of a commit that landed through Rietveld, e.g.
+// "Review-Url: https://codereview.chromium.org/1234". Older commits end the
+// line with " .".
+var reviewURLRegexp = regexp.MustCompile(`(?m)^Review[- ]U(?:RL|rl):\s*(\S+)/(\d+)\s*\.?\s*$`)
+
+// landedIssues returns the ids of the Rietveld issues on 'reviewURL' that
+// landed with the given master commits.
+func landedIssues(commits []*tracedb.CommitIDLong, reviewURL string) map[string]bool {
+	reviewURL = strings.TrimSuffix(reviewURL, "/")
+	ret := map[string]bool{}
+	for _, cid := range commits {
+		details, ok := cid.Details.(*vcsinfo.LongCommit)
+		if !ok || details == nil {
+			continue
+		}
+		for _, match := range reviewURLRegexp.FindAllStringSubmatch(details.Body, -1) {
+			if match[1] == reviewURL {
+				ret[match[2]] = true
+			}
+		}
+	}
+	return ret
+}
+
+// MergeLandedIssues merges the expectations of every issue in issueStore that
+// has landed in master into the master expectations and then removes them
+// from issueStore. Landed issues are found via the descriptions of the master
+// commits in the trace db within the time frame. The whole time frame is
+// searched on every call, so expectations that are added to an issue after
+// its commit was first seen are still merged. If the last commit searched by
+// the previous call is older than the time frame, e.g. after an outage, the
+// search starts there instead. It returns the ids of the merged issues.
+func (t *TrybotResults) MergeLandedIssues(issueStore expstorage.IssueExpectationsStore, expStore expstorage.ExpectationsStore) ([]string, error) {
+	lastMerged, err := issueStore.LastMergedCommit()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve the last merged commit: %s", err)
+	}
+	end := time.Now()
+	begin := end.Add(-t.timeFrame)
+	if lastMerged > 0 && lastMerged < begin.Unix() {
+		// The last commit is included, so commits with the same timestamp
+		// are not missed. Merging an issue again is harmless.
+		begin = time.Unix(lastMerged, 0)
+	}
+	commits, err := t.tileBuilder.ListLong(begin, end, "master")
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve master commits: %s", err)
+	}
+	if len(commits) == 0 {
+		return []string{}, nil
+	}
+	landed := landedIssues(commits, t.reviewURL)
+
+	issueIDs, err := issueStore.Issues()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to list issues with expectations: %s", err)
+	}
+
+	ret := []string{}
+	for _, issueID := range issueIDs {
+		if !landed[issueID] {
+			continue
+		}
+		exp, err := issueStore.Get(issueID)
+		if err != nil {
+			return ret, fmt.Errorf("Failed to get expectations of issue %s: %s", issueID, err)
+		}
+		if len(exp.Tests) > 0 {
+			if err := expStore.AddChange(exp.Tests, LANDED_USER_PREFIX+issueID); err != nil {
+				return ret, fmt.Errorf("Failed to merge expectations of issue %s: %s", issueID, err)
+			}
+		}
+		// Only remove what was merged, anything triaged in the meantime stays
+		// with the issue.
+		if err := issueStore.RemoveChange(issueID, exp.Tests); err != nil {
+			return ret, fmt.Errorf("Failed to remove expectations of issue %s: %s", issueID, err)
+		}
+		glog.Infof("Merged the expectations of landed issue %s into master.", issueID)
+		ret = append(ret, issueID)
+	}
+
+	newest := lastMerged
+	for _, cid := range commits {
+		if cid.Timestamp > newest {
+			newest = cid.Timestamp
+		}
+	}
+	if err := issueStore.SetLastMergedCommit(newest); err != nil {
+		return ret, fmt.Errorf("Failed to store the last merged commit: %s", err)
+	}
+	return ret, nil
+}
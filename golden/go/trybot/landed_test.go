@@ -0,0 +1,150 @@
+package trybot
+
+import (
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/tiling"
+	tracedb "go.skia.org/infra/go/trace/db"
+	"go.skia.org/infra/go/vcsinfo"
+	"go.skia.org/infra/golden/go/expstorage"
+	"go.skia.org/infra/golden/go/types"
+)
+
+// mockTileBuilder returns the commits within the requested time range for
+// every call to ListLong.
+type mockTileBuilder struct {
+	commits []*tracedb.CommitIDLong
+}
+
+func (m *mockTileBuilder) ListLong(begin, end time.Time, source string) ([]*tracedb.CommitIDLong, error) {
+	ret := []*tracedb.CommitIDLong{}
+	for _, cid := range m.commits {
+		if cid.Timestamp >= begin.Unix() && cid.Timestamp < end.Unix() {
+			ret = append(ret, cid)
+		}
+	}
+	return ret, nil
+}
+
+func (m *mockTileBuilder) CachedTileFromCommits(commits []*tracedb.CommitID) (*tiling.Tile, error) {
+	return nil, nil
+}
+
+// racingIssueStore adds a change to an issue right after its expectations
+// have been retrieved, like a user triaging while the issue is merged.
+type racingIssueStore struct {
+	expstorage.IssueExpectationsStore
+	issueID string
+	change  map[string]types.TestClassification
+}
+
+func (r *racingIssueStore) Get(issueID string) (*expstorage.Expectations, error) {
+	exp, err := r.IssueExpectationsStore.Get(issueID)
+	if err == nil && issueID == r.issueID {
+		err = r.IssueExpectationsStore.AddChange(issueID, r.change, "jim@example.com")
+	}
+	return exp, err
+}
+
+func masterCommit(body string) *tracedb.CommitIDLong {
+	return &tracedb.CommitIDLong{
+		CommitID: &tracedb.CommitID{Source: "master"},
+		Details:  &vcsinfo.LongCommit{Body: body},
+	}
+}
+
+func TestLandedIssues(t *testing.T) {
+	commits := []*tracedb.CommitIDLong{
+		masterCommit("Fix a bug.\n\nBUG=skia:123\n\nReview-Url: https://codereview.chromium.org/1111\n"),
+		masterCommit("Old style.\n\nReview URL: https://codereview.chromium.org/2222 .\n"),
+		masterCommit("Old style.\n\nReview URL: https://codereview.chromium.org/3333\n"),
+		masterCommit("Other review site.\n\nReview-Url: https://example.com/4444\n"),
+		masterCommit("Mentions https://codereview.chromium.org/5555 in passing."),
+		{CommitID: &tracedb.CommitID{Source: "master"}},
+	}
+	assert.Equal(t, map[string]bool{"1111": true, "2222": true, "3333": true}, landedIssues(commits, TEST_CODE_REVIEW_URL+"/"))
+}
+
+func TestMergeLandedIssues(t *testing.T) {
+	now := time.Now().Unix()
+	old := masterCommit("Landed.\n\nReview-Url: https://codereview.chromium.org/2222\n")
+	old.Timestamp = now - 200
+	landed := masterCommit("Landed.\n\nReview-Url: https://codereview.chromium.org/1111\n")
+	landed.Timestamp = now - 100
+	tr := &TrybotResults{
+		tileBuilder: &mockTileBuilder{
+			commits: []*tracedb.CommitIDLong{old, landed},
+		},
+		reviewURL: TEST_CODE_REVIEW_URL,
+		timeFrame: TIME_FRAME,
+	}
+	issueStore := &racingIssueStore{
+		IssueExpectationsStore: expstorage.NewMemIssueExpectationsStore(),
+		issueID:                "1111",
+		change:                 map[string]types.TestClassification{"foo": {"c": types.POSITIVE}},
+	}
+	expStore := expstorage.NewMemExpectationsStore(nil)
+
+	// Nothing to merge, but the commits have been searched.
+	merged, err := tr.MergeLandedIssues(issueStore, expStore)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, merged)
+	lastMerged, err := issueStore.LastMergedCommit()
+	assert.NoError(t, err)
+	assert.Equal(t, landed.Timestamp, lastMerged)
+
+	assert.NoError(t, issueStore.AddChange("1111", map[string]types.TestClassification{
+		"foo": {"a": types.POSITIVE},
+	}, "jon@example.com"))
+	assert.NoError(t, issueStore.AddChange("2222", map[string]types.TestClassification{
+		"foo": {"b": types.POSITIVE},
+	}, "jon@example.com"))
+
+	// 2222 landed before the last merged commit, but it is still within the
+	// time frame, so its expectations are merged even though they were added
+	// after its commit was searched.
+	merged, err = tr.MergeLandedIssues(issueStore, expStore)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1111", "2222"}, merged)
+
+	exp, err := expStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.POSITIVE, exp.Classification("foo", "a"))
+	assert.Equal(t, types.POSITIVE, exp.Classification("foo", "b"))
+	assert.Equal(t, types.UNTRIAGED, exp.Classification("foo", "c"))
+
+	// The change made while merging is kept.
+	issueExp, err := issueStore.Get("1111")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]types.TestClassification{"foo": {"c": types.POSITIVE}}, issueExp.Tests)
+	issues, err := issueStore.Issues()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1111"}, issues)
+
+	// It is merged by the next call, after the landing commit was searched.
+	issueStore.issueID = ""
+	merged, err = tr.MergeLandedIssues(issueStore, expStore)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1111"}, merged)
+	exp, err = expStore.Get()
+	assert.NoError(t, err)
+	assert.Equal(t, types.POSITIVE, exp.Classification("foo", "c"))
+	issues, err = issueStore.Issues()
+	assert.NoError(t, err)
+	assert.Equal(t, []string{}, issues)
+
+	// Commits older than the time frame are searched if the last merged
+	// commit is older, e.g. after an outage.
+	ancient := masterCommit("Landed.\n\nReview-Url: https://codereview.chromium.org/3333\n")
+	ancient.Timestamp = now - int64(2*TIME_FRAME/time.Second)
+	tr.tileBuilder.(*mockTileBuilder).commits = []*tracedb.CommitIDLong{ancient}
+	assert.NoError(t, issueStore.SetLastMergedCommit(ancient.Timestamp))
+	assert.NoError(t, issueStore.AddChange("3333", map[string]types.TestClassification{
+		"foo": {"d": types.POSITIVE},
+	}, "jon@example.com"))
+	merged, err = tr.MergeLandedIssues(issueStore, expStore)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"3333"}, merged)
+}
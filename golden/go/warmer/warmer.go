@@ -46,8 +46,8 @@ func (w *Warmer) Run(tile *tiling.Tile, summaries *summary.Summaries, tallies *t
 			t := tallies.ByTest()[test]
 			if t != nil {
 				// Calculate the closest digest for the side effect of filling in the filediffstore cache.
-				digesttools.ClosestDigest(test, digest, exp, t, w.storages.DiffStore, types.POSITIVE)
-				digesttools.ClosestDigest(test, digest, exp, t, w.storages.DiffStore, types.NEGATIVE)
+				digesttools.ClosestDigest(test, digest, exp, t, w.storages.DiffStore, types.POSITIVE, digesttools.METRIC_COMBINED)
+				digesttools.ClosestDigest(test, digest, exp, t, w.storages.DiffStore, types.NEGATIVE, digesttools.METRIC_COMBINED)
 			}
 		}
 	}
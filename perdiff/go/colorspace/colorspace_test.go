@@ -0,0 +1,33 @@
+package colorspace
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+)
+
+func TestConvertXYZToLAB(t *testing.T) {
+	// White.
+	L, A, B := ConvertXYZToLAB(ConvertAdobeRGBToXYZ(1, 1, 1))
+	assert.InDelta(t, 100.0, L, 0.0001)
+	assert.InDelta(t, 0.0, A, 0.0001)
+	assert.InDelta(t, 0.0, B, 0.0001)
+
+	// Black.
+	L, A, B = ConvertXYZToLAB(ConvertAdobeRGBToXYZ(0, 0, 0))
+	assert.InDelta(t, 0.0, L, 0.0001)
+	assert.InDelta(t, 0.0, A, 0.0001)
+	assert.InDelta(t, 0.0, B, 0.0001)
+
+	// Red has a positive a*, blue has a negative b*.
+	_, A, _ = ConvertXYZToLAB(ConvertAdobeRGBToXYZ(1, 0, 0))
+	assert.True(t, A > 0)
+	_, _, B = ConvertXYZToLAB(ConvertAdobeRGBToXYZ(0, 0, 1))
+	assert.True(t, B < 0)
+}
+
+func TestDeltaE(t *testing.T) {
+	assert.Equal(t, 0.0, DeltaE(50, 10, -10, 50, 10, -10))
+	assert.Equal(t, 100.0, DeltaE(100, 0, 0, 0, 0, 0))
+	assert.Equal(t, 5.0, DeltaE(50, 3, 0, 50, 0, 4))
+}
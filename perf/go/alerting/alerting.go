@@ -8,28 +8,40 @@ import (
 	"time"
 
 	"github.com/skia-dev/glog"
-	"go.skia.org/infra/go/auth"
 	"go.skia.org/infra/go/issues"
 	"go.skia.org/infra/go/metrics2"
+	"go.skia.org/infra/go/tiling"
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/perf/go/alerts"
 	"go.skia.org/infra/perf/go/clustering"
 	"go.skia.org/infra/perf/go/config"
 	"go.skia.org/infra/perf/go/db"
+	"go.skia.org/infra/perf/go/regression"
 	"go.skia.org/infra/perf/go/types"
 )
 
 const (
-	CLUSTER_SIZE   = 50
 	CLUSTER_STDDEV = 0.001
 
+	// SCHEDULE_PERIOD is how often we check for alerts.Configs which are due
+	// to run.
+	SCHEDULE_PERIOD = time.Minute
+
 	// TRACKED_ITEM_URL_TEMPLATE is used to generate the URL that is
 	// embedded in an issue. It is also used to search for issues linked to a
 	// specific item (cluster). The format verb is to be replaced with the ID
 	// of the tracked item.
 	TRACKED_ITEM_URL_TEMPLATE = "https://perf.skia.org/cl/%d"
+
+	// COMMIT_URL_TEMPLATE is used to link to a commit from a bug. The format
+	// verb is to be replaced with the git hash.
+	COMMIT_URL_TEMPLATE = "https://skia.googlesource.com/skia/+/%s"
 )
 
+// BUG_LABELS are the labels added to every bug filed for a regression.
+var BUG_LABELS = []string{"FromSkiaPerf", "Type-Defect", "Priority-Medium"}
+
 var (
 	// The number of clusters with a status of "New".
 	newClustersGauge *metrics2.Int64Metric
@@ -225,8 +237,99 @@ func Reset() error {
 	return nil
 }
 
-func skpOnly(_ string, tr *types.PerfTrace) bool {
-	return tr.Params()["source_type"] == "skp" && tr.Params()["sub_result"] == "min_ms"
+// findClusters runs the algorithm of the given alerts.Config over the tile
+// and returns the interesting clusters, tagged with the Config's ID.
+func findClusters(cfg *alerts.Config, tile *tiling.Tile) ([]*types.ClusterSummary, error) {
+	q, err := cfg.ParsedQuery()
+	if err != nil {
+		return nil, err
+	}
+	filter := func(_ string, tr *types.PerfTrace) bool {
+		return tiling.Matches(tr, q)
+	}
+	var found []*types.ClusterSummary
+	switch cfg.Algo {
+	case alerts.ALGO_KMEANS:
+		summary, err := clustering.CalculateClusterSummaries(tile, cfg.K, CLUSTER_STDDEV, cfg.Radius, filter)
+		if err != nil {
+			return nil, err
+		}
+		found = summary.Clusters
+	case alerts.ALGO_STEPFIT:
+		found, err = clustering.CalculateStepSummaries(tile, CLUSTER_STDDEV, cfg.Radius, cfg.Interesting, filter)
+		if err != nil {
+			return nil, err
+		}
+	case alerts.ALGO_MAD:
+		found, err = clustering.CalculateMADSummaries(tile, CLUSTER_STDDEV, cfg.Window, cfg.Sensitivity, filter)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unknown algorithm %q", cfg.Algo)
+	}
+	ret := []*types.ClusterSummary{}
+	for _, c := range found {
+		if cfg.IsInteresting(c.StepFit.Regression) && (cfg.NoiseSigma == 0 || aboveNoise(tile, c, cfg.NoiseSigma)) {
+			c.AlertID = cfg.ID
+			ret = append(ret, c)
+		}
+	}
+	return ret, nil
+}
+
+// aboveNoise returns true if the step in at least half of the traces in the
+// cluster is larger than sigma times the noise of the trace, where the noise
+// is the mean of the trace's stat=stddev sibling. Traces without a stddev
+// sibling are always counted as above the noise.
+func aboveNoise(tile *tiling.Tile, c *types.ClusterSummary, sigma float64) bool {
+	if len(c.Keys) == 0 {
+		return true
+	}
+	above := 0
+	for _, key := range c.Keys {
+		tr, ok := tile.Traces[key]
+		if !ok {
+			above += 1
+			continue
+		}
+		siblingKey := types.StatSiblingKey(key, tr.Params(), types.STAT_STDDEV)
+		sibling, ok := tile.Traces[siblingKey]
+		if siblingKey == "" || !ok {
+			above += 1
+			continue
+		}
+		values := tr.(*types.PerfTrace).Values
+		turn := c.StepFit.TurningPoint
+		if turn <= 0 || turn >= len(values) {
+			above += 1
+			continue
+		}
+		noise, ok := meanOf(sibling.(*types.PerfTrace).Values)
+		before, okBefore := meanOf(values[:turn])
+		after, okAfter := meanOf(values[turn:])
+		if !ok || !okBefore || !okAfter || math.Abs(after-before) > sigma*noise {
+			above += 1
+		}
+	}
+	return 2*above >= len(c.Keys)
+}
+
+// meanOf returns the mean of the non-missing values, and false if there are no
+// non-missing values.
+func meanOf(values []float64) (float64, bool) {
+	sum := 0.0
+	n := 0
+	for _, v := range values {
+		if v != config.MISSING_DATA_SENTINEL {
+			sum += v
+			n += 1
+		}
+	}
+	if n == 0 {
+		return 0, false
+	}
+	return sum / float64(n), true
 }
 
 // updateBugs will find all the bugs the reference the alerting cluster will
@@ -251,26 +354,96 @@ func updateBugs(c *types.ClusterSummary, issueTracker issues.IssueTracker) error
 	return nil
 }
 
-// singleStep does a single round of alerting.
-func singleStep(issueTracker issues.IssueTracker) {
+// FileBug files a bug in the issue tracker for the given direction,
+// regression.UP or regression.DOWN, of the Regression found by the alert at
+// the given commit, and records the id of the new bug in the Regression. If a
+// bug has already been filed, or is being filed, for that direction then
+// nothing is done.
+func FileBug(issueTracker issues.IssueTracker, hash string, alertID int64, direction string) (err error) {
+	reg, claimed, err := regression.ClaimBug(hash, alertID, direction)
+	if err != nil || !claimed {
+		return err
+	}
+	// Release the claim if the bug wasn't filed, so that it can be retried. Once
+	// it's filed the claim is kept even if recording the bug fails, to avoid
+	// filing a duplicate.
+	filed := false
+	defer func() {
+		if err != nil && !filed {
+			if relErr := regression.ReleaseBug(hash, alertID, direction); relErr != nil {
+				err = fmt.Errorf("%s; and failed to release the claim: %s", err, relErr)
+			}
+		}
+	}()
+	cluster := reg.Down
+	status := reg.DownStatus
+	if direction == regression.UP {
+		cluster = reg.Up
+		status = reg.UpStatus
+	}
+	cfg, err := alerts.Get(alertID)
+	if err != nil {
+		return fmt.Errorf("Failed to load alert for bug: %s", err)
+	}
+	desc := fmt.Sprintf(`A perf regression was triaged by %s.
+
+Alert: %s
+Query: %s
+Commit: %s
+Direction: %s
+Regression: %f
+Traces: %d
+
+%s`, status.User, cfg.DisplayName, cfg.Query, fmt.Sprintf(COMMIT_URL_TEMPLATE, hash), direction, cluster.StepFit.Regression, len(cluster.Keys), status.Message)
+	req := issues.IssueRequest{
+		Status:      "New",
+		Labels:      BUG_LABELS,
+		Summary:     fmt.Sprintf("Perf regression found by %q at %.7s", cfg.DisplayName, hash),
+		Description: desc,
+	}
+	if cfg.Owner != "" {
+		req.Owner = issues.MonorailPerson{
+			Name: cfg.Owner,
+		}
+	}
+	issue, err := issueTracker.AddIssue(req)
+	if err != nil {
+		return fmt.Errorf("Failed to file bug: %s", err)
+	}
+	filed = true
+	return regression.SetBug(hash, alertID, direction, issue.ID)
+}
+
+// singleStep does a single round of alerting for the given alerts.Config.
+func singleStep(cfg *alerts.Config, issueTracker issues.IssueTracker) {
 	clusteringLatency.Start()
 	tile := tileBuilder.GetTile()
-	summary, err := clustering.CalculateClusterSummaries(tile, CLUSTER_SIZE, CLUSTER_STDDEV, skpOnly)
+	fresh, err := findClusters(cfg, tile)
 	if err != nil {
-		glog.Errorf("Alerting: Failed to calculate clusters: %s", err)
+		glog.Errorf("Alerting: Failed to calculate clusters for alert %d: %s", cfg.ID, err)
 		return
 	}
-	fresh := []*types.ClusterSummary{}
-	for _, c := range summary.Clusters {
-		if math.Abs(c.StepFit.Regression) > clustering.INTERESTING_THRESHHOLD {
-			fresh = append(fresh, c)
+	// Record each cluster as a regression at its commit, which, unlike the
+	// clusters table, is never reset.
+	for _, c := range fresh {
+		if err := regression.SetCluster(cfg.ID, c); err != nil {
+			glog.Errorf("Alerting: Failed to record regression: %s", err)
 		}
 	}
-	old, err := ListFrom(tile.Commits[0].CommitTime)
+	all, err := ListFrom(tile.Commits[0].CommitTime)
 	if err != nil {
 		glog.Errorf("Alerting: Failed to get existing clusters: %s", err)
 		return
 	}
+	// Only combine with clusters found by the same alert. Clusters found
+	// before alerts were configurable have an AlertID of 0 and may be adopted
+	// by any alert.
+	old := []*types.ClusterSummary{}
+	for _, c := range all {
+		if c.AlertID == cfg.ID || c.AlertID == 0 {
+			old = append(old, c)
+		}
+	}
 	glog.Infof("Found %d old", len(old))
 	glog.Infof("Found %d fresh", len(fresh))
 	updated := CombineClusters(fresh, old)
@@ -301,7 +474,7 @@ func singleStep(issueTracker issues.IssueTracker) {
 				return
 			}
 		} else {
-			glog.Infof("Skipping ClusterSummary.Bugs update because apiKey is missing.")
+			glog.Infof("Skipping ClusterSummary.Bugs update because no issue tracker is configured.")
 			return
 		}
 	}
@@ -329,24 +502,39 @@ func calcNewClusters() {
 	newClustersGauge.Update(int64(count))
 }
 
-// Start kicks off a go routine the periodically refreshes the current alerting clusters.
-func Start(tb tracedb.MasterTileBuilder) {
+// runScheduled runs each of the alerts.Configs which has not run within its
+// interval. lastRun records the last time each Config ran, keyed by ID.
+func runScheduled(lastRun map[int64]time.Time, issueTracker issues.IssueTracker) {
+	cfgs, err := alerts.List()
+	if err != nil {
+		glog.Errorf("Alerting: Failed to load alerts: %s", err)
+		return
+	}
+	now := time.Now()
+	for _, cfg := range cfgs {
+		if now.Sub(lastRun[cfg.ID]) < cfg.IntervalDuration() {
+			continue
+		}
+		singleStep(cfg, issueTracker)
+		lastRun[cfg.ID] = now
+	}
+}
+
+// Start kicks off a go routine the periodically refreshes the current alerting
+// clusters. Each alerts.Config runs on its own interval, and Configs are
+// reloaded from the database on every check, so changes take effect without a
+// restart. The bugs of each cluster are looked up in the issueTracker, which
+// may be nil if no issue tracker is configured.
+func Start(tb tracedb.MasterTileBuilder, issueTracker issues.IssueTracker) {
 	newClustersGauge = metrics2.GetInt64Metric("perf.clustering.untriaged", nil)
 	runsCounter = metrics2.GetCounter("perf.clustering.runs", nil)
 	clusteringLatency = metrics2.NewTimer("perf.clustering.latency", nil)
 	tileBuilder = tb
-	client, err := auth.NewDefaultJWTServiceAccountClient("https://www.googleapis.com/auth/userinfo.email")
-	if err != nil {
-		glog.Errorf("Not updating bugs, not able to construct an authenticated client: %s", err)
-	}
-	var issueTracker issues.IssueTracker = nil
-	if client != nil {
-		issueTracker = issues.NewMonorailIssueTracker(client)
-	}
 
 	go func() {
-		for _ = range time.Tick(config.RECLUSTER_DURATION) {
-			singleStep(issueTracker)
+		lastRun := map[int64]time.Time{}
+		for _ = range time.Tick(SCHEDULE_PERIOD) {
+			runScheduled(lastRun, issueTracker)
 		}
 	}()
 }
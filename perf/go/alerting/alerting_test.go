@@ -3,6 +3,7 @@ package alerting
 import (
 	"testing"
 
+	"go.skia.org/infra/go/tiling"
 	"go.skia.org/infra/perf/go/types"
 )
 
@@ -145,3 +146,42 @@ func TestCombineClusters(t *testing.T) {
 		t.Errorf("Incorrect merge: Got %v Want %v", got, want)
 	}
 }
+
+func TestAboveNoise(t *testing.T) {
+	tile := tiling.NewTile()
+	newTrace := func(key string, params map[string]string, values []float64) {
+		tr := types.NewPerfTraceN(len(values))
+		copy(tr.Values, values)
+		tr.Params_ = params
+		tile.Traces[key] = tr
+	}
+	newTrace(",name=quiet,stat=median,", map[string]string{"name": "quiet", "stat": "median"}, []float64{1, 1, 1, 2, 2, 2})
+	newTrace(",name=quiet,stat=stddev,", map[string]string{"name": "quiet", "stat": "stddev"}, []float64{0.1, 0.1, 1e100, 0.1, 0.1, 0.1})
+	newTrace(",name=noisy,stat=median,", map[string]string{"name": "noisy", "stat": "median"}, []float64{1, 1, 1, 2, 2, 2})
+	newTrace(",name=noisy,stat=stddev,", map[string]string{"name": "noisy", "stat": "stddev"}, []float64{1, 1, 1, 1, 1, 1})
+	newTrace(",name=plain,", map[string]string{"name": "plain"}, []float64{1, 1, 1, 2, 2, 2})
+
+	c := newCluster([]string{",name=quiet,stat=median,"}, -100, "abc")
+	c.StepFit.TurningPoint = 3
+	if !aboveNoise(tile, c, 2) {
+		t.Errorf("Expected a step of 10 sigma to be above the noise.")
+	}
+
+	c = newCluster([]string{",name=noisy,stat=median,"}, -100, "abc")
+	c.StepFit.TurningPoint = 3
+	if aboveNoise(tile, c, 2) {
+		t.Errorf("Expected a step of 1 sigma to be in the noise.")
+	}
+
+	c = newCluster([]string{",name=plain,"}, -100, "abc")
+	c.StepFit.TurningPoint = 3
+	if !aboveNoise(tile, c, 2) {
+		t.Errorf("Expected a trace without stats to be above the noise.")
+	}
+
+	c = newCluster([]string{",name=noisy,stat=median,", ",name=quiet,stat=median,"}, -100, "abc")
+	c.StepFit.TurningPoint = 3
+	if !aboveNoise(tile, c, 2) {
+		t.Errorf("Expected a cluster with half its traces above the noise to be kept.")
+	}
+}
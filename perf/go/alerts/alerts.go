@@ -0,0 +1,272 @@
+// alerts handles storing and retrieving alert configurations, which describe
+// which traces should be checked for regressions and how.
+package alerts
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/db"
+)
+
+const (
+	// Algorithms used to find regressions.
+	ALGO_KMEANS  = "kmeans"  // Run k-means clustering and fit a step function to each centroid.
+	ALGO_STEPFIT = "stepfit" // Fit a step function to each trace individually.
+	ALGO_MAD     = "mad"     // Look for sustained shifts from the rolling median of each trace.
+
+	// Directions of steps that trigger an alert. Note that a step up is a
+	// regression, i.e. it has a negative StepFit.Regression.
+	DIRECTION_UP   = "UP"
+	DIRECTION_DOWN = "DOWN"
+	DIRECTION_BOTH = "BOTH"
+
+	// Defaults for new Configs.
+	DEFAULT_K           = 50
+	DEFAULT_RADIUS      = 5
+	DEFAULT_INTERESTING = 150.0
+	DEFAULT_INTERVAL    = int(config.RECLUSTER_DURATION / time.Minute)
+	DEFAULT_WINDOW      = 10
+	DEFAULT_SENSITIVITY = 3.5
+)
+
+// ALGOS is the list of all valid values of Config.Algo.
+var ALGOS = []string{ALGO_KMEANS, ALGO_STEPFIT, ALGO_MAD}
+
+// DIRECTIONS is the list of all valid values of Config.Direction.
+var DIRECTIONS = []string{DIRECTION_UP, DIRECTION_DOWN, DIRECTION_BOTH}
+
+// Config is a named alert configuration.
+type Config struct {
+	// ID is the identifier for this Config in the database, or -1 if the
+	// Config has not been written yet.
+	ID int64 `json:"id"`
+
+	// DisplayName is a human readable name for the Config.
+	DisplayName string `json:"display_name"`
+
+	// Query selects the traces to check, in URL query format, e.g.
+	// "config=8888&source_type=skp".
+	Query string `json:"query"`
+
+	// Algo is the algorithm used to find regressions, one of ALGOS.
+	Algo string `json:"algo"`
+
+	// K is the number of clusters to use for ALGO_KMEANS.
+	K int `json:"k"`
+
+	// Radius is the minimum number of commits needed on either side of a
+	// step.
+	Radius int `json:"radius"`
+
+	// Interesting is the magnitude of StepFit.Regression beyond which a step
+	// is reported. Not used by ALGO_MAD.
+	Interesting float64 `json:"interesting"`
+
+	// Window is the number of commits on either side of a shift used by
+	// ALGO_MAD, see clustering.FindMADShift.
+	Window int `json:"window"`
+
+	// Sensitivity is the number of median absolute deviations a shift must
+	// exceed to be reported by ALGO_MAD, see clustering.FindMADShift.
+	Sensitivity float64 `json:"sensitivity"`
+
+	// Direction is the direction of steps which are reported, one of
+	// DIRECTIONS.
+	Direction string `json:"direction"`
+
+	// NoiseSigma, if non-zero, suppresses steps that are not larger than
+	// NoiseSigma times the measured sample standard deviation of the traces,
+	// i.e. steps that are within the noise of the benchmark. Only traces that
+	// have a stat=stddev sibling trace are affected.
+	NoiseSigma float64 `json:"noise_sigma"`
+
+	// Owner is the email address of the person responsible for the Config.
+	Owner string `json:"owner"`
+
+	// Interval is the number of minutes between runs of the Config.
+	Interval int `json:"interval"`
+}
+
+// NewConfig returns a new Config with default values.
+func NewConfig() *Config {
+	return &Config{
+		ID:          -1,
+		Algo:        ALGO_KMEANS,
+		K:           DEFAULT_K,
+		Radius:      DEFAULT_RADIUS,
+		Interesting: DEFAULT_INTERESTING,
+		Window:      DEFAULT_WINDOW,
+		Sensitivity: DEFAULT_SENSITIVITY,
+		Direction:   DIRECTION_BOTH,
+		Interval:    DEFAULT_INTERVAL,
+	}
+}
+
+// Validate returns an error if the Config is not valid.
+func (c *Config) Validate() error {
+	if c.DisplayName == "" {
+		return fmt.Errorf("Alert must have a display name.")
+	}
+	if _, err := c.ParsedQuery(); err != nil {
+		return err
+	}
+	if !util.In(c.Algo, ALGOS) {
+		return fmt.Errorf("Invalid algorithm %q; must be one of %v", c.Algo, ALGOS)
+	}
+	if c.Algo == ALGO_KMEANS && c.K <= 0 {
+		return fmt.Errorf("K must be positive; got %d", c.K)
+	}
+	if c.Radius <= 0 {
+		return fmt.Errorf("Radius must be positive; got %d", c.Radius)
+	}
+	if c.Interesting <= 0 {
+		return fmt.Errorf("Interesting must be positive; got %v", c.Interesting)
+	}
+	if c.Algo == ALGO_MAD && c.Window < 2 {
+		return fmt.Errorf("Window must be at least 2; got %d", c.Window)
+	}
+	if c.Algo == ALGO_MAD && c.Sensitivity <= 0 {
+		return fmt.Errorf("Sensitivity must be positive; got %v", c.Sensitivity)
+	}
+	if !util.In(c.Direction, DIRECTIONS) {
+		return fmt.Errorf("Invalid direction %q; must be one of %v", c.Direction, DIRECTIONS)
+	}
+	if c.NoiseSigma < 0 {
+		return fmt.Errorf("NoiseSigma must not be negative; got %v", c.NoiseSigma)
+	}
+	if c.Owner == "" {
+		return fmt.Errorf("Alert must have an owner.")
+	}
+	if c.Interval <= 0 {
+		return fmt.Errorf("Interval must be positive; got %d", c.Interval)
+	}
+	return nil
+}
+
+// ParsedQuery returns the Query parsed into url.Values.
+func (c *Config) ParsedQuery() (url.Values, error) {
+	q, err := url.ParseQuery(c.Query)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid query %q: %s", c.Query, err)
+	}
+	return q, nil
+}
+
+// IntervalDuration returns the time between runs of the Config.
+func (c *Config) IntervalDuration() time.Duration {
+	return time.Duration(c.Interval) * time.Minute
+}
+
+// IsInteresting returns true if a step with the given StepFit.Regression should
+// be reported by this Config.
+//
+// ALGO_MAD only returns shifts that already exceed the Sensitivity, so only
+// the direction is checked for it.
+func (c *Config) IsInteresting(regression float64) bool {
+	threshold := c.Interesting
+	if c.Algo == ALGO_MAD {
+		threshold = 0
+	}
+	switch c.Direction {
+	case DIRECTION_UP:
+		return regression < -threshold
+	case DIRECTION_DOWN:
+		return regression > threshold
+	default:
+		return regression < -threshold || regression > threshold
+	}
+}
+
+// processRows reads all the rows from the alerts table and constructs a slice
+// of Configs from them.
+func processRows(rows *sql.Rows, err error) ([]*Config, error) {
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from database: %s", err)
+	}
+	defer util.Close(rows)
+
+	ret := []*Config{}
+	for rows.Next() {
+		var body string
+		var id int64
+		if err := rows.Scan(&id, &body); err != nil {
+			return nil, fmt.Errorf("Failed to read row from database: %s", err)
+		}
+		c := &Config{}
+		if err := json.Unmarshal([]byte(body), c); err != nil {
+			return nil, fmt.Errorf("Found invalid JSON in alerts table for %d: %s", id, err)
+		}
+		c.ID = id
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+// List returns all the Configs, ordered by ID.
+func List() ([]*Config, error) {
+	rows, err := db.DB.Query("SELECT id, alert FROM alerts ORDER BY id")
+	return processRows(rows, err)
+}
+
+// Get returns the Config with the given id.
+func Get(id int64) (*Config, error) {
+	rows, err := db.DB.Query("SELECT id, alert FROM alerts WHERE id=?", id)
+	matches, err := processRows(rows, err)
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("Failed to find alert with id: %d", id)
+	}
+	return matches[0], nil
+}
+
+// Write validates the Config and writes it to the database.
+//
+// If the ID is set to -1 then it is written as a new entry and its ID is set,
+// otherwise the existing entry is updated.
+func Write(c *Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("Failed to encode to JSON: %s", err)
+	}
+	if c.ID == -1 {
+		result, err := db.DB.Exec("INSERT INTO alerts (alert) VALUES (?)", string(b))
+		if err != nil {
+			return fmt.Errorf("Failed to write to database: %s", err)
+		}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return fmt.Errorf("Failed to retrieve ID of new alert: %s", err)
+		}
+		c.ID = id
+	} else {
+		result, err := db.DB.Exec("UPDATE alerts SET alert=? WHERE id=?", string(b), c.ID)
+		if err != nil {
+			return fmt.Errorf("Failed to update database: %s", err)
+		}
+		if n, err := result.RowsAffected(); err == nil && n == 0 {
+			if _, err := Get(c.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Delete removes the Config with the given id from the database.
+func Delete(id int64) error {
+	if _, err := db.DB.Exec("DELETE FROM alerts WHERE id=?", id); err != nil {
+		return fmt.Errorf("Failed to delete from database: %s", err)
+	}
+	return nil
+}
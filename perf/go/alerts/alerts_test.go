@@ -0,0 +1,98 @@
+package alerts
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/perf/go/db"
+)
+
+func TestValidate(t *testing.T) {
+	valid := func() *Config {
+		c := NewConfig()
+		c.DisplayName = "SKPs"
+		c.Query = "source_type=skp"
+		c.Owner = "someone@example.com"
+		return c
+	}
+	assert.NoError(t, valid().Validate())
+
+	tc := []func(*Config){
+		func(c *Config) { c.DisplayName = "" },
+		func(c *Config) { c.Query = "%zz" },
+		func(c *Config) { c.Algo = "bogus" },
+		func(c *Config) { c.K = 0 },
+		func(c *Config) { c.Radius = 0 },
+		func(c *Config) { c.Interesting = -1 },
+		func(c *Config) { c.Direction = "SIDEWAYS" },
+		func(c *Config) { c.NoiseSigma = -1 },
+		func(c *Config) { c.Owner = "" },
+		func(c *Config) { c.Interval = 0 },
+	}
+	for i, modify := range tc {
+		c := valid()
+		modify(c)
+		assert.Error(t, c.Validate(), "Case %d", i)
+	}
+
+	// K is only required for k-means.
+	c := valid()
+	c.Algo = ALGO_STEPFIT
+	c.K = 0
+	assert.NoError(t, c.Validate())
+
+	// Window and Sensitivity are only required for MAD.
+	c = valid()
+	c.Window = 0
+	c.Sensitivity = 0
+	assert.NoError(t, c.Validate())
+	c.Algo = ALGO_MAD
+	assert.Error(t, c.Validate())
+	c.Window = DEFAULT_WINDOW
+	assert.Error(t, c.Validate())
+	c.Sensitivity = DEFAULT_SENSITIVITY
+	assert.NoError(t, c.Validate())
+}
+
+func TestIsInteresting(t *testing.T) {
+	c := NewConfig()
+	c.Interesting = 100
+
+	c.Direction = DIRECTION_BOTH
+	assert.True(t, c.IsInteresting(-150))
+	assert.True(t, c.IsInteresting(150))
+	assert.False(t, c.IsInteresting(50))
+	assert.False(t, c.IsInteresting(-100))
+
+	c.Direction = DIRECTION_UP
+	assert.True(t, c.IsInteresting(-150))
+	assert.False(t, c.IsInteresting(150))
+
+	c.Direction = DIRECTION_DOWN
+	assert.False(t, c.IsInteresting(-150))
+	assert.True(t, c.IsInteresting(150))
+
+	// MAD ignores Interesting.
+	c.Algo = ALGO_MAD
+	assert.True(t, c.IsInteresting(5))
+	assert.False(t, c.IsInteresting(-5))
+}
+
+func TestSeededConfigsValidate(t *testing.T) {
+	const prefix = "INSERT INTO alerts (alert) VALUES ('"
+	found := 0
+	for _, step := range db.MigrationSteps() {
+		for _, stmt := range step.MySQLUp {
+			if !strings.HasPrefix(stmt, prefix) {
+				continue
+			}
+			c := &Config{}
+			assert.NoError(t, json.Unmarshal([]byte(strings.TrimSuffix(strings.TrimPrefix(stmt, prefix), "')")), c))
+			assert.NoError(t, c.Validate())
+			found++
+		}
+	}
+	assert.Equal(t, 1, found)
+}
@@ -145,15 +145,17 @@ func sse(xs []float64, base float64) float64 {
 	return total
 }
 
-// getStepFit takes one []float64 trace and calculates and returns a types.StepFit.
+// GetStepFit takes one []float64 trace and calculates and returns a types.StepFit.
+// The radius is the minimum number of commits needed on either side of the
+// step.
 //
 // See types.StepFit for a description of the values being calculated.
-func getStepFit(trace []float64) *types.StepFit {
+func GetStepFit(trace []float64, radius int) *types.StepFit {
 	lse := math.MaxFloat64
 	stepSize := -1.0
 	turn := 0
 
-	for i := config.MIN_CLUSTER_STEP_COMMITS; i < len(trace)-config.MIN_CLUSTER_STEP_COMMITS; i++ {
+	for i := radius; i < len(trace)-radius; i++ {
 		if i == 0 {
 			continue
 		}
@@ -204,8 +206,9 @@ func (p SortableClusterSummarySlice) Less(i, j int) bool {
 }
 func (p SortableClusterSummarySlice) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
 
-// GetClusterSummaries returns a summaries for each cluster.
-func GetClusterSummaries(observations []kmeans.Clusterable, centroids []kmeans.Centroid, commits []*tiling.Commit) *ClusterSummaries {
+// GetClusterSummaries returns a summaries for each cluster. The radius is
+// passed to GetStepFit.
+func GetClusterSummaries(observations []kmeans.Clusterable, centroids []kmeans.Centroid, commits []*tiling.Commit, radius int) *ClusterSummaries {
 	ret := &ClusterSummaries{
 		Clusters: make([]*types.ClusterSummary, len(centroids)),
 	}
@@ -217,7 +220,7 @@ func GetClusterSummaries(observations []kmeans.Clusterable, centroids []kmeans.C
 		if numSampleTraces > config.MAX_SAMPLE_TRACES_PER_CLUSTER {
 			numSampleTraces = config.MAX_SAMPLE_TRACES_PER_CLUSTER
 		}
-		stepFit := getStepFit(cluster[0].(*ctrace.ClusterableTrace).Values)
+		stepFit := GetStepFit(cluster[0].(*ctrace.ClusterableTrace).Values, radius)
 		summary := types.NewClusterSummary(len(cluster)-1, numSampleTraces)
 		summary.ParamSummaries = getParamSummaries(cluster)
 		summary.StepFit = stepFit
@@ -258,8 +261,9 @@ func GetClusterSummaries(observations []kmeans.Clusterable, centroids []kmeans.C
 // Filter returns true if a trace should be included in clustering.
 type Filter func(key string, tr *types.PerfTrace) bool
 
-// CalculateClusterSummaries runs k-means clustering over the trace shapes.
-func CalculateClusterSummaries(tile *tiling.Tile, k int, stddevThreshhold float64, filter Filter) (*ClusterSummaries, error) {
+// CalculateClusterSummaries runs k-means clustering over the trace shapes. The
+// radius is the minimum number of commits needed on either side of a step.
+func CalculateClusterSummaries(tile *tiling.Tile, k int, stddevThreshhold float64, radius int, filter Filter) (*ClusterSummaries, error) {
 	lastCommitIndex := tile.LastCommitIndex()
 	observations := make([]kmeans.Clusterable, 0, len(tile.Traces))
 	for key, trace := range tile.Traces {
@@ -284,8 +288,85 @@ func CalculateClusterSummaries(tile *tiling.Tile, k int, stddevThreshhold float6
 		}
 		lastTotalError = totalError
 	}
-	clusterSummaries := GetClusterSummaries(observations, centroids, tile.Commits)
+	clusterSummaries := GetClusterSummaries(observations, centroids, tile.Commits, radius)
 	clusterSummaries.K = k
 	clusterSummaries.StdDevThreshhold = stddevThreshhold
 	return clusterSummaries, nil
 }
+
+// stepKey identifies a group of traces which step at the same commit in the
+// same direction.
+type stepKey struct {
+	turningPoint int
+	up           bool
+}
+
+// CalculateStepSummaries fits a step function to each individual normalized
+// trace that passes the filter, as opposed to CalculateClusterSummaries which
+// only fits the centroids of the k-means clusters. This catches regressions in
+// single traces that would otherwise be hidden in a cluster dominated by other
+// traces.
+//
+// Traces whose StepFit.Regression exceeds interesting in magnitude are grouped
+// by the commit at which the step occurs and the direction of the step, and
+// each group is returned as a types.ClusterSummary whose first trace is the
+// centroid of the group. The radius is the minimum number of commits needed on
+// either side of a step.
+func CalculateStepSummaries(tile *tiling.Tile, stddevThreshhold float64, radius int, interesting float64, filter Filter) ([]*types.ClusterSummary, error) {
+	lastCommitIndex := tile.LastCommitIndex()
+	numTraces := 0
+	groups := map[stepKey][]kmeans.Clusterable{}
+	for key, trace := range tile.Traces {
+		if !filter(key, trace.(*types.PerfTrace)) {
+			continue
+		}
+		numTraces++
+		t := ctrace.NewFullTrace(string(key), trace.(*types.PerfTrace).Values[:lastCommitIndex+1], trace.Params(), stddevThreshhold)
+		stepFit := GetStepFit(t.Values, radius)
+		if math.Abs(stepFit.Regression) <= interesting {
+			continue
+		}
+		k := stepKey{
+			turningPoint: stepFit.TurningPoint,
+			up:           stepFit.Regression < 0,
+		}
+		groups[k] = append(groups[k], t)
+	}
+	if numTraces == 0 {
+		return nil, fmt.Errorf("Zero traces matched.")
+	}
+
+	ret := make([]*types.ClusterSummary, 0, len(groups))
+	for k, members := range groups {
+		sort.Sort(traceKeySlice(members))
+		centroid := ctrace.CalculateCentroid(members).(*ctrace.ClusterableTrace)
+		numSampleTraces := len(members) + 1
+		if numSampleTraces > config.MAX_SAMPLE_TRACES_PER_CLUSTER {
+			numSampleTraces = config.MAX_SAMPLE_TRACES_PER_CLUSTER
+		}
+		summary := types.NewClusterSummary(len(members), numSampleTraces)
+		summary.ParamSummaries = getParamSummaries(members)
+		summary.StepFit = GetStepFit(centroid.Values, radius)
+		summary.Hash = tile.Commits[k.turningPoint].Hash
+		summary.Timestamp = tile.Commits[k.turningPoint].CommitTime
+		for i, m := range members {
+			summary.Keys[i] = m.(*ctrace.ClusterableTrace).Key
+		}
+		summary.Traces[0] = traceToFlot(centroid)
+		for i := 1; i < numSampleTraces; i++ {
+			summary.Traces[i] = traceToFlot(members[i-1].(*ctrace.ClusterableTrace))
+		}
+		ret = append(ret, summary)
+	}
+	sort.Sort(SortableClusterSummarySlice(ret))
+	return ret, nil
+}
+
+// traceKeySlice is a utility class for sorting ClusterableTraces by Key.
+type traceKeySlice []kmeans.Clusterable
+
+func (p traceKeySlice) Len() int { return len(p) }
+func (p traceKeySlice) Less(i, j int) bool {
+	return p[i].(*ctrace.ClusterableTrace).Key < p[j].(*ctrace.ClusterableTrace).Key
+}
+func (p traceKeySlice) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
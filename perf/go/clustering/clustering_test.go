@@ -0,0 +1,85 @@
+package clustering
+
+import (
+	"fmt"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/types"
+)
+
+// stepTrace returns a slightly noisy trace of length n which steps from
+// before to after at the given index.
+func stepTrace(n, index int, before, after float64) []float64 {
+	ret := make([]float64, n)
+	for i := range ret {
+		ret[i] = before
+		if i >= index {
+			ret[i] = after
+		}
+		if i%2 == 0 {
+			ret[i] += 0.01
+		} else {
+			ret[i] -= 0.01
+		}
+	}
+	return ret
+}
+
+func TestCalculateStepSummaries(t *testing.T) {
+	n := 20
+	tile := &tiling.Tile{
+		Traces:  map[string]tiling.Trace{},
+		Commits: make([]*tiling.Commit, n),
+	}
+	for i := range tile.Commits {
+		tile.Commits[i] = &tiling.Commit{
+			CommitTime: int64(i + 1),
+			Hash:       fmt.Sprintf("hash%d", i),
+		}
+	}
+	addTrace := func(config, sourceType string, values []float64) {
+		key := fmt.Sprintf(",config=%s,source_type=%s,", config, sourceType)
+		tile.Traces[key] = &types.PerfTrace{
+			Values: values,
+			Params_: map[string]string{
+				"config":      config,
+				"source_type": sourceType,
+			},
+		}
+	}
+	// Two traces step up at the same commit, one steps down at a later
+	// commit, one is flat, and one is filtered out.
+	addTrace("8888", "skp", stepTrace(n, 10, 1, 2))
+	addTrace("565", "skp", stepTrace(n, 10, 5, 9))
+	addTrace("gpu", "skp", stepTrace(n, 12, 3, 1))
+	addTrace("nvpr", "skp", stepTrace(n, 0, 1, 1))
+	addTrace("8888", "svg", stepTrace(n, 10, 1, 2))
+
+	skp := func(_ string, tr *types.PerfTrace) bool {
+		return tr.Params()["source_type"] == "skp"
+	}
+	summaries, err := CalculateStepSummaries(tile, 0.001, config.MIN_CLUSTER_STEP_COMMITS, INTERESTING_THRESHHOLD, skp)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(summaries))
+
+	// Steps up are regressions and sort first.
+	up := summaries[0]
+	assert.Equal(t, []string{",config=565,source_type=skp,", ",config=8888,source_type=skp,"}, up.Keys)
+	assert.Equal(t, "hash10", up.Hash)
+	assert.Equal(t, int64(11), up.Timestamp)
+	assert.Equal(t, "Low", up.StepFit.Status)
+	assert.Equal(t, int64(-1), up.ID)
+	assert.Equal(t, n, len(up.Traces[0]))
+
+	down := summaries[1]
+	assert.Equal(t, []string{",config=gpu,source_type=skp,"}, down.Keys)
+	assert.Equal(t, "hash12", down.Hash)
+	assert.Equal(t, "High", down.StepFit.Status)
+
+	// No matching traces.
+	_, err = CalculateStepSummaries(tile, 0.001, config.MIN_CLUSTER_STEP_COMMITS, INTERESTING_THRESHHOLD, func(string, *types.PerfTrace) bool { return false })
+	assert.Error(t, err)
+}
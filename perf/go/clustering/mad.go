@@ -0,0 +1,221 @@
+package clustering
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/ctrace"
+	"go.skia.org/infra/perf/go/kmeans"
+	"go.skia.org/infra/perf/go/types"
+)
+
+const (
+	// MAD_SCALE converts a median absolute deviation into an estimate of the
+	// standard deviation for normally distributed data.
+	MAD_SCALE = 1.4826
+
+	// IQR_SCALE converts an interquartile range into an estimate of the
+	// standard deviation for normally distributed data.
+	IQR_SCALE = 1 / 1.349
+
+	// MAD_SUSTAINED is the fraction of the values in the window after a
+	// commit that must lie outside the band around the median of the window
+	// before the commit for the shift to count as sustained.
+	MAD_SUSTAINED = 0.75
+)
+
+// MADShift is a sustained shift in a single trace found by FindMADShift.
+type MADShift struct {
+	// Index is the index of the first value of the shift.
+	Index int
+
+	// Before is the median of the window before the shift.
+	Before float64
+
+	// After is the median of the window starting at the shift.
+	After float64
+
+	// Scale is the noise of the window before the shift, see noise.
+	Scale float64
+}
+
+// Z returns the size of the shift in units of Scale. Like
+// StepFit.Regression it's negative for a step up.
+func (m *MADShift) Z() float64 {
+	return (m.Before - m.After) / m.Scale
+}
+
+// medianOf returns the median of a non-empty slice. The slice is not modified.
+func medianOf(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	l := len(sorted)
+	if l%2 == 1 {
+		return sorted[l/2]
+	}
+	return (sorted[l/2-1] + sorted[l/2]) / 2
+}
+
+// mad returns the median absolute deviation of the values from m.
+func mad(values []float64, m float64) float64 {
+	dev := make([]float64, len(values))
+	for i, v := range values {
+		dev[i] = math.Abs(v - m)
+	}
+	return medianOf(dev)
+}
+
+// noise returns an estimate of the standard deviation of the values that
+// ignores outliers, the larger of the median absolute deviation from m scaled
+// by MAD_SCALE and the interquartile range scaled by IQR_SCALE, and at least
+// config.MIN_STDDEV.
+//
+// The MAD alone collapses to zero for a bimodal window where most values are
+// in one mode, e.g. [1, 1, 1, 1, 1, 2, 2, 2], which would make every value in
+// the other mode look like a shift. The IQR covers both modes in that case.
+func noise(values []float64, m float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	iqr := sorted[(3*n)/4] - sorted[n/4]
+	return math.Max(math.Max(MAD_SCALE*mad(values, m), IQR_SCALE*iqr), config.MIN_STDDEV)
+}
+
+// FindMADShift finds the first sustained shift in the trace, ignoring missing
+// values, and returns nil if there is none.
+//
+// At each index the median and noise of the 'window' values before the index
+// are calculated. If at least MAD_SUSTAINED of the 'window' values starting at
+// the index lie more than sensitivity times the noise away from the median, on
+// the same side, then the shift starts at the first of those values. Since
+// medians and MADs ignore outliers this works for noisy and bimodal traces
+// where a step fit would not.
+func FindMADShift(trace []float64, window int, sensitivity float64) *MADShift {
+	// Only look at the non-missing values, but remember where they came from.
+	values := []float64{}
+	index := []int{}
+	for i, v := range trace {
+		if v != config.MISSING_DATA_SENTINEL {
+			values = append(values, v)
+			index = append(index, i)
+		}
+	}
+	if window <= 0 {
+		return nil
+	}
+	need := int(math.Ceil(MAD_SUSTAINED * float64(window)))
+	for i := window; i+window <= len(values); i++ {
+		before := values[i-window : i]
+		after := values[i : i+window]
+		m := medianOf(before)
+		scale := noise(before, m)
+		band := sensitivity * scale
+		up := 0
+		down := 0
+		for _, v := range after {
+			if v > m+band {
+				up += 1
+			} else if v < m-band {
+				down += 1
+			}
+		}
+		if up < need && down < need {
+			continue
+		}
+		// The shift starts at the first value outside the band in the direction
+		// of the shift.
+		start := i
+		for j, v := range after {
+			if (up >= need && v > m+band) || (down >= need && v < m-band) {
+				start = i + j
+				break
+			}
+		}
+		end := start + window
+		if end > len(values) {
+			end = len(values)
+		}
+		return &MADShift{
+			Index:  index[start],
+			Before: m,
+			After:  medianOf(values[start:end]),
+			Scale:  scale,
+		}
+	}
+	return nil
+}
+
+// CalculateMADSummaries runs FindMADShift over each trace that passes the
+// filter.
+//
+// Traces with a shift are grouped by the commit at which the shift starts and
+// the direction of the shift, and each group is returned as a
+// types.ClusterSummary whose first trace is the centroid of the group, the
+// same as CalculateStepSummaries. The StepFit of each summary has the
+// TurningPoint at the start of the shift, and a Regression of the mean
+// MADShift.Z of the traces in the group.
+func CalculateMADSummaries(tile *tiling.Tile, stddevThreshhold float64, window int, sensitivity float64, filter Filter) ([]*types.ClusterSummary, error) {
+	lastCommitIndex := tile.LastCommitIndex()
+	numTraces := 0
+	groups := map[stepKey][]kmeans.Clusterable{}
+	zs := map[stepKey]float64{}
+	for key, trace := range tile.Traces {
+		if !filter(key, trace.(*types.PerfTrace)) {
+			continue
+		}
+		numTraces++
+		values := trace.(*types.PerfTrace).Values[:lastCommitIndex+1]
+		shift := FindMADShift(values, window, sensitivity)
+		if shift == nil {
+			continue
+		}
+		k := stepKey{
+			turningPoint: shift.Index,
+			up:           shift.After > shift.Before,
+		}
+		groups[k] = append(groups[k], ctrace.NewFullTrace(string(key), values, trace.Params(), stddevThreshhold))
+		zs[k] += shift.Z()
+	}
+	if numTraces == 0 {
+		return nil, fmt.Errorf("Zero traces matched.")
+	}
+
+	ret := make([]*types.ClusterSummary, 0, len(groups))
+	for k, members := range groups {
+		sort.Sort(traceKeySlice(members))
+		centroid := ctrace.CalculateCentroid(members).(*ctrace.ClusterableTrace)
+		numSampleTraces := len(members) + 1
+		if numSampleTraces > config.MAX_SAMPLE_TRACES_PER_CLUSTER {
+			numSampleTraces = config.MAX_SAMPLE_TRACES_PER_CLUSTER
+		}
+		summary := types.NewClusterSummary(len(members), numSampleTraces)
+		summary.ParamSummaries = getParamSummaries(members)
+		z := zs[k] / float64(len(members))
+		status := "High"
+		if k.up {
+			status = "Low"
+		}
+		summary.StepFit = &types.StepFit{
+			TurningPoint: k.turningPoint,
+			Regression:   z,
+			Status:       status,
+		}
+		summary.Hash = tile.Commits[k.turningPoint].Hash
+		summary.Timestamp = tile.Commits[k.turningPoint].CommitTime
+		for i, m := range members {
+			summary.Keys[i] = m.(*ctrace.ClusterableTrace).Key
+		}
+		summary.Traces[0] = traceToFlot(centroid)
+		for i := 1; i < numSampleTraces; i++ {
+			summary.Traces[i] = traceToFlot(members[i-1].(*ctrace.ClusterableTrace))
+		}
+		ret = append(ret, summary)
+	}
+	sort.Sort(SortableClusterSummarySlice(ret))
+	return ret, nil
+}
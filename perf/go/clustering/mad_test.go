@@ -0,0 +1,133 @@
+package clustering
+
+import (
+	"fmt"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/types"
+)
+
+// bimodalTrace returns a trace of length n where every third value is one
+// larger than the rest, and all the values from index on are offset by shift.
+func bimodalTrace(n, index int, shift float64) []float64 {
+	ret := make([]float64, n)
+	for i := range ret {
+		ret[i] = 1
+		if i%3 == 2 {
+			ret[i] = 2
+		}
+		if i >= index {
+			ret[i] += shift
+		}
+	}
+	return ret
+}
+
+func TestFindMADShift(t *testing.T) {
+	// A step up.
+	shift := FindMADShift(stepTrace(30, 15, 1, 2), 5, 3.5)
+	assert.NotNil(t, shift)
+	assert.Equal(t, 15, shift.Index)
+	assert.InDelta(t, 1.0, shift.Before, 0.02)
+	assert.InDelta(t, 2.0, shift.After, 0.02)
+	assert.True(t, shift.Z() < 0)
+
+	// A step down.
+	shift = FindMADShift(stepTrace(20, 10, 3, 1), 5, 3.5)
+	assert.NotNil(t, shift)
+	assert.Equal(t, 10, shift.Index)
+	assert.True(t, shift.Z() > 0)
+
+	// Missing values are skipped, but the index is into the original trace.
+	trace := stepTrace(30, 15, 1, 2)
+	trace[3] = config.MISSING_DATA_SENTINEL
+	trace[16] = config.MISSING_DATA_SENTINEL
+	shift = FindMADShift(trace, 5, 3.5)
+	assert.NotNil(t, shift)
+	assert.Equal(t, 15, shift.Index)
+
+	// A bimodal trace with no shift.
+	assert.Nil(t, FindMADShift(bimodalTrace(60, 60, 0), 6, 3.5))
+
+	// A bimodal trace with a shift.
+	shift = FindMADShift(bimodalTrace(60, 30, 5), 6, 3.5)
+	assert.NotNil(t, shift)
+	assert.Equal(t, 30, shift.Index)
+
+	// A single outlier isn't a sustained shift.
+	trace = stepTrace(30, 0, 1, 1)
+	trace[10] = 5
+	assert.Nil(t, FindMADShift(trace, 5, 3.5))
+
+	// A flat trace.
+	assert.Nil(t, FindMADShift(stepTrace(20, 0, 1, 1), 5, 3.5))
+
+	// A trace that's too short for the window.
+	assert.Nil(t, FindMADShift(stepTrace(9, 5, 1, 2), 5, 3.5))
+	assert.Nil(t, FindMADShift(stepTrace(20, 10, 1, 2), 0, 3.5))
+
+	// Lower sensitivity finds smaller shifts.
+	assert.Nil(t, FindMADShift(bimodalTrace(60, 30, 1), 6, 3.5))
+	assert.NotNil(t, FindMADShift(bimodalTrace(60, 30, 1), 6, 0.5))
+}
+
+func TestCalculateMADSummaries(t *testing.T) {
+	n := 20
+	tile := &tiling.Tile{
+		Traces:  map[string]tiling.Trace{},
+		Commits: make([]*tiling.Commit, n),
+	}
+	for i := range tile.Commits {
+		tile.Commits[i] = &tiling.Commit{
+			CommitTime: int64(i + 1),
+			Hash:       fmt.Sprintf("hash%d", i),
+		}
+	}
+	addTrace := func(config, sourceType string, values []float64) {
+		key := fmt.Sprintf(",config=%s,source_type=%s,", config, sourceType)
+		tile.Traces[key] = &types.PerfTrace{
+			Values: values,
+			Params_: map[string]string{
+				"config":      config,
+				"source_type": sourceType,
+			},
+		}
+	}
+	// Two traces shift up at the same commit, one shifts down at a later
+	// commit, one is bimodal, and one is filtered out.
+	addTrace("8888", "skp", stepTrace(n, 10, 1, 2))
+	addTrace("565", "skp", stepTrace(n, 10, 5, 9))
+	addTrace("gpu", "skp", stepTrace(n, 12, 3, 1))
+	addTrace("nvpr", "skp", bimodalTrace(n, n, 0))
+	addTrace("8888", "svg", stepTrace(n, 10, 1, 2))
+
+	skp := func(_ string, tr *types.PerfTrace) bool {
+		return tr.Params()["source_type"] == "skp"
+	}
+	summaries, err := CalculateMADSummaries(tile, 0.001, 5, 3.5, skp)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(summaries))
+
+	// Shifts up are regressions and sort first.
+	up := summaries[0]
+	assert.Equal(t, []string{",config=565,source_type=skp,", ",config=8888,source_type=skp,"}, up.Keys)
+	assert.Equal(t, "hash10", up.Hash)
+	assert.Equal(t, int64(11), up.Timestamp)
+	assert.Equal(t, 10, up.StepFit.TurningPoint)
+	assert.Equal(t, "Low", up.StepFit.Status)
+	assert.True(t, up.StepFit.Regression < 0)
+	assert.Equal(t, n, len(up.Traces[0]))
+
+	down := summaries[1]
+	assert.Equal(t, []string{",config=gpu,source_type=skp,"}, down.Keys)
+	assert.Equal(t, "hash12", down.Hash)
+	assert.Equal(t, "High", down.StepFit.Status)
+	assert.True(t, down.StepFit.Regression > 0)
+
+	// No matching traces.
+	_, err = CalculateMADSummaries(tile, 0.001, 5, 3.5, func(string, *types.PerfTrace) bool { return false })
+	assert.Error(t, err)
+}
@@ -87,6 +87,38 @@ var migrationSteps = []database.MigrationStep{
 		},
 		MySQLDown: []string{},
 	},
+	// version 3
+	{
+		MySQLUp: []string{
+			`CREATE TABLE IF NOT EXISTS alerts (
+				id         INT          NOT NULL AUTO_INCREMENT PRIMARY KEY,
+				alert      MEDIUMTEXT   NOT NULL
+			)`,
+
+			// The default alert matches the clustering done before alerts
+			// were configurable.
+			`INSERT INTO alerts (alert) VALUES ('{"display_name":"SKP min_ms","query":"source_type=skp&sub_result=min_ms","algo":"kmeans","k":50,"radius":5,"interesting":150,"direction":"BOTH","owner":"skiabot@google.com","interval":5}')`,
+		},
+		MySQLDown: []string{
+			`DROP TABLE IF EXISTS alerts`,
+		},
+	},
+	// version 4
+	{
+		MySQLUp: []string{
+			`CREATE TABLE IF NOT EXISTS regressions (
+				hash       VARCHAR(40)  NOT NULL,
+				alert_id   INT          NOT NULL,
+				ts         BIGINT       NOT NULL,
+				regression MEDIUMTEXT   NOT NULL,
+				PRIMARY KEY (hash, alert_id),
+				INDEX regressions_ts (ts)
+			)`,
+		},
+		MySQLDown: []string{
+			`DROP TABLE IF EXISTS regressions`,
+		},
+	},
 
 	// Use this is a template for more migration steps.
 	// version x
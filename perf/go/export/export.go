@@ -0,0 +1,319 @@
+// export writes perf traces in formats that are easy to load into other
+// tools, such as CSV, or newline delimited JSON, with one row per trace.
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strconv"
+
+	"go.skia.org/infra/go/query"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/go/vcsinfo"
+	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/ptracestore"
+	"go.skia.org/infra/perf/go/types"
+)
+
+const (
+	// Formats of exported data.
+	FORMAT_CSV  = "csv"
+	FORMAT_JSON = "json"
+
+	// DEFAULT_BATCH_SIZE is the number of traces loaded from the PTraceStore at
+	// a time by Stream.
+	DEFAULT_BATCH_SIZE = 1000
+)
+
+// FORMATS is the list of all valid formats.
+var FORMATS = []string{FORMAT_CSV, FORMAT_JSON}
+
+// Writer writes traces in a particular format.
+//
+// WriteHeader must be called once before any calls to WriteTrace.
+type Writer interface {
+	// WriteHeader writes out any header, where commits are the commits that
+	// the values of each trace correspond to, and paramKeys are all the param
+	// keys that appear in the traces, in sorted order.
+	WriteHeader(commits []*tiling.Commit, paramKeys []string) error
+
+	// WriteTrace writes a single trace. Values equal to
+	// config.MISSING_DATA_SENTINEL are written as missing.
+	WriteTrace(key string, params map[string]string, values []float64) error
+
+	// Flush writes any buffered data.
+	Flush() error
+
+	// ContentType is the MIME type of the output.
+	ContentType() string
+}
+
+// New returns a Writer for the given format, one of FORMATS.
+func New(format string, w io.Writer) (Writer, error) {
+	switch format {
+	case FORMAT_CSV:
+		return &csvWriter{w: csv.NewWriter(w)}, nil
+	case FORMAT_JSON:
+		return &jsonWriter{enc: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("Unknown format %q; must be one of %v", format, FORMATS)
+	}
+}
+
+// csvWriter writes one line per trace of the form:
+//
+//	id,<param keys>...,<commit hashes>...
+//
+// Missing values are left empty.
+type csvWriter struct {
+	w         *csv.Writer
+	paramKeys []string
+}
+
+func (c *csvWriter) WriteHeader(commits []*tiling.Commit, paramKeys []string) error {
+	c.paramKeys = paramKeys
+	header := []string{"id"}
+	header = append(header, paramKeys...)
+	for _, commit := range commits {
+		header = append(header, commit.Hash)
+	}
+	return c.w.Write(header)
+}
+
+func (c *csvWriter) WriteTrace(key string, params map[string]string, values []float64) error {
+	row := make([]string, 0, 1+len(c.paramKeys)+len(values))
+	row = append(row, key)
+	for _, k := range c.paramKeys {
+		row = append(row, params[k])
+	}
+	for _, v := range values {
+		if v == config.MISSING_DATA_SENTINEL {
+			row = append(row, "")
+		} else {
+			row = append(row, strconv.FormatFloat(v, 'g', -1, 64))
+		}
+	}
+	return c.w.Write(row)
+}
+
+func (c *csvWriter) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+func (c *csvWriter) ContentType() string {
+	return "text/csv"
+}
+
+// jsonRow is a single line written by jsonWriter.
+type jsonRow struct {
+	ID     string             `json:"id"`
+	Params map[string]string  `json:"params"`
+	Values map[string]float64 `json:"values"` // Keyed by commit hash.
+}
+
+// jsonWriter writes one JSON serialized jsonRow per line. Missing values are
+// left out of jsonRow.Values.
+type jsonWriter struct {
+	enc     *json.Encoder
+	commits []*tiling.Commit
+}
+
+func (j *jsonWriter) WriteHeader(commits []*tiling.Commit, paramKeys []string) error {
+	j.commits = commits
+	return nil
+}
+
+func (j *jsonWriter) WriteTrace(key string, params map[string]string, values []float64) error {
+	row := &jsonRow{
+		ID:     key,
+		Params: params,
+		Values: map[string]float64{},
+	}
+	for i, v := range values {
+		if v != config.MISSING_DATA_SENTINEL && i < len(j.commits) {
+			row.Values[j.commits[i].Hash] = v
+		}
+	}
+	return j.enc.Encode(row)
+}
+
+func (j *jsonWriter) Flush() error {
+	return nil
+}
+
+func (j *jsonWriter) ContentType() string {
+	return "application/x-ndjson"
+}
+
+// paramKeys returns the sorted union of the param keys of all the traces,
+// except for "id", which is always written as the trace key.
+func paramKeys(params []map[string]string) []string {
+	keys := map[string]bool{}
+	for _, p := range params {
+		for k, _ := range p {
+			if k != "id" {
+				keys[k] = true
+			}
+		}
+	}
+	ret := make([]string, 0, len(keys))
+	for k, _ := range keys {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// validCommits returns the commits up to and including the last one with a
+// non-zero CommitTime, since tiles may be padded with empty commits.
+func validCommits(commits []*tiling.Commit) []*tiling.Commit {
+	n := len(commits)
+	for n > 0 && commits[n-1].CommitTime == 0 {
+		n -= 1
+	}
+	return commits[:n]
+}
+
+// Traces writes the given traces, sorted by their "id" param, which is where
+// the parser package stores the trace key. The commits are the commits of the
+// tile that the traces were calculated from.
+func Traces(w Writer, commits []*tiling.Commit, traces []*types.PerfTrace) error {
+	commits = validCommits(commits)
+	byID := map[string]*types.PerfTrace{}
+	ids := []string{}
+	params := []map[string]string{}
+	for _, tr := range traces {
+		id := tr.Params()["id"]
+		byID[id] = tr
+		ids = append(ids, id)
+		params = append(params, tr.Params())
+	}
+	sort.Strings(ids)
+	if err := w.WriteHeader(commits, paramKeys(params)); err != nil {
+		return fmt.Errorf("Failed to write header: %s", err)
+	}
+	for _, id := range ids {
+		tr := byID[id]
+		if err := w.WriteTrace(id, tr.Params(), tr.Values[:len(commits)]); err != nil {
+			return fmt.Errorf("Failed to write trace: %s", err)
+		}
+	}
+	return w.Flush()
+}
+
+// Tile writes all the traces in the tile that match the query, sorted by key.
+func Tile(w Writer, tile *tiling.Tile, q url.Values) error {
+	commits := validCommits(tile.Commits)
+	keys := []string{}
+	params := []map[string]string{}
+	for key, tr := range tile.Traces {
+		if tiling.Matches(tr, q) {
+			keys = append(keys, key)
+			params = append(params, tr.Params())
+		}
+	}
+	sort.Strings(keys)
+	if err := w.WriteHeader(commits, paramKeys(params)); err != nil {
+		return fmt.Errorf("Failed to write header: %s", err)
+	}
+	for _, key := range keys {
+		tr := tile.Traces[key]
+		if err := w.WriteTrace(key, tr.Params(), tr.(*types.PerfTrace).Values[:len(commits)]); err != nil {
+			return fmt.Errorf("Failed to write trace: %s", err)
+		}
+	}
+	return w.Flush()
+}
+
+// Stream writes all the traces in the PTraceStore that match the query over
+// the given commits, which must be ordered from oldest to newest, sorted by
+// key.
+//
+// The trace ids are resolved once up front, then the values are loaded
+// batchSize traces at a time by looking up the ids of the batch, and each
+// batch is written and flushed before the next is loaded, so neither memory
+// use nor the cost of a batch depends on the total number of traces. If
+// flush is non-nil it's called after each batch, e.g. to flush an
+// http.ResponseWriter.
+func Stream(w Writer, store ptracestore.PTraceStore, source string, commits []*vcsinfo.IndexCommit, q query.Query, batchSize int, flush func()) error {
+	if batchSize <= 0 {
+		batchSize = DEFAULT_BATCH_SIZE
+	}
+	tileCommits := make([]*tiling.Commit, len(commits))
+	commitIDs := make([]*ptracestore.CommitID, len(commits))
+	for i, c := range commits {
+		tileCommits[i] = &tiling.Commit{
+			CommitTime: c.Timestamp.Unix(),
+			Hash:       c.Hash,
+		}
+		commitIDs[i] = &ptracestore.CommitID{
+			Offset: c.Index,
+			Source: source,
+		}
+	}
+
+	keys, err := store.TraceIDs(commitIDs, q)
+	if err != nil {
+		return fmt.Errorf("Failed to load trace ids: %s", err)
+	}
+	// Only keep the union of the param keys, the params of each trace are
+	// parsed again as it's written.
+	names := map[string]string{}
+	for _, key := range keys {
+		p, err := query.ParseKey(key)
+		if err != nil {
+			return fmt.Errorf("Found an invalid trace id: %s", err)
+		}
+		for k, _ := range p {
+			names[k] = ""
+		}
+	}
+	if err := w.WriteHeader(tileCommits, paramKeys([]map[string]string{names})); err != nil {
+		return fmt.Errorf("Failed to write header: %s", err)
+	}
+
+	values := make([]float64, len(commits))
+	for start := 0; start < len(keys); start += batchSize {
+		end := start + batchSize
+		if end > len(keys) {
+			end = len(keys)
+		}
+		batch := keys[start:end]
+		traceSet, err := store.MatchTraceIDs(commitIDs, batch)
+		if err != nil {
+			return fmt.Errorf("Failed to load traces: %s", err)
+		}
+		for _, key := range batch {
+			trace, ok := traceSet[key]
+			if !ok {
+				continue
+			}
+			for i, v := range trace {
+				if v == ptracestore.MISSING_VALUE {
+					values[i] = config.MISSING_DATA_SENTINEL
+				} else {
+					values[i] = float64(v)
+				}
+			}
+			params, err := query.ParseKey(key)
+			if err != nil {
+				return fmt.Errorf("Found an invalid trace id: %s", err)
+			}
+			if err := w.WriteTrace(key, params, values); err != nil {
+				return fmt.Errorf("Failed to write trace: %s", err)
+			}
+		}
+		if err := w.Flush(); err != nil {
+			return fmt.Errorf("Failed to flush: %s", err)
+		}
+		if flush != nil {
+			flush()
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,129 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/query"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/go/vcsinfo"
+	"go.skia.org/infra/perf/go/ptracestore"
+	"go.skia.org/infra/perf/go/types"
+)
+
+func newTile() *tiling.Tile {
+	tile := tiling.NewTile()
+	tile.Commits = []*tiling.Commit{
+		{Hash: "aaa", CommitTime: 1},
+		{Hash: "bbb", CommitTime: 2},
+		{},
+	}
+	tr := types.NewPerfTraceN(3)
+	tr.Values[0] = 1.5
+	tr.Params()["config"] = "8888"
+	tr.Params()["test"] = "foo"
+	tile.Traces[",config=8888,test=foo,"] = tr
+
+	tr = types.NewPerfTraceN(3)
+	tr.Values[0] = 2
+	tr.Values[1] = 3
+	tr.Params()["arch"] = "x86"
+	tr.Params()["config"] = "565"
+	tile.Traces[",arch=x86,config=565,"] = tr
+	return tile
+}
+
+func TestTileCSV(t *testing.T) {
+	b := &bytes.Buffer{}
+	w, err := New(FORMAT_CSV, b)
+	assert.NoError(t, err)
+	assert.NoError(t, Tile(w, newTile(), url.Values{}))
+	assert.Equal(t, "id,arch,config,test,aaa,bbb\n"+
+		"\",arch=x86,config=565,\",x86,565,,2,3\n"+
+		"\",config=8888,test=foo,\",,8888,foo,1.5,\n", b.String())
+
+	b.Reset()
+	w, err = New(FORMAT_CSV, b)
+	assert.NoError(t, err)
+	assert.NoError(t, Tile(w, newTile(), url.Values{"config": []string{"565"}}))
+	assert.Equal(t, "id,arch,config,aaa,bbb\n"+
+		"\",arch=x86,config=565,\",x86,565,2,3\n", b.String())
+}
+
+func TestTileJSON(t *testing.T) {
+	b := &bytes.Buffer{}
+	w, err := New(FORMAT_JSON, b)
+	assert.NoError(t, err)
+	assert.NoError(t, Tile(w, newTile(), url.Values{}))
+	assert.Equal(t, `{"id":",arch=x86,config=565,","params":{"arch":"x86","config":"565"},"values":{"aaa":2,"bbb":3}}`+"\n"+
+		`{"id":",config=8888,test=foo,","params":{"config":"8888","test":"foo"},"values":{"aaa":1.5}}`+"\n", b.String())
+}
+
+func TestTraces(t *testing.T) {
+	tile := newTile()
+	tr := types.NewPerfTraceN(3)
+	tr.Values[1] = 4
+	tr.Params()["id"] = "@norm(x)"
+	b := &bytes.Buffer{}
+	w, err := New(FORMAT_CSV, b)
+	assert.NoError(t, err)
+	assert.NoError(t, Traces(w, tile.Commits, []*types.PerfTrace{tr}))
+	assert.Equal(t, "id,aaa,bbb\n"+
+		"@norm(x),,4\n", b.String())
+}
+
+func TestNewUnknownFormat(t *testing.T) {
+	_, err := New("xml", &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestStream(t *testing.T) {
+	dir, err := ioutil.TempDir("", "export")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	store := ptracestore.New(dir)
+
+	// Write values at commits that span two ptracestore tiles.
+	offsets := []int{ptracestore.COMMITS_PER_TILE - 1, ptracestore.COMMITS_PER_TILE}
+	commits := []*vcsinfo.IndexCommit{}
+	for i, offset := range offsets {
+		commits = append(commits, &vcsinfo.IndexCommit{
+			Hash:      fmt.Sprintf("hash%d", i),
+			Index:     offset,
+			Timestamp: time.Unix(int64(1000+i), 0),
+		})
+		values := map[string]float32{
+			",config=8888,test=foo,": float32(i),
+			",config=565,test=foo,":  float32(10 + i),
+		}
+		// The gpu trace only appears at the last commit.
+		if i == 1 {
+			values[",config=gpu,test=foo,"] = 5
+		}
+		assert.NoError(t, store.Add(&ptracestore.CommitID{Offset: offset, Source: "master"}, values, "gs://foo"))
+	}
+
+	b := &bytes.Buffer{}
+	w, err := New(FORMAT_CSV, b)
+	assert.NoError(t, err)
+	flushes := 0
+	assert.NoError(t, Stream(w, store, "master", commits, query.New(url.Values{}), 2, func() { flushes += 1 }))
+	assert.Equal(t, "id,config,test,hash0,hash1\n"+
+		"\",config=565,test=foo,\",565,foo,10,11\n"+
+		"\",config=8888,test=foo,\",8888,foo,0,1\n"+
+		"\",config=gpu,test=foo,\",gpu,foo,,5\n", b.String())
+	assert.Equal(t, 2, flushes)
+
+	b.Reset()
+	w, err = New(FORMAT_CSV, b)
+	assert.NoError(t, err)
+	assert.NoError(t, Stream(w, store, "master", commits, query.New(url.Values{"config": []string{"gpu"}}), 0, nil))
+	assert.Equal(t, "id,config,test,hash0,hash1\n"+
+		"\",config=gpu,test=foo,\",gpu,foo,,5\n", b.String())
+}
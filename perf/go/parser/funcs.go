@@ -7,7 +7,9 @@ import (
 	"strconv"
 
 	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/perf/go/clustering"
 	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/shortcut"
 	"go.skia.org/infra/perf/go/types"
 	"go.skia.org/infra/perf/go/vec"
 )
@@ -391,3 +393,167 @@ func (LogFunc) Describe() string {
 }
 
 var logFunc = LogFunc{}
+
+// shortcutGet looks up a shortcut by id. It is a variable so it can be
+// replaced in tests.
+var shortcutGet = shortcut.Get
+
+type ShortcutFunc struct{}
+
+// shortcutFunc implements Func and returns the traces in the Tile that were
+// saved in a shortcut.
+//
+// It expects a single argument that is the string id of the shortcut.
+// Trace ids in the shortcut that aren't in the Tile are ignored, see
+// shortcut.Shortcut.Resolve.
+func (ShortcutFunc) Eval(ctx *Context, node *Node) ([]*types.PerfTrace, error) {
+	if len(node.Args) != 1 {
+		return nil, fmt.Errorf("shortcut() takes a single argument.")
+	}
+	if node.Args[0].Typ != NodeString {
+		return nil, fmt.Errorf("shortcut() takes a string argument.")
+	}
+	sh, err := shortcutGet(node.Args[0].Val)
+	if err != nil {
+		return nil, fmt.Errorf("shortcut() failed to find shortcut %q: %s", node.Args[0].Val, err)
+	}
+	traces := []*types.PerfTrace{}
+	for _, id := range sh.Resolve(ctx.Tile) {
+		tr, ok := ctx.Tile.Traces[id]
+		if !ok {
+			continue
+		}
+		cp := tr.DeepCopy()
+		cp.Params()["id"] = tiling.AsCalculatedID(id)
+		traces = append(traces, cp.(*types.PerfTrace))
+	}
+	return traces, nil
+}
+
+func (ShortcutFunc) Describe() string {
+	return `shortcut() returns the set of traces saved in a shortcut.
+
+  It expects a single argument that is the id of the shortcut as a string, such as:
+
+     shortcut("123")`
+}
+
+var shortcutFunc = ShortcutFunc{}
+
+type StepFunc struct{}
+
+// stepFunc implements Func and replaces each trace with the best fit step
+// function for that trace.
+//
+// Missing datapoints are filled in before fitting, see fillFunc. If a second
+// optional number is passed in to step() then that is used as the minimum
+// number of commits on either side of the step, otherwise it defaults to
+// config.MIN_CLUSTER_STEP_COMMITS.
+func (StepFunc) Eval(ctx *Context, node *Node) ([]*types.PerfTrace, error) {
+	if len(node.Args) > 2 || len(node.Args) == 0 {
+		return nil, fmt.Errorf("step() takes one or two arguments.")
+	}
+	if node.Args[0].Typ != NodeFunc {
+		return nil, fmt.Errorf("step() takes a function as its first argument.")
+	}
+	radius := config.MIN_CLUSTER_STEP_COMMITS
+	if len(node.Args) == 2 {
+		if node.Args[1].Typ != NodeNum {
+			return nil, fmt.Errorf("step() takes a number as its second argument.")
+		}
+		r, err := strconv.ParseInt(node.Args[1].Val, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("step() radius not a valid integer %s : %s", node.Args[1].Val, err)
+		}
+		if r < 1 {
+			return nil, fmt.Errorf("step() radius must be at least 1, got %d", r)
+		}
+		radius = int(r)
+	}
+	traces, err := node.Args[0].Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("step() failed evaluating argument: %s", err)
+	}
+
+	for _, tr := range traces {
+		vec.Fill(tr.Values)
+		stepFit := clustering.GetStepFit(tr.Values, radius)
+		vec.FillStep(tr.Values, stepFit.TurningPoint)
+	}
+	return traces, nil
+}
+
+func (StepFunc) Describe() string {
+	return `step() replaces each trace with the step function that best fits the trace.
+
+  If a second optional number is passed in to step() then that is used as the
+  minimum number of commits on either side of the step, otherwise it defaults to 5.`
+}
+
+var stepFunc = StepFunc{}
+
+type IQRRFunc struct{}
+
+// iqrrFunc implements Func and removes outliers from each trace, replacing
+// them with MISSING_DATA_SENTINEL.
+//
+// Outliers are values outside of [Q1 - 1.5*IQR, Q3 + 1.5*IQR], see vec.IQRR.
+func (IQRRFunc) Eval(ctx *Context, node *Node) ([]*types.PerfTrace, error) {
+	if len(node.Args) != 1 {
+		return nil, fmt.Errorf("iqrr() takes a single argument.")
+	}
+	if node.Args[0].Typ != NodeFunc {
+		return nil, fmt.Errorf("iqrr() takes a function argument.")
+	}
+	traces, err := node.Args[0].Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("iqrr() failed evaluating argument: %s", err)
+	}
+
+	for _, tr := range traces {
+		vec.IQRR(tr.Values)
+	}
+	return traces, nil
+}
+
+func (IQRRFunc) Describe() string {
+	return `iqrr() removes outliers from each trace using the interquartile range.
+
+  Values below Q1 - 1.5*IQR or above Q3 + 1.5*IQR are removed, where IQR = Q3 - Q1.`
+}
+
+var iqrrFunc = IQRRFunc{}
+
+type ScaleByAveFunc struct{}
+
+// scaleByAveFunc implements Func and divides each trace by its own average.
+//
+// MISSING_DATA_SENTINEL values are left untouched. Traces with an average of
+// 0, or with no values, are left unchanged.
+func (ScaleByAveFunc) Eval(ctx *Context, node *Node) ([]*types.PerfTrace, error) {
+	if len(node.Args) != 1 {
+		return nil, fmt.Errorf("scale_by_ave() takes a single argument.")
+	}
+	if node.Args[0].Typ != NodeFunc {
+		return nil, fmt.Errorf("scale_by_ave() takes a function argument.")
+	}
+	traces, err := node.Args[0].Eval(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("scale_by_ave() failed evaluating argument: %s", err)
+	}
+
+	for _, tr := range traces {
+		mean, _, err := vec.MeanAndStdDev(tr.Values)
+		if err != nil || mean == 0 {
+			continue
+		}
+		vec.ScaleBy(tr.Values, mean)
+	}
+	return traces, nil
+}
+
+func (ScaleByAveFunc) Describe() string {
+	return `scale_by_ave() divides each trace by its own average, so every trace has an average of 1.0.`
+}
+
+var scaleByAveFunc = ScaleByAveFunc{}
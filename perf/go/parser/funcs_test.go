@@ -0,0 +1,158 @@
+package parser
+
+import (
+	"fmt"
+	"testing"
+
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/perf/go/shortcut"
+	"go.skia.org/infra/perf/go/types"
+)
+
+func TestShortcut(t *testing.T) {
+	ctx := newTestContext()
+	shortcutGet = func(id string) (*shortcut.Shortcut, error) {
+		if id != "1" {
+			return nil, fmt.Errorf("Not found.")
+		}
+		return &shortcut.Shortcut{
+			Keys: []string{"t1", "t3"},
+		}, nil
+	}
+	defer func() { shortcutGet = shortcut.Get }()
+
+	traces, err := ctx.Eval(`shortcut("1")`)
+	if err != nil {
+		t.Fatalf("Failed to eval shortcut() test: %s", err)
+	}
+	if got, want := len(traces), 1; got != want {
+		t.Fatalf("shortcut() returned wrong length: Got %v Want %v", got, want)
+	}
+	if got, want := traces[0].Params()["id"], tiling.AsCalculatedID("t1"); got != want {
+		t.Errorf("shortcut() wrong id: Got %v Want %v", got, want)
+	}
+	if got, want := traces[0].Values[1], 1.234; got != want {
+		t.Errorf("shortcut() wrong value: Got %v Want %v", got, want)
+	}
+
+	// Make sure we made a deep copy of the traces in the Tile.
+	traces[0].Values[1] = 2.0
+	if got, want := ctx.Tile.Traces["t1"].(*types.PerfTrace).Values[1], 1.234; got != want {
+		t.Errorf("Tile incorrectly modified: Got %v Want %v", got, want)
+	}
+
+	if _, err := ctx.Eval(`shortcut("2")`); err == nil {
+		t.Errorf("Expected shortcut() of an unknown id to fail.")
+	}
+
+	// Structured keys resolve to the traces in the Tile with those params.
+	shortcutGet = func(id string) (*shortcut.Shortcut, error) {
+		return &shortcut.Shortcut{
+			Keys: []string{",config=gpu,os=Ubuntu12,"},
+		}, nil
+	}
+	traces, err = ctx.Eval(`shortcut("3")`)
+	if err != nil {
+		t.Fatalf("Failed to eval shortcut() test: %s", err)
+	}
+	if got, want := len(traces), 1; got != want {
+		t.Fatalf("shortcut() returned wrong length: Got %v Want %v", got, want)
+	}
+	if got, want := traces[0].Params()["id"], tiling.AsCalculatedID("t2"); got != want {
+		t.Errorf("shortcut() wrong id: Got %v Want %v", got, want)
+	}
+}
+
+func TestStep(t *testing.T) {
+	ctx := newTestContext()
+	ctx.Tile.Traces["t1"].(*types.PerfTrace).Values = []float64{1, 2, 1, 1e100, 5, 6, 5, 6}
+	delete(ctx.Tile.Traces, "t2")
+	traces, err := ctx.Eval(`step(filter(""), 2)`)
+	if err != nil {
+		t.Fatalf("Failed to eval step() test: %s", err)
+	}
+	if got, want := len(traces), 1; got != want {
+		t.Errorf("step() returned wrong length: Got %v Want %v", got, want)
+	}
+
+	for i, want := range []float64{1.333, 1.333, 1.333, 5.4, 5.4, 5.4, 5.4, 5.4} {
+		if got := traces[0].Values[i]; !near(got, want) {
+			t.Errorf("Distance mismatch: Got %v Want %v", got, want)
+		}
+	}
+}
+
+func TestIQRR(t *testing.T) {
+	ctx := newTestContext()
+	ctx.Tile.Traces["t1"].(*types.PerfTrace).Values = []float64{1, 2, 3, 4, 5, 6, 7, 8, 100}
+	delete(ctx.Tile.Traces, "t2")
+	traces, err := ctx.Eval(`iqrr(filter(""))`)
+	if err != nil {
+		t.Fatalf("Failed to eval iqrr() test: %s", err)
+	}
+	if got, want := len(traces), 1; got != want {
+		t.Errorf("iqrr() returned wrong length: Got %v Want %v", got, want)
+	}
+
+	for i, want := range []float64{1, 2, 3, 4, 5, 6, 7, 8, 1e100} {
+		if got := traces[0].Values[i]; !near(got, want) {
+			t.Errorf("Distance mismatch: Got %v Want %v", got, want)
+		}
+	}
+}
+
+func TestScaleByAve(t *testing.T) {
+	ctx := newTestContext()
+	ctx.Tile.Traces["t1"].(*types.PerfTrace).Values = []float64{2, 4, 1e100, 6}
+	ctx.Tile.Traces["t2"].(*types.PerfTrace).Values = []float64{0, 0, 1e100, 0}
+
+	traces, err := ctx.Eval(`scale_by_ave(filter("config=8888"))`)
+	if err != nil {
+		t.Fatalf("Failed to eval scale_by_ave() test: %s", err)
+	}
+	if got, want := len(traces), 1; got != want {
+		t.Errorf("scale_by_ave() returned wrong length: Got %v Want %v", got, want)
+	}
+	for i, want := range []float64{0.5, 1.0, 1e100, 1.5} {
+		if got := traces[0].Values[i]; !near(got, want) {
+			t.Errorf("Distance mismatch: Got %v Want %v", got, want)
+		}
+	}
+
+	// A trace with an average of 0 is left unchanged.
+	traces, err = ctx.Eval(`scale_by_ave(filter("config=gpu"))`)
+	if err != nil {
+		t.Fatalf("Failed to eval scale_by_ave() test: %s", err)
+	}
+	for i, want := range []float64{0, 0, 1e100, 0} {
+		if got := traces[0].Values[i]; !near(got, want) {
+			t.Errorf("Distance mismatch: Got %v Want %v", got, want)
+		}
+	}
+}
+
+func TestNewFuncsEvalErrors(t *testing.T) {
+	ctx := newTestContext()
+
+	testCases := []string{
+		`shortcut()`,
+		`shortcut(2)`,
+		`shortcut(filter(""))`,
+		`step()`,
+		`step(2)`,
+		`step(filter(""), "foo")`,
+		`step(filter(""), 1.5)`,
+		`step(filter(""), 0)`,
+		`step(filter(""), -2)`,
+		`iqrr()`,
+		`iqrr("foo")`,
+		`scale_by_ave()`,
+		`scale_by_ave(2)`,
+	}
+	for _, tc := range testCases {
+		_, err := ctx.Eval(tc)
+		if err == nil {
+			t.Fatalf("Expected %q to fail parsing:", tc)
+		}
+	}
+}
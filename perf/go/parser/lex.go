@@ -201,11 +201,12 @@ func lexNumber(l *lexer) stateFn {
 	return lexExp
 }
 
-// lexIdentifier parses function names.
+// lexIdentifier parses function names, which may contain underscores, such
+// as scale_by_ave.
 func lexIdentifier(l *lexer) stateFn {
 	for {
 		r := l.next()
-		if !unicode.IsLetter(rune(r)) && !unicode.IsDigit(rune(r)) {
+		if !unicode.IsLetter(rune(r)) && !unicode.IsDigit(rune(r)) && r != '_' {
 			l.backUp()
 			break
 		}
@@ -29,6 +29,16 @@ func TestLex(t *testing.T) {
 				item{itemEOF, ""},
 			},
 		},
+		{
+			input: "scale_by_ave(a)",
+			items: []item{
+				item{itemIdentifier, "scale_by_ave"},
+				item{itemLParen, "("},
+				item{itemIdentifier, "a"},
+				item{itemRParen, ")"},
+				item{itemEOF, ""},
+			},
+		},
 		{
 			input: " foo( \"stuff goes here\")",
 			items: []item{
@@ -67,16 +67,20 @@ func NewContext(tile *tiling.Tile) *Context {
 	return &Context{
 		Tile: tile,
 		Funcs: map[string]Func{
-			"filter": filterFunc,
-			"norm":   normFunc,
-			"fill":   fillFunc,
-			"ave":    aveFunc,
-			"avg":    aveFunc,
-			"count":  countFunc,
-			"ratio":  ratioFunc,
-			"sum":    sumFunc,
-			"geo":    geoFunc,
-			"log":    logFunc,
+			"filter":       filterFunc,
+			"norm":         normFunc,
+			"fill":         fillFunc,
+			"ave":          aveFunc,
+			"avg":          aveFunc,
+			"count":        countFunc,
+			"ratio":        ratioFunc,
+			"sum":          sumFunc,
+			"geo":          geoFunc,
+			"log":          logFunc,
+			"shortcut":     shortcutFunc,
+			"step":         stepFunc,
+			"iqrr":         iqrrFunc,
+			"scale_by_ave": scaleByAveFunc,
 		},
 	}
 }
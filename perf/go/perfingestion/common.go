@@ -1,29 +1,37 @@
 package perfingestion
 
 import (
+	"encoding/binary"
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"sort"
 	"strings"
 
 	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/query"
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/util"
 	"go.skia.org/infra/perf/go/types"
+	"go.skia.org/infra/perf/go/vec"
 )
 
 const (
 	// Configuration option that identifies the address of the traceDB service.
 	CONFIG_TRACESERVICE = "TraceService"
+
+	// Configuration option that identifies the directory of the PTraceStore.
+	// If present, ingested data is also written to the PTraceStore.
+	CONFIG_PTRACESTORE_DIR = "PTraceStoreDir"
 )
 
 // BenchResult represents a single test result.
 //
 // Used in BenchData.
 //
-// Expected to be a map of strings to float64s, with the
-// exception of the "options" entry which should be a
+// Expected to be a map of strings to float64s, or to arrays of float64
+// samples, with the exception of the "options" entry which should be a
 // map[string]string.
 type BenchResult map[string]interface{}
 
@@ -58,7 +66,32 @@ func (b *BenchData) keyPrefix() string {
 	return strings.Join(retval, ":")
 }
 
+// sampleStats returns the statistics of the samples that are stored as
+// sibling traces, keyed by the value of the types.STAT_KEY param.
+func sampleStats(samples []float64) map[string]float64 {
+	sorted := make([]float64, len(samples))
+	copy(sorted, samples)
+	sort.Float64s(sorted)
+	n := len(sorted)
+	median := sorted[n/2]
+	if n%2 == 0 {
+		median = (sorted[n/2-1] + sorted[n/2]) / 2
+	}
+	_, stddev, _ := vec.MeanAndStdDev(sorted)
+	return map[string]float64{
+		types.STAT_MIN:    sorted[0],
+		types.STAT_MEDIAN: median,
+		types.STAT_MAX:    sorted[n-1],
+		types.STAT_STDDEV: stddev,
+		types.STAT_COUNT:  float64(n),
+	}
+}
+
 // getTraceDBEntries returns a map of tracedb.Entry instances.
+//
+// A result that is an array of samples is stored as one trace per statistic
+// of the samples, see sampleStats. Those traces have a types.STAT_KEY param
+// and their trace ids end in ":<stat>".
 func (b *BenchData) getTraceDBEntries() map[string]*tracedb.Entry {
 	ret := make(map[string]*tracedb.Entry, len(b.Results))
 	keyPrefix := b.keyPrefix()
@@ -92,21 +125,40 @@ func (b *BenchData) getTraceDBEntries() map[string]*tracedb.Entry {
 					continue
 				}
 
-				floatVal, ok := vi.(float64)
-				if !ok {
-					glog.Errorf("Found a non-float64 in %s", key)
-					continue
-				}
-
 				params["sub_result"] = k
 				perResultKey := key
 				if k != "min_ms" {
 					perResultKey = fmt.Sprintf("%s:%s", perResultKey, k)
 				}
-				paramsCopy := util.CopyStringMap(params)
-				ret[perResultKey] = &tracedb.Entry{
-					Params: paramsCopy,
-					Value:  types.BytesFromFloat64(floatVal),
+
+				switch v := vi.(type) {
+				case float64:
+					paramsCopy := util.CopyStringMap(params)
+					ret[perResultKey] = &tracedb.Entry{
+						Params: paramsCopy,
+						Value:  types.BytesFromFloat64(v),
+					}
+				case []interface{}:
+					samples := make([]float64, 0, len(v))
+					for _, si := range v {
+						if f, ok := si.(float64); ok {
+							samples = append(samples, f)
+						}
+					}
+					if len(samples) != len(v) || len(samples) == 0 {
+						glog.Errorf("Found an invalid array of samples in %s", perResultKey)
+						continue
+					}
+					for stat, value := range sampleStats(samples) {
+						paramsCopy := util.CopyStringMap(params)
+						paramsCopy[types.STAT_KEY] = stat
+						ret[fmt.Sprintf("%s:%s", perResultKey, stat)] = &tracedb.Entry{
+							Params: paramsCopy,
+							Value:  types.BytesFromFloat64(value),
+						}
+					}
+				default:
+					glog.Errorf("Found a non-float64 in %s", key)
 				}
 			}
 		}
@@ -114,6 +166,52 @@ func (b *BenchData) getTraceDBEntries() map[string]*tracedb.Entry {
 	return ret
 }
 
+// getPTraceStoreEntries returns the values in BenchData keyed by structured
+// trace ids, as used by ptracestore. Any chars in the params that are not
+// valid in a structured key are replaced. Replacing chars can make distinct
+// params collide, e.g. "a b" and "a/b" both become "a_b". Traces whose params
+// collide with each other, or whose param names collide within the trace, are
+// logged and skipped, so that they never overwrite each other's values.
+func (b *BenchData) getPTraceStoreEntries() map[string]float32 {
+	entries := b.getTraceDBEntries()
+	ret := make(map[string]float32, len(entries))
+	// sources[key] are the trace ids whose params map to the structured key.
+	sources := map[string][]string{}
+	for traceID, entry := range entries {
+		params := query.ForceValid(entry.Params)
+		if len(params) != numNonEmptyParams(entry.Params) {
+			glog.Errorf("Skipping %s, whose param names collide once made valid: %v", traceID, entry.Params)
+			continue
+		}
+		key, err := query.MakeKey(params)
+		if err != nil {
+			glog.Errorf("Failed to make structured key for %s: %s", traceID, err)
+			continue
+		}
+		sources[key] = append(sources[key], traceID)
+		ret[key] = float32(math.Float64frombits(binary.LittleEndian.Uint64(entry.Value)))
+	}
+	for key, traceIDs := range sources {
+		if len(traceIDs) > 1 {
+			sort.Strings(traceIDs)
+			glog.Errorf("Skipping %v, which all map to the structured key %s", traceIDs, key)
+			delete(ret, key)
+		}
+	}
+	return ret
+}
+
+// numNonEmptyParams returns the number of params that query.ForceValid keeps.
+func numNonEmptyParams(params map[string]string) int {
+	n := 0
+	for k, v := range params {
+		if k != "" && v != "" {
+			n++
+		}
+	}
+	return n
+}
+
 // parseBenchDataFromReader parses the stream out of the io.ReadCloser
 // into BenchData and closes the reader.
 func parseBenchDataFromReader(r io.ReadCloser) (*BenchData, error) {
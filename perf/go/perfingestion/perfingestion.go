@@ -9,6 +9,7 @@ import (
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/vcsinfo"
 	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/ptracestore"
 	"go.skia.org/infra/perf/go/types"
 )
 
@@ -21,6 +22,9 @@ func init() {
 type perfProcessor struct {
 	traceDB tracedb.DB
 	vcs     vcsinfo.VCS
+
+	// store is nil unless CONFIG_PTRACESTORE_DIR is configured.
+	store ptracestore.PTraceStore
 }
 
 // newPerfProcessor implements the ingestion.Constructor signature.
@@ -30,9 +34,15 @@ func newPerfProcessor(vcs vcsinfo.VCS, config *sharedconfig.IngesterConfig, clie
 		return nil, err
 	}
 
+	var store ptracestore.PTraceStore
+	if dir := config.ExtraParams[CONFIG_PTRACESTORE_DIR]; dir != "" {
+		store = ptracestore.New(dir)
+	}
+
 	return &perfProcessor{
 		traceDB: traceDB,
 		vcs:     vcs,
+		store:   store,
 	}, nil
 }
 
@@ -65,7 +75,24 @@ func (p *perfProcessor) Process(resultsFile ingestion.ResultFileLocation) error
 	}
 
 	// Add the column to the trace db.
-	return p.traceDB.Add(cid, benchData.getTraceDBEntries())
+	if err := p.traceDB.Add(cid, benchData.getTraceDBEntries()); err != nil {
+		return err
+	}
+
+	if p.store != nil {
+		index, err := p.vcs.IndexOf(commit.Hash)
+		if err != nil {
+			return err
+		}
+		pcid := &ptracestore.CommitID{
+			Offset: index,
+			Source: "master",
+		}
+		if err := p.store.Add(pcid, benchData.getPTraceStoreEntries(), resultsFile.Name()); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // See ingestion.Processor interface.
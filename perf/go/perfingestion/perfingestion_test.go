@@ -2,18 +2,23 @@ package perfingestion
 
 import (
 	"encoding/binary"
+	"io/ioutil"
 	"math"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	assert "github.com/stretchr/testify/require"
 	"go.skia.org/infra/go/ingestion"
+	"go.skia.org/infra/go/query"
 	"go.skia.org/infra/go/sharedconfig"
 	"go.skia.org/infra/go/testutils"
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/vcsinfo"
+	"go.skia.org/infra/perf/go/ptracestore"
 	"go.skia.org/infra/perf/go/types"
 )
 
@@ -90,6 +95,89 @@ func TestBenchData(t *testing.T) {
 	}
 }
 
+// Tests that arrays of samples are stored as sibling traces of statistics.
+func TestBenchDataSamples(t *testing.T) {
+	r := ioutil.NopCloser(strings.NewReader(`{
+    "gitHash": "fe4a4029a080bc955e9588d05a6cd9eb490845d4",
+    "key": {
+        "arch": "x86"
+    },
+    "results": {
+        "desk_nytimes.skp": {
+            "8888": {
+                "min_ms": 1.5,
+                "samples": [3, 1, 4, 1, 5, 9],
+                "bad": ["foo"],
+                "options": {
+                    "source_type": "skp"
+                }
+            }
+        }
+    }
+}`))
+	benchData, err := parseBenchDataFromReader(r)
+	assert.NoError(t, err)
+
+	entries := benchData.getTraceDBEntries()
+	assert.Equal(t, 6, len(entries))
+	assert.Equal(t, "", entries["x86:desk_nytimes.skp:8888"].Params[types.STAT_KEY])
+
+	expected := map[string]float64{
+		types.STAT_MIN:    1,
+		types.STAT_MEDIAN: 3.5,
+		types.STAT_MAX:    9,
+		types.STAT_STDDEV: math.Sqrt(133.0/6.0 - (23.0/6.0)*(23.0/6.0)),
+		types.STAT_COUNT:  6,
+	}
+	for stat, value := range expected {
+		found, ok := entries["x86:desk_nytimes.skp:8888:samples:"+stat]
+		assert.True(t, ok)
+		assert.InDelta(t, value, math.Float64frombits(binary.LittleEndian.Uint64(found.Value)), 0.0001)
+		assert.Equal(t, stat, found.Params[types.STAT_KEY])
+		assert.Equal(t, "samples", found.Params["sub_result"])
+		assert.Equal(t, "skp", found.Params["source_type"])
+	}
+}
+
+// Tests that traces whose params collide once they are made valid are skipped
+// instead of overwriting each other in ptracestore.
+func TestPTraceStoreEntriesCollisions(t *testing.T) {
+	r := ioutil.NopCloser(strings.NewReader(`{
+    "gitHash": "fe4a4029a080bc955e9588d05a6cd9eb490845d4",
+    "key": {
+        "arch": "x86"
+    },
+    "results": {
+        "foo": {
+            "a b": {"min_ms": 1},
+            "a/b": {"min_ms": 2},
+            "8888": {"min_ms": 3}
+        },
+        "bar": {
+            "8888": {
+                "min_ms": 4,
+                "options": {
+                    "x y": "1",
+                    "x/y": "2"
+                }
+            }
+        }
+    }
+}`))
+	benchData, err := parseBenchDataFromReader(r)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, len(benchData.getTraceDBEntries()))
+
+	key, err := query.MakeKey(map[string]string{
+		"arch":       "x86",
+		"config":     "8888",
+		"sub_result": "min_ms",
+		"test":       "foo",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float32{key: 3}, benchData.getPTraceStoreEntries())
+}
+
 // Tests the processor in conjunction with the vcs.
 func TestPerfProcessor(t *testing.T) {
 
@@ -99,9 +187,14 @@ func TestPerfProcessor(t *testing.T) {
 	defer server.Stop()
 	defer testutils.Remove(t, TRACE_DB_FILENAME)
 
+	ptraceDir, err := ioutil.TempDir("", "ptracestore")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, ptraceDir)
+
 	ingesterConf := &sharedconfig.IngesterConfig{
 		ExtraParams: map[string]string{
-			CONFIG_TRACESERVICE: serverAddr,
+			CONFIG_TRACESERVICE:    serverAddr,
+			CONFIG_PTRACESTORE_DIR: ptraceDir,
 		},
 	}
 
@@ -146,4 +239,26 @@ func TestPerfProcessor(t *testing.T) {
 	}
 
 	assert.NoError(t, traceDB.Close())
+
+	// The same values are also in the PTraceStore, keyed by structured keys.
+	store := processor.(*perfProcessor).store
+	pcommitIDs := []*ptracestore.CommitID{
+		&ptracestore.CommitID{
+			Offset: 0,
+			Source: "master",
+		},
+	}
+	traceSet, err := store.Match(pcommitIDs, query.New(url.Values{}))
+	assert.NoError(t, err)
+	assert.Equal(t, len(TEST_ENTRIES), len(traceSet))
+	traceSet, err = store.Match(pcommitIDs, query.New(url.Values{
+		"config":     []string{"565"},
+		"test":       []string{"DeferredSurfaceCopy_discardable_640_480"},
+		"sub_result": []string{"min_ms"},
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(traceSet))
+	for _, trace := range traceSet {
+		assert.Equal(t, float32(2.215988), trace[0])
+	}
 }
@@ -12,6 +12,7 @@ import (
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/vcsinfo"
 	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/trybot"
 	"go.skia.org/infra/perf/go/types"
 )
 
@@ -82,7 +83,7 @@ func (p *perfTrybotProcessor) Process(resultsFile ingestion.ResultFileLocation)
 		}
 		p.cache[cacheId] = ts
 	}
-	source := fmt.Sprintf("%s/%d", CODE_REVIEW_URL, issue)
+	source := trybot.Source(int64(issue))
 
 	cid := &tracedb.CommitID{
 		Timestamp: ts.Unix(),
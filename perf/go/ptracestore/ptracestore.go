@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"path/filepath"
 	"regexp"
+	"sort"
 	"sync"
 	"time"
 
@@ -91,6 +92,16 @@ type PTraceStore interface {
 	// The returned TraceSet will contain a slice of Trace, and that list will be
 	// empty if there are no matches.
 	Match(commitIDs []*CommitID, q query.Query) (TraceSet, error)
+
+	// TraceIDs returns the sorted ids of all the traces that match the given
+	// Query and have at least one value stored in the tiles of the given
+	// CommitIDs. No values are loaded.
+	TraceIDs(commitIDs []*CommitID, q query.Query) ([]string, error)
+
+	// MatchTraceIDs is the same as Match, but returns only the traces whose
+	// ids appear in traceIDs. Only those traces are read, so the cost depends
+	// on len(traceIDs) and not on the number of traces in the tiles.
+	MatchTraceIDs(commitIDs []*CommitID, traceIDs []string) (TraceSet, error)
 }
 
 // BoltTraceStore is an implementation of PTraceStore that uses BoltDB.
@@ -395,10 +406,11 @@ func buildMapper(commitIDs []*CommitID) map[string]*tileMap {
 	return mapper
 }
 
-// loadMatches loads values into 'traceSet' that match the query 'q' from the
-// tile in the BoltDB 'db'.  Only values at the offsets in 'idxmap' are
-// actually loaded, and 'idxmap' determines where they are stored in the Trace.
-func loadMatches(db *bolt.DB, idxmap map[int]int, q query.Query, traceSet TraceSet, traceLen int) error {
+// loadMatches loads values into 'traceSet' for the trace ids that 'matches'
+// returns true for from the tile in the BoltDB 'db'.  Only values at the
+// offsets in 'idxmap' are actually loaded, and 'idxmap' determines where they
+// are stored in the Trace.
+func loadMatches(db *bolt.DB, idxmap map[int]int, matches func(string) bool, traceSet TraceSet, traceLen int) error {
 	get := func(tx *bolt.Tx) error {
 		bucket := tx.Bucket([]byte(TRACE_VALUES_BUCKET_NAME))
 		if bucket == nil {
@@ -407,33 +419,82 @@ func loadMatches(db *bolt.DB, idxmap map[int]int, q query.Query, traceSet TraceS
 			return nil
 		}
 		v := bucket.Cursor()
-		value := traceValue{}
 		// Loop over the entire bucket.
 		for btraceid, rawValues := v.First(); btraceid != nil; btraceid, rawValues = v.Next() {
 			traceid := string(btraceid)
-			// Does the trace id match the query?
-			if !q.Matches(traceid) {
+			// Does the trace id match?
+			if !matches(traceid) {
 				continue
 			}
 
-			// Get the trace.
-			trace := traceSet[traceid]
-			if trace == nil {
-				traceSet[traceid] = NewTrace(traceLen)
-				trace = traceSet[traceid]
+			addValues(traceSet, traceid, rawValues, idxmap, traceLen)
+		}
+		return nil
+	}
+
+	return db.View(get)
+}
+
+// loadTraceIDValues is the same as loadMatches, but only loads the traces
+// with the given ids, which are looked up directly instead of visiting every
+// trace in the tile.
+func loadTraceIDValues(db *bolt.DB, idxmap map[int]int, traceIDs []string, traceSet TraceSet, traceLen int) error {
+	get := func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(TRACE_VALUES_BUCKET_NAME))
+		if bucket == nil {
+			return nil
+		}
+		for _, traceid := range traceIDs {
+			rawValues := bucket.Get([]byte(traceid))
+			if rawValues == nil {
+				continue
 			}
+			addValues(traceSet, traceid, rawValues, idxmap, traceLen)
+		}
+		return nil
+	}
+
+	return db.View(get)
+}
+
+// addValues decodes all the [index, float32] pairs in 'rawValues' and stores
+// the ones at the offsets in 'idxmap' in the trace with the given id in
+// 'traceSet', which is created if needed.
+func addValues(traceSet TraceSet, traceid string, rawValues []byte, idxmap map[int]int, traceLen int) {
+	// Get the trace.
+	trace := traceSet[traceid]
+	if trace == nil {
+		traceSet[traceid] = NewTrace(traceLen)
+		trace = traceSet[traceid]
+	}
 
-			// Decode all the [index, float32] pairs stored for the trace.
-			buf := bytes.NewBuffer(rawValues)
-			for {
-				if err := binary.Read(buf, binary.LittleEndian, &value); err != nil {
-					break
-				}
-				// Store the value in trace if the index appears in idxmap.
-				if offset, ok := idxmap[int(value.Index)]; ok {
-					trace[offset] = value.Value
-					// Don't break, we want the last value for index.
-				}
+	value := traceValue{}
+	buf := bytes.NewBuffer(rawValues)
+	for {
+		if err := binary.Read(buf, binary.LittleEndian, &value); err != nil {
+			break
+		}
+		// Store the value in trace if the index appears in idxmap.
+		if offset, ok := idxmap[int(value.Index)]; ok {
+			trace[offset] = value.Value
+			// Don't break, we want the last value for index.
+		}
+	}
+}
+
+// loadTraceIDs adds the ids of all the traces in the tile in the BoltDB 'db'
+// that match the query 'q' to 'traceIDs'.
+func loadTraceIDs(db *bolt.DB, q query.Query, traceIDs util.StringSet) error {
+	get := func(tx *bolt.Tx) error {
+		bucket := tx.Bucket([]byte(TRACE_VALUES_BUCKET_NAME))
+		if bucket == nil {
+			return nil
+		}
+		v := bucket.Cursor()
+		for btraceid, _ := v.First(); btraceid != nil; btraceid, _ = v.Next() {
+			traceid := string(btraceid)
+			if q.Matches(traceid) {
+				traceIDs[traceid] = true
 			}
 		}
 		return nil
@@ -443,6 +504,25 @@ func loadMatches(db *bolt.DB, idxmap map[int]int, q query.Query, traceSet TraceS
 }
 
 func (b *BoltTraceStore) Match(commitIDs []*CommitID, q query.Query) (TraceSet, error) {
+	return b.matchFunc(commitIDs, q.Matches)
+}
+
+func (b *BoltTraceStore) MatchTraceIDs(commitIDs []*CommitID, traceIDs []string) (TraceSet, error) {
+	ret := TraceSet{}
+	for _, tm := range buildMapper(commitIDs) {
+		db, err := b.getBoltDB(tm.commitID)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to open datastore: %s", err)
+		}
+		if err := loadTraceIDValues(db, tm.idxmap, traceIDs, ret, len(commitIDs)); err != nil {
+			return nil, fmt.Errorf("Failed to load traces from %s: %s", tm.commitID.Filename(), err)
+		}
+	}
+	return ret, nil
+}
+
+// matchFunc implements Match.
+func (b *BoltTraceStore) matchFunc(commitIDs []*CommitID, matches func(string) bool) (TraceSet, error) {
 	ret := TraceSet{}
 	mapper := buildMapper(commitIDs)
 	for _, tm := range mapper {
@@ -450,12 +530,28 @@ func (b *BoltTraceStore) Match(commitIDs []*CommitID, q query.Query) (TraceSet,
 		if err != nil {
 			return nil, fmt.Errorf("Unable to open datastore: %s", err)
 		}
-		if err := loadMatches(db, tm.idxmap, q, ret, len(commitIDs)); err != nil {
+		if err := loadMatches(db, tm.idxmap, matches, ret, len(commitIDs)); err != nil {
 			return nil, fmt.Errorf("Failed to load traces from %s: %s", tm.commitID.Filename(), err)
 		}
 	}
 	return ret, nil
 }
 
+func (b *BoltTraceStore) TraceIDs(commitIDs []*CommitID, q query.Query) ([]string, error) {
+	ids := util.StringSet{}
+	for _, tm := range buildMapper(commitIDs) {
+		db, err := b.getBoltDB(tm.commitID)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to open datastore: %s", err)
+		}
+		if err := loadTraceIDs(db, q, ids); err != nil {
+			return nil, fmt.Errorf("Failed to load trace ids from %s: %s", tm.commitID.Filename(), err)
+		}
+	}
+	ret := ids.Keys()
+	sort.Strings(ret)
+	return ret, nil
+}
+
 // Ensure that *BoltTraceStore implements PTraceStore.
 var _ PTraceStore = &BoltTraceStore{}
@@ -260,3 +260,49 @@ func TestMatch(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 0, len(traces))
 }
+
+func TestTraceIDs(t *testing.T) {
+	setupStoreDir(t)
+	defer cleanup()
+
+	d := New(tmpDir)
+	commitID1 := &CommitID{
+		Offset: 1,
+		Source: "master",
+	}
+	err := d.Add(commitID1, map[string]float32{
+		",config=565,test=foo,":  1.23,
+		",config=8888,test=foo,": 3.21,
+	}, "gs://foo")
+	assert.NoError(t, err)
+
+	// A trace that only appears in the second tile.
+	commitID2 := &CommitID{
+		Offset: COMMITS_PER_TILE + 3,
+		Source: "master",
+	}
+	err = d.Add(commitID2, map[string]float32{
+		",config=565,test=foo,": 2.34,
+		",config=gpu,test=foo,": 4.56,
+	}, "gs://foo")
+	assert.NoError(t, err)
+
+	commits := []*CommitID{commitID1, commitID2}
+	ids, err := d.TraceIDs(commits, query.New(url.Values{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{",config=565,test=foo,", ",config=8888,test=foo,", ",config=gpu,test=foo,"}, ids)
+
+	ids, err = d.TraceIDs(commits, query.New(url.Values{"config": []string{"!565"}}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{",config=8888,test=foo,", ",config=gpu,test=foo,"}, ids)
+
+	ids, err = d.TraceIDs(commits[:1], query.New(url.Values{}))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{",config=565,test=foo,", ",config=8888,test=foo,"}, ids)
+
+	traces, err := d.MatchTraceIDs(commits, []string{",config=565,test=foo,", ",config=gpu,test=foo,", ",config=pdf,"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(traces))
+	assert.Equal(t, Trace{1.23, 2.34}, traces[",config=565,test=foo,"])
+	assert.Equal(t, Trace{MISSING_VALUE, 4.56}, traces[",config=gpu,test=foo,"])
+}
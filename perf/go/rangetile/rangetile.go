@@ -0,0 +1,104 @@
+// rangetile builds tiling.Tiles over arbitrary ranges of commits from the
+// data in a ptracestore.PTraceStore, as opposed to the fixed size tiles built
+// by tracedb.MasterTileBuilder.
+package rangetile
+
+import (
+	"fmt"
+	"sort"
+
+	"go.skia.org/infra/go/query"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/go/vcsinfo"
+	"go.skia.org/infra/perf/go/ptracestore"
+	"go.skia.org/infra/perf/go/types"
+)
+
+// pages splits the commitIDs into runs of consecutive entries that are each
+// stored in a single ptracestore tile, so that each call to Match only needs
+// to open a single tile.
+func pages(commitIDs []*ptracestore.CommitID) [][]*ptracestore.CommitID {
+	ret := [][]*ptracestore.CommitID{}
+	start := 0
+	for i := 1; i <= len(commitIDs); i++ {
+		if i == len(commitIDs) || commitIDs[i].Filename() != commitIDs[start].Filename() {
+			ret = append(ret, commitIDs[start:i])
+			start = i
+		}
+	}
+	return ret
+}
+
+// New returns a tiling.Tile of *types.PerfTrace for the given commits, which
+// must be ordered from oldest to newest, that contains only the traces that
+// match the query. The commits may span any number of ptracestore tiles,
+// which are loaded one at a time. The keys of the traces are structured keys,
+// see the go/query package.
+//
+// If maxValues is greater than 0 then an error is returned as soon as the
+// tile would hold more than maxValues values, i.e. traces times commits.
+func New(store ptracestore.PTraceStore, source string, commits []*vcsinfo.IndexCommit, q query.Query, maxValues int) (*tiling.Tile, error) {
+	n := len(commits)
+	tile := &tiling.Tile{
+		Traces:   map[string]tiling.Trace{},
+		ParamSet: map[string][]string{},
+		Commits:  make([]*tiling.Commit, n),
+	}
+	commitIDs := make([]*ptracestore.CommitID, n)
+	for i, c := range commits {
+		tile.Commits[i] = &tiling.Commit{
+			CommitTime: c.Timestamp.Unix(),
+			Hash:       c.Hash,
+		}
+		commitIDs[i] = &ptracestore.CommitID{
+			Offset: c.Index,
+			Source: source,
+		}
+	}
+
+	offset := 0
+	for _, page := range pages(commitIDs) {
+		traceSet, err := store.Match(page, q)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load traces: %s", err)
+		}
+		for key, trace := range traceSet {
+			tr, ok := tile.Traces[key]
+			if !ok {
+				params, err := query.ParseKey(key)
+				if err != nil {
+					return nil, fmt.Errorf("Found an invalid trace id: %s", err)
+				}
+				tr = types.NewPerfTraceN(n)
+				for k, v := range params {
+					tr.Params()[k] = v
+				}
+				tile.Traces[key] = tr
+				if maxValues > 0 && len(tile.Traces)*n > maxValues {
+					return nil, fmt.Errorf("Too many values, more than %d, in the range; choose a shorter range or a narrower query.", maxValues)
+				}
+			}
+			// Missing values are left as config.MISSING_DATA_SENTINEL.
+			values := tr.(*types.PerfTrace).Values
+			for i, v := range trace {
+				if v != ptracestore.MISSING_VALUE {
+					values[offset+i] = float64(v)
+				}
+			}
+		}
+		offset += len(page)
+	}
+
+	for _, tr := range tile.Traces {
+		for k, v := range tr.Params() {
+			if !util.In(v, tile.ParamSet[k]) {
+				tile.ParamSet[k] = append(tile.ParamSet[k], v)
+			}
+		}
+	}
+	for _, values := range tile.ParamSet {
+		sort.Strings(values)
+	}
+	return tile, nil
+}
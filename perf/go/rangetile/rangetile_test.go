@@ -0,0 +1,80 @@
+package rangetile
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"testing"
+	"time"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/query"
+	"go.skia.org/infra/go/testutils"
+	"go.skia.org/infra/go/vcsinfo"
+	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/ptracestore"
+	"go.skia.org/infra/perf/go/types"
+)
+
+func TestPages(t *testing.T) {
+	ids := []*ptracestore.CommitID{}
+	for _, offset := range []int{48, 49, 50, 51, 120} {
+		ids = append(ids, &ptracestore.CommitID{Offset: offset, Source: "master"})
+	}
+	p := pages(ids)
+	assert.Equal(t, 3, len(p))
+	assert.Equal(t, ids[0:2], p[0])
+	assert.Equal(t, ids[2:4], p[1])
+	assert.Equal(t, ids[4:5], p[2])
+	assert.Equal(t, 0, len(pages([]*ptracestore.CommitID{})))
+}
+
+func TestNew(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rangetile")
+	assert.NoError(t, err)
+	defer testutils.RemoveAll(t, dir)
+	store := ptracestore.New(dir)
+
+	// Write values at commits that span three ptracestore tiles.
+	offsets := []int{ptracestore.COMMITS_PER_TILE - 1, ptracestore.COMMITS_PER_TILE, 2*ptracestore.COMMITS_PER_TILE + 3}
+	commits := []*vcsinfo.IndexCommit{}
+	for i, offset := range offsets {
+		commits = append(commits, &vcsinfo.IndexCommit{
+			Hash:      fmt.Sprintf("hash%d", i),
+			Index:     offset,
+			Timestamp: time.Unix(int64(1000+i), 0),
+		})
+		values := map[string]float32{
+			",config=8888,test=foo,": float32(i),
+		}
+		// The 565 trace is missing a value at the middle commit.
+		if i != 1 {
+			values[",config=565,test=foo,"] = float32(10 + i)
+		}
+		assert.NoError(t, store.Add(&ptracestore.CommitID{Offset: offset, Source: "master"}, values, "gs://bucket/file.json"))
+	}
+
+	tile, err := New(store, "master", commits, query.New(url.Values{"test": []string{"foo"}}), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, len(tile.Commits))
+	assert.Equal(t, "hash2", tile.Commits[2].Hash)
+	assert.Equal(t, int64(1002), tile.Commits[2].CommitTime)
+	assert.Equal(t, 2, len(tile.Traces))
+	assert.Equal(t, []float64{0, 1, 2}, tile.Traces[",config=8888,test=foo,"].(*types.PerfTrace).Values)
+	tr565 := tile.Traces[",config=565,test=foo,"].(*types.PerfTrace)
+	assert.Equal(t, []float64{10, config.MISSING_DATA_SENTINEL, 12}, tr565.Values)
+	assert.Equal(t, map[string]string{"config": "565", "test": "foo"}, tr565.Params())
+	assert.Equal(t, []string{"565", "8888"}, tile.ParamSet["config"])
+
+	// Only matching traces are loaded.
+	tile, err = New(store, "master", commits, query.New(url.Values{"config": []string{"565"}}), 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(tile.Traces))
+
+	// The number of values can be limited.
+	_, err = New(store, "master", commits, query.New(url.Values{}), 5)
+	assert.Error(t, err)
+	tile, err = New(store, "master", commits, query.New(url.Values{}), 6)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(tile.Traces))
+}
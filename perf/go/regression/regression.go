@@ -0,0 +1,304 @@
+// regression handles storing and triaging regressions, which are the
+// clusters found by an alert at a single commit.
+package regression
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+
+	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/perf/go/db"
+	"go.skia.org/infra/perf/go/types"
+)
+
+const (
+	// Triage states.
+	UNTRIAGED = "untriaged"
+	POSITIVE  = "positive"
+	NEGATIVE  = "negative"
+
+	// Directions of a step. A step down has a positive StepFit.Regression
+	// and a step up has a negative StepFit.Regression.
+	UP   = "up"
+	DOWN = "down"
+
+	// BUG_PENDING is the bug id of a direction while a bug is being filed
+	// for it, see ClaimBug.
+	BUG_PENDING = -1
+)
+
+// STATUSES is the list of all valid values of TriageStatus.Status.
+var STATUSES = []string{UNTRIAGED, POSITIVE, NEGATIVE}
+
+// TriageStatus is the triage state of one direction of a Regression.
+type TriageStatus struct {
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	User    string `json:"user"`
+}
+
+// Regression is the regressions found by a single alert at a single commit.
+// Steps in each direction are kept and triaged separately.
+type Regression struct {
+	// Up is the step up cluster, i.e. the one with a negative
+	// StepFit.Regression, or nil if there is none.
+	Up *types.ClusterSummary `json:"up"`
+
+	// Down is the step down cluster, i.e. the one with a positive
+	// StepFit.Regression, or nil if there is none.
+	Down *types.ClusterSummary `json:"down"`
+
+	UpStatus   TriageStatus `json:"up_status"`
+	DownStatus TriageStatus `json:"down_status"`
+
+	// UpBug and DownBug are the ids of the bugs filed for each direction, or
+	// 0 if no bug has been filed.
+	UpBug   int64 `json:"up_bug"`
+	DownBug int64 `json:"down_bug"`
+}
+
+// New returns a new, untriaged Regression with no clusters.
+func New() *Regression {
+	return &Regression{
+		UpStatus:   TriageStatus{Status: UNTRIAGED},
+		DownStatus: TriageStatus{Status: UNTRIAGED},
+	}
+}
+
+// SetCluster adds the cluster to the Regression as either Up or Down,
+// depending on the direction of its step. If the Regression already has a
+// cluster in that direction it is only replaced if the new cluster has a
+// larger StepFit.Regression magnitude. The triage status is unchanged. Only
+// the centroid of the cluster is kept. Returns true iff the Regression was
+// modified.
+func (r *Regression) SetCluster(c *types.ClusterSummary) bool {
+	cp := *c
+	if len(cp.Traces) > 1 {
+		cp.Traces = cp.Traces[:1]
+	}
+	target := &r.Up
+	if c.StepFit.Regression > 0 {
+		target = &r.Down
+	}
+	if *target != nil && math.Abs((*target).StepFit.Regression) >= math.Abs(c.StepFit.Regression) {
+		return false
+	}
+	*target = &cp
+	return true
+}
+
+// Triage sets the triage status of the given direction, UP or DOWN.
+func (r *Regression) Triage(direction string, tr TriageStatus) error {
+	if !util.In(tr.Status, STATUSES) {
+		return fmt.Errorf("Invalid triage status %q; must be one of %v", tr.Status, STATUSES)
+	}
+	switch direction {
+	case UP:
+		if r.Up == nil {
+			return fmt.Errorf("No step up cluster to triage.")
+		}
+		r.UpStatus = tr
+	case DOWN:
+		if r.Down == nil {
+			return fmt.Errorf("No step down cluster to triage.")
+		}
+		r.DownStatus = tr
+	default:
+		return fmt.Errorf("Invalid direction %q; must be %q or %q", direction, UP, DOWN)
+	}
+	return nil
+}
+
+// Bug returns the id of the bug filed for the given direction, UP or DOWN, or
+// 0 if there is none.
+func (r *Regression) Bug(direction string) int64 {
+	if direction == UP {
+		return r.UpBug
+	}
+	return r.DownBug
+}
+
+// SetBug records the id of the bug filed for the given direction, UP or DOWN.
+func (r *Regression) SetBug(direction string, bug int64) error {
+	switch direction {
+	case UP:
+		r.UpBug = bug
+	case DOWN:
+		r.DownBug = bug
+	default:
+		return fmt.Errorf("Invalid direction %q; must be %q or %q", direction, UP, DOWN)
+	}
+	return nil
+}
+
+// ClaimBug marks that a bug is being filed for the given direction, UP or
+// DOWN, by setting its bug id to BUG_PENDING. It returns false if a bug has
+// already been filed or claimed for that direction.
+func (r *Regression) ClaimBug(direction string) (bool, error) {
+	cluster := r.Down
+	if direction == UP {
+		cluster = r.Up
+	} else if direction != DOWN {
+		return false, fmt.Errorf("Invalid direction %q; must be %q or %q", direction, UP, DOWN)
+	}
+	if cluster == nil {
+		return false, fmt.Errorf("No %s cluster to file a bug for.", direction)
+	}
+	if r.Bug(direction) != 0 {
+		return false, nil
+	}
+	return true, r.SetBug(direction, BUG_PENDING)
+}
+
+// update applies f to the Regression for the given commit and alert in a
+// transaction and writes the result back to the database. If there is no
+// Regression and create is true then f is applied to a new Regression at the
+// given timestamp, otherwise an error is returned.
+func update(hash string, ts int64, alertID int64, create bool, f func(*Regression) (bool, error)) (err error) {
+	tx, err := db.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("Failed to start transaction: %s", err)
+	}
+	defer func() {
+		if err != nil {
+			if rbErr := tx.Rollback(); rbErr != nil {
+				err = fmt.Errorf("%s; and failed to roll back: %s", err, rbErr)
+			}
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	r := New()
+	var body string
+	exists := true
+	if err := tx.QueryRow("SELECT regression FROM regressions WHERE hash=? AND alert_id=? FOR UPDATE", hash, alertID).Scan(&body); err == sql.ErrNoRows {
+		if !create {
+			return fmt.Errorf("Failed to find regression for %s and alert %d", hash, alertID)
+		}
+		exists = false
+	} else if err != nil {
+		return fmt.Errorf("Failed to read from database: %s", err)
+	} else if err := json.Unmarshal([]byte(body), r); err != nil {
+		return fmt.Errorf("Found invalid JSON in regressions table: %s", err)
+	}
+
+	modified, err := f(r)
+	if err != nil || !modified {
+		return err
+	}
+	b, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("Failed to encode to JSON: %s", err)
+	}
+	if exists {
+		_, err = tx.Exec("UPDATE regressions SET regression=? WHERE hash=? AND alert_id=?", string(b), hash, alertID)
+	} else {
+		_, err = tx.Exec("INSERT INTO regressions (hash, alert_id, ts, regression) VALUES (?, ?, ?, ?)", hash, alertID, ts, string(b))
+	}
+	if err != nil {
+		return fmt.Errorf("Failed to write to database: %s", err)
+	}
+	return nil
+}
+
+// SetCluster records the cluster found by the given alert at the commit of
+// the cluster's step, creating the Regression if needed. See
+// Regression.SetCluster.
+func SetCluster(alertID int64, c *types.ClusterSummary) error {
+	return update(c.Hash, c.Timestamp, alertID, true, func(r *Regression) (bool, error) {
+		return r.SetCluster(c), nil
+	})
+}
+
+// Triage sets the triage status of the given direction, UP or DOWN, of the
+// Regression found by the given alert at the given commit.
+func Triage(hash string, alertID int64, direction string, tr TriageStatus) error {
+	return update(hash, 0, alertID, false, func(r *Regression) (bool, error) {
+		return true, r.Triage(direction, tr)
+	})
+}
+
+// SetBug records the id of the bug filed for the given direction, UP or DOWN,
+// of the Regression found by the given alert at the given commit.
+func SetBug(hash string, alertID int64, direction string, bug int64) error {
+	return update(hash, 0, alertID, false, func(r *Regression) (bool, error) {
+		return true, r.SetBug(direction, bug)
+	})
+}
+
+// ClaimBug claims filing the bug for the given direction, UP or DOWN, of the
+// Regression found by the given alert at the given commit, see
+// Regression.ClaimBug. The check and the claim are done in a single
+// transaction, so only one caller succeeds. It returns the Regression and
+// whether the claim succeeded. The claim must be followed by either SetBug or
+// ReleaseBug.
+func ClaimBug(hash string, alertID int64, direction string) (*Regression, bool, error) {
+	var reg *Regression
+	claimed := false
+	err := update(hash, 0, alertID, false, func(r *Regression) (bool, error) {
+		var err error
+		reg = r
+		claimed, err = r.ClaimBug(direction)
+		return claimed, err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return reg, claimed, nil
+}
+
+// ReleaseBug removes the claim made by ClaimBug if no bug has been recorded
+// since, so that filing the bug can be retried.
+func ReleaseBug(hash string, alertID int64, direction string) error {
+	return update(hash, 0, alertID, false, func(r *Regression) (bool, error) {
+		if r.Bug(direction) != BUG_PENDING {
+			return false, nil
+		}
+		return true, r.SetBug(direction, 0)
+	})
+}
+
+// Get returns the Regression found by the given alert at the given commit.
+func Get(hash string, alertID int64) (*Regression, error) {
+	var body string
+	if err := db.DB.QueryRow("SELECT regression FROM regressions WHERE hash=? AND alert_id=?", hash, alertID).Scan(&body); err != nil {
+		return nil, fmt.Errorf("Failed to find regression for %s and alert %d: %s", hash, alertID, err)
+	}
+	r := New()
+	if err := json.Unmarshal([]byte(body), r); err != nil {
+		return nil, fmt.Errorf("Found invalid JSON in regressions table: %s", err)
+	}
+	return r, nil
+}
+
+// Range returns all the Regressions at commits with timestamps in
+// [begin, end), keyed by commit hash and then by alert ID.
+func Range(begin, end int64) (map[string]map[int64]*Regression, error) {
+	rows, err := db.DB.Query("SELECT hash, alert_id, regression FROM regressions WHERE ts>=? AND ts<?", begin, end)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read from database: %s", err)
+	}
+	defer util.Close(rows)
+
+	ret := map[string]map[int64]*Regression{}
+	for rows.Next() {
+		var hash string
+		var alertID int64
+		var body string
+		if err := rows.Scan(&hash, &alertID, &body); err != nil {
+			return nil, fmt.Errorf("Failed to read row from database: %s", err)
+		}
+		r := New()
+		if err := json.Unmarshal([]byte(body), r); err != nil {
+			return nil, fmt.Errorf("Found invalid JSON in regressions table: %s", err)
+		}
+		if _, ok := ret[hash]; !ok {
+			ret[hash] = map[int64]*Regression{}
+		}
+		ret[hash][alertID] = r
+	}
+	return ret, nil
+}
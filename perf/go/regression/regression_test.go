@@ -0,0 +1,94 @@
+package regression
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/perf/go/types"
+)
+
+func newCluster(regression float64) *types.ClusterSummary {
+	c := types.NewClusterSummary(1, 2)
+	c.Keys[0] = ",config=8888,"
+	c.Traces[0] = [][]float64{{0, 1}}
+	c.Traces[1] = [][]float64{{0, 2}}
+	c.StepFit.Regression = regression
+	c.Hash = "abc"
+	return c
+}
+
+func TestSetCluster(t *testing.T) {
+	r := New()
+	assert.Equal(t, UNTRIAGED, r.UpStatus.Status)
+	assert.Equal(t, UNTRIAGED, r.DownStatus.Status)
+
+	// A positive StepFit.Regression is a step down, a negative one a step up.
+	assert.True(t, r.SetCluster(newCluster(200)))
+	assert.Nil(t, r.Up)
+	assert.Equal(t, 200.0, r.Down.StepFit.Regression)
+	assert.Equal(t, 1, len(r.Down.Traces))
+	assert.True(t, r.SetCluster(newCluster(-300)))
+	assert.Equal(t, -300.0, r.Up.StepFit.Regression)
+
+	// Weaker clusters don't replace stronger ones.
+	assert.False(t, r.SetCluster(newCluster(150)))
+	assert.Equal(t, 200.0, r.Down.StepFit.Regression)
+	assert.True(t, r.SetCluster(newCluster(250)))
+	assert.Equal(t, 250.0, r.Down.StepFit.Regression)
+}
+
+func TestTriage(t *testing.T) {
+	r := New()
+	tr := TriageStatus{Status: NEGATIVE, Message: "Real regression.", User: "someone@example.com"}
+
+	// Can't triage a direction with no cluster.
+	assert.Error(t, r.Triage(DOWN, tr))
+	r.SetCluster(newCluster(200))
+	assert.NoError(t, r.Triage(DOWN, tr))
+	assert.Equal(t, tr, r.DownStatus)
+	assert.Equal(t, UNTRIAGED, r.UpStatus.Status)
+
+	// Triage status survives a stronger cluster.
+	r.SetCluster(newCluster(500))
+	assert.Equal(t, tr, r.DownStatus)
+
+	// Invalid inputs.
+	assert.Error(t, r.Triage("sideways", tr))
+	assert.Error(t, r.Triage(DOWN, TriageStatus{Status: "bogus"}))
+}
+
+func TestSetBug(t *testing.T) {
+	r := New()
+	assert.Equal(t, int64(0), r.Bug(DOWN))
+	assert.NoError(t, r.SetBug(DOWN, 123))
+	assert.NoError(t, r.SetBug(UP, 456))
+	assert.Equal(t, int64(123), r.Bug(DOWN))
+	assert.Equal(t, int64(456), r.Bug(UP))
+	assert.Error(t, r.SetBug("sideways", 789))
+}
+
+func TestClaimBug(t *testing.T) {
+	r := New()
+	r.SetCluster(newCluster(200))
+
+	// No step up cluster.
+	_, err := r.ClaimBug(UP)
+	assert.Error(t, err)
+	_, err = r.ClaimBug("sideways")
+	assert.Error(t, err)
+
+	claimed, err := r.ClaimBug(DOWN)
+	assert.NoError(t, err)
+	assert.True(t, claimed)
+	assert.Equal(t, int64(BUG_PENDING), r.Bug(DOWN))
+
+	// Claimed, or already filed, bugs can't be claimed again.
+	claimed, err = r.ClaimBug(DOWN)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+	assert.NoError(t, r.SetBug(DOWN, 123))
+	claimed, err = r.ClaimBug(DOWN)
+	assert.NoError(t, err)
+	assert.False(t, claimed)
+	assert.Equal(t, int64(123), r.Bug(DOWN))
+}
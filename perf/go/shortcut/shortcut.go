@@ -7,6 +7,8 @@ import (
 	"io"
 	"io/ioutil"
 
+	"go.skia.org/infra/go/query"
+	"go.skia.org/infra/go/tiling"
 	"go.skia.org/infra/perf/go/db"
 )
 
@@ -18,6 +20,55 @@ type Shortcut struct {
 	Issue string   `json:"issue"`
 }
 
+// StructuredKey returns the structured key, see go/query, of a trace with the
+// given params. It's the key the trace has in a ptracestore.PTraceStore.
+func StructuredKey(params map[string]string) (string, error) {
+	return query.MakeKey(query.ForceValid(params))
+}
+
+// Normalize replaces the keys of the shortcut that are traces in the tile with
+// their structured keys, so that the shortcut can be resolved against tiles
+// built from either the trace db or a ptracestore. Other keys, such as
+// formula ids, are kept as is.
+func (s *Shortcut) Normalize(tile *tiling.Tile) {
+	for i, key := range s.Keys {
+		tr, ok := tile.Traces[key]
+		if !ok {
+			continue
+		}
+		if structured, err := StructuredKey(tr.Params()); err == nil {
+			s.Keys[i] = structured
+		}
+	}
+}
+
+// Resolve returns the keys of the shortcut as keys of the traces in the tile.
+// A key is either a key in the tile or the structured key of one of its
+// traces, see Normalize. Keys that don't resolve are returned as is, so
+// formula ids can still be recognized.
+func (s *Shortcut) Resolve(tile *tiling.Tile) []string {
+	ret := make([]string, len(s.Keys))
+	var byStructuredKey map[string]string
+	for i, key := range s.Keys {
+		ret[i] = key
+		if _, ok := tile.Traces[key]; ok {
+			continue
+		}
+		if byStructuredKey == nil {
+			byStructuredKey = make(map[string]string, len(tile.Traces))
+			for tileKey, tr := range tile.Traces {
+				if structured, err := StructuredKey(tr.Params()); err == nil {
+					byStructuredKey[structured] = tileKey
+				}
+			}
+		}
+		if tileKey, ok := byStructuredKey[key]; ok {
+			ret[i] = tileKey
+		}
+	}
+	return ret
+}
+
 // Insert adds the shortcut content into the database. The id of the shortcut
 // is returned.
 func Insert(r io.Reader) (string, error) {
@@ -0,0 +1,30 @@
+package shortcut
+
+import (
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/perf/go/types"
+)
+
+func TestNormalizeAndResolve(t *testing.T) {
+	tile := tiling.NewTile()
+	tr := types.NewPerfTraceN(3)
+	tr.Params_["arch"] = "x86"
+	tr.Params_["config"] = "8888"
+	tile.Traces["x86:8888"] = tr
+
+	sh := &Shortcut{
+		Keys: []string{"x86:8888", "x86:565", "@filter(\"config=8888\")"},
+	}
+	sh.Normalize(tile)
+	assert.Equal(t, []string{",arch=x86,config=8888,", "x86:565", "@filter(\"config=8888\")"}, sh.Keys)
+
+	// Structured keys resolve against tiles keyed by trace db ids, and against
+	// tiles keyed by structured keys.
+	assert.Equal(t, []string{"x86:8888", "x86:565", "@filter(\"config=8888\")"}, sh.Resolve(tile))
+	rangeTile := tiling.NewTile()
+	rangeTile.Traces[",arch=x86,config=8888,"] = tr
+	assert.Equal(t, sh.Keys, sh.Resolve(rangeTile))
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -18,28 +19,38 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/skia-dev/glog"
+	"go.skia.org/infra/go/auth"
 	"go.skia.org/infra/go/common"
 	"go.skia.org/infra/go/eventbus"
 	"go.skia.org/infra/go/gitinfo"
 	"go.skia.org/infra/go/httputils"
 	"go.skia.org/infra/go/human"
 	"go.skia.org/infra/go/influxdb"
+	"go.skia.org/infra/go/issues"
 	"go.skia.org/infra/go/login"
+	"go.skia.org/infra/go/query"
 	"go.skia.org/infra/go/rietveld"
 	"go.skia.org/infra/go/tiling"
 	tracedb "go.skia.org/infra/go/trace/db"
 	"go.skia.org/infra/go/util"
+	"go.skia.org/infra/go/vcsinfo"
 	"go.skia.org/infra/perf/go/activitylog"
 	"go.skia.org/infra/perf/go/alerting"
+	"go.skia.org/infra/perf/go/alerts"
 	"go.skia.org/infra/perf/go/annotate"
 	"go.skia.org/infra/perf/go/clustering"
 	"go.skia.org/infra/perf/go/config"
 	idb "go.skia.org/infra/perf/go/db"
+	"go.skia.org/infra/perf/go/export"
 	"go.skia.org/infra/perf/go/parser"
+	"go.skia.org/infra/perf/go/ptracestore"
 	"go.skia.org/infra/perf/go/quartiles"
+	"go.skia.org/infra/perf/go/rangetile"
+	"go.skia.org/infra/perf/go/regression"
 	"go.skia.org/infra/perf/go/shortcut"
 	"go.skia.org/infra/perf/go/stats"
 	"go.skia.org/infra/perf/go/tilestats"
+	"go.skia.org/infra/perf/go/trybot"
 	"go.skia.org/infra/perf/go/types"
 	"go.skia.org/infra/perf/go/vec"
 )
@@ -49,6 +60,13 @@ var (
 	BEGINNING_OF_TIME = time.Date(2014, time.June, 18, 0, 0, 0, 0, time.UTC)
 )
 
+const (
+	// MAX_ALL_VALUES is the maximum number of values, i.e. traces times
+	// commits, that are loaded from the PTraceStore for requests that need
+	// all the traces in a range, such as /calc/.
+	MAX_ALL_VALUES = 20 * 1000 * 1000
+)
+
 var (
 	// indexTemplate is the main index.html page we serve.
 	indexTemplate *template.Template = nil
@@ -84,6 +102,9 @@ var (
 
 	activityHandlerPath = regexp.MustCompile(`/activitylog/([0-9]*)$`)
 
+	// The capture group is the alert ID.
+	alertDeleteHandlerPath = regexp.MustCompile(`/_/alerts/([0-9]+)/delete$`)
+
 	git *gitinfo.GitInfo = nil
 
 	commitLinkifyRe = regexp.MustCompile("(?m)^commit (.*)$")
@@ -91,17 +112,20 @@ var (
 
 // flags
 var (
-	gitRepoDir     = flag.String("git_repo_dir", "../../../skia", "Directory location for the Skia repo.")
-	gitRepoURL     = flag.String("git_repo_url", "https://skia.googlesource.com/skia", "The URL to pass to git clone for the source repository.")
-	influxDatabase = flag.String("influxdb_database", influxdb.DEFAULT_DATABASE, "The InfluxDB database.")
-	influxHost     = flag.String("influxdb_host", influxdb.DEFAULT_HOST, "The InfluxDB hostname.")
-	influxPassword = flag.String("influxdb_password", influxdb.DEFAULT_PASSWORD, "The InfluxDB password.")
-	influxUser     = flag.String("influxdb_name", influxdb.DEFAULT_USER, "The InfluxDB username.")
-	local          = flag.Bool("local", false, "Running locally if true. As opposed to in production.")
-	port           = flag.String("port", ":8000", "HTTP service address (e.g., ':8000')")
-	resourcesDir   = flag.String("resources_dir", "", "The directory to find templates, JS, and CSS files. If blank the current directory will be used.")
-	tileSize       = flag.Int("tile_size", 100, "The size of Tiles.")
-	traceservice   = flag.String("trace_service", "localhost:9090", "The address of the traceservice endpoint.")
+	gitRepoDir      = flag.String("git_repo_dir", "../../../skia", "Directory location for the Skia repo.")
+	gitRepoURL      = flag.String("git_repo_url", "https://skia.googlesource.com/skia", "The URL to pass to git clone for the source repository.")
+	influxDatabase  = flag.String("influxdb_database", influxdb.DEFAULT_DATABASE, "The InfluxDB database.")
+	influxHost      = flag.String("influxdb_host", influxdb.DEFAULT_HOST, "The InfluxDB hostname.")
+	influxPassword  = flag.String("influxdb_password", influxdb.DEFAULT_PASSWORD, "The InfluxDB password.")
+	influxUser      = flag.String("influxdb_name", influxdb.DEFAULT_USER, "The InfluxDB username.")
+	issueTrackerTyp = flag.String("issue_tracker", "monorail", "The issue tracker to use for bugs, either 'monorail', 'local', or '' for none.")
+	local           = flag.Bool("local", false, "Running locally if true. As opposed to in production.")
+	localIssuesFile = flag.String("local_issues_file", "issues.json", "The file the 'local' issue tracker stores issues in.")
+	port            = flag.String("port", ":8000", "HTTP service address (e.g., ':8000')")
+	ptraceStoreDir  = flag.String("ptrace_store_dir", "", "The directory of the PTraceStore. If set, queries with begin and end parameters are served from it.")
+	resourcesDir    = flag.String("resources_dir", "", "The directory to find templates, JS, and CSS files. If blank the current directory will be used.")
+	tileSize        = flag.Int("tile_size", 100, "The size of Tiles.")
+	traceservice    = flag.String("trace_service", "localhost:9090", "The address of the traceservice endpoint.")
 )
 
 var (
@@ -111,6 +135,14 @@ var (
 
 	branchTileBuilder tracedb.BranchTileBuilder
 
+	rietveldAPI *rietveld.Rietveld
+
+	// issueTracker is nil if no issue tracker is configured.
+	issueTracker issues.IssueTracker
+
+	// ptraceStore is nil unless --ptrace_store_dir is set.
+	ptraceStore ptracestore.PTraceStore
+
 	templates *template.Template
 
 	tileStats *tilestats.TileStats
@@ -172,8 +204,83 @@ func Init() {
 		glog.Fatalf("Failed to build trace/db.DB: %s", err)
 	}
 
-	rietveldAPI := rietveld.New(rietveld.RIETVELD_SKIA_URL, httputils.NewTimeoutClient())
+	rietveldAPI = rietveld.New(rietveld.RIETVELD_SKIA_URL, httputils.NewTimeoutClient())
 	branchTileBuilder = tracedb.NewBranchTileBuilder(db, git, rietveldAPI, evt)
+
+	if *ptraceStoreDir != "" {
+		ptraceStore = ptracestore.New(*ptraceStoreDir)
+	}
+
+	switch *issueTrackerTyp {
+	case "monorail":
+		client, err := auth.NewDefaultJWTServiceAccountClient("https://www.googleapis.com/auth/userinfo.email")
+		if err != nil {
+			glog.Errorf("Not updating bugs, not able to construct an authenticated client: %s", err)
+		} else {
+			issueTracker = issues.NewMonorailIssueTracker(client)
+		}
+	case "local":
+		issueTracker = issues.NewLocalIssueTracker(*localIssuesFile)
+	case "":
+	default:
+		glog.Fatalf("Unknown --issue_tracker: %q", *issueTrackerTyp)
+	}
+}
+
+// commitsForRequest returns the commits to use when serving the request.
+//
+// If the request has begin and end query parameters, which are Unix
+// timestamps, and --ptrace_store_dir is set, then all the commits in
+// [begin, end) are returned. Otherwise nil is returned, which means the
+// current master tile should be used.
+//
+// The begin and end query parameters are removed from r.Form so that they
+// aren't treated as trace params.
+func commitsForRequest(r *http.Request) ([]*vcsinfo.IndexCommit, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, fmt.Errorf("Failed to parse query params: %s", err)
+	}
+	beginStr := r.Form.Get("begin")
+	endStr := r.Form.Get("end")
+	delete(r.Form, "begin")
+	delete(r.Form, "end")
+	if beginStr == "" || endStr == "" || ptraceStore == nil {
+		return nil, nil
+	}
+	begin, err := strconv.ParseInt(beginStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid value for begin: %s", err)
+	}
+	end, err := strconv.ParseInt(endStr, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid value for end: %s", err)
+	}
+	commits := git.Range(time.Unix(begin, 0), time.Unix(end, 0))
+	if len(commits) == 0 {
+		return nil, fmt.Errorf("No commits found in the range [%d, %d).", begin, end)
+	}
+	return commits, nil
+}
+
+// tileForRequest returns the tile to use when serving the request.
+//
+// If commitsForRequest returns a range of commits then the tile is built from
+// the PTraceStore over those commits. If all is false the tile only contains
+// the traces that match the rest of the query parameters, otherwise it
+// contains all traces, up to MAX_ALL_VALUES values. If there is no range of
+// commits then the current master tile is returned.
+func tileForRequest(r *http.Request, all bool) (*tiling.Tile, error) {
+	commits, err := commitsForRequest(r)
+	if err != nil {
+		return nil, err
+	}
+	if commits == nil {
+		return masterTileBuilder.GetTile(), nil
+	}
+	if all {
+		return rangetile.New(ptraceStore, "master", commits, query.New(url.Values{}), MAX_ALL_VALUES)
+	}
+	return rangetile.New(ptraceStore, "master", commits, query.New(r.Form), 0)
 }
 
 // showcutHandler handles the POST requests of the shortcut page.
@@ -184,15 +291,19 @@ func Init() {
 //       "scale": 0,
 //       "tiles": [-1],
 //       "hash": "a1092123890...",
-//       "ids": [
-//            "x86:...",
-//            "x86:...",
+//       "keys": [
 //            "x86:...",
+//            ",arch=x86,config=8888,...",
+//            ...
 //       ]
 //    }
 //
 // hash - The git hash of where a step was detected. Can be null.
 //
+// Keys of traces in the current master tile are stored as structured keys,
+// so the shortcut also resolves against tiles built from the PTraceStore. See
+// shortcut.Normalize.
+//
 func shortcutHandler(w http.ResponseWriter, r *http.Request) {
 	// TODO(jcgregorio): Add unit tests.
 	match := shortcutHandlerPath.FindStringSubmatch(r.URL.Path)
@@ -207,7 +318,18 @@ func shortcutHandler(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 		defer util.Close(r.Body)
-		id, err := shortcut.Insert(r.Body)
+		sh := &shortcut.Shortcut{}
+		if err := json.NewDecoder(r.Body).Decode(sh); err != nil {
+			httputils.ReportError(w, r, err, "Unable to decode shortcut.")
+			return
+		}
+		sh.Normalize(masterTileBuilder.GetTile())
+		b, err := json.Marshal(sh)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Unable to encode shortcut.")
+			return
+		}
+		id, err := shortcut.Insert(bytes.NewReader(b))
 		if err != nil {
 			httputils.ReportError(w, r, err, "Error inserting shortcut.")
 			return
@@ -258,6 +380,271 @@ func alertResetHandler(w http.ResponseWriter, r *http.Request) {
 	http.Redirect(w, r, "/alerts/", 303)
 }
 
+// alertsHandler lists the alert configurations on GET, and creates or updates
+// an alert configuration on POST.
+//
+// The POST body is a JSON serialized alerts.Config. If its id is -1 then a new
+// Config is created. If owner is empty it defaults to the logged in user. The
+// response is the stored Config.
+//
+// The GET response is a JSON list of alerts.Config.
+func alertsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	switch r.Method {
+	case "GET":
+		cfgs, err := alerts.List()
+		if err != nil {
+			httputils.ReportError(w, r, err, "Failed to retrieve alerts.")
+			return
+		}
+		if err := json.NewEncoder(w).Encode(cfgs); err != nil {
+			glog.Errorf("Failed to write or encode output: %s", err)
+		}
+	case "POST":
+		user := login.LoggedInAs(r)
+		if user == "" {
+			httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to change an alert.")
+			return
+		}
+		cfg := alerts.NewConfig()
+		if err := json.NewDecoder(r.Body).Decode(cfg); err != nil {
+			httputils.ReportError(w, r, err, "Failed to decode alert.")
+			return
+		}
+		if cfg.Owner == "" {
+			cfg.Owner = user
+		}
+		if err := alerts.Write(cfg); err != nil {
+			httputils.ReportError(w, r, err, "Failed to write alert.")
+			return
+		}
+		if err := json.NewEncoder(w).Encode(cfg); err != nil {
+			glog.Errorf("Failed to write or encode output: %s", err)
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// alertNewHandler returns a JSON serialized alerts.Config populated with
+// default values, for use as the starting point of a new alert.
+func alertNewHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	cfg := alerts.NewConfig()
+	cfg.Owner = login.LoggedInAs(r)
+	if err := json.NewEncoder(w).Encode(cfg); err != nil {
+		glog.Errorf("Failed to write or encode output: %s", err)
+	}
+}
+
+// alertDeleteHandler deletes the alert configuration with the given ID.
+func alertDeleteHandler(w http.ResponseWriter, r *http.Request) {
+	if login.LoggedInAs(r) == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to delete an alert.")
+		return
+	}
+	match := alertDeleteHandlerPath.FindStringSubmatch(r.URL.Path)
+	if r.Method != "POST" || match == nil || len(match) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+	id, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed parsing ID.")
+		return
+	}
+	if err := alerts.Delete(id); err != nil {
+		httputils.ReportError(w, r, err, "Failed to delete alert.")
+		return
+	}
+}
+
+// RegressionRow is one row of the regression grid, the Regressions found at a
+// single commit. Columns has one entry per alert in the header, which is nil
+// if that alert found no Regression at the commit.
+type RegressionRow struct {
+	Commit  *tiling.Commit           `json:"commit"`
+	Columns []*regression.Regression `json:"columns"`
+}
+
+// RegressionGridResponse is the response of regGridHandler.
+type RegressionGridResponse struct {
+	Header []*alerts.Config `json:"header"`
+	Table  []*RegressionRow `json:"table"`
+}
+
+// regGridHandler returns a grid of commits by alerts, where each cell is the
+// Regression found by that alert at that commit.
+//
+// The optional query parameters begin and end are Unix timestamps and select
+// the commits in [begin, end) from the git repo. They default to the range of
+// the current tile.
+// The response is a JSON serialized RegressionGridResponse, with the most
+// recent commits first.
+func regGridHandler(w http.ResponseWriter, r *http.Request) {
+	tile := masterTileBuilder.GetTile()
+	begin := tile.Commits[0].CommitTime
+	end := tile.Commits[tile.LastCommitIndex()].CommitTime + 1
+	var err error
+	if s := r.FormValue("begin"); s != "" {
+		if begin, err = strconv.ParseInt(s, 10, 64); err != nil {
+			httputils.ReportError(w, r, err, "Invalid value for begin.")
+			return
+		}
+	}
+	if s := r.FormValue("end"); s != "" {
+		if end, err = strconv.ParseInt(s, 10, 64); err != nil {
+			httputils.ReportError(w, r, err, "Invalid value for end.")
+			return
+		}
+	}
+	cfgs, err := alerts.List()
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to retrieve alerts.")
+		return
+	}
+	regressions, err := regression.Range(begin, end)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to retrieve regressions.")
+		return
+	}
+	// Use the commits from git, not the tile, so that regressions outside of
+	// the current tile are included.
+	commits := git.Range(time.Unix(begin, 0), time.Unix(end, 0))
+	resp := RegressionGridResponse{
+		Header: cfgs,
+		Table:  make([]*RegressionRow, 0, len(commits)),
+	}
+	for i := len(commits) - 1; i >= 0; i-- {
+		c := commits[i]
+		details, err := git.Details(c.Hash, false)
+		if err != nil {
+			httputils.ReportError(w, r, err, "Failed to retrieve commit details.")
+			return
+		}
+		row := &RegressionRow{
+			Commit: &tiling.Commit{
+				CommitTime: c.Timestamp.Unix(),
+				Hash:       c.Hash,
+				Author:     details.Author,
+			},
+			Columns: make([]*regression.Regression, len(cfgs)),
+		}
+		for j, cfg := range cfgs {
+			row.Columns[j] = regressions[c.Hash][cfg.ID]
+		}
+		resp.Table = append(resp.Table, row)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		glog.Errorf("Failed to write or encode output: %s", err)
+	}
+}
+
+// TriageRequest is the body of a request to triageHandler.
+type TriageRequest struct {
+	Hash      string                  `json:"hash"`
+	AlertID   int64                   `json:"alert_id"`
+	Direction string                  `json:"direction"`
+	Triage    regression.TriageStatus `json:"triage"`
+}
+
+// triageHandler sets the triage status of one direction of a Regression.
+//
+// The POST body is a JSON serialized TriageRequest. The user in the
+// TriageStatus is always set to the logged in user. If the Regression is
+// triaged as negative, and an issue tracker is configured, then a bug is
+// filed for it, see alerting.FileBug.
+func triageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.NotFound(w, r)
+		return
+	}
+	user := login.LoggedInAs(r)
+	if user == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to triage.")
+		return
+	}
+	req := &TriageRequest{}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		httputils.ReportError(w, r, err, "Failed to decode triage request.")
+		return
+	}
+	req.Triage.User = user
+	if err := regression.Triage(req.Hash, req.AlertID, req.Direction, req.Triage); err != nil {
+		httputils.ReportError(w, r, err, "Failed to triage.")
+		return
+	}
+	if req.Triage.Status == regression.NEGATIVE && issueTracker != nil {
+		if err := alerting.FileBug(issueTracker, req.Hash, req.AlertID, req.Direction); err != nil {
+			httputils.ReportError(w, r, err, "Triaged, but failed to file a bug.")
+			return
+		}
+	}
+}
+
+// TrybotResponse is the response of trybotHandler.
+type TrybotResponse struct {
+	Issue    int64                `json:"issue"`
+	PatchSet int64                `json:"patchset"`
+	N        int                  `json:"n"`
+	Deltas   []*trybot.TraceDelta `json:"deltas"`
+}
+
+// trybotHandler compares the trybot results of a single patchset against the
+// baseline of each trace on master.
+//
+// Takes the following query parameters:
+//
+//   issue    - The Rietveld issue id.
+//   patchset - The patchset id.
+//   n        - The number of most recent master commits the baseline is
+//              calculated over. Defaults to trybot.DEFAULT_N.
+//
+// The response is a JSON serialized TrybotResponse, where the deltas are
+// ordered from most to least significant. See trybot.Compare.
+func trybotHandler(w http.ResponseWriter, r *http.Request) {
+	issue, err := strconv.ParseInt(r.FormValue("issue"), 10, 64)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid value for issue.")
+		return
+	}
+	patchset, err := strconv.ParseInt(r.FormValue("patchset"), 10, 64)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid value for patchset.")
+		return
+	}
+	n := trybot.DEFAULT_N
+	if s := r.FormValue("n"); s != "" {
+		num, err := strconv.ParseInt(s, 10, 32)
+		if err != nil || num <= 0 {
+			httputils.ReportError(w, r, fmt.Errorf("Invalid n: %q", s), "Invalid value for n.")
+			return
+		}
+		n = int(num)
+	}
+	cid, err := trybot.CommitID(rietveldAPI, issue, patchset)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to find patchset.")
+		return
+	}
+	try, err := branchTileBuilder.CachedTileFromCommits([]*tracedb.CommitID{cid})
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to load trybot results.")
+		return
+	}
+	resp := TrybotResponse{
+		Issue:    issue,
+		PatchSet: patchset,
+		N:        n,
+		Deltas:   trybot.Compare(try, masterTileBuilder.GetTile(), n, tileStats),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		glog.Errorf("Failed to write or encode output: %s", err)
+	}
+}
+
 // clHandler serves the HTML for the /cl/<id> page.
 //
 // These are shortcuts to individual clusters.
@@ -450,7 +837,6 @@ func perCommitJSONHandler(w http.ResponseWriter, r *http.Request) {
 // sk.Query.selectionsAsQuery().
 func clusteringHandler(w http.ResponseWriter, r *http.Request) {
 	glog.Infof("Clustering Handler: %q\n", r.URL.Path)
-	tile := masterTileBuilder.GetTile()
 	w.Header().Set("Content-Type", "application/json")
 	// If there are no query parameters just return with an empty set of ClusterSummaries.
 	if r.FormValue("_k") == "" || r.FormValue("_stddev") == "" {
@@ -473,13 +859,19 @@ func clusteringHandler(w http.ResponseWriter, r *http.Request) {
 	delete(r.Form, "_stddev")
 	delete(r.Form, "_issue")
 
+	tile, err := tileForRequest(r, false)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to load traces.")
+		return
+	}
+
 	// Create a filter function for traces that match the query parameters and
 	// optionally tryResults.
 	filter := func(key string, tr *types.PerfTrace) bool {
 		return tiling.Matches(tr, r.Form)
 	}
 
-	summary, err := clustering.CalculateClusterSummaries(tile, int(k), stddev, filter)
+	summary, err := clustering.CalculateClusterSummaries(tile, int(k), stddev, config.MIN_CLUSTER_STEP_COMMITS, filter)
 	if err != nil {
 		httputils.ReportError(w, r, err, "Failed to calculate clusters.")
 		return
@@ -649,7 +1041,9 @@ type FlatQueryResponse struct {
 //  }
 //
 //
-// TODO Add ability to query across a range of tiles.
+// If begin and end query parameters are supplied, and --ptrace_store_dir is
+// set, then the traces are loaded from the PTraceStore for the commits in
+// [begin, end). See tileForRequest.
 func queryHandler(w http.ResponseWriter, r *http.Request) {
 	glog.Infof("Query Handler: %q\n", r.URL.Path)
 	match := queryHandlerPath.FindStringSubmatch(r.URL.Path)
@@ -658,8 +1052,10 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 		http.NotFound(w, r)
 		return
 	}
-	if err := r.ParseForm(); err != nil {
-		httputils.ReportError(w, r, err, "Failed to parse query params.")
+	tile, err := tileForRequest(r, false)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to load traces.")
+		return
 	}
 	tileScale, err := strconv.ParseInt(match[1], 10, 0)
 	if err != nil {
@@ -672,7 +1068,6 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	glog.Infof("tile: %d %d", tileScale, tileNumber)
-	tile := masterTileBuilder.GetTile()
 	w.Header().Set("Content-Type", "application/json")
 	ret := &QueryResponse{
 		Traces: []*tiling.TraceGUI{},
@@ -703,7 +1098,7 @@ func queryHandler(w http.ResponseWriter, r *http.Request) {
 			}
 
 			ret.Hash = sh.Hash
-			for _, k := range sh.Keys {
+			for _, k := range sh.Resolve(tile) {
 				if tr, ok := tile.Traces[k]; ok {
 					tg := traceGuiFromTrace(tr.(*types.PerfTrace), k, tile)
 					if tg != nil {
@@ -830,15 +1225,39 @@ func traceGuiFromTrace(trace *types.PerfTrace, key string, tile *tiling.Tile) *t
 	}
 	if len(newTraceData) >= 0 {
 		return &tiling.TraceGUI{
-			Data:   newTraceData,
-			Label:  key,
-			Params: trace.Params(),
+			Data:      newTraceData,
+			Label:     key,
+			Params:    trace.Params(),
+			ErrorBand: errorBand(trace, key, tile),
 		}
 	} else {
 		return nil
 	}
 }
 
+// errorBand returns the band of one standard deviation around the given
+// trace, if the trace is the median of a set of samples and the tile contains
+// the sibling trace of the standard deviation of those samples. Otherwise nil
+// is returned.
+func errorBand(trace *types.PerfTrace, key string, tile *tiling.Tile) [][3]float64 {
+	if trace.Params()[types.STAT_KEY] != types.STAT_MEDIAN {
+		return nil
+	}
+	sibling, ok := tile.Traces[types.StatSiblingKey(key, trace.Params(), types.STAT_STDDEV)]
+	if !ok {
+		return nil
+	}
+	stddevs := sibling.(*types.PerfTrace).Values
+	ret := [][3]float64{}
+	for i, v := range trace.Values {
+		sd := stddevs[i]
+		if v != config.MISSING_DATA_SENTINEL && sd != config.MISSING_DATA_SENTINEL && tile.Commits[i] != nil && tile.Commits[i].CommitTime > 0 {
+			ret = append(ret, [3]float64{float64(i), v - sd, v + sd})
+		}
+	}
+	return ret
+}
+
 // addCalculatedTraces adds the traces returned from evaluating the given
 // formula over the given tile to the QueryResponse.
 func addCalculatedTraces(qr *QueryResponse, tile *tiling.Tile, formula string) error {
@@ -889,7 +1308,11 @@ func addFlatCalculatedTraces(qr *FlatQueryResponse, tile *tiling.Tile, formula s
 func calcHandler(w http.ResponseWriter, r *http.Request) {
 	glog.Infof("Calc Handler: %q\n", r.URL.Path)
 	w.Header().Set("Content-Type", "application/json")
-	tile := masterTileBuilder.GetTile()
+	tile, err := tileForRequest(r, true)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Failed to load traces.")
+		return
+	}
 	formula := r.FormValue("formula")
 
 	var data interface{} = nil
@@ -920,6 +1343,105 @@ func calcHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// exportHandler handles requests of the form:
+//
+//    /_/export/?format=csv&config=8888&begin=1460000000&end=1470000000&formula=...
+//
+// and writes one row per trace that matches the query params, with the trace
+// params and a column per commit. The format is either "csv", the default, or
+// "json", which is newline delimited JSON. See the export package.
+//
+// If begin and end are supplied, and --ptrace_store_dir is set, then the
+// traces are streamed from the PTraceStore over the commits in [begin, end)
+// in batches, otherwise the traces come from the current master tile.
+//
+// If formula is supplied then it's evaluated over a tile of the traces that
+// match the query, and the resulting traces are written.
+//
+// The user must be logged in.
+func exportHandler(w http.ResponseWriter, r *http.Request) {
+	glog.Infof("Export Handler: %q\n", r.URL.Path)
+	if login.LoggedInAs(r) == "" {
+		httputils.ReportError(w, r, fmt.Errorf("Not logged in."), "You must be logged in to export data.")
+		return
+	}
+	commits, err := commitsForRequest(r)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid commit range.")
+		return
+	}
+	format := r.Form.Get("format")
+	if format == "" {
+		format = export.FORMAT_CSV
+	}
+	formula := r.Form.Get("formula")
+	delete(r.Form, "format")
+	delete(r.Form, "formula")
+
+	wr, err := export.New(format, w)
+	if err != nil {
+		httputils.ReportError(w, r, err, "Invalid format.")
+		return
+	}
+
+	if formula != "" {
+		var tile *tiling.Tile
+		if commits == nil {
+			tile = matchingTile(masterTileBuilder.GetTile(), r.Form)
+		} else {
+			tile, err = rangetile.New(ptraceStore, "master", commits, query.New(r.Form), 0)
+			if err != nil {
+				httputils.ReportError(w, r, err, "Failed to load traces.")
+				return
+			}
+		}
+		traces, err := parser.NewContext(tile).Eval(formula)
+		if err != nil {
+			httputils.ReportError(w, r, err, fmt.Sprintf("Failed to evaluate formula %q.", formula))
+			return
+		}
+		w.Header().Set("Content-Type", wr.ContentType())
+		if err := export.Traces(wr, tile.Commits, traces); err != nil {
+			glog.Errorf("Failed to write export: %s", err)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", wr.ContentType())
+	if commits == nil {
+		err = export.Tile(wr, masterTileBuilder.GetTile(), r.Form)
+	} else {
+		flush := func() {
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+		err = export.Stream(wr, ptraceStore, "master", commits, query.New(r.Form), export.DEFAULT_BATCH_SIZE, flush)
+	}
+	if err != nil {
+		// The response has already been started, so all we can do is log.
+		glog.Errorf("Failed to write export: %s", err)
+	}
+}
+
+// matchingTile returns a shallow copy of the tile that only contains the
+// traces that match the query.
+func matchingTile(tile *tiling.Tile, q url.Values) *tiling.Tile {
+	ret := &tiling.Tile{
+		Traces:    map[string]tiling.Trace{},
+		ParamSet:  tile.ParamSet,
+		Commits:   tile.Commits,
+		Scale:     tile.Scale,
+		TileIndex: tile.TileIndex,
+	}
+	for key, tr := range tile.Traces {
+		if tiling.Matches(tr, q) {
+			ret.Traces[key] = tr
+		}
+	}
+	return ret
+}
+
 // commitsHandler handles requests for commits.
 //
 // Queries look like:
@@ -1144,7 +1666,7 @@ func main() {
 	}
 
 	stats.Start(masterTileBuilder, git)
-	alerting.Start(masterTileBuilder)
+	alerting.Start(masterTileBuilder, issueTracker)
 
 	var redirectURL = fmt.Sprintf("http://localhost%s/oauth2callback/", *port)
 	if !*local {
@@ -1175,6 +1697,13 @@ func main() {
 	router.HandleFunc("/alerts/", templateHandler("alerting.html"))
 	router.HandleFunc("/alerting/", alertingHandler)
 	router.HandleFunc("/alert_reset/", alertResetHandler)
+	router.HandleFunc("/_/alerts/", alertsHandler)
+	router.HandleFunc("/_/alerts/new", alertNewHandler)
+	router.PathPrefix("/_/alerts/").HandlerFunc(alertDeleteHandler)
+	router.HandleFunc("/_/reg/", regGridHandler)
+	router.HandleFunc("/_/triage/", triageHandler)
+	router.HandleFunc("/_/trybot/", trybotHandler)
+	router.HandleFunc("/_/export/", exportHandler)
 	router.HandleFunc("/annotate/", annotate.Handler)
 	router.HandleFunc("/compare/", templateHandler("compare.html"))
 	router.HandleFunc("/per/", templateHandler("percommit.html"))
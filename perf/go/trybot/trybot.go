@@ -0,0 +1,172 @@
+// trybot compares the results of trybot runs against the recent values of
+// the same traces on master.
+package trybot
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"go.skia.org/infra/go/rietveld"
+	"go.skia.org/infra/go/tiling"
+	tracedb "go.skia.org/infra/go/trace/db"
+	"go.skia.org/infra/perf/go/config"
+	"go.skia.org/infra/perf/go/tilestats"
+	"go.skia.org/infra/perf/go/types"
+	"go.skia.org/infra/perf/go/vec"
+)
+
+const (
+	// DEFAULT_N is the default number of master commits used to calculate
+	// the baseline.
+	DEFAULT_N = 20
+)
+
+// Source returns the tracedb.CommitID Source that trybot results for the
+// given issue are stored under.
+func Source(issue int64) string {
+	return fmt.Sprintf("%s/%d", rietveld.RIETVELD_SKIA_URL, issue)
+}
+
+// CommitID returns the tracedb.CommitID that the trybot results for the given
+// issue and patchset are stored under. The patchset creation time is looked
+// up in Rietveld.
+func CommitID(review *rietveld.Rietveld, issue, patchset int64) (*tracedb.CommitID, error) {
+	patchinfo, err := review.GetPatchset(issue, patchset)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to retrieve trybot patch info: %s", err)
+	}
+	return &tracedb.CommitID{
+		Timestamp: patchinfo.Created.Unix(),
+		ID:        fmt.Sprintf("%d", patchset),
+		Source:    Source(issue),
+	}, nil
+}
+
+// TraceDelta is the comparison of the trybot value of a single trace against
+// the baseline value of that trace on master.
+type TraceDelta struct {
+	Key    string            `json:"key"`
+	Params map[string]string `json:"params"`
+
+	// Value is the trybot value.
+	Value float64 `json:"value"`
+
+	// Baseline is the median of the trace over the last N commits on master.
+	Baseline float64 `json:"baseline"`
+
+	// Delta is Value - Baseline.
+	Delta float64 `json:"delta"`
+
+	// StdDev is the standard deviation of the trace on master.
+	StdDev float64 `json:"stddev"`
+
+	// Sigma is Delta / StdDev, i.e. how significant the delta is. Sigma is
+	// positive if the trybot value is larger than the baseline.
+	Sigma float64 `json:"sigma"`
+}
+
+// Compare compares the trybot value of each trace in 'try' against the median
+// of the last 'n' non-missing values of the same trace in 'master'.
+//
+// The trybot value of a trace is its last non-missing value in 'try'. The
+// standard deviation of each trace is taken from 'stats' if it's non-nil and
+// has stats for the trace, otherwise it's calculated from the values used for
+// the baseline. Standard deviations smaller than config.MIN_STDDEV are
+// clamped to config.MIN_STDDEV.
+//
+// Traces that don't appear in both tiles are ignored. The results are sorted
+// by decreasing absolute Sigma, so the most significant changes come first.
+func Compare(try, master *tiling.Tile, n int, stats *tilestats.TileStats) []*TraceDelta {
+	if stats != nil {
+		stats.RLock()
+		defer stats.RUnLock()
+	}
+	ret := []*TraceDelta{}
+	for key, tr := range try.Traces {
+		value, ok := lastValue(tr.(*types.PerfTrace).Values)
+		if !ok {
+			continue
+		}
+		mtr, ok := master.Traces[key]
+		if !ok {
+			continue
+		}
+		recent := lastN(mtr.(*types.PerfTrace).Values, n)
+		if len(recent) == 0 {
+			continue
+		}
+		baseline := median(recent)
+		_, stddev, _ := vec.MeanAndStdDev(recent)
+		if st, ok := traceStats(stats, key); ok {
+			stddev = st.StdDev
+		}
+		delta := value - baseline
+		ret = append(ret, &TraceDelta{
+			Key:      key,
+			Params:   tr.Params(),
+			Value:    value,
+			Baseline: baseline,
+			Delta:    delta,
+			StdDev:   stddev,
+			Sigma:    delta / math.Max(stddev, config.MIN_STDDEV),
+		})
+	}
+	sort.Sort(traceDeltaSlice(ret))
+	return ret
+}
+
+// traceStats returns the stats for the given trace, if any.
+func traceStats(stats *tilestats.TileStats, key string) (*tilestats.TraceStats, bool) {
+	if stats == nil {
+		return nil, false
+	}
+	return stats.TraceStats(key)
+}
+
+// lastValue returns the last non-missing value in the slice.
+func lastValue(values []float64) (float64, bool) {
+	for i := len(values) - 1; i >= 0; i-- {
+		if values[i] != config.MISSING_DATA_SENTINEL {
+			return values[i], true
+		}
+	}
+	return 0, false
+}
+
+// lastN returns up to the last n non-missing values in the slice, in order.
+func lastN(values []float64, n int) []float64 {
+	ret := []float64{}
+	for i := len(values) - 1; i >= 0 && len(ret) < n; i-- {
+		if values[i] != config.MISSING_DATA_SENTINEL {
+			ret = append([]float64{values[i]}, ret...)
+		}
+	}
+	return ret
+}
+
+// median returns the median of a non-empty slice. The slice is not modified.
+func median(values []float64) float64 {
+	sorted := make([]float64, len(values))
+	copy(sorted, values)
+	sort.Float64s(sorted)
+	l := len(sorted)
+	if l%2 == 1 {
+		return sorted[l/2]
+	}
+	return (sorted[l/2-1] + sorted[l/2]) / 2
+}
+
+// traceDeltaSlice sorts TraceDeltas by decreasing absolute Sigma, and then by
+// Key.
+type traceDeltaSlice []*TraceDelta
+
+func (p traceDeltaSlice) Len() int { return len(p) }
+func (p traceDeltaSlice) Less(i, j int) bool {
+	si, sj := math.Abs(p[i].Sigma), math.Abs(p[j].Sigma)
+	if si != sj {
+		return si > sj
+	}
+	return p[i].Key < p[j].Key
+}
+func (p traceDeltaSlice) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
@@ -0,0 +1,64 @@
+package trybot
+
+import (
+	"math"
+	"testing"
+
+	assert "github.com/stretchr/testify/require"
+	"go.skia.org/infra/go/tiling"
+	"go.skia.org/infra/perf/go/types"
+)
+
+func newTrace(values []float64) *types.PerfTrace {
+	tr := types.NewPerfTraceN(len(values))
+	copy(tr.Values, values)
+	return tr
+}
+
+func TestCompare(t *testing.T) {
+	master := tiling.NewTile()
+	master.Traces[",config=8888,"] = newTrace([]float64{1, 2, 3, 1e100, 4, 5})
+	master.Traces[",config=565,"] = newTrace([]float64{10, 10, 10})
+	master.Traces[",config=gpu,"] = newTrace([]float64{1e100, 1e100, 1e100})
+	master.Traces[",config=nvpr,"] = newTrace([]float64{1, 2, 3})
+
+	try := tiling.NewTile()
+	try.Traces[",config=8888,"] = newTrace([]float64{1e100, 6})
+	try.Traces[",config=565,"] = newTrace([]float64{10.5})
+	try.Traces[",config=gpu,"] = newTrace([]float64{1})
+	try.Traces[",config=nvpr,"] = newTrace([]float64{1e100})
+	try.Traces[",config=pdf,"] = newTrace([]float64{1})
+
+	deltas := Compare(try, master, 3, nil)
+	assert.Equal(t, 2, len(deltas))
+
+	assert.Equal(t, ",config=565,", deltas[0].Key)
+	assert.Equal(t, 10.5, deltas[0].Value)
+	assert.Equal(t, 10.0, deltas[0].Baseline)
+	assert.Equal(t, 0.5, deltas[0].Delta)
+	assert.Equal(t, 0.0, deltas[0].StdDev)
+	assert.InDelta(t, 500.0, deltas[0].Sigma, 0.01)
+
+	assert.Equal(t, ",config=8888,", deltas[1].Key)
+	assert.Equal(t, 6.0, deltas[1].Value)
+	assert.Equal(t, 4.0, deltas[1].Baseline)
+	assert.Equal(t, 2.0, deltas[1].Delta)
+	assert.InDelta(t, math.Sqrt(2.0/3.0), deltas[1].StdDev, 0.0001)
+	assert.InDelta(t, 2.0/math.Sqrt(2.0/3.0), deltas[1].Sigma, 0.0001)
+}
+
+func TestLastN(t *testing.T) {
+	assert.Equal(t, []float64{3, 4, 5}, lastN([]float64{1, 2, 3, 1e100, 4, 5}, 3))
+	assert.Equal(t, []float64{1, 2}, lastN([]float64{1, 1e100, 2}, 3))
+	assert.Equal(t, []float64{}, lastN([]float64{1e100}, 3))
+}
+
+func TestMedian(t *testing.T) {
+	assert.Equal(t, 2.0, median([]float64{3, 1, 2}))
+	assert.Equal(t, 2.5, median([]float64{4, 1, 2, 3}))
+	assert.Equal(t, 1.0, median([]float64{1}))
+}
+
+func TestSource(t *testing.T) {
+	assert.Equal(t, "https://codereview.chromium.org/1234", Source(1234))
+}
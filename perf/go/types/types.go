@@ -5,6 +5,7 @@ import (
 	"encoding/gob"
 	"fmt"
 	"math"
+	"strings"
 	"time"
 
 	"go.skia.org/infra/go/tiling"
@@ -159,6 +160,40 @@ func NewTryBotResults() *TryBotResults {
 	}
 }
 
+const (
+	// STAT_KEY is the param key that identifies which statistic of a set of
+	// benchmark samples a trace holds. Traces whose params only differ in
+	// STAT_KEY summarize the same samples, and are called siblings.
+	STAT_KEY = "stat"
+
+	// The statistics stored for a set of samples.
+	STAT_MIN    = "min"
+	STAT_MEDIAN = "median"
+	STAT_MAX    = "max"
+	STAT_STDDEV = "stddev"
+	STAT_COUNT  = "count"
+)
+
+// StatSiblingKey returns the key of the trace that holds the given sibling
+// statistic of the same samples as the trace with the given key and params,
+// or "" if the trace doesn't hold a statistic of samples.
+//
+// Both traceDB keys, which end in ":<stat>", and structured keys, which
+// contain ",stat=<stat>,", are supported.
+func StatSiblingKey(key string, params map[string]string, sibling string) string {
+	stat, ok := params[STAT_KEY]
+	if !ok {
+		return ""
+	}
+	if strings.HasPrefix(key, ",") {
+		return strings.Replace(key, ","+STAT_KEY+"="+stat+",", ","+STAT_KEY+"="+sibling+",", 1)
+	}
+	if !strings.HasSuffix(key, ":"+stat) {
+		return ""
+	}
+	return strings.TrimSuffix(key, stat) + sibling
+}
+
 // ValueWeight is a weight proportional to the number of times the parameter
 // Value appears in a cluster. Used in ClusterSummary.
 type ValueWeight struct {
@@ -227,6 +262,9 @@ type ClusterSummary struct {
 
 	// Bugs is a list of IDs of bugs in the issue tracker.
 	Bugs []int64
+
+	// AlertID is the ID of the alerts.Config that found this cluster.
+	AlertID int64
 }
 
 // ValidStatusValues are the valid values of ClusterSummary.Status when the
@@ -348,3 +348,37 @@ func TestSetAt(t *testing.T) {
 		}
 	}
 }
+
+func TestStatSiblingKey(t *testing.T) {
+	testCases := []struct {
+		key    string
+		params map[string]string
+		want   string
+	}{
+		{
+			key:    "x86:8888:desk:min_ms:median",
+			params: map[string]string{"config": "8888", STAT_KEY: STAT_MEDIAN},
+			want:   "x86:8888:desk:min_ms:stddev",
+		},
+		{
+			key:    ",config=8888,stat=median,test=desk,",
+			params: map[string]string{"config": "8888", STAT_KEY: STAT_MEDIAN, "test": "desk"},
+			want:   ",config=8888,stat=stddev,test=desk,",
+		},
+		{
+			key:    "x86:8888:desk",
+			params: map[string]string{"config": "8888"},
+			want:   "",
+		},
+		{
+			key:    "x86:8888:desk",
+			params: map[string]string{"config": "8888", STAT_KEY: STAT_MEDIAN},
+			want:   "",
+		},
+	}
+	for _, tc := range testCases {
+		if got, want := StatSiblingKey(tc.key, tc.params, STAT_STDDEV), tc.want; got != want {
+			t.Errorf("StatSiblingKey(%q) failed: Got %q Want %q", tc.key, got, want)
+		}
+	}
+}
@@ -4,6 +4,7 @@ package vec
 import (
 	"fmt"
 	"math"
+	"sort"
 
 	"go.skia.org/infra/perf/go/config"
 )
@@ -102,3 +103,80 @@ func FillAt(a []float64, i int) (float64, error) {
 	Fill(b)
 	return b[i], nil
 }
+
+// ScaleBy divides each non-sentinel value in the slice by 'b'.
+func ScaleBy(a []float64, b float64) {
+	for i, x := range a {
+		if x != config.MISSING_DATA_SENTINEL {
+			a[i] = x / b
+		}
+	}
+}
+
+// FillStep replaces the values in the slice with a step function that changes
+// value at index 'i'. All the values before 'i' are replaced with their mean,
+// as are all the values at and after 'i'. If 'i' is not an index into the
+// slice then all the values are replaced with the mean of the whole slice.
+//
+// The slice is expected to contain no MISSING_DATA_SENTINEL values, see Fill.
+func FillStep(a []float64, i int) {
+	if i <= 0 || i >= len(a) {
+		i = len(a)
+	}
+	fillMean(a[:i])
+	fillMean(a[i:])
+}
+
+// fillMean replaces every value in the slice with the mean of the slice.
+func fillMean(a []float64) {
+	if len(a) == 0 {
+		return
+	}
+	sum := 0.0
+	for _, x := range a {
+		sum += x
+	}
+	mean := sum / float64(len(a))
+	for i := range a {
+		a[i] = mean
+	}
+}
+
+// IQRR sets each value in the slice that is an outlier to
+// MISSING_DATA_SENTINEL. Outliers are values below Q1 - 1.5*IQR or above
+// Q3 + 1.5*IQR, where IQR is Q3 - Q1, the interquartile range. See
+// https://en.wikipedia.org/wiki/Interquartile_range
+//
+// MISSING_DATA_SENTINEL values are not used when calculating the quartiles.
+func IQRR(a []float64) {
+	sorted := []float64{}
+	for _, x := range a {
+		if x != config.MISSING_DATA_SENTINEL {
+			sorted = append(sorted, x)
+		}
+	}
+	n := len(sorted)
+	if n < 4 {
+		return
+	}
+	sort.Float64s(sorted)
+	q1 := median(sorted[:n/2])
+	q3 := median(sorted[(n+1)/2:])
+	iqr := q3 - q1
+	low := q1 - 1.5*iqr
+	high := q3 + 1.5*iqr
+	for i, x := range a {
+		if x != config.MISSING_DATA_SENTINEL && (x < low || x > high) {
+			a[i] = config.MISSING_DATA_SENTINEL
+		}
+	}
+}
+
+// median returns the median of an already sorted non-empty slice.
+func median(sorted []float64) float64 {
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
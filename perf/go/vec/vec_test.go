@@ -119,3 +119,76 @@ func TestFillAtErrors(t *testing.T) {
 		}
 	}
 }
+
+func TestScaleBy(t *testing.T) {
+	a := []float64{2.0, 1e100, -4.0, 0.0}
+	ScaleBy(a, 2.0)
+	if got, want := a, []float64{1.0, 1e100, -2.0, 0.0}; !vecNear(got, want) {
+		t.Errorf("ScaleBy: Got %#v Want %#v", got, want)
+	}
+}
+
+func TestFillStep(t *testing.T) {
+	testCases := []struct {
+		In  []float64
+		I   int
+		Out []float64
+	}{
+		{
+			In:  []float64{1.0, 2.0, 3.0, 5.0, 7.0},
+			I:   2,
+			Out: []float64{1.5, 1.5, 5.0, 5.0, 5.0},
+		},
+		{
+			In:  []float64{1.0, 2.0, 3.0},
+			I:   0,
+			Out: []float64{2.0, 2.0, 2.0},
+		},
+		{
+			In:  []float64{1.0, 2.0, 3.0},
+			I:   5,
+			Out: []float64{2.0, 2.0, 2.0},
+		},
+		{
+			In:  []float64{},
+			I:   0,
+			Out: []float64{},
+		},
+	}
+	for _, tc := range testCases {
+		FillStep(tc.In, tc.I)
+		if got, want := tc.In, tc.Out; !vecNear(got, want) {
+			t.Errorf("FillStep: Got %#v Want %#v", got, want)
+		}
+	}
+}
+
+func TestIQRR(t *testing.T) {
+	testCases := []struct {
+		In  []float64
+		Out []float64
+	}{
+		{
+			In:  []float64{1, 2, 3, 4, 5, 6, 7, 8, 100},
+			Out: []float64{1, 2, 3, 4, 5, 6, 7, 8, 1e100},
+		},
+		{
+			In:  []float64{-100, 1, 2, 1e100, 3, 4, 5, 6, 7, 8},
+			Out: []float64{1e100, 1, 2, 1e100, 3, 4, 5, 6, 7, 8},
+		},
+		{
+			In:  []float64{1, 100, 1e100},
+			Out: []float64{1, 100, 1e100},
+		},
+		{
+			In:  []float64{},
+			Out: []float64{},
+		},
+	}
+	for _, tc := range testCases {
+		IQRR(tc.In)
+		if got, want := tc.In, tc.Out; !vecNear(got, want) {
+			t.Errorf("IQRR: Got %#v Want %#v", got, want)
+		}
+	}
+}